@@ -0,0 +1,47 @@
+package header2post
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// fieldAdder merges AddFields into the top level of JSON object payloads.
+// Values are Go templates over the same data as PayloadTemplate.
+type fieldAdder struct {
+	fields map[string]*template.Template
+}
+
+// newFieldAdder returns nil when AddFields is empty.
+func newFieldAdder(m map[string]string) (*fieldAdder, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	f := &fieldAdder{fields: make(map[string]*template.Template, len(m))}
+	for k, v := range m {
+		if strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("addfields names cannot be empty")
+		}
+		tmpl, err := template.New(k).Funcs(templateFuncs).Option("missingkey=zero").Parse(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid addfields value for %q: %w", k, err)
+		}
+		f.fields[k] = tmpl
+	}
+	return f, nil
+}
+
+// apply renders every field and sets it on data.
+func (f *fieldAdder) apply(data []byte, td *templateData) ([]byte, error) {
+	values := make(map[string]any, len(f.fields))
+	for k, tmpl := range f.fields {
+		buf := getBuffer()
+		err := tmpl.Execute(buf, td)
+		values[k] = buf.String()
+		putBuffer(buf)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return setFields(data, values), nil
+}
@@ -0,0 +1,60 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPAddFields(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		expectBody string
+	}{
+		{name: "object", payload: `{"id":1,"environment":"dev"}`, expectBody: `{"environment":"prod","gateway":"example.com","id":1,"route":"/orders POST"}`},
+		{name: "not object", payload: `[1]`, expectBody: `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.payload)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader: "X-Notify",
+				NotifyUrl:    "https://example.com/notification",
+				AddFields: map[string]string{
+					"environment": "prod",
+					"gateway":     "{{.Request.Host}}",
+					"route":       "{{.Request.Path}} {{.Request.Method}}",
+				},
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", nil))
+			if body != tt.expectBody {
+				t.Errorf("expected body %s, got %s", tt.expectBody, body)
+			}
+		})
+	}
+}
+
+func TestNewFieldAdderErrors(t *testing.T) {
+	if _, err := newFieldAdder(map[string]string{" ": "x"}); err == nil || err.Error() != "addfields names cannot be empty" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := newFieldAdder(map[string]string{"host": "{{.Request.Host"}); err == nil {
+		t.Errorf("expected template parse error")
+	}
+}
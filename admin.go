@@ -0,0 +1,185 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultAdminRecentErrors = 20
+
+// adminState keeps the last failed deliveries and the drop counts of a
+// middleware for its admin endpoint.
+type adminState struct {
+	mu     sync.Mutex
+	size   int
+	errors []adminError
+	next   int
+	drops  map[string]int64
+}
+
+// adminError is a failed delivery as listed by the admin endpoint.
+type adminError struct {
+	Time    time.Time `json:"time"`
+	Target  string    `json:"target"`
+	Status  int       `json:"status,omitempty"`
+	Retries int       `json:"retries,omitempty"`
+	Error   string    `json:"error"`
+}
+
+// newAdminState validates AdminPath and AdminRecentErrors. The state is
+// kept even without AdminPath so Notifier.AdminHandler can serve it.
+func newAdminState(config *Config) (*adminState, error) {
+	if config.AdminPath != "" && !strings.HasPrefix(config.AdminPath, "/") {
+		return nil, fmt.Errorf("invalid adminpath: %q", config.AdminPath)
+	}
+	if config.AdminPath != "" && config.AdminPath == config.MetricsPath {
+		return nil, fmt.Errorf("adminpath cannot be the same as metricspath")
+	}
+	if config.AdminRecentErrors < 0 {
+		return nil, fmt.Errorf("adminrecenterrors cannot be negative")
+	}
+	size := config.AdminRecentErrors
+	if size == 0 {
+		size = defaultAdminRecentErrors
+	}
+	return &adminState{size: size, drops: map[string]int64{}}, nil
+}
+
+func (s *adminState) delivery(_ string, r deliveryResult) {
+	if r.Success {
+		return
+	}
+	e := adminError{Time: timeNow().UTC(), Target: r.Target, Status: r.Status, Retries: r.Retries, Error: r.Error}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.errors) < s.size {
+		s.errors = append(s.errors, e)
+		return
+	}
+	s.errors[s.next] = e
+	s.next = (s.next + 1) % s.size
+}
+
+func (s *adminState) dropped(_, reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops[reason]++
+}
+
+// recent returns the recorded failures, newest first.
+func (s *adminState) recent() []adminError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]adminError, 0, len(s.errors))
+	for i := len(s.errors) - 1; i >= 0; i-- {
+		out = append(out, s.errors[(s.next+i)%len(s.errors)])
+	}
+	return out
+}
+
+func (s *adminState) dropCounts() map[string]int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]int64, len(s.drops))
+	for reason, n := range s.drops {
+		out[reason] = n
+	}
+	return out
+}
+
+// adminSnapshot is the JSON document served on AdminPath.
+type adminSnapshot struct {
+	Middleware   string                  `json:"middleware"`
+	Version      string                  `json:"version"`
+	QueueDepth   int                     `json:"queue_depth"`
+	SpoolPending *int                    `json:"spool_pending,omitempty"`
+	Breakers     []adminBreaker          `json:"breakers"`
+	Limiters     map[string]adminLimiter `json:"limiters,omitempty"`
+	Dropped      map[string]int64        `json:"dropped"`
+	RecentErrors []adminError            `json:"recent_errors"`
+}
+
+// adminBreaker is the state of a target guarded by the health probe:
+// "closed" lets deliveries through, "open" short-circuits them until the
+// probe sees the endpoint recover.
+type adminBreaker struct {
+	Target string `json:"target"`
+	State  string `json:"state"`
+}
+
+type adminLimiter struct {
+	InFlight int `json:"in_flight"`
+	Waiting  int `json:"waiting"`
+}
+
+// snapshot collects the current delivery state of the middleware.
+func (a *notify) snapshot() adminSnapshot {
+	snap := adminSnapshot{
+		Middleware:   a.name,
+		Version:      GetBuildInfo().String(),
+		QueueDepth:   a.queueDepth(),
+		Breakers:     []adminBreaker{},
+		Dropped:      a.admin.dropCounts(),
+		RecentErrors: a.admin.recent(),
+	}
+	if a.spool != nil {
+		pending := a.spool.depth()
+		snap.SpoolPending = &pending
+	}
+	if a.health != nil {
+		state := "closed"
+		if !a.health.healthy() {
+			state = "open"
+		}
+		snap.Breakers = append(snap.Breakers, adminBreaker{Target: a.notifyUrl, State: state})
+	}
+	if len(a.limiters) > 0 {
+		snap.Limiters = make(map[string]adminLimiter, len(a.limiters))
+		for target, l := range a.limiters {
+			snap.Limiters[target] = adminLimiter{InFlight: len(l.slots), Waiting: l.depth()}
+		}
+	}
+	return snap
+}
+
+// serveAdmin answers a loopback client on AdminPath with the delivery
+// state snapshot. Other clients get a 404 so the endpoint stays hidden
+// from the outside.
+func (a *notify) serveAdmin(rw http.ResponseWriter, req *http.Request) {
+	if !loopback(req.RemoteAddr) {
+		http.NotFound(rw, req)
+		return
+	}
+	a.writeAdmin(rw, req)
+}
+
+// writeAdmin writes the delivery state snapshot as JSON.
+func (a *notify) writeAdmin(rw http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		rw.Header().Set("Allow", "GET, HEAD")
+		http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(a.snapshot()); err != nil {
+		a.log.Debug("admin write error", "error", err)
+	}
+}
+
+// loopback reports whether addr, a host:port or bare host, is a loopback
+// address.
+func loopback(addr string) bool {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,148 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminStateRecent(t *testing.T) {
+	s, err := newAdminState(&Config{AdminRecentErrors: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.delivery("mw", deliveryResult{Target: "http://a", Success: true})
+	for _, e := range []string{"first", "second", "third"} {
+		s.delivery("mw", deliveryResult{Target: "http://a", Error: e})
+	}
+	s.dropped("mw", dropDuplicate)
+	s.dropped("mw", dropDuplicate)
+
+	recent := s.recent()
+	if len(recent) != 2 || recent[0].Error != "third" || recent[1].Error != "second" {
+		t.Errorf("unexpected recent errors %+v", recent)
+	}
+	if drops := s.dropCounts(); drops[dropDuplicate] != 2 {
+		t.Errorf("unexpected drops %v", drops)
+	}
+}
+
+func TestServeHTTPAdmin(t *testing.T) {
+	captureLog(t)
+	defer func(f func() time.Time) { timeNow = f }(timeNow)
+	timeNow = func() time.Time { return time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	upstream := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream++
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:            "X-Notify",
+		NotifyUrl:               "https://example.com/notification",
+		HealthCheckInterval:     "1h",
+		MaxConcurrentDeliveries: 2,
+		AdminPath:               "/_header2post/admin",
+	}, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+	handler.(*notify).health.down.Store(true)
+
+	tests := []struct {
+		name       string
+		method     string
+		remoteAddr string
+		expect     int
+	}{
+		{name: "loopback", method: http.MethodGet, remoteAddr: "127.0.0.1:51000", expect: http.StatusOK},
+		{name: "ipv6 loopback", method: http.MethodGet, remoteAddr: "[::1]:51000", expect: http.StatusOK},
+		{name: "remote", method: http.MethodGet, remoteAddr: "192.0.2.1:51000", expect: http.StatusNotFound},
+		{name: "post", method: http.MethodPost, remoteAddr: "127.0.0.1:51000", expect: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/_header2post/admin", nil)
+			req.RemoteAddr = tt.remoteAddr
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expect {
+				t.Fatalf("expected status %d, got %d", tt.expect, rec.Code)
+			}
+			if tt.expect != http.StatusOK {
+				return
+			}
+			var snap adminSnapshot
+			if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+				t.Fatal(err)
+			}
+			if snap.Middleware != "orders" || snap.QueueDepth != 0 {
+				t.Errorf("unexpected snapshot %+v", snap)
+			}
+			if len(snap.Breakers) != 1 || snap.Breakers[0] != (adminBreaker{Target: "https://example.com/notification", State: "open"}) {
+				t.Errorf("unexpected breakers %+v", snap.Breakers)
+			}
+			if l, ok := snap.Limiters["https://example.com/notification"]; !ok || l.InFlight != 0 {
+				t.Errorf("unexpected limiters %+v", snap.Limiters)
+			}
+			if snap.SpoolPending != nil {
+				t.Errorf("unexpected spool pending %d", *snap.SpoolPending)
+			}
+			if len(snap.RecentErrors) != 1 || snap.RecentErrors[0].Status != http.StatusBadGateway || !snap.RecentErrors[0].Time.Equal(timeNow()) {
+				t.Errorf("unexpected recent errors %+v", snap.RecentErrors)
+			}
+		})
+	}
+	if upstream != 1 {
+		t.Errorf("admin request reached upstream")
+	}
+}
+
+func TestNotifierAdminHandler(t *testing.T) {
+	captureLog(t)
+	nt, err := NewNotifier(context.Background(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/debug/notify", nil)
+	rec := httptest.NewRecorder()
+	nt.AdminHandler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var snap adminSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+		t.Fatal(err)
+	}
+	if snap.Middleware != "header2post" || len(snap.Breakers) != 0 || len(snap.RecentErrors) != 0 {
+		t.Errorf("unexpected snapshot %+v", snap)
+	}
+}
+
+func TestNewAdminStateErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "relative path", config: Config{AdminPath: "admin"}, expectErr: `invalid adminpath: "admin"`},
+		{name: "metrics path", config: Config{AdminPath: "/metrics", MetricsPath: "/metrics"}, expectErr: "adminpath cannot be the same as metricspath"},
+		{name: "negative size", config: Config{AdminPath: "/admin", AdminRecentErrors: -1}, expectErr: "adminrecenterrors cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAdminState(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
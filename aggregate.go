@@ -0,0 +1,84 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// aggregateField is one header merged into an aggregated payload.
+type aggregateField struct {
+	field  string
+	header string
+	// raw parts are set as strings rather than decoded.
+	raw bool
+}
+
+func newAggregateFields(config *Config) ([]aggregateField, error) {
+	if len(config.AggregateHeaders) == 0 {
+		return nil, nil
+	}
+	if config.NotifyHeader != "" {
+		return nil, fmt.Errorf("aggregateheaders cannot be combined with notifyheader")
+	}
+	raw := make(map[string]bool, len(config.AggregateRawFields))
+	for _, field := range config.AggregateRawFields {
+		if _, ok := config.AggregateHeaders[field]; !ok {
+			return nil, fmt.Errorf("invalid aggregaterawfields: %q", field)
+		}
+		raw[field] = true
+	}
+	fields := make([]aggregateField, 0, len(config.AggregateHeaders))
+	for field, header := range config.AggregateHeaders {
+		r := raw[field]
+		field, header = strings.TrimSpace(field), strings.TrimSpace(header)
+		if field == "" || header == "" {
+			return nil, fmt.Errorf("aggregateheaders names cannot be empty")
+		}
+		fields = append(fields, aggregateField{field: field, header: http.CanonicalHeaderKey(header), raw: r})
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].field < fields[j].field })
+	return fields, nil
+}
+
+// aggregateValue collects the aggregated headers present in h, or
+// returns false when there is none.
+func (a *notify) aggregateValue(h http.Header) (notifyValue, bool) {
+	var parts []aggregatePart
+	for _, f := range a.aggregate {
+		if value := h.Get(f.header); value != "" {
+			parts = append(parts, aggregatePart{aggregateField: f, value: value})
+		}
+	}
+	return notifyValue{parts: parts}, parts != nil
+}
+
+// aggregatePart is the value of one aggregated header.
+type aggregatePart struct {
+	aggregateField
+	value string
+}
+
+// decodeAggregate decodes the parts with the header codec and merges them
+// into one JSON object, keeping raw parts and parts that are not JSON as
+// strings.
+func (a *notify) decodeAggregate(parts []aggregatePart) ([]byte, error) {
+	obj := make(map[string]json.RawMessage, len(parts))
+	for _, p := range parts {
+		if p.raw {
+			obj[p.field], _ = json.Marshal(p.value)
+			continue
+		}
+		data, err := a.decoder.Decode(p.value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.field, err)
+		}
+		if !json.Valid(data) {
+			data, _ = json.Marshal(string(data))
+		}
+		obj[p.field] = data
+	}
+	return json.Marshal(obj)
+}
@@ -0,0 +1,92 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPAggregateHeaders(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		expectBody string
+	}{
+		{
+			name:       "all present",
+			headers:    map[string]string{"X-Event-Type": "order.created", "X-Event-Body": encode(`{"total":3}`), "X-Event-Id": encode("42"), "X-Event-Note": encode("rush")},
+			expectBody: `{"body":{"total":3},"id":42,"note":"rush","type":"order.created"}`,
+		},
+		{
+			name:       "some present",
+			headers:    map[string]string{"X-Event-Type": "ping"},
+			expectBody: `{"type":"ping"}`,
+		},
+		{name: "none present"},
+		{
+			name:    "decode error",
+			headers: map[string]string{"X-Event-Type": "ping", "X-Event-Body": "%%%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyUrl: "https://example.com/notification",
+				AggregateHeaders: map[string]string{
+					"type": "X-Event-Type",
+					"body": "X-Event-Body",
+					"id":   "x-event-id",
+					"note": "X-Event-Note",
+				},
+				AggregateRawFields: []string{"type"},
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if body != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, body)
+			}
+			for k := range tt.headers {
+				if rec.Header().Get(k) != "" {
+					t.Errorf("header %s not removed", k)
+				}
+			}
+		})
+	}
+}
+
+func TestNewAggregateFieldsErrors(t *testing.T) {
+	tests := []struct {
+		config Config
+		expect string
+	}{
+		{config: Config{NotifyHeader: "X-Notify", AggregateHeaders: map[string]string{"a": "X-A"}}, expect: "aggregateheaders cannot be combined with notifyheader"},
+		{config: Config{AggregateHeaders: map[string]string{"a": " "}}, expect: "aggregateheaders names cannot be empty"},
+		{config: Config{AggregateHeaders: map[string]string{"a": "X-A"}, AggregateRawFields: []string{"b"}}, expect: `invalid aggregaterawfields: "b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expect, func(t *testing.T) {
+			if _, err := newAggregateFields(&tt.config); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
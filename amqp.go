@@ -0,0 +1,374 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// amqpSink publishes payloads to a RabbitMQ (AMQP 0.9.1) exchange. Each
+// send opens a connection, enables publisher confirms on a channel and
+// waits for the broker's ack.
+type amqpSink struct {
+	url          *url.URL
+	vhost        string
+	exchange     string
+	routingKey   *keyTemplate
+	deliveryMode byte
+}
+
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xCE
+
+	amqpTransient  = 1
+	amqpPersistent = 2
+)
+
+func newAmqpSink(config *Config) (*amqpSink, error) {
+	if config.AmqpUrl == "" {
+		return nil, fmt.Errorf("amqpurl cannot be empty")
+	}
+	u, err := url.Parse(config.AmqpUrl)
+	if err != nil || u.Host == "" || (u.Scheme != "amqp" && u.Scheme != "amqps") {
+		return nil, fmt.Errorf("invalid amqpurl: %q", config.AmqpUrl)
+	}
+	routingKey, err := newKeyTemplate("amqproutingkey", config.AmqpRoutingKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &amqpSink{url: u, vhost: "/", exchange: config.AmqpExchange, routingKey: routingKey, deliveryMode: amqpPersistent}
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		s.vhost, _ = url.PathUnescape(p)
+	}
+	switch config.AmqpDeliveryMode {
+	case "", "persistent":
+	case "transient":
+		s.deliveryMode = amqpTransient
+	default:
+		return nil, fmt.Errorf("invalid amqpdeliverymode: %q", config.AmqpDeliveryMode)
+	}
+	return s, nil
+}
+
+func (s *amqpSink) Target() string {
+	return s.url.Scheme + "://" + s.url.Host + "/" + s.exchange
+}
+
+func (s *amqpSink) Send(ctx context.Context, n Notification) error {
+	routingKey, err := s.routingKey.render(n.Payload)
+	if err != nil {
+		return fmt.Errorf("render routing key: %w", err)
+	}
+	c, err := s.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+
+	// confirm.select
+	var m amqpEncoder
+	m.method(85, 10)
+	m.octet(0)
+	if err := c.call(1, m.buf, 85, 11); err != nil {
+		return err
+	}
+
+	// basic.publish, content header and body frames
+	m = amqpEncoder{}
+	m.method(60, 40)
+	m.short(0)
+	m.shortstr(s.exchange)
+	m.shortstr(routingKey)
+	m.octet(0)
+	if err := c.writeFrame(amqpFrameMethod, 1, m.buf); err != nil {
+		return err
+	}
+	var h amqpEncoder
+	h.short(60)
+	h.short(0)
+	h.longlong(uint64(len(n.Body)))
+	flags := uint16(1<<12 | 1<<15)
+	if len(n.Header) > 0 {
+		flags |= 1 << 13
+	}
+	h.short(flags)
+	h.shortstr(n.ContentType)
+	if len(n.Header) > 0 {
+		table := make(map[string]string, len(n.Header))
+		for k := range n.Header {
+			table[k] = n.Header.Get(k)
+		}
+		h.table(table)
+	}
+	h.octet(s.deliveryMode)
+	if err := c.writeFrame(amqpFrameHeader, 1, h.buf); err != nil {
+		return err
+	}
+	for body := n.Body; len(body) > 0; {
+		n := min(len(body), int(c.frameMax)-8)
+		if err := c.writeFrame(amqpFrameBody, 1, body[:n]); err != nil {
+			return err
+		}
+		body = body[n:]
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	class, method, _, err := c.readMethod(1)
+	if err != nil {
+		return err
+	}
+	switch {
+	case class == 60 && method == 80:
+		return nil
+	case class == 60 && method == 120:
+		return errors.New("message nacked by broker")
+	}
+	return fmt.Errorf("unexpected method %d.%d", class, method)
+}
+
+// amqpConn is a single-use connection with channel 1 open.
+type amqpConn struct {
+	conn     net.Conn
+	r        *bufio.Reader
+	w        *bufio.Writer
+	frameMax uint32
+}
+
+func (s *amqpSink) open(ctx context.Context) (*amqpConn, error) {
+	var dialer net.Dialer
+	addr := s.url.Host
+	if s.url.Port() == "" {
+		port := "5672"
+		if s.url.Scheme == "amqps" {
+			port = "5671"
+		}
+		addr = net.JoinHostPort(s.url.Hostname(), port)
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.url.Scheme == "amqps" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: s.url.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	c := &amqpConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn), frameMax: 131072}
+	if err := c.handshake(s); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *amqpConn) handshake(s *amqpSink) error {
+	c.w.WriteString("AMQP\x00\x00\x09\x01")
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	if _, _, _, err := c.expect(0, 10, 10); err != nil {
+		return err
+	}
+
+	user, pass := "guest", "guest"
+	if s.url.User != nil {
+		user = s.url.User.Username()
+		pass, _ = s.url.User.Password()
+	}
+	var m amqpEncoder
+	m.method(10, 11)
+	m.table(map[string]string{"product": clientName, "version": Version})
+	m.shortstr("PLAIN")
+	m.longstr("\x00" + user + "\x00" + pass)
+	m.shortstr("en_US")
+	if err := c.writeFrame(amqpFrameMethod, 0, m.buf); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	_, _, args, err := c.expect(0, 10, 30)
+	if err != nil {
+		return err
+	}
+	if len(args) >= 6 {
+		if fm := binary.BigEndian.Uint32(args[2:6]); fm != 0 && fm < c.frameMax {
+			c.frameMax = fm
+		}
+	}
+	m = amqpEncoder{}
+	m.method(10, 31)
+	m.short(1)
+	m.long(c.frameMax)
+	m.short(0)
+	if err := c.writeFrame(amqpFrameMethod, 0, m.buf); err != nil {
+		return err
+	}
+
+	m = amqpEncoder{}
+	m.method(10, 40)
+	m.shortstr(s.vhost)
+	m.shortstr("")
+	m.octet(0)
+	if err := c.call(0, m.buf, 10, 41); err != nil {
+		return err
+	}
+
+	m = amqpEncoder{}
+	m.method(20, 10)
+	m.shortstr("")
+	return c.call(1, m.buf, 20, 11)
+}
+
+// call writes a method frame and waits for the expected reply.
+func (c *amqpConn) call(channel uint16, method []byte, class, id uint16) error {
+	if err := c.writeFrame(amqpFrameMethod, channel, method); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	_, _, _, err := c.expect(channel, class, id)
+	return err
+}
+
+func (c *amqpConn) expect(channel, class, id uint16) (uint16, uint16, []byte, error) {
+	gotClass, gotID, args, err := c.readMethod(channel)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if gotClass != class || gotID != id {
+		return 0, 0, nil, fmt.Errorf("unexpected method %d.%d, want %d.%d", gotClass, gotID, class, id)
+	}
+	return gotClass, gotID, args, nil
+}
+
+// readMethod returns the next method frame, translating connection and
+// channel close methods into errors.
+func (c *amqpConn) readMethod(channel uint16) (uint16, uint16, []byte, error) {
+	for {
+		typ, _, payload, err := c.readFrame()
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		if typ == amqpFrameHeartbeat {
+			continue
+		}
+		if typ != amqpFrameMethod || len(payload) < 4 {
+			return 0, 0, nil, fmt.Errorf("unexpected frame type %d", typ)
+		}
+		class := binary.BigEndian.Uint16(payload)
+		id := binary.BigEndian.Uint16(payload[2:])
+		args := payload[4:]
+		if (class == 10 || class == 20) && id == 40 && len(args) >= 3 {
+			code := binary.BigEndian.Uint16(args)
+			text := args[3:]
+			if n := int(args[2]); n <= len(text) {
+				text = text[:n]
+			}
+			return 0, 0, nil, fmt.Errorf("amqp %d: %s", code, text)
+		}
+		return class, id, args, nil
+	}
+}
+
+func (c *amqpConn) readFrame() (byte, uint16, []byte, error) {
+	var hdr [7]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(hdr[3:])
+	if size > c.frameMax {
+		return 0, 0, nil, fmt.Errorf("frame too large: %d", size)
+	}
+	payload := make([]byte, size+1)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	if payload[size] != amqpFrameEnd {
+		return 0, 0, nil, errors.New("malformed frame")
+	}
+	return hdr[0], binary.BigEndian.Uint16(hdr[1:]), payload[:size], nil
+}
+
+func (c *amqpConn) writeFrame(typ byte, channel uint16, payload []byte) error {
+	var hdr [7]byte
+	hdr[0] = typ
+	binary.BigEndian.PutUint16(hdr[1:], channel)
+	binary.BigEndian.PutUint32(hdr[3:], uint32(len(payload)))
+	c.w.Write(hdr[:])
+	c.w.Write(payload)
+	return c.w.WriteByte(amqpFrameEnd)
+}
+
+// close sends connection.close without waiting for close-ok.
+func (c *amqpConn) close() {
+	var m amqpEncoder
+	m.method(10, 50)
+	m.short(200)
+	m.shortstr("bye")
+	m.short(0)
+	m.short(0)
+	c.writeFrame(amqpFrameMethod, 0, m.buf)
+	c.w.Flush()
+	c.conn.Close()
+}
+
+type amqpEncoder struct {
+	buf []byte
+}
+
+func (e *amqpEncoder) method(class, id uint16) {
+	e.short(class)
+	e.short(id)
+}
+
+func (e *amqpEncoder) octet(v byte)      { e.buf = append(e.buf, v) }
+func (e *amqpEncoder) short(v uint16)    { e.buf = binary.BigEndian.AppendUint16(e.buf, v) }
+func (e *amqpEncoder) long(v uint32)     { e.buf = binary.BigEndian.AppendUint32(e.buf, v) }
+func (e *amqpEncoder) longlong(v uint64) { e.buf = binary.BigEndian.AppendUint64(e.buf, v) }
+
+func (e *amqpEncoder) shortstr(s string) {
+	if len(s) > 255 {
+		s = s[:255]
+	}
+	e.octet(byte(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *amqpEncoder) longstr(s string) {
+	e.long(uint32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// table encodes a field table of long string values.
+func (e *amqpEncoder) table(t map[string]string) {
+	var inner amqpEncoder
+	for k, v := range t {
+		inner.shortstr(k)
+		inner.octet('S')
+		inner.longstr(v)
+	}
+	e.long(uint32(len(inner.buf)))
+	e.buf = append(e.buf, inner.buf...)
+}
@@ -0,0 +1,140 @@
+package header2post
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeAmqpBroker performs the broker side of the handshake on a single
+// connection, then answers the publish with ack (60.80) or nack (60.120).
+func fakeAmqpBroker(t *testing.T, ack bool) (string, chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	published := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c := &amqpConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn), frameMax: 131072}
+		proto := make([]byte, 8)
+		io.ReadFull(c.r, proto)
+
+		reply := func(channel, class, id uint16, args ...byte) {
+			var m amqpEncoder
+			m.method(class, id)
+			m.buf = append(m.buf, args...)
+			c.writeFrame(amqpFrameMethod, channel, m.buf)
+			c.w.Flush()
+		}
+		reply(0, 10, 10, 0, 9, 0, 0, 0, 0, 0, 0, 0, 0)
+		c.readFrame() // start-ok
+		var tune amqpEncoder
+		tune.short(0)
+		tune.long(4096)
+		tune.short(0)
+		reply(0, 10, 30, tune.buf...)
+		c.readFrame() // tune-ok
+		c.readFrame() // open
+		reply(0, 10, 41, 0)
+		c.readFrame() // channel.open
+		reply(1, 20, 11, 0, 0, 0, 0)
+		c.readFrame() // confirm.select
+		reply(1, 85, 11)
+		c.readFrame() // basic.publish
+		_, _, header, _ := c.readFrame()
+		size := binary.BigEndian.Uint64(header[4:])
+		var body []byte
+		for uint64(len(body)) < size {
+			_, _, chunk, err := c.readFrame()
+			if err != nil {
+				return
+			}
+			body = append(body, chunk...)
+		}
+		published <- append(header, body...)
+		if ack {
+			reply(1, 60, 80, 0, 0, 0, 0, 0, 0, 0, 1, 0)
+		} else {
+			reply(1, 60, 120, 0, 0, 0, 0, 0, 0, 0, 1, 0)
+		}
+	}()
+	return "amqp://guest:guest@" + ln.Addr().String() + "/", published
+}
+
+func TestAmqpSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		ack       bool
+		expectErr string
+	}{
+		{name: "ack", ack: true},
+		{name: "nack", ack: false, expectErr: "message nacked by broker"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, published := fakeAmqpBroker(t, tt.ack)
+			s, err := newAmqpSink(&Config{AmqpUrl: addr, AmqpExchange: "events", AmqpRoutingKey: "order.{{.Payload.type}}"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			data := bytes.Repeat([]byte("x"), 5000)
+			err = s.Send(ctx, Notification{Body: data, ContentType: "application/json", Payload: []byte(`{"type":"created"}`)})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			msg := <-published
+			if !bytes.Contains(msg, []byte("application/json")) || !bytes.HasSuffix(msg, data) {
+				t.Errorf("unexpected published message")
+			}
+		})
+	}
+}
+
+func TestNewAmqpSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+		vhost     string
+	}{
+		{name: "missing url", expectErr: "amqpurl cannot be empty"},
+		{name: "invalid scheme", config: Config{AmqpUrl: "http://localhost"}, expectErr: `invalid amqpurl: "http://localhost"`},
+		{name: "invalid delivery mode", config: Config{AmqpUrl: "amqp://localhost", AmqpDeliveryMode: "forever"}, expectErr: `invalid amqpdeliverymode: "forever"`},
+		{name: "default vhost", config: Config{AmqpUrl: "amqp://localhost"}, vhost: "/"},
+		{name: "custom vhost", config: Config{AmqpUrl: "amqp://localhost/prod%2Fevents"}, vhost: "prod/events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newAmqpSink(&tt.config)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Errorf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.vhost != tt.vhost {
+				t.Errorf("expected vhost %q, got %q", tt.vhost, s.vhost)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultApiKeyHeader         = "X-Api-Key"
+	defaultApiKeyReloadInterval = 10 * time.Second
+)
+
+// apiKeySource sends the API key read from ApiKeyFile. The file is read
+// again when its modification time changes, checked at most every
+// interval; until a rotated key reads back non-empty the previous key is
+// kept.
+type apiKeySource struct {
+	mu       sync.Mutex
+	path     string
+	header   string
+	prefix   string
+	interval time.Duration
+	key      string
+	modTime  time.Time
+	checked  time.Time
+	// tenants holds the keys of TenantApiKeys, sent instead of the file
+	// key.
+	tenants map[string]string
+}
+
+// newApiKeySource returns nil when no api key file or tenant api key is
+// configured.
+func newApiKeySource(config *Config) (*apiKeySource, error) {
+	if config.ApiKeyFile == "" && len(config.TenantApiKeys) == 0 {
+		return nil, nil
+	}
+	interval, err := parseDuration("apikeyreloadinterval", config.ApiKeyReloadInterval, defaultApiKeyReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	s := &apiKeySource{
+		path:     config.ApiKeyFile,
+		header:   http.CanonicalHeaderKey(strings.TrimSpace(config.ApiKeyHeader)),
+		prefix:   config.ApiKeyPrefix,
+		interval: interval,
+		tenants:  config.TenantApiKeys,
+	}
+	if s.header == "" {
+		s.header = defaultApiKeyHeader
+	}
+	if s.path == "" {
+		return s, nil
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("read apikeyfile: %w", err)
+	}
+	if err := s.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	if s.key == "" {
+		return nil, fmt.Errorf("apikeyfile is empty")
+	}
+	s.checked = timeNow()
+	return s, nil
+}
+
+// authorize sets the api key of the tenant of req, or else of the file,
+// on req.
+func (s *apiKeySource) authorize(ctx context.Context, req *http.Request) error {
+	key, ok := s.tenants[tenantOf(ctx)]
+	if !ok {
+		if s.path == "" {
+			return nil
+		}
+		key = s.current()
+	}
+	req.Header.Set(s.header, s.prefix+key)
+	return nil
+}
+
+// current returns the api key, reading the file again if it changed.
+func (s *apiKeySource) current() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := timeNow()
+	if now.Sub(s.checked) < s.interval {
+		return s.key
+	}
+	s.checked = now
+	if info, err := os.Stat(s.path); err == nil && !info.ModTime().Equal(s.modTime) {
+		s.load(info.ModTime())
+	}
+	return s.key
+}
+
+// load reads the key modified at modTime, keeping the previous key when
+// the file cannot be read or is empty.
+func (s *apiKeySource) load(modTime time.Time) error {
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("read apikeyfile: %w", err)
+	}
+	if key := strings.TrimSpace(string(b)); key != "" {
+		s.key = key
+		s.modTime = modTime
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package header2post
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestApiKeySourceConfig(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty")
+	os.WriteFile(empty, []byte("\n"), 0o600)
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{ApiKeyFile: filepath.Join(dir, "missing")}, expectErr: "read apikeyfile: stat " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		{config: Config{ApiKeyFile: empty}, expectErr: "apikeyfile is empty"},
+		{config: Config{ApiKeyFile: empty, ApiKeyReloadInterval: "soon"}, expectErr: `invalid apikeyreloadinterval: "soon"`},
+	}
+	for _, tt := range tests {
+		_, err := newApiKeySource(&tt.config)
+		if err == nil || err.Error() != tt.expectErr {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
+
+func TestApiKeySourceRotation(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	path := filepath.Join(t.TempDir(), "apikey")
+	write := func(key string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(key), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mod, mod)
+	}
+	write("key-1\n", now)
+	s, err := newApiKeySource(&Config{ApiKeyFile: path, ApiKeyHeader: "authorization", ApiKeyPrefix: "Bearer "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(expect string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, "https://example.com", nil)
+		s.authorize(context.Background(), req)
+		if got := req.Header.Get("Authorization"); got != expect {
+			t.Errorf("expected %q, got %q", expect, got)
+		}
+	}
+	check("Bearer key-1")
+
+	write("key-2", now.Add(time.Second))
+	check("Bearer key-1")
+	now = now.Add(defaultApiKeyReloadInterval)
+	check("Bearer key-2")
+
+	// a rotation caught half way keeps the previous key
+	write("", now.Add(2*time.Second))
+	now = now.Add(defaultApiKeyReloadInterval)
+	check("Bearer key-2")
+	write("key-3", now.Add(3*time.Second))
+	now = now.Add(defaultApiKeyReloadInterval)
+	check("Bearer key-3")
+}
+
+func TestHTTPSenderApiKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikey")
+	os.WriteFile(path, []byte("k3y\n"), 0o600)
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Api-Key")
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	senders, err := newSenders(&Config{NotifyUrl: srv.URL, ApiKeyFile: path}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := deliverTo(senders[0], Notification{Body: []byte(`{"a":1}`), ContentType: "application/json"})
+	if !result.Success || got != "k3y" {
+		t.Errorf("unexpected delivery %+v with api key %q", result, got)
+	}
+}
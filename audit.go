@@ -0,0 +1,120 @@
+package header2post
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	auditHash = "hash"
+	auditFull = "full"
+)
+
+// auditJournal appends one JSON line per dispatched notification to a
+// rotating file: when it left, what it carried and how every target
+// answered, whether or not the receivers acknowledged it.
+type auditJournal struct {
+	w    io.Writer
+	name string
+	full bool
+}
+
+type auditRecord struct {
+	Time          string           `json:"time"`
+	Middleware    string           `json:"middleware"`
+	EventIDs      []string         `json:"event_ids,omitempty"`
+	PayloadSHA256 string           `json:"payload_sha256,omitempty"`
+	Payload       json.RawMessage  `json:"payload,omitempty"`
+	Outcome       string           `json:"outcome"`
+	Results       []deliveryResult `json:"results"`
+}
+
+func newAuditJournal(config *Config, name string) (*auditJournal, error) {
+	if config.AuditFile == "" {
+		if config.AuditPayload != "" || config.AuditMaxSizeMB != 0 || config.AuditMaxBackups != 0 {
+			return nil, fmt.Errorf("auditpayload, auditmaxsizemb and auditmaxbackups require auditfile")
+		}
+		return nil, nil
+	}
+	a := &auditJournal{name: name}
+	switch config.AuditPayload {
+	case "", auditHash:
+	case auditFull:
+		a.full = true
+	default:
+		return nil, fmt.Errorf("invalid auditpayload: %q", config.AuditPayload)
+	}
+	if config.AuditMaxSizeMB < 0 {
+		return nil, fmt.Errorf("auditmaxsizemb cannot be negative")
+	}
+	if config.AuditMaxBackups < 0 {
+		return nil, fmt.Errorf("auditmaxbackups cannot be negative")
+	}
+	maxSize := int64(config.AuditMaxSizeMB) << 20
+	if maxSize == 0 {
+		maxSize = defaultLogMaxSizeMB << 20
+	}
+	backups := config.AuditMaxBackups
+	if backups == 0 {
+		backups = defaultLogMaxBackups
+	}
+	f, err := openLogFile(config.AuditFile, maxSize, backups)
+	if err != nil {
+		return nil, fmt.Errorf("open auditfile: %w", err)
+	}
+	a.w = f
+	return a, nil
+}
+
+// record journals msg with the results of its deliveries.
+func (a *auditJournal) record(msg Notification, results []deliveryResult) error {
+	data := msg.Payload
+	if data == nil {
+		data = msg.Body
+	}
+	rec := auditRecord{
+		Time:       timeNow().UTC().Format(time.RFC3339Nano),
+		Middleware: a.name,
+		EventIDs:   msg.EventIDs,
+		Outcome:    auditOutcome(results),
+		Results:    results,
+	}
+	if a.full {
+		if json.Valid(data) {
+			rec.Payload = data
+		} else {
+			rec.Payload, _ = json.Marshal(string(data))
+		}
+	} else {
+		sum := sha256.Sum256(data)
+		rec.PayloadSHA256 = hex.EncodeToString(sum[:])
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = a.w.Write(append(line, '\n'))
+	return err
+}
+
+// auditOutcome summarizes results as "delivered", "failed" or, when only
+// some targets accepted the notification, "partial".
+func auditOutcome(results []deliveryResult) string {
+	delivered := 0
+	for _, r := range results {
+		if r.Success {
+			delivered++
+		}
+	}
+	switch {
+	case delivered == len(results):
+		return resultDelivered
+	case delivered == 0:
+		return resultFailed
+	}
+	return "partial"
+}
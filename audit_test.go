@@ -0,0 +1,112 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAuditJournalErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "payload without file", config: Config{AuditPayload: "full"}, expectErr: "auditpayload, auditmaxsizemb and auditmaxbackups require auditfile"},
+		{name: "payload", config: Config{AuditFile: filepath.Join(dir, "audit.log"), AuditPayload: "body"}, expectErr: `invalid auditpayload: "body"`},
+		{name: "size", config: Config{AuditFile: filepath.Join(dir, "audit.log"), AuditMaxSizeMB: -1}, expectErr: "auditmaxsizemb cannot be negative"},
+		{name: "missing dir", config: Config{AuditFile: filepath.Join(dir, "missing", "audit.log")}, expectErr: "open auditfile: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAuditJournal(&tt.config, "notify")
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expectErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestAuditOutcome(t *testing.T) {
+	tests := []struct {
+		results []deliveryResult
+		expect  string
+	}{
+		{results: []deliveryResult{{Success: true}, {Success: true}}, expect: "delivered"},
+		{results: []deliveryResult{{Success: true}, {}}, expect: "partial"},
+		{results: []deliveryResult{{}}, expect: "failed"},
+	}
+	for _, tt := range tests {
+		if got := auditOutcome(tt.results); got != tt.expect {
+			t.Errorf("expected %q, got %q", tt.expect, got)
+		}
+	}
+}
+
+func TestServeHTTPAudit(t *testing.T) {
+	captureLog(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	tests := []struct {
+		name    string
+		payload string
+		expect  func(rec map[string]any) bool
+	}{
+		{name: "hash", payload: "", expect: func(rec map[string]any) bool {
+			return rec["payload_sha256"] == "015abd7f5cc57a2dd94b7590f04ad8084273905ee33ec5cebeae62276a97f862" && rec["payload"] == nil
+		}},
+		{name: "full", payload: "full", expect: func(rec map[string]any) bool {
+			p, _ := rec["payload"].(map[string]any)
+			return p["a"] == float64(1) && rec["payload_sha256"] == nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.log")
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader: "X-Notify",
+				NotifyUrl:    "https://example.com/notification",
+				AuditFile:    path,
+				AuditPayload: tt.payload,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusBadGateway, Body: http.NoBody}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			b, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rec map[string]any
+			if err := json.Unmarshal(b, &rec); err != nil {
+				t.Fatalf("invalid journal line %q: %v", b, err)
+			}
+			if rec["time"] != "2024-01-01T00:00:00Z" || rec["middleware"] != "header2post" || rec["outcome"] != "failed" {
+				t.Errorf("unexpected record %s", b)
+			}
+			if results, _ := rec["results"].([]any); len(results) != 1 {
+				t.Errorf("expected one result, got %s", b)
+			}
+			if !tt.expect(rec) {
+				t.Errorf("unexpected payload in %s", b)
+			}
+		})
+	}
+}
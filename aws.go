@@ -0,0 +1,288 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsSink delivers payloads to an SQS queue (SendMessage) or SNS topic
+// (Publish) using the query API signed with Signature Version 4.
+// Credentials come from the standard AWS_* environment variables or, for
+// IRSA, from a web identity token exchanged with STS.
+type awsSink struct {
+	arn      string
+	service  string
+	region   string
+	endpoint string
+	client   *http.Client
+	creds    *awsCredentialProvider
+}
+
+func newAwsSink(config *Config) (*awsSink, error) {
+	parts := strings.SplitN(config.AwsTargetArn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || (parts[2] != "sqs" && parts[2] != "sns") {
+		return nil, fmt.Errorf("invalid awstargetarn: %q", config.AwsTargetArn)
+	}
+	s := &awsSink{
+		arn:     config.AwsTargetArn,
+		service: parts[2],
+		region:  parts[3],
+		client:  &http.Client{},
+	}
+	if config.AwsRegion != "" {
+		s.region = config.AwsRegion
+	}
+	if s.region == "" {
+		return nil, fmt.Errorf("awsregion cannot be empty")
+	}
+	endpoint := config.AwsEndpoint
+	if endpoint == "" {
+		endpoint = "https://" + s.service + "." + s.region + ".amazonaws.com"
+	}
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if s.service == "sqs" {
+		// queue url: <endpoint>/<account>/<queue name>
+		endpoint += "/" + parts[4] + "/" + parts[5]
+	} else {
+		endpoint += "/"
+	}
+	s.endpoint = endpoint
+	s.creds = newAwsCredentialProvider(s.region, s.client)
+	return s, nil
+}
+
+func (s *awsSink) Target() string {
+	return s.arn
+}
+
+func (s *awsSink) Send(ctx context.Context, n Notification) error {
+	form := url.Values{}
+	if s.service == "sqs" {
+		form.Set("Action", "SendMessage")
+		form.Set("Version", "2012-11-05")
+		form.Set("MessageBody", string(n.Body))
+	} else {
+		form.Set("Action", "Publish")
+		form.Set("Version", "2010-03-31")
+		form.Set("TopicArn", s.arn)
+		form.Set("Message", string(n.Body))
+	}
+	body := []byte(form.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+	creds, err := s.creds.get(ctx)
+	if err != nil {
+		return fmt.Errorf("aws credentials: %w", err)
+	}
+	signAwsV4(req, body, creds, s.region, s.service, timeNow())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var awsErr struct {
+		Code    string `xml:"Error>Code"`
+		Message string `xml:"Error>Message"`
+	}
+	if xml.Unmarshal(respBody, &awsErr) == nil && awsErr.Code != "" {
+		return fmt.Errorf("%s: %s", awsErr.Code, awsErr.Message)
+	}
+	return fmt.Errorf("http status %d", resp.StatusCode)
+}
+
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	expires         time.Time
+}
+
+// awsCredentialProvider resolves credentials from the environment,
+// caching web identity credentials until shortly before they expire.
+type awsCredentialProvider struct {
+	mu     sync.Mutex
+	region string
+	client *http.Client
+	cached *awsCredentials
+}
+
+func newAwsCredentialProvider(region string, client *http.Client) *awsCredentialProvider {
+	return &awsCredentialProvider{region: region, client: client}
+}
+
+func (p *awsCredentialProvider) get(ctx context.Context) (*awsCredentials, error) {
+	if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		return &awsCredentials{
+			accessKeyID:     id,
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	}
+	tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	roleArn := os.Getenv("AWS_ROLE_ARN")
+	if tokenFile == "" || roleArn == "" {
+		return nil, fmt.Errorf("no credentials in environment")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached != nil && timeNow().Add(time.Minute).Before(p.cached.expires) {
+		return p.cached, nil
+	}
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := p.assumeRoleWithWebIdentity(ctx, roleArn, strings.TrimSpace(string(token)))
+	if err != nil {
+		return nil, err
+	}
+	p.cached = creds
+	return creds, nil
+}
+
+func (p *awsCredentialProvider) assumeRoleWithWebIdentity(ctx context.Context, roleArn, token string) (*awsCredentials, error) {
+	q := url.Values{}
+	q.Set("Action", "AssumeRoleWithWebIdentity")
+	q.Set("Version", "2011-06-15")
+	q.Set("RoleArn", roleArn)
+	q.Set("RoleSessionName", clientName)
+	q.Set("WebIdentityToken", token)
+	endpoint := os.Getenv("AWS_STS_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "https://sts." + p.region + ".amazonaws.com/"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("sts: http status %d", resp.StatusCode)
+	}
+	var out struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("sts: %w", err)
+	}
+	return &awsCredentials{
+		accessKeyID:     out.Credentials.AccessKeyID,
+		secretAccessKey: out.Credentials.SecretAccessKey,
+		sessionToken:    out.Credentials.SessionToken,
+		expires:         out.Credentials.Expiration,
+	}, nil
+}
+
+// signAwsV4 adds Signature Version 4 headers to req, signing the host
+// header and every header already set on the request.
+func signAwsV4(req *http.Request, body []byte, creds *awsCredentials, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func awsCanonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(k)+"="+awsEscape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape percent-encodes everything except the RFC 3986 unreserved
+// characters.
+func awsEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
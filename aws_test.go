@@ -0,0 +1,158 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAwsV4(t *testing.T) {
+	// example from the AWS Signature Version 4 documentation
+	req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	creds := &awsCredentials{accessKeyID: "AKIDEXAMPLE", secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	signAwsV4(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expect := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := req.Header.Get("Authorization"); got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+}
+
+func TestAwsSink(t *testing.T) {
+	tests := []struct {
+		name       string
+		arn        string
+		status     int
+		response   string
+		expectPath string
+		expectForm map[string]string
+		expectErr  string
+	}{
+		{
+			name:       "sqs send message",
+			arn:        "arn:aws:sqs:eu-west-1:123456789012:events",
+			status:     http.StatusOK,
+			expectPath: "/123456789012/events",
+			expectForm: map[string]string{"Action": "SendMessage", "MessageBody": `{"a":1}`},
+		},
+		{
+			name:       "sns publish",
+			arn:        "arn:aws:sns:eu-west-1:123456789012:events",
+			status:     http.StatusOK,
+			expectPath: "/",
+			expectForm: map[string]string{"Action": "Publish", "TopicArn": "arn:aws:sns:eu-west-1:123456789012:events", "Message": `{"a":1}`},
+		},
+		{
+			name:       "error response",
+			arn:        "arn:aws:sqs:eu-west-1:123456789012:events",
+			status:     http.StatusBadRequest,
+			response:   `<ErrorResponse><Error><Code>AWS.SimpleQueueService.NonExistentQueue</Code><Message>no such queue</Message></Error></ErrorResponse>`,
+			expectPath: "/123456789012/events",
+			expectErr:  "AWS.SimpleQueueService.NonExistentQueue: no such queue",
+		},
+	}
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.expectPath {
+					t.Errorf("expected path %q, got %q", tt.expectPath, r.URL.Path)
+				}
+				if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+					t.Errorf("missing signature: %q", r.Header.Get("Authorization"))
+				}
+				body, _ := io.ReadAll(r.Body)
+				form, _ := url.ParseQuery(string(body))
+				for k, v := range tt.expectForm {
+					if form.Get(k) != v {
+						t.Errorf("expected %s=%q, got %q", k, v, form.Get(k))
+					}
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer srv.Close()
+
+			s, err := newAwsSink(&Config{AwsTargetArn: tt.arn, AwsEndpoint: srv.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Send(context.Background(), Notification{Body: []byte(`{"a":1}`)})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestAwsWebIdentityCredentials(t *testing.T) {
+	calls := 0
+	sts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Query().Get("WebIdentityToken") != "jwt-token" || r.URL.Query().Get("RoleArn") != "arn:aws:iam::1:role/r" {
+			t.Errorf("unexpected sts request %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `<AssumeRoleWithWebIdentityResponse><AssumeRoleWithWebIdentityResult><Credentials>`+
+			`<AccessKeyId>ASIA</AccessKeyId><SecretAccessKey>s</SecretAccessKey><SessionToken>tok</SessionToken>`+
+			`<Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleWithWebIdentityResult></AssumeRoleWithWebIdentityResponse>`)
+	}))
+	defer sts.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	os.WriteFile(tokenFile, []byte("jwt-token\n"), 0o600)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::1:role/r")
+	t.Setenv("AWS_STS_ENDPOINT", sts.URL+"/")
+
+	p := newAwsCredentialProvider("eu-west-1", sts.Client())
+	for i := 0; i < 2; i++ {
+		creds, err := p.get(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if creds.accessKeyID != "ASIA" || creds.sessionToken != "tok" {
+			t.Errorf("unexpected credentials %+v", creds)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("expected cached credentials, sts called %d times", calls)
+	}
+}
+
+func TestNewAwsSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "invalid arn", config: Config{AwsTargetArn: "queue"}, expectErr: `invalid awstargetarn: "queue"`},
+		{name: "unsupported service", config: Config{AwsTargetArn: "arn:aws:s3:::bucket"}, expectErr: `invalid awstargetarn: "arn:aws:s3:::bucket"`},
+		{name: "missing region", config: Config{AwsTargetArn: "arn:aws:sqs::1:q"}, expectErr: "awsregion cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAwsSink(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
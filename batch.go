@@ -0,0 +1,132 @@
+package header2post
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultBatchMaxWait = time.Second
+
+// batchItem is one decoded payload waiting to be batched.
+type batchItem struct {
+	data          []byte
+	eventIDs      []string
+	correlationID string
+	// dedupKey is the DedupTTL key recorded for the item, if any.
+	dedupKey string
+	// created is when the item was triggered.
+	created time.Time
+}
+
+// batcher collects items and hands them to flush once maxSize items are
+// pending or maxWait has elapsed since the first pending item. A maxSize
+// of zero means only maxWait triggers a flush.
+type batcher struct {
+	mu      sync.Mutex
+	items   []batchItem
+	maxSize int
+	maxWait time.Duration
+	timer   *time.Timer
+	gen     int
+	flush   func([]batchItem)
+	wg      sync.WaitGroup
+}
+
+func newBatcher(maxSize int, maxWait time.Duration, flush func([]batchItem)) *batcher {
+	return &batcher{maxSize: maxSize, maxWait: maxWait, flush: flush}
+}
+
+func (b *batcher) add(item batchItem) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.items = append(b.items, item)
+	if len(b.items) == 1 {
+		gen := b.gen
+		b.timer = time.AfterFunc(b.maxWait, func() { b.flushPending(gen) })
+	}
+	if b.maxSize > 0 && len(b.items) >= b.maxSize {
+		b.timer.Stop()
+		b.dispatchLocked()
+	}
+}
+
+// depth returns the number of items waiting for the next batch.
+func (b *batcher) depth() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.items)
+}
+
+// flushPending is called by the wait timer; a timer belonging to an
+// already dispatched batch is ignored.
+func (b *batcher) flushPending(gen int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if gen != b.gen {
+		return
+	}
+	b.dispatchLocked()
+}
+
+func (b *batcher) dispatchLocked() {
+	if len(b.items) == 0 {
+		return
+	}
+	items := b.items
+	b.items = nil
+	b.gen++
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		b.flush(items)
+	}()
+}
+
+// close flushes pending items and waits for in-flight batches.
+func (b *batcher) close() {
+	b.mu.Lock()
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.dispatchLocked()
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// deliverBatch posts the batched payloads as a single JSON array.
+func (a *notify) deliverBatch(items []batchItem) {
+	var eventIDs, correlationIDs, dedupKeys []string
+	payloads := make([][]byte, 0, len(items))
+	for _, item := range items {
+		eventIDs = append(eventIDs, item.eventIDs...)
+		if item.correlationID != "" {
+			correlationIDs = append(correlationIDs, item.correlationID)
+		}
+		if item.dedupKey != "" {
+			dedupKeys = append(dedupKeys, item.dedupKey)
+		}
+		payloads = append(payloads, item.data)
+	}
+	payload, err := a.format.encodeBatch(payloads)
+	plain := payload
+	if err == nil && a.encrypter != nil {
+		payload, err = a.encrypter.seal(payload)
+	}
+	if err != nil {
+		a.log.Error("encode batch error", "error", err, "correlation_ids", correlationIDs)
+		for range items {
+			a.dropped(dropEncode)
+		}
+		a.settleDedup(dedupKeys, false)
+		return
+	}
+	report := newDeliveryReport(eventIDs)
+	report.CorrelationIDs = correlationIDs
+	msg := newNotification(payload, plain.body, eventIDs)
+	msg.expires = a.expiry(items[0].created)
+	msg.dedupKeys = dedupKeys
+	a.setIdempotencyKey(&msg, "", plain.body)
+	a.setEventId(&msg, report)
+	a.dispatch(a.detached, msg, report)
+	report.log(a.log)
+}
@@ -0,0 +1,82 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatcher(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxSize int
+		maxWait time.Duration
+		add     int
+		expect  []int
+	}{
+		{name: "flush on size", maxSize: 2, maxWait: time.Hour, add: 5, expect: []int{2, 2, 1}},
+		{name: "flush on wait", maxSize: 0, maxWait: time.Millisecond, add: 3, expect: []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var sizes []int
+			b := newBatcher(tt.maxSize, tt.maxWait, func(items []batchItem) {
+				mu.Lock()
+				sizes = append(sizes, len(items))
+				mu.Unlock()
+			})
+			for i := 0; i < tt.add; i++ {
+				b.add(batchItem{data: []byte("{}")})
+			}
+			if tt.maxSize == 0 {
+				time.Sleep(20 * tt.maxWait)
+			}
+			b.close()
+
+			total := 0
+			for _, n := range sizes {
+				total += n
+			}
+			if total != tt.add || len(sizes) != len(tt.expect) {
+				t.Errorf("expected batches %v, got %v", tt.expect, sizes)
+			}
+		})
+	}
+}
+
+func TestServeHTTPBatch(t *testing.T) {
+	captureLog(t)
+	var bodies []string
+	var mu sync.Mutex
+	payloads := []string{`{"id":1}`, `not json`}
+	i := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(payloads[i])))
+	})
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", BatchMaxSize: 2, BatchMaxWait: "1h"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+	for i = range payloads {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	handler.(*notify).batch.close()
+
+	expect := `[{"id":1},"not json"]`
+	if len(bodies) != 1 || bodies[0] != expect {
+		t.Errorf("expected single batch %s, got %v", expect, bodies)
+	}
+}
@@ -0,0 +1,185 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// loadDuration enables TestLoadHarness, e.g.
+// go test -run LoadHarness -load 10s -load.clients 64
+var (
+	loadDuration = flag.Duration("load", 0, "run the load harness for this long")
+	loadClients  = flag.Int("load.clients", 32, "concurrent clients of the load harness")
+)
+
+func BenchmarkWrappedResponseWriter(b *testing.B) {
+	chunk := bytes.Repeat([]byte("x"), 4<<10)
+	for _, buffer := range []bool{false, true} {
+		b.Run("buffer="+strconv.FormatBool(buffer), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(8 * len(chunk)))
+			for i := 0; i < b.N; i++ {
+				w := newResponseWriter(httptest.NewRecorder(), buffer, 0, func(http.Header) {})
+				w.Header().Set("X-Notify", "e30=")
+				for j := 0; j < 8; j++ {
+					w.Write(chunk)
+				}
+				w.finish()
+				w.release()
+			}
+		})
+	}
+}
+
+func BenchmarkBase64Decode(b *testing.B) {
+	for _, size := range []int{64, 4 << 10, 64 << 10} {
+		value := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("x"), size))
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			codec, err := newLenientCodec(&Config{}, codecBase64, base64Codec{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				if _, err := codec.Decode(value); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// newFakeReceiver starts a notify receiver answering 202 after delay and
+// counting the deliveries it took.
+func newFakeReceiver(tb testing.TB, delay time.Duration) (*httptest.Server, *atomic.Int64) {
+	var received atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if delay > 0 {
+			time.Sleep(delay)
+		}
+		received.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	tb.Cleanup(srv.Close)
+	return srv, &received
+}
+
+// newLoadHandler wraps an upstream setting a notify header around a
+// middleware delivering to receiverURL.
+func newLoadHandler(tb testing.TB, receiverURL string, config *Config) http.Handler {
+	body := bytes.Repeat([]byte("x"), 4<<10)
+	value := base64.StdEncoding.EncodeToString([]byte(`{"order":"a","total":42}`))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", value)
+		w.Write(body)
+	})
+	config.NotifyHeader = "X-Notify"
+	config.NotifyUrl = receiverURL
+	config.LogLevel = "warn"
+	handler, err := New(context.Background(), next, config, "header2post")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return handler
+}
+
+// BenchmarkDeliveryPipeline measures a request through decode, encode and
+// delivery to a receiver over a real connection.
+func BenchmarkDeliveryPipeline(b *testing.B) {
+	captureLog(b)
+	for _, tt := range []struct {
+		name   string
+		config Config
+	}{
+		{name: "streamed"},
+		{name: "buffered", config: Config{ExposeStatusHeader: true}},
+		{name: "cloudevents", config: Config{Format: formatCloudEvents}},
+	} {
+		b.Run(tt.name, func(b *testing.B) {
+			receiver, received := newFakeReceiver(b, 0)
+			config := tt.config
+			handler := newLoadHandler(b, receiver.URL, &config)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				for pb.Next() {
+					handler.ServeHTTP(httptest.NewRecorder(), req)
+				}
+			})
+			b.StopTimer()
+			if received.Load() == 0 {
+				b.Fatal("no delivery reached the receiver")
+			}
+		})
+	}
+}
+
+// TestLoadHarness drives the middleware with -load.clients concurrent
+// clients over HTTP for -load, against a receiver answering after 1ms, and
+// logs the throughput and client latency percentiles.
+func TestLoadHarness(t *testing.T) {
+	if *loadDuration == 0 {
+		t.Skip("enable with -load")
+	}
+	captureLog(t)
+	receiver, received := newFakeReceiver(t, time.Millisecond)
+	srv := httptest.NewServer(newLoadHandler(t, receiver.URL, &Config{}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var latencies []time.Duration
+	var failed atomic.Int64
+	deadline := time.Now().Add(*loadDuration)
+	var wg sync.WaitGroup
+	for i := 0; i < *loadClients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var own []time.Duration
+			for time.Now().Before(deadline) {
+				start := time.Now()
+				resp, err := srv.Client().Get(srv.URL)
+				if err != nil {
+					failed.Add(1)
+					continue
+				}
+				io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+				if resp.StatusCode != http.StatusOK {
+					failed.Add(1)
+				}
+				own = append(own, time.Since(start))
+			}
+			mu.Lock()
+			latencies = append(latencies, own...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(latencies) == 0 {
+		t.Fatal("no request completed")
+	}
+	slices.Sort(latencies)
+	percentile := func(p float64) time.Duration {
+		return latencies[int(float64(len(latencies)-1)*p)]
+	}
+	t.Logf("requests=%d failed=%d delivered=%d rps=%.0f p50=%s p99=%s max=%s",
+		len(latencies), failed.Load(), received.Load(),
+		float64(len(latencies))/loadDuration.Seconds(),
+		percentile(0.5), percentile(0.99), latencies[len(latencies)-1])
+}
@@ -0,0 +1,121 @@
+package header2post
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// TLSConfig groups the notify client TLS options of Config.TLS. Each field
+// names, in its flat tag, the top-level option it sets.
+type TLSConfig struct {
+	CertFile           string `yaml:"certfile" json:"certfile" toml:"certfile" flat:"ClientCertFile"`
+	KeyFile            string `yaml:"keyfile" json:"keyfile" toml:"keyfile" flat:"ClientKeyFile"`
+	CertPEM            string `yaml:"certpem" json:"certpem" toml:"certpem" flat:"ClientCertPEM"`
+	KeyPEM             string `yaml:"keypem" json:"keypem" toml:"keypem" flat:"ClientKeyPEM"`
+	CAFile             string `yaml:"cafile" json:"cafile" toml:"cafile" flat:"RootCAFile"`
+	CAPEM              string `yaml:"capem" json:"capem" toml:"capem" flat:"RootCAPEM"`
+	InsecureSkipVerify bool   `yaml:"insecureskipverify" json:"insecureskipverify" toml:"insecureskipverify" flat:"InsecureSkipVerify"`
+	HandshakeTimeout   string `yaml:"handshaketimeout" json:"handshaketimeout" toml:"handshaketimeout" flat:"TLSHandshakeTimeout"`
+}
+
+// RetryConfig groups the retry options of Config.Retry.
+type RetryConfig struct {
+	Max           int      `yaml:"max" json:"max" toml:"max" flat:"MaxRetries"`
+	Backoff       string   `yaml:"backoff" json:"backoff" toml:"backoff" flat:"RetryBackoff"`
+	MaxBackoff    string   `yaml:"maxbackoff" json:"maxbackoff" toml:"maxbackoff" flat:"RetryMaxBackoff"`
+	OnStatusCodes []string `yaml:"onstatuscodes" json:"onstatuscodes" toml:"onstatuscodes" flat:"RetryOnStatusCodes"`
+	NoStatusCodes []string `yaml:"nostatuscodes" json:"nostatuscodes" toml:"nostatuscodes" flat:"NoRetryStatusCodes"`
+	MaxAfter      string   `yaml:"maxafter" json:"maxafter" toml:"maxafter" flat:"MaxRetryAfter"`
+	BudgetRatio   float64  `yaml:"budgetratio" json:"budgetratio" toml:"budgetratio" flat:"RetryBudgetRatio"`
+	MaxPerSecond  int      `yaml:"maxpersecond" json:"maxpersecond" toml:"maxpersecond" flat:"MaxRetriesPerSecond"`
+}
+
+// AuthConfig groups the notify request authentication options of
+// Config.Auth.
+type AuthConfig struct {
+	OAuth2 *OAuth2Config `yaml:"oauth2" json:"oauth2" toml:"oauth2"`
+	Jwt    *JwtConfig    `yaml:"jwt" json:"jwt" toml:"jwt"`
+	ApiKey *ApiKeyConfig `yaml:"apikey" json:"apikey" toml:"apikey"`
+}
+
+// OAuth2Config groups the OAuth2 client credentials options.
+type OAuth2Config struct {
+	TokenUrl     string   `yaml:"tokenurl" json:"tokenurl" toml:"tokenurl" flat:"TokenUrl"`
+	ClientId     string   `yaml:"clientid" json:"clientid" toml:"clientid" flat:"ClientId"`
+	ClientSecret string   `yaml:"clientsecret" json:"clientsecret" toml:"clientsecret" flat:"ClientSecret"`
+	Scopes       []string `yaml:"scopes" json:"scopes" toml:"scopes" flat:"Scopes"`
+}
+
+// JwtConfig groups the JWT bearer token options.
+type JwtConfig struct {
+	SigningKeyFile string            `yaml:"signingkeyfile" json:"signingkeyfile" toml:"signingkeyfile" flat:"JwtSigningKeyFile"`
+	SigningKeyEnv  string            `yaml:"signingkeyenv" json:"signingkeyenv" toml:"signingkeyenv" flat:"JwtSigningKeyEnv"`
+	Algorithm      string            `yaml:"algorithm" json:"algorithm" toml:"algorithm" flat:"JwtAlgorithm"`
+	KeyId          string            `yaml:"keyid" json:"keyid" toml:"keyid" flat:"JwtKeyId"`
+	Issuer         string            `yaml:"issuer" json:"issuer" toml:"issuer" flat:"JwtIssuer"`
+	Audience       string            `yaml:"audience" json:"audience" toml:"audience" flat:"JwtAudience"`
+	TTL            string            `yaml:"ttl" json:"ttl" toml:"ttl" flat:"JwtTTL"`
+	Claims         map[string]string `yaml:"claims" json:"claims" toml:"claims" flat:"JwtClaims"`
+}
+
+// ApiKeyConfig groups the API key options.
+type ApiKeyConfig struct {
+	File           string `yaml:"file" json:"file" toml:"file" flat:"ApiKeyFile"`
+	Header         string `yaml:"header" json:"header" toml:"header" flat:"ApiKeyHeader"`
+	Prefix         string `yaml:"prefix" json:"prefix" toml:"prefix" flat:"ApiKeyPrefix"`
+	ReloadInterval string `yaml:"reloadinterval" json:"reloadinterval" toml:"reloadinterval" flat:"ApiKeyReloadInterval"`
+}
+
+// flattenBlocks returns a copy of config with the TLS, Retry and Auth
+// blocks moved onto the top-level options they group. A block option and
+// its top-level option may both be set only to the same value.
+func flattenBlocks(config *Config) (*Config, error) {
+	if config.TLS == nil && config.Retry == nil && config.Auth == nil {
+		return config, nil
+	}
+	c := *config
+	dst := reflect.ValueOf(&c).Elem()
+	for _, b := range []struct {
+		option string
+		block  any
+	}{
+		{"tls", c.TLS},
+		{"retry", c.Retry},
+		{"auth", c.Auth},
+	} {
+		if err := mergeBlock(dst, reflect.ValueOf(b.block), b.option); err != nil {
+			return nil, err
+		}
+	}
+	c.TLS, c.Retry, c.Auth = nil, nil, nil
+	return &c, nil
+}
+
+func mergeBlock(dst, block reflect.Value, option string) error {
+	if block.IsNil() {
+		return nil
+	}
+	block = block.Elem()
+	t := block.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := option + "." + field.Tag.Get("yaml")
+		v := block.Field(i)
+		if v.Kind() == reflect.Pointer {
+			if err := mergeBlock(dst, v, name); err != nil {
+				return err
+			}
+			continue
+		}
+		if v.IsZero() {
+			continue
+		}
+		flatField, _ := dst.Type().FieldByName(field.Tag.Get("flat"))
+		flat := dst.FieldByIndex(flatField.Index)
+		if !flat.IsZero() && !reflect.DeepEqual(flat.Interface(), v.Interface()) {
+			return fmt.Errorf("%s cannot be combined with %s", name, flatField.Tag.Get("yaml"))
+		}
+		flat.Set(v)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package header2post
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFlattenBlocks(t *testing.T) {
+	config := &Config{
+		NotifyUrl:  "http://example.com",
+		MaxRetries: 3,
+		TLS:        &TLSConfig{CAFile: "/etc/ca.pem", InsecureSkipVerify: true},
+		Retry:      &RetryConfig{Max: 3, Backoff: "200ms", OnStatusCodes: []string{"502-504"}},
+		Auth: &AuthConfig{
+			OAuth2: &OAuth2Config{TokenUrl: "http://auth/token", Scopes: []string{"notify"}},
+			Jwt:    &JwtConfig{Claims: map[string]string{"tenant": "a"}},
+			ApiKey: &ApiKeyConfig{Header: "X-Key"},
+		},
+	}
+	got, err := flattenBlocks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &Config{
+		NotifyUrl:          "http://example.com",
+		RootCAFile:         "/etc/ca.pem",
+		InsecureSkipVerify: true,
+		MaxRetries:         3,
+		RetryBackoff:       "200ms",
+		RetryOnStatusCodes: []string{"502-504"},
+		TokenUrl:           "http://auth/token",
+		Scopes:             []string{"notify"},
+		JwtClaims:          map[string]string{"tenant": "a"},
+		ApiKeyHeader:       "X-Key",
+	}
+	if !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %+v, got %+v", expect, got)
+	}
+	if config.RootCAFile != "" || config.TLS == nil {
+		t.Error("expected the original config to be left unchanged")
+	}
+}
+
+func TestFlattenBlocksErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{
+			name:      "tls",
+			config:    Config{ClientCertFile: "/a.pem", TLS: &TLSConfig{CertFile: "/b.pem"}},
+			expectErr: "tls.certfile cannot be combined with clientcertfile",
+		},
+		{
+			name:      "retry",
+			config:    Config{MaxRetries: 2, Retry: &RetryConfig{Max: 5}},
+			expectErr: "retry.max cannot be combined with maxretries",
+		},
+		{
+			name:      "auth",
+			config:    Config{ApiKeyPrefix: "Bearer ", Auth: &AuthConfig{ApiKey: &ApiKeyConfig{Prefix: "Token "}}},
+			expectErr: "auth.apikey.prefix cannot be combined with apikeyprefix",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := flattenBlocks(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
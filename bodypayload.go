@@ -0,0 +1,119 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+)
+
+// bodyExtractor takes the payload from the response body of backends that
+// cannot set the notify header.
+type bodyExtractor struct {
+	pattern *regexp.Regexp
+	pointer []string
+}
+
+// newBodyExtractor returns nil when neither BodyPattern nor BodyPointer is
+// set.
+func newBodyExtractor(config *Config) (*bodyExtractor, error) {
+	switch {
+	case config.BodyPattern != "" && config.BodyPointer != "":
+		return nil, fmt.Errorf("bodypattern cannot be combined with bodypointer")
+	case config.BodyPattern != "":
+		re, err := regexp.Compile(config.BodyPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bodypattern: %w", err)
+		}
+		return &bodyExtractor{pattern: re}, nil
+	case config.BodyPointer != "":
+		path, err := parseFieldPath("bodypointer", config.BodyPointer)
+		if err != nil {
+			return nil, err
+		}
+		return &bodyExtractor{pointer: path}, nil
+	}
+	return nil, nil
+}
+
+// extract returns the payload found in body, or false when the pattern
+// does not match or the pointer does not resolve.
+func (e *bodyExtractor) extract(body []byte) ([]byte, bool) {
+	if e.pattern != nil {
+		m := e.pattern.FindSubmatch(body)
+		if m == nil {
+			return nil, false
+		}
+		if len(m) > 1 {
+			return bytes.Clone(m[1]), true
+		}
+		return bytes.Clone(m[0]), true
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, false
+	}
+	for _, p := range e.pointer {
+		switch v := doc.(type) {
+		case map[string]any:
+			var ok bool
+			if doc, ok = v[p]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(p)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	if doc == nil {
+		return nil, false
+	}
+	if s, ok := doc.(string); ok {
+		return []byte(s), true
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// bodyValue extracts the payload from the buffered response body, decoded
+// from its Content-Encoding. A body that was flushed or spilled to disk is
+// not searched.
+func (a *notify) bodyValue(req *http.Request, w *wrappedResponseWriter) (notifyValue, bool) {
+	if a.bodyPayload == nil || w.code == http.StatusSwitchingProtocols {
+		return notifyValue{}, false
+	}
+	switch {
+	case w.buf == nil:
+		a.log.Warn("payload not extracted: response already flushed", "path", req.URL.Path)
+	case w.buf.spilled():
+		a.log.Warn("payload not extracted: response exceeds maxbufferbytes", "path", req.URL.Path)
+	default:
+		body := w.buf.Bytes()
+		if encoding := contentEncoding(w.upstreamHeader()); encoding != "" {
+			max := a.maxBufferBytes
+			if max == 0 {
+				max = defaultDecodedBodyBytes
+			}
+			decoded, complete, err := decodeContentEncoding(encoding, body, max)
+			if err != nil || !complete {
+				a.log.Warn("payload not extracted: response body not decoded", "path", req.URL.Path, "content_encoding", encoding, "error", err)
+				return notifyValue{}, false
+			}
+			body = decoded
+		}
+		if data, ok := a.bodyPayload.extract(body); ok {
+			return notifyValue{payload: data}, true
+		}
+	}
+	return notifyValue{}, false
+}
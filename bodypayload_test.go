@@ -0,0 +1,133 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBodyExtractorExtract(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		body   string
+		expect string
+		ok     bool
+	}{
+		{name: "pattern group", config: Config{BodyPattern: `<!-- event: (\{.*?\}) -->`}, body: `<html><!-- event: {"id":"e1"} --></html>`, expect: `{"id":"e1"}`, ok: true},
+		{name: "pattern match", config: Config{BodyPattern: `\{"event":[^}]*\}`}, body: `ok {"event":"paid"} done`, expect: `{"event":"paid"}`, ok: true},
+		{name: "pattern miss", config: Config{BodyPattern: `event: (\w+)`}, body: `nothing here`},
+		{name: "pointer object", config: Config{BodyPointer: "/meta/event"}, body: `{"meta":{"event":{"id":"e1"}}}`, expect: `{"id":"e1"}`, ok: true},
+		{name: "pointer string", config: Config{BodyPointer: "/meta/event"}, body: `{"meta":{"event":"{\"id\":\"e1\"}"}}`, expect: `{"id":"e1"}`, ok: true},
+		{name: "pointer index", config: Config{BodyPointer: "/events/1"}, body: `{"events":[{"id":"e1"},{"id":"e2"}]}`, expect: `{"id":"e2"}`, ok: true},
+		{name: "dotted path", config: Config{BodyPointer: "meta.event"}, body: `{"meta":{"event":7}}`, expect: `7`, ok: true},
+		{name: "pointer escape", config: Config{BodyPointer: "/a~1b"}, body: `{"a/b":true}`, expect: `true`, ok: true},
+		{name: "pointer missing", config: Config{BodyPointer: "/meta/event"}, body: `{"meta":{}}`},
+		{name: "pointer null", config: Config{BodyPointer: "/event"}, body: `{"event":null}`},
+		{name: "index out of range", config: Config{BodyPointer: "/events/2"}, body: `{"events":[1]}`},
+		{name: "not json", config: Config{BodyPointer: "/event"}, body: `<html>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := newBodyExtractor(&tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := e.extract([]byte(tt.body))
+			if ok != tt.ok || string(got) != tt.expect {
+				t.Errorf("expected %q, %v, got %q, %v", tt.expect, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestNewBodyExtractorErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "both", config: Config{BodyPattern: "x", BodyPointer: "/x"}, err: "bodypattern cannot be combined with bodypointer"},
+		{name: "bad pattern", config: Config{BodyPattern: "("}, err: "invalid bodypattern: error parsing regexp: missing closing ): `(`"},
+		{name: "bad pointer", config: Config{BodyPointer: "/a//b"}, err: `invalid bodypointer path: "/a//b"`},
+		{name: "request trigger", config: Config{BodyPointer: "/x", TriggerSource: triggerRequest}, err: "bodypattern and bodypointer require triggersource response"},
+		{name: "write header", config: Config{BodyPointer: "/x", TriggerOnWriteHeader: true}, err: "bodypattern and bodypointer cannot be combined with triggeronwriteheader"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyUrl = "https://example.com/notification"
+			_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPBodyPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		header   string
+		encoding string
+		body     string
+		flush    bool
+		expect   string
+		log      string
+	}{
+		{name: "extracted", body: `{"order":"o1","event":{"id":"e1"}}`, expect: `{"id":"e1"}`},
+		{name: "no match", body: `{"order":"o1"}`},
+		{name: "header wins", config: Config{NotifyHeader: "X-Notify"}, header: `{"id":"h1"}`, body: `{"event":{"id":"e1"}}`, expect: `{"id":"h1"}`},
+		{name: "spilled", config: Config{MaxBufferBytes: 8}, body: `{"event":{"id":"e1"}}`, log: "payload not extracted: response exceeds maxbufferbytes"},
+		{name: "flushed", body: `{"event":{"id":"e1"}}`, flush: true, log: "payload not extracted: response already flushed"},
+		{name: "gzip", encoding: "gzip", body: gzipString(t, `{"event":{"id":"e1"}}`), expect: `{"id":"e1"}`},
+		{name: "gzip too large", config: Config{MaxBufferBytes: 64}, encoding: "gzip", body: gzipString(t, `{"event":{"id":"e1"},"pad":"`+strings.Repeat("x", 100)+`"}`), log: "payload not extracted: response body not decoded"},
+		{name: "brotli", encoding: "br", body: "\x8b\x06\x80{}\x03", log: "payload not extracted: response body not decoded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.header)))
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				if tt.flush {
+					w.(http.Flusher).Flush()
+				}
+				io.WriteString(w, tt.body)
+			})
+			config := tt.config
+			config.NotifyUrl = "https://example.com/notification"
+			config.BodyPointer = "/event"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orders", nil))
+			if body != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, body)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("expected response %s, got %s", tt.body, rec.Body.String())
+			}
+			if tt.log != "" && !strings.Contains(logs.String(), tt.log) {
+				t.Errorf("expected log %q, got %s", tt.log, logs.String())
+			}
+		})
+	}
+}
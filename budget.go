@@ -0,0 +1,102 @@
+package header2post
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	// retryBudgetWindow is how many seconds of deliveries the retry ratio
+	// is measured over.
+	retryBudgetWindow = 10
+	// minBudgetRetries are always allowed within the window, so that the
+	// ratio does not rule out every retry at low traffic.
+	minBudgetRetries = 10
+)
+
+// retryBudget bounds the retries of all deliveries of a middleware, so a
+// receiver coming back from an outage is not flooded with retries on top
+// of new traffic.
+type retryBudget struct {
+	// ratio is the largest fraction of first attempts that may be
+	// retried within the window; zero disables the check.
+	ratio float64
+	// perSecond caps retries in any one second; zero disables the check.
+	perSecond int
+
+	mu      sync.Mutex
+	buckets [retryBudgetWindow]budgetBucket
+}
+
+type budgetBucket struct {
+	second   int64
+	attempts int
+	retries  int
+}
+
+// newRetryBudget returns nil when neither RetryBudgetRatio nor
+// MaxRetriesPerSecond is set.
+func newRetryBudget(config *Config) (*retryBudget, error) {
+	if config.RetryBudgetRatio == 0 && config.MaxRetriesPerSecond == 0 {
+		return nil, nil
+	}
+	if config.RetryBudgetRatio < 0 || config.RetryBudgetRatio > 1 {
+		return nil, fmt.Errorf("retrybudgetratio must be between 0 and 1")
+	}
+	if config.MaxRetriesPerSecond < 0 {
+		return nil, fmt.Errorf("maxretriespersecond cannot be negative")
+	}
+	if config.MaxRetries == 0 {
+		return nil, fmt.Errorf("retrybudgetratio and maxretriespersecond require maxretries")
+	}
+	return &retryBudget{ratio: config.RetryBudgetRatio, perSecond: config.MaxRetriesPerSecond}, nil
+}
+
+// bucket returns the bucket of the current second, emptied when it last
+// held an older second. b.mu must be held.
+func (b *retryBudget) bucket(second int64) *budgetBucket {
+	cur := &b.buckets[second%retryBudgetWindow]
+	if cur.second != second {
+		*cur = budgetBucket{second: second}
+	}
+	return cur
+}
+
+// attempt records the first attempt of a delivery.
+func (b *retryBudget) attempt() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bucket(timeNow().Unix()).attempts++
+}
+
+// allow reports whether one more retry fits the budget, and records it
+// if so.
+func (b *retryBudget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := timeNow().Unix()
+	cur := b.bucket(now)
+	if b.perSecond > 0 && cur.retries >= b.perSecond {
+		return false
+	}
+	if b.ratio > 0 {
+		attempts, retries := 0, 0
+		for _, bucket := range b.buckets {
+			if bucket.second > now-retryBudgetWindow {
+				attempts += bucket.attempts
+				retries += bucket.retries
+			}
+		}
+		if retries >= minBudgetRetries && float64(retries+1) > b.ratio*float64(attempts) {
+			return false
+		}
+	}
+	cur.retries++
+	return true
+}
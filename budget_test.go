@@ -0,0 +1,105 @@
+package header2post
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewRetryBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectNil bool
+		expectErr string
+	}{
+		{name: "disabled", config: Config{MaxRetries: 3}, expectNil: true},
+		{name: "ratio", config: Config{MaxRetries: 3, RetryBudgetRatio: 0.1}},
+		{name: "per second", config: Config{MaxRetries: 3, MaxRetriesPerSecond: 5}},
+		{name: "ratio too large", config: Config{MaxRetries: 3, RetryBudgetRatio: 1.5}, expectErr: "retrybudgetratio must be between 0 and 1"},
+		{name: "negative rate", config: Config{MaxRetries: 3, MaxRetriesPerSecond: -1}, expectErr: "maxretriespersecond cannot be negative"},
+		{name: "no retries", config: Config{RetryBudgetRatio: 0.1}, expectErr: "retrybudgetratio and maxretriespersecond require maxretries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newRetryPolicy(&tt.config)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (p.budget == nil) != tt.expectNil {
+				t.Errorf("expected nil budget %v, got %v", tt.expectNil, p.budget)
+			}
+		})
+	}
+}
+
+func TestRetryBudgetRatio(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	b := &retryBudget{ratio: 0.1}
+	for range 200 {
+		b.attempt()
+	}
+	allowed := 0
+	for b.allow() {
+		allowed++
+	}
+	if allowed != 20 {
+		t.Errorf("expected 20 retries for 200 deliveries, got %d", allowed)
+	}
+
+	// the window moves on: old deliveries and retries no longer count
+	now = now.Add(retryBudgetWindow * time.Second)
+	allowed = 0
+	for b.allow() {
+		allowed++
+	}
+	if allowed != minBudgetRetries {
+		t.Errorf("expected the floor of %d retries without traffic, got %d", minBudgetRetries, allowed)
+	}
+}
+
+func TestRetryBudgetPerSecond(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	b := &retryBudget{perSecond: 3}
+	for second := range 2 {
+		allowed := 0
+		for b.allow() {
+			allowed++
+		}
+		if allowed != 3 {
+			t.Errorf("second %d: expected 3 retries, got %d", second, allowed)
+		}
+		now = now.Add(time.Second)
+	}
+}
+
+func TestDeliverRetryBudget(t *testing.T) {
+	timeNow = func() time.Time { return time.Unix(1700000000, 0) }
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	t.Cleanup(func() {
+		timeNow = time.Now
+		sleep = sleepContext
+	})
+	attempts := 0
+	s := SenderFunc(func(ctx context.Context, n Notification) error {
+		attempts++
+		return &StatusError{StatusCode: 503}
+	})
+	p := &retryPolicy{max: 5, budget: &retryBudget{perSecond: 2}}
+	result := deliverRetry(context.Background(), s, Notification{}, p, time.Second, nil)
+	if attempts != 3 || result.Retries != 2 || result.Success {
+		t.Errorf("expected 3 attempts within the budget, got %d: %+v", attempts, result)
+	}
+}
@@ -0,0 +1,93 @@
+package header2post
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// responseBuffer holds a buffered response body in memory up to max
+// bytes, then moves it to a temporary file so large responses do not
+// stay in memory for every in-flight request. A zero max never spills.
+type responseBuffer struct {
+	mem  *bytes.Buffer
+	file *os.File
+	max  int
+}
+
+func newResponseBuffer(max int) *responseBuffer {
+	return &responseBuffer{mem: getBuffer(), max: max}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.file == nil && b.max > 0 && b.mem.Len()+len(p) > b.max {
+		if err := b.spill(); err != nil {
+			return 0, err
+		}
+	}
+	if b.file != nil {
+		return b.file.Write(p)
+	}
+	return b.mem.Write(p)
+}
+
+// spill moves the buffered body to a temporary file.
+func (b *responseBuffer) spill() error {
+	f, err := os.CreateTemp("", "header2post-*")
+	if err != nil {
+		return fmt.Errorf("spill response body: %w", err)
+	}
+	if _, err := b.mem.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return fmt.Errorf("spill response body: %w", err)
+	}
+	b.file = f
+	return nil
+}
+
+// spilled reports whether the body moved to disk.
+func (b *responseBuffer) spilled() bool {
+	return b.file != nil
+}
+
+// Bytes returns the body held in memory. It is only complete when the
+// body has not spilled.
+func (b *responseBuffer) Bytes() []byte {
+	return b.mem.Bytes()
+}
+
+// Reset discards the body, including any spilled file.
+func (b *responseBuffer) Reset() {
+	b.removeFile()
+	b.mem.Reset()
+}
+
+// WriteTo copies the whole body to w.
+func (b *responseBuffer) WriteTo(w io.Writer) (int64, error) {
+	if b.file == nil {
+		return b.mem.WriteTo(w)
+	}
+	if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, b.file)
+}
+
+// release removes any spilled file and returns the memory buffer to the
+// pool. b must not be used afterwards.
+func (b *responseBuffer) release() {
+	b.removeFile()
+	putBuffer(b.mem)
+	b.mem = nil
+}
+
+func (b *responseBuffer) removeFile() {
+	if b.file == nil {
+		return
+	}
+	b.file.Close()
+	os.Remove(b.file.Name())
+	b.file = nil
+}
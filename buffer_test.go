@@ -0,0 +1,93 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestResponseBuffer(t *testing.T) {
+	tests := []struct {
+		name         string
+		max          int
+		writes       []string
+		expectSpill  bool
+		expectMemory string
+	}{
+		{name: "unlimited", writes: []string{"abc", "def"}, expectMemory: "abcdef"},
+		{name: "within limit", max: 6, writes: []string{"abc", "def"}, expectMemory: "abcdef"},
+		{name: "spilled", max: 4, writes: []string{"abc", "def", "ghi"}, expectSpill: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newResponseBuffer(tt.max)
+			defer b.release()
+			for _, w := range tt.writes {
+				if _, err := b.Write([]byte(w)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if b.spilled() != tt.expectSpill || string(b.Bytes()) != tt.expectMemory {
+				t.Errorf("unexpected spilled %v memory %q", b.spilled(), b.Bytes())
+			}
+			var out bytes.Buffer
+			b.WriteTo(&out)
+			if out.String() != strings.Join(tt.writes, "") {
+				t.Errorf("unexpected body %q", out.String())
+			}
+		})
+	}
+}
+
+func TestResponseBufferRemovesSpill(t *testing.T) {
+	b := newResponseBuffer(1)
+	b.Write([]byte("ab"))
+	name := b.file.Name()
+	b.Reset()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", name, err)
+	}
+	b.Write([]byte("cd"))
+	name = b.file.Name()
+	b.release()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", name, err)
+	}
+}
+
+func TestServeHTTPMaxBufferBytes(t *testing.T) {
+	captureLog(t)
+	body := strings.Repeat("x", 100)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+		w.Write([]byte(body[:50]))
+		w.Write([]byte(body[50:]))
+	})
+	_, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", MaxBufferBytes: -1}, "header2post")
+	if err == nil || err.Error() != "maxbufferbytes cannot be negative" {
+		t.Errorf("unexpected error %v", err)
+	}
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:       "X-Notify",
+		NotifyUrl:          "https://example.com/notification",
+		ExposeStatusHeader: true,
+		MaxBufferBytes:     10,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Body.String() != body || rec.Header().Get(notifyResultHeader) != resultDelivered {
+		t.Errorf("unexpected response %v %q", rec.Header(), rec.Body.String())
+	}
+}
@@ -0,0 +1,60 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxRequestBodyBytes = 64 << 10
+	defaultRequestBodyField    = "request_body"
+)
+
+// capturedBody is the start of the incoming request body, kept so it can
+// be attached to the notification.
+type capturedBody struct {
+	data      []byte
+	truncated bool
+}
+
+// captureRequestBody reads up to limit bytes of the request body and puts
+// them back in front of the remainder, so the next handler still sees the
+// whole body.
+func captureRequestBody(req *http.Request, limit int) *capturedBody {
+	if req.Body == nil || req.Body == http.NoBody {
+		return &capturedBody{}
+	}
+	head, err := io.ReadAll(io.LimitReader(req.Body, int64(limit)+1))
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), req.Body), req.Body}
+	c := &capturedBody{data: head}
+	if err != nil || len(head) > limit {
+		c.data = head[:min(len(head), limit)]
+		c.truncated = true
+	}
+	return c
+}
+
+// inject adds the captured body to a JSON object payload under field, as
+// JSON when the body is valid JSON and as a string otherwise. Other
+// payloads are returned unchanged.
+func (c *capturedBody) inject(data []byte, field string) []byte {
+	fields := map[string]any{}
+	switch {
+	case !c.truncated && len(c.data) > 0 && json.Valid(c.data):
+		fields[field] = json.RawMessage(c.data)
+	case utf8.Valid(c.data):
+		fields[field] = string(c.data)
+	default:
+		fields[field] = c.data // base64
+	}
+	if c.truncated {
+		fields[field+"_truncated"] = true
+	}
+	return setFields(data, fields)
+}
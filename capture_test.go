@@ -0,0 +1,74 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCapturedBodyInject(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		body    string
+		limit   int
+		expect  string
+	}{
+		{name: "json body", payload: `{"id":1}`, body: `{"qty":2}`, limit: 100, expect: `{"id":1,"request_body":{"qty":2}}`},
+		{name: "text body", payload: `{"id":1}`, body: "a=b", limit: 100, expect: `{"id":1,"request_body":"a=b"}`},
+		{name: "truncated", payload: `{"id":1}`, body: `{"qty":2}`, limit: 4, expect: `{"id":1,"request_body":"{\"qt","request_body_truncated":true}`},
+		{name: "binary body", payload: `{"id":1}`, body: "\xff\xfe", limit: 100, expect: `{"id":1,"request_body":"//4="}`},
+		{name: "empty body", payload: `{"id":1}`, limit: 100, expect: `{"id":1,"request_body":""}`},
+		{name: "non object payload", payload: `[1]`, body: "x", limit: 100, expect: `[1]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			c := captureRequestBody(req, tt.limit)
+			rest, _ := io.ReadAll(req.Body)
+			if string(rest) != tt.body {
+				t.Errorf("expected upstream body %q, got %q", tt.body, rest)
+			}
+			if got := string(c.inject([]byte(tt.payload), "request_body")); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestServeHTTPCaptureRequestBody(t *testing.T) {
+	captureLog(t)
+	var upstream string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		upstream = string(b)
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"event":"created"}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:       "X-Notify",
+		NotifyUrl:          "https://example.com/notification",
+		CaptureRequestBody: true,
+		RequestBodyField:   "request",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notified string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		notified = string(b)
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"sku":"a"}`)))
+
+	if upstream != `{"sku":"a"}` {
+		t.Errorf("upstream body %q", upstream)
+	}
+	if expect := `{"event":"created","request":{"sku":"a"}}`; notified != expect {
+		t.Errorf("expected %s, got %s", expect, notified)
+	}
+}
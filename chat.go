@@ -0,0 +1,83 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+const (
+	formatSlack   = "slack"
+	formatDiscord = "discord"
+	formatTeams   = "teams"
+
+	discordMaxContent = 2000
+)
+
+// chatText renders the message text for chat formats: the chat template
+// when configured, else a "text" or "message" string field, else the
+// payload as an indented JSON code block.
+func (f *payloadFormat) chatText(data []byte) (string, error) {
+	if f.chatTemplate != nil {
+		return f.chatTemplate.render(data)
+	}
+	for _, field := range []string{"text", "message"} {
+		if v, ok := lookupField(data, field); ok {
+			if s, ok := v.(string); ok {
+				return s, nil
+			}
+		}
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, data, "", "  ") != nil {
+		out.Reset()
+		out.Write(data)
+	}
+	return "```\n" + out.String() + "\n```", nil
+}
+
+// encodeChat shapes the payload as an incoming-webhook message for the
+// chat platform.
+func (f *payloadFormat) encodeChat(data []byte) (*encodedPayload, error) {
+	text, err := f.chatText(data)
+	if err != nil {
+		return nil, err
+	}
+	var msg any
+	switch f.name {
+	case formatSlack:
+		msg = map[string]any{
+			"text": text,
+			"blocks": []any{map[string]any{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": text},
+			}},
+		}
+	case formatDiscord:
+		if r := []rune(text); len(r) > discordMaxContent {
+			text = string(r[:discordMaxContent-1]) + "…"
+		}
+		msg = map[string]string{"content": text}
+	case formatTeams:
+		msg = map[string]any{
+			"type": "message",
+			"attachments": []any{map[string]any{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]any{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []any{map[string]any{
+						"type": "TextBlock",
+						"text": text,
+						"wrap": true,
+					}},
+				},
+			}},
+		}
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	return &encodedPayload{body: body, contentType: "application/json"}, nil
+}
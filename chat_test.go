@@ -0,0 +1,69 @@
+package header2post
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChatFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		data   string
+		expect string
+	}{
+		{
+			name:   "slack text field",
+			config: Config{Format: "slack"},
+			data:   `{"text":"deploy done"}`,
+			expect: `{"blocks":[{"text":{"text":"deploy done","type":"mrkdwn"},"type":"section"}],"text":"deploy done"}`,
+		},
+		{
+			name:   "discord template",
+			config: Config{Format: "discord", ChatTemplate: "order {{.Payload.id}} failed"},
+			data:   `{"id":42}`,
+			expect: `{"content":"order 42 failed"}`,
+		},
+		{
+			name:   "discord code block",
+			config: Config{Format: "discord"},
+			data:   `{"id":42}`,
+			expect: `{"content":"` + "```" + `\n{\n  \"id\": 42\n}\n` + "```" + `"}`,
+		},
+		{
+			name:   "teams message field",
+			config: Config{Format: "teams"},
+			data:   `{"message":"disk full"}`,
+			expect: `{"attachments":[{"content":{"$schema":"http://adaptivecards.io/schemas/adaptive-card.json","body":[{"text":"disk full","type":"TextBlock","wrap":true}],"type":"AdaptiveCard","version":"1.4"},"contentType":"application/vnd.microsoft.card.adaptive"}],"type":"message"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newPayloadFormat(&tt.config, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.batchable() {
+				t.Errorf("chat formats must not be batchable")
+			}
+			p, err := f.encode([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(p.body) != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, p.body)
+			}
+		})
+	}
+}
+
+func TestDiscordTruncation(t *testing.T) {
+	f, _ := newPayloadFormat(&Config{Format: "discord"}, "test")
+	p, err := f.encode([]byte(`{"text":"` + strings.Repeat("a", 3000) + `"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.body) > discordMaxContent+20 {
+		t.Errorf("discord content not truncated: %d bytes", len(p.body))
+	}
+}
@@ -0,0 +1,30 @@
+package header2post
+
+import (
+	"net/http"
+)
+
+// clientCertIdentity describes the TLS client certificate of req, or
+// returns nil when the client presented none.
+func clientCertIdentity(req *http.Request) map[string]any {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := req.TLS.PeerCertificates[0]
+	sans := make([]string, 0, len(cert.DNSNames)+len(cert.EmailAddresses)+len(cert.IPAddresses)+len(cert.URIs))
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	return map[string]any{
+		"subject":     cert.Subject.String(),
+		"common_name": cert.Subject.CommonName,
+		"issuer":      cert.Issuer.String(),
+		"serial":      cert.SerialNumber.String(),
+		"sans":        sans,
+	}
+}
@@ -0,0 +1,79 @@
+package header2post
+
+import (
+	"context"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testClientCert(t *testing.T) *x509.Certificate {
+	t.Helper()
+	public, private, _ := ed25519.GenerateKey(cryptorand.Reader)
+	spiffe, _ := url.Parse("spiffe://example.org/billing")
+	template := &x509.Certificate{
+		SerialNumber:   big.NewInt(42),
+		Subject:        pkix.Name{CommonName: "billing", Organization: []string{"Example"}},
+		DNSNames:       []string{"billing.example.org"},
+		EmailAddresses: []string{"ops@example.org"},
+		IPAddresses:    []net.IP{net.ParseIP("10.0.0.7")},
+		URIs:           []*url.URL{spiffe},
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, public, private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestServeHTTPClientCert(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:    "X-Notify",
+		NotifyUrl:       "https://example.com/notification",
+		ClientCertField: "caller",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		body = string(b)
+		return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if body != `{"id":"e1"}` {
+		t.Errorf("expected no identity without a client certificate, got %q", body)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{testClientCert(t)}}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	expect := `{"caller":{"common_name":"billing","issuer":"CN=billing,O=Example","sans":["billing.example.org","ops@example.org","10.0.0.7","spiffe://example.org/billing"],"serial":"42","subject":"CN=billing,O=Example"},"id":"e1"}`
+	if body != expect {
+		t.Errorf("expected %s, got %s", expect, body)
+	}
+}
@@ -0,0 +1,40 @@
+package header2post
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientIP resolves the IP of the end user behind depth trusted proxies:
+// the X-Forwarded-For entry depth hops left of the peer address, or
+// X-Real-IP when the trusted proxies sent no X-Forwarded-For.
+func clientIP(req *http.Request, depth int) string {
+	peer := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if depth == 0 {
+		return peer
+	}
+	var chain []string
+	for _, v := range req.Header.Values("X-Forwarded-For") {
+		for _, entry := range strings.Split(v, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				chain = append(chain, entry)
+			}
+		}
+	}
+	if len(chain) == 0 {
+		if real := strings.TrimSpace(req.Header.Get("X-Real-Ip")); real != "" {
+			return real
+		}
+		return peer
+	}
+	chain = append(chain, peer)
+	i := len(chain) - 1 - depth
+	if i < 0 {
+		i = 0
+	}
+	return chain[i]
+}
@@ -0,0 +1,72 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		depth  int
+		expect string
+	}{
+		{name: "peer", header: http.Header{"X-Forwarded-For": {"203.0.113.9"}}, expect: "192.0.2.1"},
+		{name: "one proxy", header: http.Header{"X-Forwarded-For": {"203.0.113.9"}}, depth: 1, expect: "203.0.113.9"},
+		{name: "spoofed entry", header: http.Header{"X-Forwarded-For": {"10.6.6.6, 203.0.113.9"}}, depth: 1, expect: "203.0.113.9"},
+		{name: "two proxies", header: http.Header{"X-Forwarded-For": {"203.0.113.9, 198.51.100.7"}}, depth: 2, expect: "203.0.113.9"},
+		{name: "repeated headers", header: http.Header{"X-Forwarded-For": {"203.0.113.9", "198.51.100.7"}}, depth: 2, expect: "203.0.113.9"},
+		{name: "deeper than chain", header: http.Header{"X-Forwarded-For": {"203.0.113.9"}}, depth: 5, expect: "203.0.113.9"},
+		{name: "real ip", header: http.Header{"X-Real-Ip": {"203.0.113.9"}}, depth: 1, expect: "203.0.113.9"},
+		{name: "nothing forwarded", header: http.Header{}, depth: 1, expect: "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header = tt.header
+			if got := clientIP(req, tt.depth); got != tt.expect {
+				t.Errorf("expected %q, got %q", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestServeHTTPClientIP(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	_, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", TrustedProxyDepth: -1}, "header2post")
+	if err == nil || err.Error() != "trustedproxydepth cannot be negative" {
+		t.Errorf("unexpected error %v", err)
+	}
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:      "X-Notify",
+		NotifyUrl:         "https://example.com/notification",
+		ClientIpField:     "client_ip",
+		ClientIpHeader:    "x-client-ip",
+		TrustedProxyDepth: 1,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body, header string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		body, header = string(b), req.Header.Get("X-Client-Ip")
+		return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if body != `{"client_ip":"203.0.113.9","id":"e1"}` || header != "203.0.113.9" {
+		t.Errorf("unexpected notification %q with header %q", body, header)
+	}
+}
@@ -0,0 +1,166 @@
+// Command notify-receiver is a notify url for local development: it
+// pretty-prints the notifications it receives, verifies their signatures
+// and can answer with failures, throttling or delays to exercise retries,
+// spooling and timeouts.
+//
+//	go run ./cmd/notify-receiver -addr 127.0.0.1:8000 -fail 0.2 -delay 2s
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arwoosa/header2post"
+)
+
+// options configures the receiver.
+type options struct {
+	status        int
+	fail          float64
+	failStatus    int
+	throttle      float64
+	retryAfter    time.Duration
+	delay         time.Duration
+	publicKey     ed25519.PublicKey
+	tolerance     time.Duration
+	eventIdHeader string
+}
+
+func main() {
+	var opts options
+	addr := flag.String("addr", "127.0.0.1:8000", "listen address")
+	flag.IntVar(&opts.status, "status", http.StatusAccepted, "status of successful replies")
+	flag.Float64Var(&opts.fail, "fail", 0, "fraction of requests answered with -fail-status")
+	flag.IntVar(&opts.failStatus, "fail-status", http.StatusInternalServerError, "status of failed replies")
+	flag.Float64Var(&opts.throttle, "throttle", 0, "fraction of requests answered 429 with Retry-After")
+	flag.DurationVar(&opts.retryAfter, "retry-after", time.Second, "Retry-After of throttled replies")
+	flag.DurationVar(&opts.delay, "delay", 0, "wait before every reply")
+	flag.StringVar(&opts.eventIdHeader, "event-id-header", "X-Notify-Event-Id", "header whose value is echoed back, acknowledging the event")
+	publicKey := flag.String("public-key", "", "PEM Ed25519 public key verifying X-Notify-Signature; unsigned or invalid requests are answered 401")
+	flag.DurationVar(&opts.tolerance, "signature-tolerance", header2post.DefaultSignatureTolerance, "oldest X-Notify-Timestamp a signed request may carry")
+	flag.Parse()
+
+	if *publicKey != "" {
+		key, err := readPublicKey(*publicKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts.publicKey = key
+	}
+	log.Printf("listening on http://%s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, newReceiver(opts, os.Stdout, rand.Float64)))
+}
+
+func readPublicKey(path string) (ed25519.PublicKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read public key: %w", err)
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("invalid public key: no PEM block")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("invalid public key: %T is not an ed25519 key", key)
+	}
+	return edKey, nil
+}
+
+// newReceiver returns the receiver handler printing every request to out.
+// random draws the failures and throttled replies.
+func newReceiver(opts options, out io.Writer, random func() float64) http.Handler {
+	var mu sync.Mutex
+	var count int
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var verifyErr error
+		if opts.publicKey != nil {
+			verifyErr = verify(r.Header, body, opts.publicKey, opts.tolerance)
+		}
+		status, note := opts.status, ""
+		switch draw := random(); {
+		case verifyErr != nil:
+			status, note = http.StatusUnauthorized, verifyErr.Error()
+		case draw < opts.throttle:
+			status, note = http.StatusTooManyRequests, "simulated throttling"
+			w.Header().Set("Retry-After", strconv.Itoa(int(opts.retryAfter.Seconds())))
+		case draw < opts.throttle+opts.fail:
+			status, note = opts.failStatus, "simulated failure"
+		case opts.publicKey != nil:
+			note = "signature verified"
+		}
+
+		mu.Lock()
+		count++
+		printRequest(out, count, r, body, status, note)
+		mu.Unlock()
+
+		if opts.delay > 0 {
+			time.Sleep(opts.delay)
+		}
+		if id := r.Header.Get(opts.eventIdHeader); id != "" && opts.eventIdHeader != "" {
+			w.Header().Set(opts.eventIdHeader, id)
+		}
+		w.WriteHeader(status)
+	})
+}
+
+func verify(h http.Header, body []byte, key ed25519.PublicKey, tolerance time.Duration) error {
+	if h.Get("X-Notify-Signature") == "" {
+		return errors.New("missing signature")
+	}
+	return header2post.VerifySignature(h, body, key, tolerance)
+}
+
+// printRequest writes request number n, its headers sorted by name, its
+// body indented when it is JSON, and the reply status.
+func printRequest(out io.Writer, n int, r *http.Request, body []byte, status int, note string) {
+	fmt.Fprintf(out, "--- #%d %s %s %s\n", n, time.Now().Format(time.TimeOnly), r.Method, r.URL.RequestURI())
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "%s: %s\n", name, strings.Join(r.Header[name], ", "))
+	}
+	fmt.Fprintln(out)
+	var indented bytes.Buffer
+	if json.Indent(&indented, body, "", "  ") == nil {
+		body = indented.Bytes()
+	}
+	out.Write(body)
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		fmt.Fprintln(out)
+	}
+	reply := fmt.Sprintf("=> %d %s", status, http.StatusText(status))
+	if note != "" {
+		reply += " (" + note + ")"
+	}
+	fmt.Fprintln(out, reply)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReceiver(t *testing.T) {
+	tests := []struct {
+		name         string
+		opts         options
+		draw         float64
+		expectStatus int
+		expectOutput string
+	}{
+		{name: "accepted", opts: options{status: http.StatusAccepted}, draw: 0.5, expectStatus: http.StatusAccepted, expectOutput: "=> 202 Accepted\n"},
+		{name: "failure", opts: options{status: http.StatusAccepted, fail: 0.6, failStatus: http.StatusInternalServerError}, draw: 0.5, expectStatus: http.StatusInternalServerError, expectOutput: "=> 500 Internal Server Error (simulated failure)\n"},
+		{name: "throttled", opts: options{status: http.StatusAccepted, throttle: 0.6, retryAfter: 2 * time.Second}, draw: 0.5, expectStatus: http.StatusTooManyRequests, expectOutput: "(simulated throttling)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			h := newReceiver(tt.opts, &out, func() float64 { return tt.draw })
+			req := httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(`{"a":1}`))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.expectStatus {
+				t.Errorf("expected status %d, got %d", tt.expectStatus, rec.Code)
+			}
+			if !strings.Contains(out.String(), "{\n  \"a\": 1\n}\n") || !strings.Contains(out.String(), "Content-Type: application/json\n") {
+				t.Errorf("request not printed:\n%s", out.String())
+			}
+			if !strings.Contains(out.String(), tt.expectOutput) {
+				t.Errorf("expected %q in:\n%s", tt.expectOutput, out.String())
+			}
+			if tt.opts.throttle > 0 && rec.Header().Get("Retry-After") != "2" {
+				t.Errorf("expected Retry-After 2, got %q", rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestReceiverSignature(t *testing.T) {
+	public, private, _ := ed25519.GenerateKey(cryptorand.Reader)
+	body := `{"a":1}`
+	sign := func(req *http.Request, age time.Duration) {
+		timestamp, nonce := strconv.FormatInt(time.Now().Add(-age).Unix(), 10), "n0nce"
+		req.Header.Set("X-Notify-Timestamp", timestamp)
+		req.Header.Set("X-Notify-Nonce", nonce)
+		req.Header.Set("X-Notify-Signature", base64.StdEncoding.EncodeToString(ed25519.Sign(private, []byte(timestamp+"."+nonce+"."+body))))
+	}
+	for _, tt := range []struct {
+		name      string
+		signed    bool
+		age       time.Duration
+		tolerance time.Duration
+		expect    int
+	}{
+		{name: "signed", signed: true, expect: http.StatusAccepted},
+		{name: "unsigned", expect: http.StatusUnauthorized},
+		{name: "within tolerance", signed: true, age: 2 * time.Minute, tolerance: 5 * time.Minute, expect: http.StatusAccepted},
+		{name: "outside tolerance", signed: true, age: 2 * time.Minute, tolerance: time.Minute, expect: http.StatusUnauthorized},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newReceiver(options{status: http.StatusAccepted, publicKey: public, tolerance: tt.tolerance}, &bytes.Buffer{}, func() float64 { return 1 })
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			if tt.signed {
+				sign(req, tt.age)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.expect {
+				t.Errorf("expected status %d, got %d", tt.expect, rec.Code)
+			}
+		})
+	}
+}
+
+func TestReceiverEventIdAck(t *testing.T) {
+	h := newReceiver(options{status: http.StatusAccepted, eventIdHeader: "X-Notify-Event-Id"}, &bytes.Buffer{}, func() float64 { return 1 })
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	req.Header.Set("X-Notify-Event-Id", "e1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Notify-Event-Id"); got != "e1" {
+		t.Errorf("expected the event id echoed, got %q", got)
+	}
+}
@@ -0,0 +1,219 @@
+package header2post
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sync"
+)
+
+const (
+	codecBase64   = "base64"
+	codecGzip     = "gzip"
+	codecJSON     = "json"
+	codecForm     = "form"
+	codecProtobuf = "protobuf"
+)
+
+// PayloadCodec converts payloads to and from a wire representation.
+// HeaderEncoding selects the codec whose Decode turns the notify header
+// value into the payload; BodyEncoding selects the codec whose Encode
+// renders the payload as a notification body.
+type PayloadCodec interface {
+	Decode(value string) ([]byte, error)
+	Encode(data []byte) (body []byte, contentType string, err error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]PayloadCodec{
+		codecBase64:   base64Codec{},
+		codecGzip:     gzipCodec{},
+		codecJSON:     jsonCodec{},
+		codecForm:     formCodec{},
+		codecProtobuf: protobufCodec{},
+	}
+)
+
+// RegisterPayloadCodec makes codec available under name for the
+// HeaderEncoding and BodyEncoding options, replacing any codec already
+// registered with that name.
+func RegisterPayloadCodec(name string, codec PayloadCodec) {
+	if name == "" || codec == nil {
+		panic("header2post: RegisterPayloadCodec requires a name and a codec")
+	}
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[name] = codec
+}
+
+func lookupPayloadCodec(name string) (PayloadCodec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	codec, ok := codecs[name]
+	return codec, ok
+}
+
+// base64Codec is the default header encoding.
+type base64Codec struct{}
+
+func (base64Codec) Decode(value string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(value)
+}
+
+func (base64Codec) Encode(data []byte) ([]byte, string, error) {
+	return []byte(base64.StdEncoding.EncodeToString(data)), "text/plain", nil
+}
+
+// gzipCodec carries gzip-compressed payloads, base64 encoded in headers.
+type gzipCodec struct{}
+
+func (gzipCodec) Decode(value string) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (gzipCodec) Encode(data []byte) ([]byte, string, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, "", err
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return bytes.Clone(buf.Bytes()), "application/gzip", nil
+}
+
+// jsonCodec passes payloads through unchanged; it is the default body
+// encoding.
+type jsonCodec struct{}
+
+func (jsonCodec) Decode(value string) ([]byte, error) {
+	if !json.Valid([]byte(value)) {
+		return nil, errors.New("header value is not valid json")
+	}
+	return []byte(value), nil
+}
+
+func (jsonCodec) Encode(data []byte) ([]byte, string, error) {
+	return data, "application/json", nil
+}
+
+// formCodec maps a flat JSON object to application/x-www-form-urlencoded.
+// Nested values are encoded as JSON text.
+type formCodec struct{}
+
+func (formCodec) Decode(value string) ([]byte, error) {
+	form, err := url.ParseQuery(value)
+	if err != nil {
+		return nil, err
+	}
+	obj := make(map[string]any, len(form))
+	for k, v := range form {
+		if len(v) == 1 {
+			obj[k] = v[0]
+		} else {
+			obj[k] = v
+		}
+	}
+	return json.Marshal(obj)
+}
+
+func (formCodec) Encode(data []byte) ([]byte, string, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, "", errors.New("form encoding requires a json object payload")
+	}
+	form := url.Values{}
+	for k, raw := range obj {
+		var s string
+		switch {
+		case json.Unmarshal(raw, &s) == nil:
+		case string(raw) == "null":
+		default:
+			var compact bytes.Buffer
+			json.Compact(&compact, raw)
+			s = compact.String()
+		}
+		form.Set(k, s)
+	}
+	return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+}
+
+// protobufCodec wraps payloads in the NotifyRequest message from
+// proto/notify.proto; header values are base64 encoded messages.
+type protobufCodec struct{}
+
+func (protobufCodec) Decode(value string) ([]byte, error) {
+	msg, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return protoField(msg, 1)
+}
+
+func (protobufCodec) Encode(data []byte) ([]byte, string, error) {
+	contentType := "application/json"
+	if !json.Valid(data) {
+		contentType = "application/octet-stream"
+	}
+	return grpcNotifyRequest(Notification{Body: data, ContentType: contentType}), "application/x-protobuf", nil
+}
+
+// protoField returns the last occurrence of a length-delimited field,
+// skipping every other field.
+func protoField(msg []byte, field int) ([]byte, error) {
+	var out []byte
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, errors.New("malformed protobuf tag")
+		}
+		msg = msg[n:]
+		switch tag & 7 {
+		case 0:
+			_, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return nil, errors.New("malformed protobuf varint")
+			}
+			msg = msg[n:]
+		case 1, 5:
+			size := 8
+			if tag&7 == 5 {
+				size = 4
+			}
+			if len(msg) < size {
+				return nil, io.ErrUnexpectedEOF
+			}
+			msg = msg[size:]
+		case 2:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < size {
+				return nil, io.ErrUnexpectedEOF
+			}
+			if int(tag>>3) == field {
+				out = msg[n : n+int(size)]
+			}
+			msg = msg[n+int(size):]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", tag&7)
+		}
+	}
+	return out, nil
+}
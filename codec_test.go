@@ -0,0 +1,162 @@
+package header2post
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPayloadCodecs(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(`{"id":1}`))
+	w.Close()
+	proto := grpcNotifyRequest(Notification{Body: []byte(`{"id":1}`), ContentType: "application/json"})
+
+	tests := []struct {
+		name      string
+		codec     string
+		value     string
+		expect    string
+		expectErr bool
+	}{
+		{name: "base64", codec: codecBase64, value: base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)), expect: `{"id":1}`},
+		{name: "base64 invalid", codec: codecBase64, value: "%%%", expectErr: true},
+		{name: "gzip", codec: codecGzip, value: base64.StdEncoding.EncodeToString(gz.Bytes()), expect: `{"id":1}`},
+		{name: "gzip not compressed", codec: codecGzip, value: base64.StdEncoding.EncodeToString([]byte("plain")), expectErr: true},
+		{name: "json", codec: codecJSON, value: `{"id":1}`, expect: `{"id":1}`},
+		{name: "json invalid", codec: codecJSON, value: `{id`, expectErr: true},
+		{name: "form", codec: codecForm, value: "id=1&tag=a&tag=b", expect: `{"id":"1","tag":["a","b"]}`},
+		{name: "protobuf", codec: codecProtobuf, value: base64.StdEncoding.EncodeToString(proto), expect: `{"id":1}`},
+		{name: "protobuf truncated", codec: codecProtobuf, value: base64.StdEncoding.EncodeToString(proto[:4]), expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, ok := lookupPayloadCodec(tt.codec)
+			if !ok {
+				t.Fatalf("codec %q not registered", tt.codec)
+			}
+			got, err := codec.Decode(tt.value)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestPayloadCodecEncode(t *testing.T) {
+	tests := []struct {
+		codec       string
+		data        string
+		expect      string
+		contentType string
+		expectErr   bool
+	}{
+		{codec: codecJSON, data: `{"id":1}`, expect: `{"id":1}`, contentType: "application/json"},
+		{codec: codecBase64, data: "hi", expect: "aGk=", contentType: "text/plain"},
+		{codec: codecForm, data: `{"id":1,"name":"a b","tags":["x"],"none":null}`, expect: "id=1&name=a+b&none=&tags=%5B%22x%22%5D", contentType: "application/x-www-form-urlencoded"},
+		{codec: codecForm, data: `[1,2]`, expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.codec, func(t *testing.T) {
+			codec, _ := lookupPayloadCodec(tt.codec)
+			body, contentType, err := codec.Encode([]byte(tt.data))
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got %q", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.expect || contentType != tt.contentType {
+				t.Errorf("expected %q (%s), got %q (%s)", tt.expect, tt.contentType, body, contentType)
+			}
+		})
+	}
+
+	for _, name := range []string{codecGzip, codecProtobuf} {
+		codec, _ := lookupPayloadCodec(name)
+		body, _, err := codec.Encode([]byte(`{"id":1}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := codec.Decode(base64.StdEncoding.EncodeToString(body))
+		if err != nil || string(got) != `{"id":1}` {
+			t.Errorf("%s: round trip returned %q, %v", name, got, err)
+		}
+	}
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Decode(value string) ([]byte, error) {
+	return []byte(strings.ToLower(value)), nil
+}
+
+func (upperCodec) Encode(data []byte) ([]byte, string, error) {
+	return bytes.ToUpper(data), "text/plain", nil
+}
+
+func TestServeHTTPPayloadCodec(t *testing.T) {
+	captureLog(t)
+	RegisterPayloadCodec("upper", upperCodec{})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", "ID=1&NAME=A")
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:   "X-Notify",
+		NotifyUrl:      "https://example.com/notification",
+		HeaderEncoding: "upper",
+		BodyEncoding:   "upper",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body, contentType string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		body, contentType = string(b), req.Header.Get("Content-Type")
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if body != "ID=1&NAME=A" || contentType != "text/plain" {
+		t.Errorf("unexpected body %q (%s)", body, contentType)
+	}
+
+	for _, tt := range []struct {
+		config    Config
+		expectErr error
+	}{
+		{config: Config{HeaderEncoding: "rot13"}, expectErr: errors.New(`unsupported headerencoding: "rot13"`)},
+		{config: Config{BodyEncoding: "rot13"}, expectErr: errors.New(`unsupported bodyencoding: "rot13"`)},
+		{config: Config{BodyEncoding: codecForm, Format: formatSlack}, expectErr: errors.New(`bodyencoding cannot be combined with format "slack"`)},
+		{config: Config{BodyEncoding: codecForm, BatchMaxSize: 2}, expectErr: errors.New(`bodyencoding "form" cannot be combined with batching`)},
+	} {
+		config := tt.config
+		config.NotifyHeader = "X-Notify"
+		config.NotifyUrl = "https://example.com/notification"
+		_, err := New(context.Background(), next, &config, "header2post")
+		if err == nil || err.Error() != tt.expectErr.Error() {
+			t.Errorf("expected error %v, got %v", tt.expectErr, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// condition is a compiled Condition expression deciding whether a
+// payload is notified.
+type condition struct {
+	eval evalFunc
+}
+
+func newCondition(expr string) (*condition, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	eval, err := compileExpr(expr, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition: %w", err)
+	}
+	return &condition{eval: eval}, nil
+}
+
+// match evaluates the condition against the payload and the exchange.
+// It sees payload, the decoded JSON or the raw string when it is not
+// JSON, request (method, host, path, query, remote_addr, header) and
+// response (status, header), with lower case header names.
+func (c *condition) match(data []byte, ex *exchange) (bool, error) {
+	payload, err := decodeExprValue(data)
+	if err != nil {
+		payload = string(data)
+	}
+	v, err := c.eval(map[string]any{
+		"payload": payload,
+		"request": map[string]any{
+			"method":      ex.req.Method,
+			"host":        ex.req.Host,
+			"path":        ex.req.URL.Path,
+			"query":       ex.req.URL.RawQuery,
+			"remote_addr": ex.req.RemoteAddr,
+			"header":      exprHeader(ex.req.Header),
+		},
+		"response": map[string]any{
+			"status": json.Number(strconv.Itoa(ex.status)),
+			"header": exprHeader(ex.respHeader),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return exprTruthy(v), nil
+}
+
+// exprHeader exposes the first value of each header by lower case name.
+func exprHeader(h http.Header) map[string]any {
+	out := make(map[string]any, len(h))
+	for k, v := range h {
+		if len(v) > 0 {
+			out[strings.ToLower(k)] = v[0]
+		}
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConditionMatch(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/orders?dry=1", nil)
+	req.Header.Set("X-Tenant", "acme")
+	ex := &exchange{req: req, status: http.StatusCreated, respHeader: http.Header{"Content-Type": {"application/json"}}}
+	payload := `{"amount":1500,"currency":"EUR","tags":["vip"],"refund":false}`
+	tests := []struct {
+		expr      string
+		payload   string
+		expect    bool
+		expectErr string
+	}{
+		{expr: "payload.amount > 1000 && response.status == 201", expect: true},
+		{expr: "payload.amount > 1000 && response.status == 200"},
+		{expr: "payload.amount >= 1500 || payload.currency == \"USD\"", expect: true},
+		{expr: "payload.amount < 10 || payload.currency != \"EUR\""},
+		{expr: `payload.tags[0] == "vip"`, expect: true},
+		{expr: `payload.tags == ["vip"]`, expect: true},
+		{expr: "!payload.refund", expect: true},
+		{expr: "payload.missing"},
+		{expr: `request.method == "POST" && request.path == "/orders" && request.query == "dry=1"`, expect: true},
+		{expr: `request.header."x-tenant" == "acme"`, expect: true},
+		{expr: `response.header["content-type"] == "application/json"`, expect: true},
+		{expr: `payload == "plain"`, payload: "plain", expect: true},
+		{expr: "payload.amount > 1000 * 2 || (payload.amount - 500) * 2 == 2000", expect: true},
+		{expr: `payload.amount > "100"`, expectErr: "cannot compare number and string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := newCondition(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := payload
+			if tt.payload != "" {
+				data = tt.payload
+			}
+			ok, err := c.match([]byte(data), ex)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Errorf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.expect {
+				t.Errorf("expected %v, got %v", tt.expect, ok)
+			}
+		})
+	}
+}
+
+func TestNewConditionErrors(t *testing.T) {
+	if c, err := newCondition(""); c != nil || err != nil {
+		t.Errorf("expected no condition, got %v %v", c, err)
+	}
+	for expr, expect := range map[string]string{
+		"payload.amount >":        "invalid condition: unexpected end of expression",
+		"payload.amount = 1":      `invalid condition: unexpected character '='`,
+		"payload.a && || payload": `invalid condition: unexpected "||"`,
+	} {
+		if _, err := newCondition(expr); err == nil || err.Error() != expect {
+			t.Errorf("%s: expected error %q, got %v", expr, expect, err)
+		}
+	}
+}
+
+func TestServeHTTPCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		status     int
+		expectSent bool
+	}{
+		{name: "met", payload: `{"amount":1500}`, status: http.StatusCreated, expectSent: true},
+		{name: "amount too low", payload: `{"amount":10}`, status: http.StatusCreated},
+		{name: "wrong status", payload: `{"amount":1500}`, status: http.StatusOK},
+		{name: "evaluation error", payload: `{"amount":"lots"}`, status: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.payload)))
+				w.WriteHeader(tt.status)
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:       "X-Notify",
+				NotifyUrl:          "https://example.com/notification",
+				Condition:          "payload.amount > 1000 && response.status == 201",
+				ExposeStatusHeader: true,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := false
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				sent = true
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+			if sent != tt.expectSent {
+				t.Errorf("expected sent %v, got %v", tt.expectSent, sent)
+			}
+			if !tt.expectSent && w.Header().Get("X-Notify-Result") != resultSkipped {
+				t.Errorf("expected skipped result, got %q", w.Header().Get("X-Notify-Result"))
+			}
+		})
+	}
+}
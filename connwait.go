@@ -0,0 +1,53 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+var errConnWait = errors.New("no receiver connection available within connwaittimeout")
+
+// connWaitTransport fails a request that waited longer than wait for one
+// of the MaxConnsPerReceiver connections, instead of queueing it for the
+// whole notify timeout.
+type connWaitTransport struct {
+	base http.RoundTripper
+	wait time.Duration
+}
+
+func (t *connWaitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(t.wait, func() { cancel(errConnWait) })
+	trace := &httptrace.ClientTrace{
+		GotConn: func(httptrace.GotConnInfo) { timer.Stop() },
+	}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(ctx, trace)))
+	if err != nil {
+		timer.Stop()
+		cause := context.Cause(ctx)
+		cancel(nil)
+		if errors.Is(cause, errConnWait) {
+			return nil, errConnWait
+		}
+		return nil, err
+	}
+	// the context must outlive the response body
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: func() { cancel(nil) }}
+	return resp, nil
+}
+
+// cancelBody releases the request context once the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,63 @@
+package header2post
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestConnWaitTransport(t *testing.T) {
+	release := make(chan struct{})
+	accepted := make(chan struct{}, 2)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted <- struct{}{}
+		<-release
+	}))
+	defer srv.Close()
+
+	client, err := newHTTPClient(&Config{MaxConnsPerReceiver: 1, ConnWaitTimeout: "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		first <- err
+	}()
+	<-accepted
+
+	// the only connection is busy
+	if _, err := client.Get(srv.URL); !errors.Is(err, errConnWait) {
+		t.Errorf("expected %v, got %v", errConnWait, err)
+	}
+	close(release)
+	if err := <-first; err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewHTTPClientConnLimitErrors(t *testing.T) {
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{MaxConnsPerReceiver: -1}, expectErr: "maxconnsperreceiver cannot be negative"},
+		{config: Config{ConnWaitTimeout: "1s"}, expectErr: "connwaittimeout requires maxconnsperreceiver"},
+		{config: Config{MaxConnsPerReceiver: 1, ConnWaitTimeout: "soon"}, expectErr: `invalid connwaittimeout: "soon"`},
+	}
+	for _, tt := range tests {
+		_, err := newHTTPClient(&tt.config)
+		if err == nil || err.Error() != tt.expectErr {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package header2post
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// defaultDecodedBodyBytes bounds a decompressed response body searched by
+// BodyPattern or BodyPointer when MaxBufferBytes is not set.
+const defaultDecodedBodyBytes = 1 << 20
+
+// decodeContentEncoding undoes the Content-Encoding encoding of body,
+// keeping at most max decoded bytes. complete is false when the decoded
+// body was cut at max or body itself was cut short, in which case the
+// bytes decoded so far are returned. Encodings other than gzip and
+// deflate, such as br, are an error.
+func decodeContentEncoding(encoding string, body []byte, max int) (decoded []byte, complete bool, err error) {
+	codings := strings.Split(encoding, ",")
+	decoded, complete = body, true
+	// the last coding listed was applied last
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		var r io.Reader
+		switch coding {
+		case "", "identity":
+			continue
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(bytes.NewReader(decoded))
+		case "deflate":
+			// deflate is zlib wrapped, though some servers send it raw
+			if r, err = zlib.NewReader(bytes.NewReader(decoded)); err != nil {
+				r, err = flate.NewReader(bytes.NewReader(decoded)), nil
+			}
+		default:
+			return nil, false, fmt.Errorf("unsupported content encoding: %q", coding)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("decode %s body: %w", coding, err)
+		}
+		out, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+		switch {
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			complete = false
+		case err != nil && len(out) == 0:
+			return nil, false, fmt.Errorf("decode %s body: %w", coding, err)
+		case err != nil:
+			complete = false
+		}
+		decoded = out
+	}
+	if len(decoded) > max {
+		decoded, complete = decoded[:max], false
+	}
+	return decoded, complete, nil
+}
+
+// contentEncoding returns the Content-Encoding of h, or "" when the body
+// is not encoded.
+func contentEncoding(h http.Header) string {
+	encoding := strings.TrimSpace(h.Get("Content-Encoding"))
+	if strings.EqualFold(encoding, "identity") {
+		return ""
+	}
+	return encoding
+}
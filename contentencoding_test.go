@@ -0,0 +1,87 @@
+package header2post
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// gzipString returns s gzip compressed.
+func gzipString(t *testing.T, s string) string {
+	t.Helper()
+	return compressString(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, s)
+}
+
+func compressString(t *testing.T, newWriter func(io.Writer) io.WriteCloser, s string) string {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	w := newWriter(buf)
+	if _, err := io.WriteString(w, s); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
+	text := "upstream down"
+	zlibbed := compressString(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, text)
+	raw := compressString(t, func(w io.Writer) io.WriteCloser {
+		fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return fw
+	}, text)
+	gzipped := gzipString(t, text)
+	tests := []struct {
+		name           string
+		encoding       string
+		body           string
+		max            int
+		expect         string
+		expectComplete bool
+		expectErr      string
+	}{
+		{name: "gzip", encoding: "gzip", body: gzipped, max: 100, expect: text, expectComplete: true},
+		{name: "x-gzip", encoding: "X-Gzip", body: gzipped, max: 100, expect: text, expectComplete: true},
+		{name: "deflate", encoding: "deflate", body: zlibbed, max: 100, expect: text, expectComplete: true},
+		{name: "raw deflate", encoding: "deflate", body: raw, max: 100, expect: text, expectComplete: true},
+		{name: "layered", encoding: "deflate, gzip", body: gzipString(t, zlibbed), max: 100, expect: text, expectComplete: true},
+		{name: "identity", encoding: "identity", body: text, max: 100, expect: text, expectComplete: true},
+		{name: "limited", encoding: "gzip", body: gzipped, max: 8, expect: text[:8]},
+		{name: "cut short", encoding: "gzip", body: gzipped[:len(gzipped)-8], max: 100, expect: text},
+		{name: "brotli", encoding: "br", body: "\x8b\x06\x80", max: 100, expectErr: `unsupported content encoding: "br"`},
+		{name: "corrupt", encoding: "gzip", body: "not gzip", max: 100, expectErr: "decode gzip body: unexpected EOF"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, complete, err := decodeContentEncoding(tt.encoding, []byte(tt.body), tt.max)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expect || complete != tt.expectComplete {
+				t.Errorf("expected %q complete %v, got %q complete %v", tt.expect, tt.expectComplete, got, complete)
+			}
+		})
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	for value, expect := range map[string]string{"": "", "identity": "", " gzip ": "gzip", "br": "br"} {
+		h := http.Header{}
+		h.Set("Content-Encoding", value)
+		if got := contentEncoding(h); got != expect {
+			t.Errorf("%q: expected %q, got %q", value, expect, got)
+		}
+	}
+}
@@ -0,0 +1,6 @@
+package header2post
+
+const (
+	defaultCorrelationIdHeader = "X-Correlation-Id"
+	defaultCorrelationIdField  = "correlation_id"
+)
@@ -0,0 +1,85 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPCorrelationId(t *testing.T) {
+	defer func() { generateID = newUUID }()
+	generateID = func() string { return "11111111-2222-4333-8444-555555555555" }
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"event":"created"}`))
+
+	tests := []struct {
+		name   string
+		config Config
+		header string
+		field  string
+	}{
+		{
+			name:   "response trigger",
+			config: Config{CorrelationId: true},
+			header: "X-Correlation-Id",
+			field:  "correlation_id",
+		},
+		{
+			name:   "request trigger",
+			config: Config{CorrelationId: true, TriggerSource: triggerRequest},
+			header: "X-Correlation-Id",
+			field:  "correlation_id",
+		},
+		{
+			name:   "custom names",
+			config: Config{CorrelationId: true, CorrelationIdHeader: "X-Trace-Ref", CorrelationIdField: "trace_ref"},
+			header: "X-Trace-Ref",
+			field:  "trace_ref",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logBuf := captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", payload)
+				w.Write([]byte("ok"))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var notified *http.Request
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				notified = req
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("X-Notify", payload)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			id := generateID()
+			if got := w.Header().Get(tt.header); got != id {
+				t.Errorf("expected client header %q, got %q", id, got)
+			}
+			if got := notified.Header.Get(tt.header); got != id {
+				t.Errorf("expected notify header %q, got %q", id, got)
+			}
+			if got, _ := fieldString([]byte(body), tt.field); got != id {
+				t.Errorf("expected %s %q in body %s", tt.field, id, body)
+			}
+			if !strings.Contains(logBuf.String(), `"correlation_ids":["`+id+`"]`) {
+				t.Errorf("correlation id missing from log %q", logBuf.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package header2post
+
+import (
+	"sync"
+	"time"
+)
+
+// debouncer holds the last notification of each key until no other one
+// has been seen for the quiet period, collapsing bursts of updates to the
+// same resource into a single delivery.
+type debouncer struct {
+	mu       sync.Mutex
+	keyField string
+	quiet    time.Duration
+	pending  map[string]*debounced
+	wg       sync.WaitGroup
+}
+
+// debounced is the latest notification waiting for its key to go quiet.
+type debounced struct {
+	gen   int
+	timer *time.Timer
+	send  func()
+}
+
+func newDebouncer(keyField string, quiet time.Duration) *debouncer {
+	return &debouncer{keyField: keyField, quiet: quiet, pending: make(map[string]*debounced)}
+}
+
+// add schedules send once key has been quiet for the quiet period. It
+// reports whether a pending notification of the same key was superseded.
+func (d *debouncer) add(key string, send func()) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p, superseded := d.pending[key]
+	if superseded {
+		p.timer.Stop()
+		p.gen++
+	} else {
+		p = &debounced{}
+		d.pending[key] = p
+	}
+	p.send = send
+	gen := p.gen
+	p.timer = time.AfterFunc(d.quiet, func() { d.fire(key, gen) })
+	return superseded
+}
+
+// fire delivers the pending notification of key unless it has been
+// superseded since its timer was armed.
+func (d *debouncer) fire(key string, gen int) {
+	d.mu.Lock()
+	p, ok := d.pending[key]
+	if !ok || p.gen != gen {
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.wg.Add(1)
+	d.mu.Unlock()
+	defer d.wg.Done()
+	p.send()
+}
+
+// depth returns the number of notifications waiting for their key to go
+// quiet.
+func (d *debouncer) depth() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.pending)
+}
+
+// close delivers every pending notification without waiting for the
+// quiet period and waits for in-flight deliveries.
+func (d *debouncer) close() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[string]*debounced)
+	for _, p := range pending {
+		p.timer.Stop()
+	}
+	d.mu.Unlock()
+	for _, p := range pending {
+		p.send()
+	}
+	d.wg.Wait()
+}
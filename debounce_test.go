@@ -0,0 +1,120 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDebouncerFire(t *testing.T) {
+	d := newDebouncer("id", 20*time.Millisecond)
+	sent := make(chan string, 2)
+	if d.add("k", func() { sent <- "first" }) {
+		t.Errorf("first add superseded nothing")
+	}
+	if !d.add("k", func() { sent <- "second" }) {
+		t.Errorf("expected second add to supersede the first")
+	}
+	if d.depth() != 1 {
+		t.Errorf("expected depth 1, got %d", d.depth())
+	}
+	select {
+	case got := <-sent:
+		if got != "second" {
+			t.Errorf("expected the last notification, got %q", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("debounced notification never sent")
+	}
+	d.close()
+	if len(sent) != 0 || d.depth() != 0 {
+		t.Errorf("unexpected extra delivery")
+	}
+}
+
+func TestServeHTTPDebounce(t *testing.T) {
+	log := captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", r.Header.Get("X-Payload"))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:       "X-Notify",
+		NotifyUrl:          "https://example.com/notification",
+		DebounceKeyField:   "order.id",
+		DebounceWindow:     "1h",
+		LogLevel:           "debug",
+		ExposeStatusHeader: true,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var bodies []string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+	for _, payload := range []string{
+		`{"order":{"id":"a"},"v":1}`,
+		`{"order":{"id":"b"},"v":1}`,
+		`{"order":{"id":"a"},"v":2}`,
+		`{"order":{"id":"a"},"v":3}`,
+		`{"v":4}`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Payload", base64.StdEncoding.EncodeToString([]byte(payload)))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		expect := resultQueued
+		if !strings.Contains(payload, "order") {
+			expect = resultDelivered
+		}
+		if got := rec.Header().Get("X-Notify-Result"); got != expect {
+			t.Errorf("%s: expected result %q, got %q", payload, expect, got)
+		}
+	}
+	if depth := handler.(*notify).queueDepth(); depth != 2 {
+		t.Errorf("expected 2 pending notifications, got %d", depth)
+	}
+	handler.(*notify).debounce.close()
+
+	sort.Strings(bodies)
+	expect := []string{`{"order":{"id":"a"},"v":3}`, `{"order":{"id":"b"},"v":1}`, `{"v":4}`}
+	if strings.Join(bodies, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected %v, got %v", expect, bodies)
+	}
+	if strings.Count(log.String(), "debounced notification superseded") != 2 {
+		t.Errorf("expected two superseded records in %s", log)
+	}
+}
+
+func TestNewDebounceErrors(t *testing.T) {
+	tests := []struct {
+		config Config
+		expect string
+	}{
+		{config: Config{DebounceKeyField: "id"}, expect: `invalid debouncewindow: ""`},
+		{config: Config{DebounceKeyField: "id", DebounceWindow: "-1s"}, expect: `invalid debouncewindow: "-1s"`},
+		{config: Config{DebounceKeyField: "id", DebounceWindow: "1s", BatchMaxSize: 10}, expect: "debouncekeyfield cannot be combined with batching"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expect, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			if _, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post"); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package header2post
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+var timeNow = time.Now
+
+// dedupCache remembers recently seen notification keys so that
+// duplicates within the ttl window can be suppressed. A key is kept for
+// ttl once its notification is delivered, and forgotten when delivery
+// fails so that a retried request goes through.
+type dedupCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	keyField string
+	seen     map[string]time.Time
+	// sweepAt is when the expired keys are next swept from seen.
+	sweepAt time.Time
+}
+
+func newDedupCache(ttl time.Duration, keyField string) *dedupCache {
+	return &dedupCache{ttl: ttl, keyField: keyField, seen: make(map[string]time.Time)}
+}
+
+// key returns the dedup key for the payload: the configured JSON field
+// when present, otherwise the sha256 of the whole payload.
+func (d *dedupCache) key(data []byte) string {
+	if d.keyField != "" {
+		if k, ok := fieldString(data, d.keyField); ok {
+			return k
+		}
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// duplicate reports whether the payload was already seen within the ttl
+// window. Otherwise its key is recorded and returned for done to settle
+// once the notification is delivered or dropped. Expired keys are
+// swept at most once per ttl.
+func (d *dedupCache) duplicate(data []byte) (string, bool) {
+	k := d.key(data)
+	now := timeNow()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !now.Before(d.sweepAt) {
+		for sk, exp := range d.seen {
+			if !now.Before(exp) {
+				delete(d.seen, sk)
+			}
+		}
+		d.sweepAt = now.Add(d.ttl)
+	}
+	if exp, ok := d.seen[k]; ok && now.Before(exp) {
+		return "", true
+	}
+	d.seen[k] = now.Add(d.ttl)
+	return k, false
+}
+
+// done settles a key returned by duplicate: it is kept for ttl from now
+// when delivered is true and forgotten otherwise.
+func (d *dedupCache) done(key string, delivered bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if delivered {
+		d.seen[key] = timeNow().Add(d.ttl)
+		return
+	}
+	delete(d.seen, key)
+}
@@ -0,0 +1,98 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDedupCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	tests := []struct {
+		name     string
+		keyField string
+		first    string
+		second   string
+		advance  time.Duration
+		failed   bool
+		expect   bool
+	}{
+		{name: "identical payload", first: `{"a":1}`, second: `{"a":1}`, expect: true},
+		{name: "different payload", first: `{"a":1}`, second: `{"a":2}`, expect: false},
+		{name: "identical payload after ttl", first: `{"a":1}`, second: `{"a":1}`, advance: time.Minute, expect: false},
+		{name: "same key field", keyField: "event.id", first: `{"event":{"id":"e1"},"n":1}`, second: `{"event":{"id":"e1"},"n":2}`, expect: true},
+		{name: "different key field", keyField: "event.id", first: `{"event":{"id":"e1"}}`, second: `{"event":{"id":"e2"}}`, expect: false},
+		{name: "missing key field falls back to hash", keyField: "id", first: `{"a":1}`, second: `{"a":2}`, expect: false},
+		{name: "identical payload after failed delivery", first: `{"a":1}`, second: `{"a":1}`, failed: true, expect: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := now
+			defer func() { now = start }()
+			d := newDedupCache(30*time.Second, tt.keyField)
+			key, duplicate := d.duplicate([]byte(tt.first))
+			if duplicate {
+				t.Fatal("first payload reported as duplicate")
+			}
+			d.done(key, !tt.failed)
+			now = now.Add(tt.advance)
+			if _, got := d.duplicate([]byte(tt.second)); got != tt.expect {
+				t.Errorf("expected duplicate %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestDedupCacheSweep(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	d := newDedupCache(30*time.Second, "")
+	for _, payload := range []string{`{"a":1}`, `{"a":2}`} {
+		key, _ := d.duplicate([]byte(payload))
+		d.done(key, true)
+	}
+	now = now.Add(10 * time.Second)
+	d.duplicate([]byte(`{"a":3}`))
+	if len(d.seen) != 3 {
+		t.Fatalf("expected no sweep within ttl, got %d keys", len(d.seen))
+	}
+	now = now.Add(30 * time.Second)
+	d.duplicate([]byte(`{"a":4}`))
+	if len(d.seen) != 1 {
+		t.Errorf("expected expired keys swept, got %d keys", len(d.seen))
+	}
+}
+
+func TestServeHTTPDedupAfterFailure(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", DedupTTL: "1m"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []int{http.StatusBadGateway, http.StatusAccepted}
+	sent := 0
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		status := statuses[sent]
+		sent++
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	})
+	// the failed delivery does not suppress the retry, the delivered one
+	// suppresses the next
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if sent != 2 {
+		t.Errorf("expected 2 deliveries, got %d", sent)
+	}
+}
@@ -0,0 +1,58 @@
+package header2post
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// deliveryCache remembers the notifications delivered successfully within
+// the ttl, keyed on the target and the payload hash, so an identical
+// notification re-emitted by the backend is not posted again.
+type deliveryCache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	delivered map[string]time.Time
+}
+
+func newDeliveryCache(ttl time.Duration) *deliveryCache {
+	return &deliveryCache{ttl: ttl, delivered: make(map[string]time.Time)}
+}
+
+// key identifies msg for target by the decoded payload, or the body when
+// there is none, so per delivery values of the body format such as ids
+// and timestamps do not defeat the cache.
+func (c *deliveryCache) key(target string, msg Notification) string {
+	data := msg.Payload
+	if data == nil {
+		data = msg.Body
+	}
+	h := sha256.New()
+	h.Write([]byte(target))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hit reports whether key was delivered within the ttl.
+func (c *deliveryCache) hit(key string) bool {
+	now := timeNow()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exp, ok := c.delivered[key]
+	return ok && now.Before(exp)
+}
+
+// store records a successful delivery of key.
+func (c *deliveryCache) store(key string) {
+	now := timeNow()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, exp := range c.delivered {
+		if !now.Before(exp) {
+			delete(c.delivered, k)
+		}
+	}
+	c.delivered[key] = now.Add(c.ttl)
+}
@@ -0,0 +1,73 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDeliveryCache(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	c := newDeliveryCache(10 * time.Second)
+	msg := Notification{Body: []byte(`{"id":"n1","a":1}`), Payload: []byte(`{"a":1}`)}
+	key := c.key("http://a", msg)
+	if c.hit(key) {
+		t.Fatal("unexpected hit before any delivery")
+	}
+	c.store(key)
+	if !c.hit(key) {
+		t.Error("expected a hit for the delivered payload")
+	}
+	other := msg
+	other.Body = []byte(`{"id":"n2","a":1}`)
+	if !c.hit(c.key("http://a", other)) {
+		t.Error("expected the body format to be ignored")
+	}
+	if c.hit(c.key("http://b", msg)) {
+		t.Error("expected a miss for another target")
+	}
+	now = now.Add(10 * time.Second)
+	if c.hit(key) {
+		t.Error("expected a miss after the ttl")
+	}
+}
+
+func TestServeHTTPDeliveryCache(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:     "X-Notify",
+		NotifyUrl:        "https://example.com/notification",
+		DeliveryCacheTTL: "1m",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := []int{http.StatusBadGateway, http.StatusAccepted, http.StatusAccepted}
+	posts := 0
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		status := statuses[posts]
+		posts++
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	})
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	// the failed delivery is not cached, the successful one is
+	if posts != 2 {
+		t.Errorf("expected 2 posts, got %d", posts)
+	}
+
+	_, err = New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", DeliveryCacheTTL: "soon"}, "header2post")
+	if err == nil || err.Error() != `invalid deliverycachettl: "soon"` {
+		t.Errorf("expected invalid deliverycachettl error, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package header2post
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	discoveryKubernetes = "kubernetes"
+	discoveryConsul     = "consul"
+
+	defaultDiscoveryInterval = 30 * time.Second
+	defaultKubernetesUrl     = "https://kubernetes.default.svc"
+	defaultConsulUrl         = "http://127.0.0.1:8500"
+
+	// maxDiscoveryBytes bounds a discovery API response.
+	maxDiscoveryBytes = 4 << 20
+)
+
+// serviceAccountDir holds the token, CA and namespace of the pod.
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// endpointPool keeps the receiver addresses of DiscoveryService, refreshed
+// every interval, and spreads deliveries over them in turn.
+type endpointPool struct {
+	fetch    func(ctx context.Context) ([]string, error)
+	interval time.Duration
+	log      *slog.Logger
+
+	mu        sync.Mutex
+	endpoints []string
+	next      atomic.Uint64
+}
+
+// newEndpointPool returns nil unless DiscoveryMode is set.
+func newEndpointPool(config *Config) (*endpointPool, error) {
+	if config.DiscoveryMode == "" {
+		return nil, nil
+	}
+	if config.DiscoveryService == "" {
+		return nil, fmt.Errorf("discoverymode requires discoveryservice")
+	}
+	interval, err := parseDuration("discoveryinterval", config.DiscoveryInterval, defaultDiscoveryInterval)
+	if err != nil {
+		return nil, err
+	}
+	p := &endpointPool{interval: interval, log: discardLogger()}
+	switch config.DiscoveryMode {
+	case discoveryKubernetes:
+		d, err := newKubernetesDiscovery(config)
+		if err != nil {
+			return nil, err
+		}
+		p.fetch = d.endpoints
+	case discoveryConsul:
+		d, err := newConsulDiscovery(config)
+		if err != nil {
+			return nil, err
+		}
+		p.fetch = d.endpoints
+	default:
+		return nil, fmt.Errorf("invalid discoverymode: %q", config.DiscoveryMode)
+	}
+	return p, nil
+}
+
+// run refreshes the endpoints every interval until ctx is done.
+func (p *endpointPool) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.refresh(ctx)
+		}
+	}
+}
+
+// refresh fetches the endpoints, keeping the previous ones when the
+// discovery API fails or lists none.
+func (p *endpointPool) refresh(ctx context.Context) []string {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+	endpoints, err := p.fetch(ctx)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case err != nil:
+		p.log.Warn("service discovery error", "error", err, "endpoints", len(p.endpoints))
+	case len(endpoints) == 0:
+		p.log.Warn("service discovery found no endpoints", "endpoints", len(p.endpoints))
+	default:
+		p.endpoints = endpoints
+	}
+	return p.endpoints
+}
+
+// route points req at the next endpoint, discovering them on first use.
+func (p *endpointPool) route(ctx context.Context, req *http.Request) error {
+	p.mu.Lock()
+	endpoints := p.endpoints
+	p.mu.Unlock()
+	if len(endpoints) == 0 {
+		if endpoints = p.refresh(ctx); len(endpoints) == 0 {
+			return fmt.Errorf("service discovery error: no endpoints")
+		}
+	}
+	req.URL.Host = endpoints[(p.next.Add(1)-1)%uint64(len(endpoints))]
+	req.Host = req.URL.Host
+	return nil
+}
+
+// kubernetesDiscovery lists the ready addresses of a Service from the
+// endpoints API with the pod service account.
+type kubernetesDiscovery struct {
+	url      string
+	token    string
+	portName string
+	client   *http.Client
+}
+
+func newKubernetesDiscovery(config *Config) (*kubernetesDiscovery, error) {
+	namespace, name, found := strings.Cut(config.DiscoveryService, "/")
+	if !found {
+		namespace, name = "default", config.DiscoveryService
+		if b, err := os.ReadFile(filepath.Join(serviceAccountDir, "namespace")); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	if namespace == "" || name == "" || strings.Contains(name, "/") {
+		return nil, fmt.Errorf("invalid discoveryservice: %q", config.DiscoveryService)
+	}
+	base, err := discoveryBaseURL(config, defaultKubernetesUrl)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if pem, err := os.ReadFile(filepath.Join(serviceAccountDir, "ca.crt")); err == nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("invalid service account ca.crt")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &kubernetesDiscovery{
+		url:      base + "/api/v1/namespaces/" + url.PathEscape(namespace) + "/endpoints/" + url.PathEscape(name),
+		token:    config.DiscoveryToken,
+		portName: config.DiscoveryPort,
+		client:   &http.Client{Transport: transport},
+	}, nil
+}
+
+func (d *kubernetesDiscovery) endpoints(ctx context.Context) ([]string, error) {
+	token := d.token
+	if token == "" {
+		// projected tokens rotate, so the file is read on every refresh
+		if b, err := os.ReadFile(filepath.Join(serviceAccountDir, "token")); err == nil {
+			token = strings.TrimSpace(string(b))
+		}
+	}
+	var doc struct {
+		Subsets []struct {
+			Addresses []struct {
+				IP string `json:"ip"`
+			} `json:"addresses"`
+			Ports []struct {
+				Name string `json:"name"`
+				Port int    `json:"port"`
+			} `json:"ports"`
+		} `json:"subsets"`
+	}
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	if err := getDiscoveryJSON(ctx, d.client, d.url, header, &doc); err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, subset := range doc.Subsets {
+		port := 0
+		for _, p := range subset.Ports {
+			if d.portName == "" || p.Name == d.portName {
+				port = p.Port
+				break
+			}
+		}
+		if port == 0 {
+			continue
+		}
+		for _, addr := range subset.Addresses {
+			out = append(out, net.JoinHostPort(addr.IP, strconv.Itoa(port)))
+		}
+	}
+	return out, nil
+}
+
+// consulDiscovery lists the instances of a Consul service passing their
+// health checks.
+type consulDiscovery struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func newConsulDiscovery(config *Config) (*consulDiscovery, error) {
+	if strings.Contains(config.DiscoveryService, "/") {
+		return nil, fmt.Errorf("invalid discoveryservice: %q", config.DiscoveryService)
+	}
+	base, err := discoveryBaseURL(config, defaultConsulUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &consulDiscovery{
+		url:    base + "/v1/health/service/" + url.PathEscape(config.DiscoveryService) + "?passing=true",
+		token:  config.DiscoveryToken,
+		client: &http.Client{},
+	}, nil
+}
+
+func (d *consulDiscovery) endpoints(ctx context.Context) ([]string, error) {
+	var doc []struct {
+		Node struct {
+			Address string `json:"Address"`
+		} `json:"Node"`
+		Service struct {
+			Address string `json:"Address"`
+			Port    int    `json:"Port"`
+		} `json:"Service"`
+	}
+	header := http.Header{}
+	if d.token != "" {
+		header.Set("X-Consul-Token", d.token)
+	}
+	if err := getDiscoveryJSON(ctx, d.client, d.url, header, &doc); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(doc))
+	for _, e := range doc {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		if host != "" && e.Service.Port > 0 {
+			out = append(out, net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+		}
+	}
+	return out, nil
+}
+
+// discoveryBaseURL returns DiscoveryUrl, or def, without a trailing slash.
+func discoveryBaseURL(config *Config, def string) (string, error) {
+	raw := config.DiscoveryUrl
+	if raw == "" {
+		raw = def
+	}
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", fmt.Errorf("invalid discoveryurl: %q", config.DiscoveryUrl)
+	}
+	return strings.TrimSuffix(raw, "/"), nil
+}
+
+// getDiscoveryJSON decodes the JSON answer to a GET of rawURL sent with
+// header.
+func getDiscoveryJSON(ctx context.Context, client *http.Client, rawURL string, header http.Header, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxDiscoveryBytes)).Decode(v)
+}
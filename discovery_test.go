@@ -0,0 +1,222 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useServiceAccount points the kubernetes discovery at a temporary
+// service account directory holding token and namespace.
+func useServiceAccount(t *testing.T, token, namespace string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte(token+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if namespace != "" {
+		if err := os.WriteFile(filepath.Join(dir, "namespace"), []byte(namespace), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	prev := serviceAccountDir
+	serviceAccountDir = dir
+	t.Cleanup(func() { serviceAccountDir = prev })
+}
+
+func TestNewEndpointPoolErrors(t *testing.T) {
+	useServiceAccount(t, "sa-token", "")
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "mode", config: Config{DiscoveryMode: "eureka", DiscoveryService: "orders"}, err: `invalid discoverymode: "eureka"`},
+		{name: "no service", config: Config{DiscoveryMode: discoveryConsul}, err: "discoverymode requires discoveryservice"},
+		{name: "interval", config: Config{DiscoveryMode: discoveryConsul, DiscoveryService: "orders", DiscoveryInterval: "often"}, err: `invalid discoveryinterval: "often"`},
+		{name: "url", config: Config{DiscoveryMode: discoveryConsul, DiscoveryService: "orders", DiscoveryUrl: "consul:8500"}, err: `invalid discoveryurl: "consul:8500"`},
+		{name: "kubernetes service", config: Config{DiscoveryMode: discoveryKubernetes, DiscoveryService: "a/b/c"}, err: `invalid discoveryservice: "a/b/c"`},
+		{name: "consul service", config: Config{DiscoveryMode: discoveryConsul, DiscoveryService: "a/b"}, err: `invalid discoveryservice: "a/b"`},
+		{name: "no notifyurl", config: Config{DiscoveryMode: discoveryConsul, DiscoveryService: "orders", NotifyUrl: "", FanoutUrls: []string{"https://example.com"}}, err: "discoverymode requires notifyurl"},
+		{name: "unix", config: Config{DiscoveryMode: discoveryConsul, DiscoveryService: "orders", NotifyUrl: "unix:///run/notify.sock:/events"}, err: "discoverymode cannot be combined with an srv or unix notifyurl"},
+		{name: "health check", config: Config{DiscoveryMode: discoveryConsul, DiscoveryService: "orders", HealthCheckInterval: "10s"}, err: "healthcheckinterval cannot be combined with discoverymode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			if config.NotifyUrl == "" && config.FanoutUrls == nil {
+				config.NotifyUrl = "http://orders/events"
+			}
+			_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestKubernetesDiscovery(t *testing.T) {
+	tests := []struct {
+		name      string
+		service   string
+		port      string
+		namespace string
+		expectURL string
+		expect    []string
+	}{
+		{name: "first port", service: "receiver", namespace: "shop", expectURL: "/api/v1/namespaces/shop/endpoints/receiver", expect: []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.1.1:9000"}},
+		{name: "named port", service: "events/receiver", port: "metrics", expectURL: "/api/v1/namespaces/events/endpoints/receiver", expect: []string{"10.0.0.1:9090", "10.0.0.2:9090"}},
+		{name: "default namespace", service: "receiver", expectURL: "/api/v1/namespaces/default/endpoints/receiver", expect: []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.1.1:9000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useServiceAccount(t, "sa-token", tt.namespace)
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != tt.expectURL || r.Header.Get("Authorization") != "Bearer sa-token" {
+					t.Errorf("unexpected request %s %v", r.URL.Path, r.Header)
+				}
+				w.Write([]byte(`{"subsets":[
+					{"addresses":[{"ip":"10.0.0.1"},{"ip":"10.0.0.2"}],"ports":[{"name":"http","port":8080},{"name":"metrics","port":9090}]},
+					{"addresses":[{"ip":"10.0.1.1"}],"ports":[{"name":"http","port":9000}]}
+				]}`))
+			}))
+			defer api.Close()
+			d, err := newKubernetesDiscovery(&Config{DiscoveryService: tt.service, DiscoveryPort: tt.port, DiscoveryUrl: api.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := d.endpoints(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestConsulDiscovery(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/health/service/receiver" || r.URL.Query().Get("passing") != "true" || r.Header.Get("X-Consul-Token") != "acl" {
+			t.Errorf("unexpected request %s %v", r.URL, r.Header)
+		}
+		w.Write([]byte(`[
+			{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8080}},
+			{"Node":{"Address":"10.0.0.2"},"Service":{"Address":"10.1.0.2","Port":8081}},
+			{"Node":{"Address":"10.0.0.3"},"Service":{"Address":"10.1.0.3","Port":0}}
+		]`))
+	}))
+	defer api.Close()
+	d, err := newConsulDiscovery(&Config{DiscoveryService: "receiver", DiscoveryUrl: api.URL + "/", DiscoveryToken: "acl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.endpoints(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"10.0.0.1:8080", "10.1.0.2:8081"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+}
+
+func TestEndpointPoolRoute(t *testing.T) {
+	logs := captureLog(t)
+	var fetched []string
+	var fetchErr error
+	p := &endpointPool{interval: time.Second, log: newLogger("header2post", slog.LevelInfo, logs)}
+	p.fetch = func(ctx context.Context) ([]string, error) {
+		return fetched, fetchErr
+	}
+	route := func() (string, error) {
+		req, _ := http.NewRequest(http.MethodPost, "http://receiver/events", nil)
+		err := p.route(context.Background(), req)
+		return req.URL.Host, err
+	}
+
+	if _, err := route(); err == nil || err.Error() != "service discovery error: no endpoints" {
+		t.Fatalf("expected no endpoints error, got %v", err)
+	}
+	fetched = []string{"10.0.0.1:80", "10.0.0.2:80"}
+	var got []string
+	for range 3 {
+		host, err := route()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, host)
+	}
+	if expect := []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.1:80"}; !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected round robin %v, got %v", expect, got)
+	}
+
+	fetchErr = errors.New("connection refused")
+	if endpoints := p.refresh(context.Background()); len(endpoints) != 2 {
+		t.Errorf("expected the endpoints to be kept, got %v", endpoints)
+	}
+	fetchErr, fetched = nil, nil
+	if endpoints := p.refresh(context.Background()); len(endpoints) != 2 {
+		t.Errorf("expected the endpoints to be kept, got %v", endpoints)
+	}
+	for _, msg := range []string{"service discovery error", "service discovery found no endpoints"} {
+		if !strings.Contains(logs.String(), msg) {
+			t.Errorf("expected log %q, got %s", msg, logs.String())
+		}
+	}
+}
+
+func TestServeHTTPDiscovery(t *testing.T) {
+	captureLog(t)
+	var mu sync.Mutex
+	hits := map[string]int{}
+	receiver := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name+" "+r.URL.Path]++
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		}))
+	}
+	a, b := receiver("a"), receiver("b")
+	defer a.Close()
+	defer b.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := func(u string) string { return strings.TrimPrefix(u, "http://") }
+		ha, pa, _ := strings.Cut(host(a.URL), ":")
+		hb, pb, _ := strings.Cut(host(b.URL), ":")
+		w.Write([]byte(`[{"Service":{"Address":"` + ha + `","Port":` + pa + `}},{"Service":{"Address":"` + hb + `","Port":` + pb + `}}]`))
+	}))
+	defer api.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:     "X-Notify",
+		NotifyUrl:        "http://receiver/events",
+		DiscoveryMode:    discoveryConsul,
+		DiscoveryService: "receiver",
+		DiscoveryUrl:     api.URL,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 4 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	if expect := map[string]int{"a /events": 2, "b /events": 2}; !reflect.DeepEqual(hits, expect) {
+		t.Errorf("expected %v, got %v", expect, hits)
+	}
+}
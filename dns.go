@@ -0,0 +1,117 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// dnsCache resolves notify hosts with an optional custom resolver and
+// keeps the addresses for ttl. An expired entry is still used when a new
+// lookup fails, so a transient DNS failure does not fail the delivery.
+type dnsCache struct {
+	lookupHost func(ctx context.Context, host string) ([]string, error)
+	ttl        time.Duration
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// newDNSCache returns nil when neither DnsResolver nor DnsCacheTtl is set.
+func newDNSCache(config *Config, dialTimeout time.Duration) (*dnsCache, error) {
+	if config.DnsResolver == "" && config.DnsCacheTtl == "" {
+		return nil, nil
+	}
+	ttl, err := parseDuration("dnscachettl", config.DnsCacheTtl, 0)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := newResolver(config, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &dnsCache{lookupHost: resolver.LookupHost, ttl: ttl, entries: map[string]dnsEntry{}}, nil
+}
+
+// newResolver returns a resolver querying DnsResolver, or the system
+// resolver when it is not set.
+func newResolver(config *Config, dialTimeout time.Duration) (*net.Resolver, error) {
+	if config.DnsResolver == "" {
+		return net.DefaultResolver, nil
+	}
+	addr := config.DnsResolver
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	host, _, _ := net.SplitHostPort(addr)
+	if net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("invalid dnsresolver: %q", config.DnsResolver)
+	}
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}, nil
+}
+
+// lookup returns the addresses of host, from the cache while fresh.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	now := timeNow()
+	c.mu.Lock()
+	entry, cached := c.entries[host]
+	c.mu.Unlock()
+	if cached && now.Before(entry.expires) {
+		return entry.addrs, nil
+	}
+	addrs, err := c.lookupHost(ctx, host)
+	if err != nil {
+		if cached {
+			return entry.addrs, nil
+		}
+		return nil, err
+	}
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(c.ttl)}
+		c.mu.Unlock()
+	}
+	return addrs, nil
+}
+
+// dialContext resolves the host of addr through the cache and dials its
+// addresses in turn with dial.
+func (c *dnsCache) dialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		var firstErr error
+		for _, ip := range addrs {
+			conn, err := dial(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no addresses for %s", host)
+		}
+		return nil, firstErr
+	}
+}
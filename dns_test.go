@@ -0,0 +1,124 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewDNSCache(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectNil bool
+		expectTTL time.Duration
+		expectErr string
+	}{
+		{name: "disabled", expectNil: true},
+		{name: "resolver", config: Config{DnsResolver: "10.96.0.10"}},
+		{name: "resolver with port", config: Config{DnsResolver: "[fd00::10]:5353"}},
+		{name: "ttl", config: Config{DnsCacheTtl: "30s"}, expectTTL: 30 * time.Second},
+		{name: "bad resolver", config: Config{DnsResolver: "dns.example.com"}, expectErr: `invalid dnsresolver: "dns.example.com"`},
+		{name: "bad ttl", config: Config{DnsCacheTtl: "soon"}, expectErr: `invalid dnscachettl: "soon"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newDNSCache(&tt.config, time.Second)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (c == nil) != tt.expectNil {
+				t.Fatalf("expected nil %v, got %v", tt.expectNil, c)
+			}
+			if c != nil && c.ttl != tt.expectTTL {
+				t.Errorf("expected ttl %v, got %v", tt.expectTTL, c.ttl)
+			}
+		})
+	}
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time { return now }
+	t.Cleanup(func() { timeNow = time.Now })
+
+	lookups := 0
+	var lookupErr error
+	c := &dnsCache{ttl: time.Minute, entries: map[string]dnsEntry{}}
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		lookups++
+		return []string{"10.0.0.1"}, lookupErr
+	}
+	lookup := func() []string {
+		t.Helper()
+		addrs, err := c.lookup(context.Background(), "notify.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return addrs
+	}
+
+	lookup()
+	lookup()
+	if lookups != 1 {
+		t.Errorf("expected 1 lookup while cached, got %d", lookups)
+	}
+	now = now.Add(2 * time.Minute)
+	lookup()
+	if lookups != 2 {
+		t.Errorf("expected a new lookup once expired, got %d", lookups)
+	}
+	now = now.Add(2 * time.Minute)
+	lookupErr = errors.New("server misbehaving")
+	if addrs := lookup(); !reflect.DeepEqual(addrs, []string{"10.0.0.1"}) {
+		t.Errorf("expected the stale addresses, got %v", addrs)
+	}
+	if _, err := c.lookup(context.Background(), "other.example.com"); err == nil {
+		t.Error("expected the lookup error without a cached entry")
+	}
+}
+
+func TestDNSCacheDialContext(t *testing.T) {
+	c := &dnsCache{entries: map[string]dnsEntry{}}
+	c.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+		return []string{"10.0.0.1", "10.0.0.2"}, nil
+	}
+	var dialed []string
+	dial := c.dialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		if addr == "10.0.0.2:443" || addr == "192.0.2.1:80" {
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+		return nil, errors.New("connection refused")
+	})
+
+	conn, err := dial(context.Background(), "tcp", "notify.example.com:443")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if expect := []string{"10.0.0.1:443", "10.0.0.2:443"}; !reflect.DeepEqual(dialed, expect) {
+		t.Errorf("expected %v, got %v", expect, dialed)
+	}
+
+	dialed = nil
+	conn, err = dial(context.Background(), "tcp", "192.0.2.1:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if expect := []string{"192.0.2.1:80"}; !reflect.DeepEqual(dialed, expect) {
+		t.Errorf("expected ip addresses to be dialed directly, got %v", dialed)
+	}
+}
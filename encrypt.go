@@ -0,0 +1,96 @@
+package header2post
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	encryptionJWE    = "jwe"
+	encryptionAESGCM = "aesgcm"
+
+	encryptionHeader = "X-Notify-Encryption"
+)
+
+// payloadEncrypter encrypts encoded notification bodies with AES-GCM.
+type payloadEncrypter struct {
+	format string
+	aead   cipher.AEAD
+	// enc is the JWE name of the cipher, e.g. A256GCM.
+	enc   string
+	keyID string
+}
+
+// newPayloadEncrypter returns nil when no encryption key is configured.
+func newPayloadEncrypter(config *Config) (*payloadEncrypter, error) {
+	if config.EncryptionKey == "" {
+		if config.EncryptionFormat != "" {
+			return nil, fmt.Errorf("encryptionformat requires encryptionkey")
+		}
+		return nil, nil
+	}
+	e := &payloadEncrypter{format: config.EncryptionFormat, keyID: config.EncryptionKeyId}
+	switch e.format {
+	case "":
+		e.format = encryptionJWE
+	case encryptionJWE, encryptionAESGCM:
+	default:
+		return nil, fmt.Errorf("invalid encryptionformat: %q", config.EncryptionFormat)
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(config.EncryptionKey))
+	if err != nil || (len(key) != 16 && len(key) != 24 && len(key) != 32) {
+		return nil, fmt.Errorf("invalid encryptionkey: must be a base64 key of 16, 24 or 32 bytes")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryptionkey: %w", err)
+	}
+	if e.aead, err = cipher.NewGCM(block); err != nil {
+		return nil, err
+	}
+	e.enc = fmt.Sprintf("A%dGCM", len(key)*8)
+	return e, nil
+}
+
+// seal returns p with its body encrypted.
+func (e *payloadEncrypter) seal(p *encodedPayload) (*encodedPayload, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := cryptorand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("encrypt payload: %w", err)
+	}
+	out := &encodedPayload{header: p.header}
+	if e.format == encryptionAESGCM {
+		out.body = e.aead.Seal(nonce, nonce, p.body, nil)
+		out.contentType = "application/octet-stream"
+		out.header = out.header.Clone()
+		if out.header == nil {
+			out.header = http.Header{}
+		}
+		out.header.Set(encryptionHeader, e.enc)
+		return out, nil
+	}
+	header := map[string]string{"alg": "dir", "enc": e.enc, "cty": p.contentType}
+	if e.keyID != "" {
+		header["kid"] = e.keyID
+	}
+	b, err := json.Marshal(header)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt payload: %w", err)
+	}
+	protected := base64.RawURLEncoding.EncodeToString(b)
+	// the protected header is the additional authenticated data
+	sealed := e.aead.Seal(nil, nonce, p.body, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-e.aead.Overhead()], sealed[len(sealed)-e.aead.Overhead():]
+	out.body = []byte(protected + ".." +
+		base64.RawURLEncoding.EncodeToString(nonce) + "." +
+		base64.RawURLEncoding.EncodeToString(ciphertext) + "." +
+		base64.RawURLEncoding.EncodeToString(tag))
+	out.contentType = "application/jose"
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package header2post
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestNewPayloadEncrypter(t *testing.T) {
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{}},
+		{config: Config{EncryptionFormat: encryptionJWE}, expectErr: "encryptionformat requires encryptionkey"},
+		{config: Config{EncryptionKey: testEncryptionKey, EncryptionFormat: "rot13"}, expectErr: `invalid encryptionformat: "rot13"`},
+		{config: Config{EncryptionKey: "not base64!"}, expectErr: "invalid encryptionkey: must be a base64 key of 16, 24 or 32 bytes"},
+		{config: Config{EncryptionKey: base64.StdEncoding.EncodeToString([]byte("short"))}, expectErr: "invalid encryptionkey: must be a base64 key of 16, 24 or 32 bytes"},
+		{config: Config{EncryptionKey: testEncryptionKey}},
+	}
+	for _, tt := range tests {
+		_, err := newPayloadEncrypter(&tt.config)
+		if (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
+
+func testGCM(t *testing.T) cipher.AEAD {
+	key, _ := base64.StdEncoding.DecodeString(testEncryptionKey)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+// openJWE decrypts a compact JWE produced by payloadEncrypter.
+func openJWE(t *testing.T, body string) (map[string]string, string) {
+	t.Helper()
+	parts := strings.Split(body, ".")
+	if len(parts) != 5 || parts[1] != "" {
+		t.Fatalf("unexpected jwe %q", body)
+	}
+	var header map[string]string
+	b, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	if err := json.Unmarshal(b, &header); err != nil {
+		t.Fatal(err)
+	}
+	nonce, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	ciphertext, _ := base64.RawURLEncoding.DecodeString(parts[3])
+	tag, _ := base64.RawURLEncoding.DecodeString(parts[4])
+	plain, err := testGCM(t).Open(nil, nonce, append(ciphertext, tag...), []byte(parts[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header, string(plain)
+}
+
+func TestPayloadEncrypterSeal(t *testing.T) {
+	e, err := newPayloadEncrypter(&Config{EncryptionKey: testEncryptionKey, EncryptionKeyId: "k1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := e.seal(&encodedPayload{body: []byte(`{"a":1}`), contentType: "application/json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, plain := openJWE(t, string(sealed.body))
+	if sealed.contentType != "application/jose" || plain != `{"a":1}` {
+		t.Errorf("unexpected payload %q %q", sealed.contentType, plain)
+	}
+	if header["alg"] != "dir" || header["enc"] != "A256GCM" || header["cty"] != "application/json" || header["kid"] != "k1" {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	e, err = newPayloadEncrypter(&Config{EncryptionKey: testEncryptionKey, EncryptionFormat: encryptionAESGCM})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err = e.seal(&encodedPayload{body: []byte(`{"a":1}`), contentType: "application/json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead := testGCM(t)
+	opened, err := aead.Open(nil, sealed.body[:aead.NonceSize()], sealed.body[aead.NonceSize():], nil)
+	if err != nil || string(opened) != `{"a":1}` {
+		t.Errorf("unexpected plaintext %q %v", opened, err)
+	}
+	if sealed.contentType != "application/octet-stream" || sealed.header.Get(encryptionHeader) != "A256GCM" {
+		t.Errorf("unexpected payload %q %v", sealed.contentType, sealed.header)
+	}
+}
+
+func TestServeHTTPEncryption(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"email":"a@example.com"}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:  "X-Notify",
+		NotifyUrl:     "https://example.com/notification",
+		EncryptionKey: testEncryptionKey,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body, contentType string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		body, contentType = string(b), req.Header.Get("Content-Type")
+		return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if strings.Contains(body, "example.com") || contentType != "application/jose" {
+		t.Fatalf("expected an encrypted body, got %q %q", contentType, body)
+	}
+	if _, plain := openJWE(t, body); plain != `{"email":"a@example.com"}` {
+		t.Errorf("unexpected plaintext %q", plain)
+	}
+}
@@ -0,0 +1,100 @@
+package header2post
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+const (
+	enrichHeader  = "header"
+	enrichReplace = "replace"
+	enrichMerge   = "merge"
+
+	defaultEnrichHeader = "X-Notify-Reply"
+
+	// maxReplyBytes bounds the notify reply kept for enrichment.
+	maxReplyBytes = 1 << 20
+)
+
+// notifyReply is the reply of the notify url to a synchronous delivery.
+// With several senders the first successful reply is kept.
+type notifyReply struct {
+	// acceptAny makes any 2xx status, not only 202, a successful delivery.
+	acceptAny   bool
+	status      int
+	contentType string
+	body        []byte
+}
+
+func (r *notifyReply) record(status int, contentType string, body []byte) {
+	if r.success() {
+		return
+	}
+	r.status = status
+	r.contentType = contentType
+	r.body = body
+}
+
+func (r *notifyReply) success() bool {
+	return r.status/100 == 2
+}
+
+// enrich injects reply into the client response according to EnrichMode.
+func (a *notify) enrich(ex *exchange, reply *notifyReply, logAttrs []any) {
+	if a.enrichMode != enrichHeader && ex.respBody == nil {
+		a.log.Warn("response not enriched: response already flushed", logAttrs...)
+		return
+	}
+	switch a.enrichMode {
+	case enrichHeader:
+		ex.clientHeader.Set(a.enrichHeader, strings.TrimSpace(string(reply.body)))
+	case enrichReplace:
+		ex.respBody.Reset()
+		ex.respBody.Write(reply.body)
+		ex.clientHeader.Del("Content-Length")
+		if reply.contentType != "" {
+			ex.clientHeader.Set("Content-Type", reply.contentType)
+		}
+	case enrichMerge:
+		if ex.respBody.spilled() {
+			a.log.Warn("response not enriched: response exceeds maxbufferbytes", logAttrs...)
+			return
+		}
+		merged, ok := mergeReply(ex.respBody.Bytes(), reply.body, a.enrichField)
+		if !ok {
+			a.log.Warn("response not enriched: reply and response must be JSON objects", logAttrs...)
+			return
+		}
+		ex.respBody.Reset()
+		ex.respBody.Write(merged)
+		ex.clientHeader.Del("Content-Length")
+	}
+}
+
+// mergeReply adds the fields of the JSON object reply to the JSON object
+// body, or sets reply under field when it is not empty.
+func mergeReply(body, reply []byte, field string) ([]byte, bool) {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(body, &obj) != nil || obj == nil {
+		return nil, false
+	}
+	if field != "" {
+		if !json.Valid(reply) {
+			return nil, false
+		}
+		obj[field] = reply
+	} else {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(reply, &fields) != nil || fields == nil {
+			return nil, false
+		}
+		for k, v := range fields {
+			obj[k] = v
+		}
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
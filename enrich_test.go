@@ -0,0 +1,91 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPEnrich(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       Config
+		upstream     string
+		status       int
+		reply        string
+		expectBody   string
+		expectHeader string
+		expectType   string
+	}{
+		{name: "header", config: Config{EnrichMode: "header"}, upstream: `{"id":1}`, status: http.StatusOK, reply: " {\"score\":7}\n", expectBody: `{"id":1}`, expectHeader: `{"score":7}`, expectType: "application/json"},
+		{name: "custom header", config: Config{EnrichMode: "header", EnrichHeader: "X-Score"}, upstream: `{"id":1}`, status: http.StatusOK, reply: "7", expectBody: `{"id":1}`, expectType: "application/json"},
+		{name: "replace", config: Config{EnrichMode: "replace"}, upstream: `{"id":1}`, status: http.StatusCreated, reply: "done", expectBody: "done", expectType: "text/plain"},
+		{name: "merge", config: Config{EnrichMode: "merge"}, upstream: `{"id":1,"score":0}`, status: http.StatusOK, reply: `{"score":7}`, expectBody: `{"id":1,"score":7}`, expectType: "application/json"},
+		{name: "merge field", config: Config{EnrichMode: "merge", EnrichField: "receipt"}, upstream: `{"id":1}`, status: http.StatusOK, reply: `["a"]`, expectBody: `{"id":1,"receipt":["a"]}`, expectType: "application/json"},
+		{name: "merge not object", config: Config{EnrichMode: "merge"}, upstream: `[1]`, status: http.StatusOK, reply: `{"score":7}`, expectBody: `[1]`, expectType: "application/json"},
+		{name: "failed", config: Config{EnrichMode: "replace"}, upstream: `{"id":1}`, status: http.StatusConflict, reply: "conflict", expectBody: `{"id":1}`, expectType: "application/json"},
+		{name: "disabled", upstream: `{"id":1}`, status: http.StatusAccepted, reply: "done", expectBody: `{"id":1}`, expectType: "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Length", "99")
+				w.Write([]byte(tt.upstream))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				header := http.Header{"Content-Type": []string{"text/plain"}}
+				return &http.Response{StatusCode: tt.status, Header: header, Body: io.NopCloser(strings.NewReader(tt.reply))}, nil
+			})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Body.String() != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, w.Body.String())
+			}
+			if got := w.Header().Get(defaultEnrichHeader); got != tt.expectHeader {
+				t.Errorf("expected header %q, got %q", tt.expectHeader, got)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.expectType {
+				t.Errorf("expected content type %q, got %q", tt.expectType, got)
+			}
+			if tt.config.EnrichHeader != "" && w.Header().Get(tt.config.EnrichHeader) != tt.reply {
+				t.Errorf("expected %s %q, got %q", tt.config.EnrichHeader, tt.reply, w.Header().Get(tt.config.EnrichHeader))
+			}
+		})
+	}
+}
+
+func TestNewEnrichErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		expect string
+	}{
+		{name: "mode", config: Config{EnrichMode: "append"}, expect: `invalid enrichmode: "append"`},
+		{name: "request trigger", config: Config{EnrichMode: "merge", TriggerSource: "request"}, expect: `enrichmode "merge" requires triggersource response`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			if _, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post"); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package header2post
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const defaultErrorBodyBytes = 1024
+
+// errorReporter generates the notification of an upstream error response
+// that carries no notify header.
+type errorReporter struct {
+	statuses statusSet
+	maxBody  int
+}
+
+// newErrorReporter returns nil when ErrorStatusCodes is empty.
+func newErrorReporter(config *Config) (*errorReporter, error) {
+	if len(config.ErrorStatusCodes) == 0 {
+		return nil, nil
+	}
+	statuses, err := parseStatusClasses("errorstatuscodes", config.ErrorStatusCodes)
+	if err != nil {
+		return nil, err
+	}
+	if config.ErrorBodyBytes < 0 {
+		return nil, fmt.Errorf("errorbodybytes cannot be negative")
+	}
+	maxBody := config.ErrorBodyBytes
+	if maxBody == 0 {
+		maxBody = defaultErrorBodyBytes
+	}
+	return &errorReporter{statuses: statuses, maxBody: maxBody}, nil
+}
+
+// report returns the payload describing the response to req, or false
+// when its status is not reported. A body compressed with encoding is
+// decoded first, and sent base64 encoded along with body_encoding when
+// it cannot be.
+func (r *errorReporter) report(req *http.Request, status int, latency time.Duration, head *bodyHead, encoding string) (notifyValue, bool) {
+	if r == nil || !r.statuses.has(status) {
+		return notifyValue{}, false
+	}
+	payload := map[string]any{
+		"status":     status,
+		"method":     req.Method,
+		"host":       req.Host,
+		"path":       req.URL.Path,
+		"latency_ms": latency.Milliseconds(),
+		"body":       string(head.b),
+	}
+	truncated := head.n > len(head.b)
+	if encoding != "" {
+		if body, complete, err := decodeContentEncoding(encoding, head.b, r.maxBody); err == nil {
+			payload["body"], truncated = string(body), !complete
+		} else {
+			payload["body"] = base64.StdEncoding.EncodeToString(head.b)
+			payload["body_encoding"] = strings.ToLower(encoding)
+		}
+	}
+	if truncated {
+		payload["truncated"] = true
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return notifyValue{}, false
+	}
+	return notifyValue{payload: data}, true
+}
+
+// bodyHead keeps the first max bytes written to it and counts the rest.
+type bodyHead struct {
+	b   []byte
+	n   int
+	max int
+}
+
+func (h *bodyHead) Write(p []byte) (int, error) {
+	if h == nil {
+		return len(p), nil
+	}
+	if room := h.max - len(h.b); room > 0 {
+		h.b = append(h.b, p[:min(room, len(p))]...)
+	}
+	h.n += len(p)
+	return len(p), nil
+}
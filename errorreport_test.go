@@ -0,0 +1,154 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBodyHead(t *testing.T) {
+	h := &bodyHead{max: 5}
+	h.Write([]byte("abc"))
+	h.Write([]byte("defg"))
+	if string(h.b) != "abcde" || h.n != 7 {
+		t.Errorf("expected abcde of 7 bytes, got %q of %d", h.b, h.n)
+	}
+	var nilHead *bodyHead
+	if n, err := nilHead.Write([]byte("abc")); n != 3 || err != nil {
+		t.Errorf("expected 3, nil, got %d, %v", n, err)
+	}
+}
+
+func TestNewErrorReporterErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "bad status", config: Config{ErrorStatusCodes: []string{"9xx"}}, err: `invalid errorstatuscodes: "9xx"`},
+		{name: "negative body", config: Config{ErrorStatusCodes: []string{"5xx"}, ErrorBodyBytes: -1}, err: "errorbodybytes cannot be negative"},
+		{name: "request trigger", config: Config{ErrorStatusCodes: []string{"5xx"}, TriggerSource: triggerRequest}, err: "errorstatuscodes requires triggersource response"},
+		{name: "write header", config: Config{ErrorStatusCodes: []string{"5xx"}, TriggerOnWriteHeader: true}, err: "errorstatuscodes cannot be combined with triggeronwriteheader"},
+		{name: "no header", config: Config{}, err: "notifyheader cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyUrl = "https://example.com/notification"
+			_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPErrorReport(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name     string
+		config   Config
+		status   int
+		header   string
+		encoding string
+		body     string
+		expect   string
+	}{
+		{
+			name:   "reported",
+			status: http.StatusBadGateway,
+			body:   "upstream down",
+			expect: `{"body":"upstream down","host":"shop.example.com","latency_ms":25,"method":"GET","path":"/orders","status":502}`,
+		},
+		{
+			name:   "truncated",
+			config: Config{ErrorBodyBytes: 4},
+			status: http.StatusInternalServerError,
+			body:   "panic: nil map",
+			expect: `{"body":"pani","host":"shop.example.com","latency_ms":25,"method":"GET","path":"/orders","status":500,"truncated":true}`,
+		},
+		{name: "not reported", status: http.StatusNotFound, body: "missing"},
+		{name: "success", status: http.StatusOK, body: "ok"},
+		{
+			name:   "header wins",
+			config: Config{NotifyHeader: "X-Notify"},
+			status: http.StatusServiceUnavailable,
+			header: `{"id":"e1"}`,
+			expect: `{"id":"e1"}`,
+		},
+		{
+			name:   "long body",
+			status: http.StatusGatewayTimeout,
+			body:   strings.Repeat("x", 2000),
+			expect: `{"body":"` + strings.Repeat("x", 1024) + `","host":"shop.example.com","latency_ms":25,"method":"GET","path":"/orders","status":504,"truncated":true}`,
+		},
+		{
+			name:     "gzip",
+			status:   http.StatusBadGateway,
+			encoding: "gzip",
+			body:     gzipString(t, "upstream down"),
+			expect:   `{"body":"upstream down","host":"shop.example.com","latency_ms":25,"method":"GET","path":"/orders","status":502}`,
+		},
+		{
+			name:     "gzip truncated",
+			config:   Config{ErrorBodyBytes: 30},
+			status:   http.StatusBadGateway,
+			encoding: "gzip",
+			body:     gzipString(t, strings.Repeat("x", 100)),
+			expect:   `{"body":"` + strings.Repeat("x", 30) + `","host":"shop.example.com","latency_ms":25,"method":"GET","path":"/orders","status":502,"truncated":true}`,
+		},
+		{
+			name:     "brotli",
+			status:   http.StatusBadGateway,
+			encoding: "br",
+			body:     "\x8b\x06\x80upstream down\x03",
+			expect:   `{"body":"iwaAdXBzdHJlYW0gZG93bgM=","body_encoding":"br","host":"shop.example.com","latency_ms":25,"method":"GET","path":"/orders","status":502}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Unix(1700000000, 0)
+			timeNow = func() time.Time {
+				now = now.Add(25 * time.Millisecond)
+				return now
+			}
+			t.Cleanup(func() { timeNow = time.Now })
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.header != "" {
+					w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.header)))
+				}
+				if tt.encoding != "" {
+					w.Header().Set("Content-Encoding", tt.encoding)
+				}
+				w.WriteHeader(tt.status)
+				io.WriteString(w, tt.body)
+			})
+			config := tt.config
+			config.NotifyUrl = "https://example.com/notification"
+			config.ErrorStatusCodes = []string{"5xx"}
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "https://shop.example.com/orders", nil))
+			if body != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, body)
+			}
+			if rec.Code != tt.status || rec.Body.String() != tt.body {
+				t.Errorf("expected %d %q, got %d %q", tt.status, tt.body, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package header2post
+
+import "net/http"
+
+const defaultEventIdHeader = "X-Notify-Event-Id"
+
+// notifyAck receives the event id a receiver echoes back in the event id
+// header of its reply.
+type notifyAck struct {
+	header string
+	value  string
+}
+
+// setEventId assigns msg a new event id, sent in the event id header and
+// logged with report, if enabled.
+func (a *notify) setEventId(msg *Notification, report *deliveryReport) {
+	if a.eventIdHeader == "" {
+		return
+	}
+	id := generateID()
+	if msg.Header == nil {
+		msg.Header = http.Header{}
+	}
+	msg.Header.Set(a.eventIdHeader, id)
+	report.NotificationID = id
+}
+
+// acknowledged records the event id echoed by the receiver in result,
+// warning when it is not the one sent.
+func (a *notify) acknowledged(msg Notification, ack *notifyAck, result *deliveryResult) {
+	if ack.value == "" {
+		return
+	}
+	result.Ack = ack.value
+	if id := msg.Header.Get(a.eventIdHeader); ack.value != id {
+		a.log.Warn("receiver acknowledged another event id", "target", result.Target, "event_id", id, "ack", ack.value)
+	}
+}
@@ -0,0 +1,82 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPEventId(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		echo       func(id string) string
+		expectAck  string
+		expectWarn bool
+	}{
+		{name: "echoed", echo: func(id string) string { return id }, expectAck: "evt-1"},
+		{name: "custom header", header: "X-Event", echo: func(id string) string { return id }, expectAck: "evt-1"},
+		{name: "no echo", echo: func(string) string { return "" }},
+		{name: "mismatch", echo: func(string) string { return "evt-9" }, expectAck: "evt-9", expectWarn: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			generateID = func() string { return "evt-1" }
+			defer func() { generateID = newUUID }()
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:  "X-Notify",
+				NotifyUrl:     "https://example.com/notification",
+				SendEventId:   true,
+				EventIdHeader: tt.header,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			header := tt.header
+			if header == "" {
+				header = defaultEventIdHeader
+			}
+			var sent string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				sent = req.Header.Get(header)
+				resp := &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: http.NoBody}
+				if echo := tt.echo(sent); echo != "" {
+					resp.Header.Set(header, echo)
+				}
+				return resp, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if sent != "evt-1" {
+				t.Errorf("expected event id evt-1 to be sent, got %q", sent)
+			}
+
+			var report struct {
+				NotificationID string `json:"notification_id"`
+				Results        []deliveryResult
+			}
+			for _, line := range strings.Split(logs.String(), "\n") {
+				if strings.Contains(line, `"msg":"delivery report"`) {
+					json.Unmarshal([]byte(line), &report)
+				}
+			}
+			if report.NotificationID != "evt-1" {
+				t.Errorf("expected notification_id evt-1, got %q", report.NotificationID)
+			}
+			if len(report.Results) != 1 || report.Results[0].Ack != tt.expectAck {
+				t.Errorf("expected ack %q, got %+v", tt.expectAck, report.Results)
+			}
+			if warned := strings.Contains(logs.String(), "receiver acknowledged another event id"); warned != tt.expectWarn {
+				t.Errorf("expected warning %v, got %v", tt.expectWarn, warned)
+			}
+		})
+	}
+}
@@ -0,0 +1,555 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// evalFunc evaluates a compiled expression against its input. The
+// expression language, shared by Transform and Condition, is a subset of
+// jq: paths (.a.b, .a[0], ."a b"), object and array construction,
+// literals, pipes, arithmetic, comparisons and the && || ! operators.
+type evalFunc func(input any) (any, error)
+
+// compileExpr parses an expression. With names, a bare identifier such
+// as payload is the field of that name of the input.
+func compileExpr(expr string, names bool) (evalFunc, error) {
+	p := &exprParser{src: expr, names: names}
+	if err := p.tokenize(); err != nil {
+		return nil, err
+	}
+	eval, err := p.parsePipe()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return eval, err
+}
+
+const (
+	tokPunct = iota
+	tokIdent
+	tokString
+	tokNumber
+)
+
+type exprToken struct {
+	kind int
+	text string
+}
+
+type exprParser struct {
+	src   string
+	names bool
+	toks  []exprToken
+	pos   int
+}
+
+func (p *exprParser) tokenize() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case i+1 < len(s) && exprOperators[s[i:i+2]]:
+			p.toks = append(p.toks, exprToken{kind: tokPunct, text: s[i : i+2]})
+			i += 2
+		case strings.IndexByte(".{}[](),:|+-*/<>!", c) >= 0:
+			p.toks = append(p.toks, exprToken{kind: tokPunct, text: string(c)})
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return errors.New("unterminated string")
+			}
+			text, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return fmt.Errorf("invalid string %s", s[i:j+1])
+			}
+			p.toks = append(p.toks, exprToken{kind: tokString, text: text})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || s[j] == 'e' || s[j] == 'E') {
+				j++
+			}
+			p.toks = append(p.toks, exprToken{kind: tokNumber, text: s[i:j]})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+			p.toks = append(p.toks, exprToken{kind: tokIdent, text: s[i:j]})
+			i = j
+		default:
+			return fmt.Errorf("unexpected character %q", c)
+		}
+	}
+	return nil
+}
+
+func (p *exprParser) peek(text string) bool {
+	return p.pos < len(p.toks) && p.toks[p.pos].kind == tokPunct && p.toks[p.pos].text == text
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.peek(text) {
+		if p.pos >= len(p.toks) {
+			return fmt.Errorf("expected %q at end", text)
+		}
+		return fmt.Errorf("expected %q, got %q", text, p.toks[p.pos].text)
+	}
+	p.pos++
+	return nil
+}
+
+// parsePipe parses a | b: b is evaluated against the result of a.
+func (p *exprParser) parsePipe() (evalFunc, error) {
+	left, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek("|") {
+		p.pos++
+		right, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(in any) (any, error) {
+			v, err := l(in)
+			if err != nil {
+				return nil, err
+			}
+			return right(v)
+		}
+	}
+	return left, nil
+}
+
+var exprOperators = map[string]bool{"==": true, "!=": true, "<=": true, ">=": true, "&&": true, "||": true}
+
+var exprPrecedence = [][]string{{"||"}, {"&&"}, {"==", "!=", "<", "<=", ">", ">="}, {"+", "-"}, {"*", "/"}}
+
+func (p *exprParser) parseBinary(level int) (evalFunc, error) {
+	if level == len(exprPrecedence) {
+		return p.parsePostfix()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range exprPrecedence[level] {
+			if p.peek(o) {
+				op = o
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(in any) (any, error) {
+			a, err := l(in)
+			if err != nil {
+				return nil, err
+			}
+			// && and || short-circuit and yield booleans
+			if op == "&&" && !exprTruthy(a) || op == "||" && exprTruthy(a) {
+				return op == "||", nil
+			}
+			b, err := right(in)
+			if err != nil {
+				return nil, err
+			}
+			switch op {
+			case "&&", "||":
+				return exprTruthy(b), nil
+			case "==", "!=", "<", "<=", ">", ">=":
+				return exprCompare(op, a, b)
+			}
+			return exprArith(op, a, b)
+		}
+	}
+}
+
+func (p *exprParser) parsePostfix() (evalFunc, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var index evalFunc
+		switch {
+		case p.peek("."):
+			p.pos++
+			key, err := p.parseKey()
+			if err != nil {
+				return nil, err
+			}
+			index = func(any) (any, error) { return key, nil }
+		case p.peek("["):
+			p.pos++
+			if index, err = p.parsePipe(); err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+		default:
+			return base, nil
+		}
+		b, idx := base, index
+		base = func(in any) (any, error) {
+			v, err := b(in)
+			if err != nil {
+				return nil, err
+			}
+			i, err := idx(in)
+			if err != nil {
+				return nil, err
+			}
+			return exprIndex(v, i)
+		}
+	}
+}
+
+// parseKey reads the name after a dot: an identifier or a string.
+func (p *exprParser) parseKey() (string, error) {
+	if p.pos < len(p.toks) && (p.toks[p.pos].kind == tokIdent || p.toks[p.pos].kind == tokString) {
+		p.pos++
+		return p.toks[p.pos-1].text, nil
+	}
+	return "", errors.New("expected a field name after \".\"")
+}
+
+func (p *exprParser) parsePrimary() (evalFunc, error) {
+	if p.pos >= len(p.toks) {
+		return nil, errors.New("unexpected end of expression")
+	}
+	tok := p.toks[p.pos]
+	switch tok.kind {
+	case tokString:
+		p.pos++
+		return func(any) (any, error) { return tok.text, nil }, nil
+	case tokNumber:
+		p.pos++
+		n := json.Number(tok.text)
+		if _, err := n.Float64(); err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return func(any) (any, error) { return n, nil }, nil
+	case tokIdent:
+		p.pos++
+		switch tok.text {
+		case "true", "false":
+			v := tok.text == "true"
+			return func(any) (any, error) { return v, nil }, nil
+		case "null":
+			return func(any) (any, error) { return nil, nil }, nil
+		}
+		if p.names {
+			return func(in any) (any, error) { return exprIndex(in, tok.text) }, nil
+		}
+		return nil, fmt.Errorf("unknown identifier %q", tok.text)
+	}
+	p.pos++
+	switch tok.text {
+	case ".":
+		// a dot alone is the input, .name and .[i] index it
+		if p.pos < len(p.toks) && (p.toks[p.pos].kind == tokIdent || p.toks[p.pos].kind == tokString) {
+			key, _ := p.parseKey()
+			return func(in any) (any, error) { return exprIndex(in, key) }, nil
+		}
+		return func(in any) (any, error) { return in, nil }, nil
+	case "(":
+		e, err := p.parsePipe()
+		if err != nil {
+			return nil, err
+		}
+		return e, p.expect(")")
+	case "[":
+		return p.parseArray()
+	case "{":
+		return p.parseObject()
+	case "!":
+		e, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return func(in any) (any, error) {
+			v, err := e(in)
+			if err != nil {
+				return nil, err
+			}
+			return !exprTruthy(v), nil
+		}, nil
+	case "-":
+		e, err := p.parsePostfix()
+		if err != nil {
+			return nil, err
+		}
+		return func(in any) (any, error) {
+			v, err := e(in)
+			if err != nil {
+				return nil, err
+			}
+			return exprArith("-", json.Number("0"), v)
+		}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
+
+func (p *exprParser) parseArray() (evalFunc, error) {
+	var elems []evalFunc
+	for !p.peek("]") {
+		e, err := p.parseBinary(0)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, e)
+		if !p.peek(",") {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect("]"); err != nil {
+		return nil, err
+	}
+	return func(in any) (any, error) {
+		out := make([]any, 0, len(elems))
+		for _, e := range elems {
+			v, err := e(in)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}, nil
+}
+
+// parseObject parses {key: value, ...}; {name} is short for {name: .name}.
+func (p *exprParser) parseObject() (evalFunc, error) {
+	var keys []string
+	var values []evalFunc
+	for !p.peek("}") {
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, errors.New("expected an object key")
+		}
+		var value evalFunc
+		if p.peek(":") {
+			p.pos++
+			if value, err = p.parseBinary(0); err != nil {
+				return nil, err
+			}
+		} else {
+			value = func(in any) (any, error) { return exprIndex(in, key) }
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+		if !p.peek(",") {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect("}"); err != nil {
+		return nil, err
+	}
+	return func(in any) (any, error) {
+		out := make(map[string]any, len(keys))
+		for i, key := range keys {
+			v, err := values[i](in)
+			if err != nil {
+				return nil, err
+			}
+			out[key] = v
+		}
+		return out, nil
+	}, nil
+}
+
+// decodeExprValue decodes JSON keeping numbers as json.Number, so that
+// large integers survive a round trip.
+func decodeExprValue(data []byte) (any, error) {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("trailing data after json value")
+	}
+	return v, nil
+}
+
+// exprIndex looks up a key in an object or an index in an array. Missing
+// entries and indexing null yield null.
+func exprIndex(v, i any) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		if key, ok := i.(string); ok {
+			return v[key], nil
+		}
+	case []any:
+		if n, ok := exprNumber(i); ok {
+			idx := int(n)
+			if idx < 0 {
+				idx += len(v)
+			}
+			if idx < 0 || idx >= len(v) {
+				return nil, nil
+			}
+			return v[idx], nil
+		}
+	}
+	return nil, fmt.Errorf("cannot index %s with %s", exprType(v), exprType(i))
+}
+
+func exprArith(op string, a, b any) (any, error) {
+	if x, ok := exprNumber(a); ok {
+		if y, ok := exprNumber(b); ok {
+			var r float64
+			switch op {
+			case "+":
+				r = x + y
+			case "-":
+				r = x - y
+			case "*":
+				r = x * y
+			case "/":
+				if y == 0 {
+					return nil, errors.New("division by zero")
+				}
+				r = x / y
+			}
+			return json.Number(strconv.FormatFloat(r, 'f', -1, 64)), nil
+		}
+	}
+	if op == "+" {
+		switch x := a.(type) {
+		case nil:
+			return b, nil
+		case string:
+			if y, ok := b.(string); ok {
+				return x + y, nil
+			}
+		case []any:
+			if y, ok := b.([]any); ok {
+				return append(append([]any{}, x...), y...), nil
+			}
+		case map[string]any:
+			if y, ok := b.(map[string]any); ok {
+				out := make(map[string]any, len(x)+len(y))
+				for k, v := range x {
+					out[k] = v
+				}
+				for k, v := range y {
+					out[k] = v
+				}
+				return out, nil
+			}
+		}
+		if b == nil {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot apply %s to %s and %s", op, exprType(a), exprType(b))
+}
+
+// exprCompare orders numbers and strings; other values are only equal or
+// not.
+func exprCompare(op string, a, b any) (any, error) {
+	cmp := 0
+	x, xok := exprNumber(a)
+	y, yok := exprNumber(b)
+	as, asok := a.(string)
+	bs, bsok := b.(string)
+	switch {
+	case xok && yok:
+		cmp = compareFloat(x, y)
+	case asok && bsok:
+		cmp = strings.Compare(as, bs)
+	case op == "==" || op == "!=":
+		if !reflect.DeepEqual(a, b) {
+			cmp = 1
+		}
+	default:
+		return nil, fmt.Errorf("cannot compare %s and %s", exprType(a), exprType(b))
+	}
+	switch op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func compareFloat(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// exprTruthy follows jq: only false and null are false.
+func exprTruthy(v any) bool {
+	return v != nil && v != false
+}
+
+func exprNumber(v any) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func exprType(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	}
+	return "object"
+}
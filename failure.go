@@ -0,0 +1,51 @@
+package header2post
+
+import (
+	"fmt"
+	"net/http"
+)
+
+const (
+	failOpen   = "failopen"
+	failClosed = "failclosed"
+
+	defaultFailureBody        = `{"error":"notification failed"}`
+	defaultFailureContentType = "application/json"
+)
+
+// failureResponse replaces the client response when a notification fails
+// in failclosed mode.
+type failureResponse struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+// newFailureResponse returns nil unless FailureMode is failclosed.
+func newFailureResponse(config *Config) (*failureResponse, error) {
+	switch config.FailureMode {
+	case "", failOpen:
+		return nil, nil
+	case failClosed:
+	default:
+		return nil, fmt.Errorf("invalid failuremode: %q", config.FailureMode)
+	}
+	f := &failureResponse{
+		status:      config.FailureStatusCode,
+		contentType: config.FailureContentType,
+		body:        []byte(config.FailureBody),
+	}
+	if f.status == 0 {
+		f.status = http.StatusBadGateway
+	}
+	if f.status < 400 || f.status > 599 {
+		return nil, fmt.Errorf("invalid failurestatuscode: %d", config.FailureStatusCode)
+	}
+	if len(f.body) == 0 {
+		f.body = []byte(defaultFailureBody)
+	}
+	if f.contentType == "" {
+		f.contentType = defaultFailureContentType
+	}
+	return f, nil
+}
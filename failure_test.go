@@ -0,0 +1,86 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPFailClosed(t *testing.T) {
+	valid := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	tests := []struct {
+		name           string
+		config         Config
+		payload        string
+		status         int
+		expectCode     int
+		expectBody     string
+		expectType     string
+		expectUpstream bool
+	}{
+		{name: "delivered", config: Config{FailureMode: "failclosed"}, payload: valid, status: http.StatusAccepted, expectCode: http.StatusCreated, expectBody: "created", expectType: "text/plain", expectUpstream: true},
+		{name: "failed", config: Config{FailureMode: "failclosed"}, payload: valid, status: http.StatusInternalServerError, expectCode: http.StatusBadGateway, expectBody: defaultFailureBody, expectType: "application/json", expectUpstream: true},
+		{name: "decode error", config: Config{FailureMode: "failclosed"}, payload: "%%%", expectCode: http.StatusBadGateway, expectBody: defaultFailureBody, expectType: "application/json", expectUpstream: true},
+		{name: "custom", config: Config{FailureMode: "failclosed", FailureStatusCode: 503, FailureBody: "try later", FailureContentType: "text/plain"}, payload: valid, status: http.StatusInternalServerError, expectCode: 503, expectBody: "try later", expectType: "text/plain", expectUpstream: true},
+		{name: "fail open", payload: valid, status: http.StatusInternalServerError, expectCode: http.StatusCreated, expectBody: "created", expectType: "text/plain", expectUpstream: true},
+		{name: "request delivered", config: Config{FailureMode: "failclosed", TriggerSource: "request"}, payload: valid, status: http.StatusAccepted, expectCode: http.StatusCreated, expectBody: "created", expectType: "text/plain", expectUpstream: true},
+		{name: "request failed", config: Config{FailureMode: "failclosed", TriggerSource: "request"}, payload: valid, status: http.StatusInternalServerError, expectCode: http.StatusBadGateway, expectBody: defaultFailureBody, expectType: "application/json"},
+		{name: "queued", config: Config{FailureMode: "failclosed", BatchMaxWait: "1h"}, payload: valid, expectCode: http.StatusCreated, expectBody: "created", expectType: "text/plain", expectUpstream: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			upstream := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = true
+				w.Header().Set("X-Notify", tt.payload)
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("created"))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("X-Notify", tt.payload)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if upstream != tt.expectUpstream {
+				t.Errorf("expected upstream called %v, got %v", tt.expectUpstream, upstream)
+			}
+			if w.Code != tt.expectCode || w.Body.String() != tt.expectBody || w.Header().Get("Content-Type") != tt.expectType {
+				t.Errorf("expected %d %q %q, got %d %q %q", tt.expectCode, tt.expectType, tt.expectBody, w.Code, w.Header().Get("Content-Type"), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNewFailureResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		expect string
+	}{
+		{name: "mode", config: Config{FailureMode: "closed"}, expect: `invalid failuremode: "closed"`},
+		{name: "status", config: Config{FailureMode: "failclosed", FailureStatusCode: 200}, expect: "invalid failurestatuscode: 200"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newFailureResponse(&tt.config); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	formatForm      = "form"
+	formatMultipart = "multipart"
+
+	defaultFormField         = "payload"
+	defaultMultipartField    = "file"
+	defaultMultipartFilename = "payload.json"
+)
+
+// newFormFields validates the static FormFields.
+func newFormFields(m map[string]string) (url.Values, error) {
+	fields := url.Values{}
+	for k, v := range m {
+		if strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("formfields names cannot be empty")
+		}
+		fields.Set(k, v)
+	}
+	return fields, nil
+}
+
+// encodeForm posts the payload as the FormField value of an
+// application/x-www-form-urlencoded body, next to the static FormFields.
+func (f *payloadFormat) encodeForm(data []byte) *encodedPayload {
+	form := url.Values{}
+	for k, v := range f.formFields {
+		form[k] = v
+	}
+	form.Set(f.formField, string(data))
+	return &encodedPayload{body: []byte(form.Encode()), contentType: "application/x-www-form-urlencoded"}
+}
+
+// encodeMultipart posts the payload as a file part of a
+// multipart/form-data body, after the static FormFields.
+func (f *payloadFormat) encodeMultipart(data []byte) (*encodedPayload, error) {
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	names := make([]string, 0, len(f.formFields))
+	for k := range f.formFields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		for _, v := range f.formFields[k] {
+			if err := w.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	contentType := "application/json"
+	if !json.Valid(data) {
+		contentType = "application/octet-stream"
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", mime.FormatMediaType("form-data", map[string]string{"name": f.formField, "filename": f.filename}))
+	h.Set("Content-Type", contentType)
+	part, err := w.CreatePart(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &encodedPayload{body: buf.Bytes(), contentType: w.FormDataContentType()}, nil
+}
@@ -0,0 +1,237 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	formatJSON        = "json"
+	formatCloudEvents = "cloudevents"
+
+	cloudEventsStructured = "structured"
+	cloudEventsBinary     = "binary"
+
+	defaultCloudEventsType = "com.github.arwoosa.header2post.notification"
+)
+
+// encodedPayload is a decoded payload rendered in the configured output
+// format, ready to become the body of a notification request.
+type encodedPayload struct {
+	body        []byte
+	contentType string
+	header      http.Header
+}
+
+// payloadFormat renders decoded payloads for delivery.
+type payloadFormat struct {
+	name     string
+	ceMode   string
+	ceSource string
+	ceType   string
+
+	chatTemplate *keyTemplate
+	body         PayloadCodec
+
+	formField  string
+	formFields url.Values
+	filename   string
+	xmlRoot    string
+	source     string
+}
+
+func newPayloadFormat(config *Config, name string) (*payloadFormat, error) {
+	f := &payloadFormat{name: config.Format}
+	if config.BodyEncoding != "" && config.BodyEncoding != codecJSON {
+		if config.Format != "" && config.Format != formatJSON {
+			return nil, fmt.Errorf("bodyencoding cannot be combined with format %q", config.Format)
+		}
+		codec, ok := lookupPayloadCodec(config.BodyEncoding)
+		if !ok {
+			return nil, fmt.Errorf("unsupported bodyencoding: %q", config.BodyEncoding)
+		}
+		f.body = codec
+	}
+	switch config.Format {
+	case "", formatJSON:
+		f.name = formatJSON
+	case formatCloudEvents:
+		f.ceMode = config.CloudEventsMode
+		switch f.ceMode {
+		case "":
+			f.ceMode = cloudEventsStructured
+		case cloudEventsStructured, cloudEventsBinary:
+		default:
+			return nil, fmt.Errorf("invalid cloudeventsmode: %q", config.CloudEventsMode)
+		}
+		f.ceSource = config.CloudEventsSource
+		if f.ceSource == "" {
+			f.ceSource = "/header2post/" + name
+		}
+		f.ceType = config.CloudEventsType
+		if f.ceType == "" {
+			f.ceType = defaultCloudEventsType
+		}
+	case formatSlack, formatDiscord, formatTeams:
+		tmpl, err := newKeyTemplate("chattemplate", config.ChatTemplate)
+		if err != nil {
+			return nil, err
+		}
+		f.chatTemplate = tmpl
+	case formatProtobuf:
+		f.source = "/header2post/" + name
+	case formatMsgpack, formatStandardWebhooks:
+	case formatXML:
+		f.xmlRoot = config.XmlRootElement
+		if f.xmlRoot == "" {
+			f.xmlRoot = defaultXmlRootElement
+		}
+		if xmlName(f.xmlRoot) != f.xmlRoot {
+			return nil, fmt.Errorf("invalid xmlrootelement: %q", config.XmlRootElement)
+		}
+	case formatForm, formatMultipart:
+		f.formField = config.FormField
+		if f.formField == "" {
+			f.formField = defaultFormField
+			if f.name == formatMultipart {
+				f.formField = defaultMultipartField
+			}
+		}
+		f.filename = config.MultipartFilename
+		if f.filename == "" {
+			f.filename = defaultMultipartFilename
+		}
+		fields, err := newFormFields(config.FormFields)
+		if err != nil {
+			return nil, err
+		}
+		f.formFields = fields
+	default:
+		return nil, fmt.Errorf("unsupported format: %q", config.Format)
+	}
+	return f, nil
+}
+
+// batchable reports whether multiple payloads can be combined into one
+// request in this format.
+func (f *payloadFormat) batchable() bool {
+	switch f.name {
+	case formatSlack, formatDiscord, formatTeams, formatForm, formatMultipart, formatXML, formatProtobuf, formatMsgpack:
+		return false
+	case formatCloudEvents:
+		return f.ceMode != cloudEventsBinary
+	}
+	return true
+}
+
+// encode renders a single payload.
+func (f *payloadFormat) encode(data []byte) (*encodedPayload, error) {
+	switch f.name {
+	case formatCloudEvents:
+		if f.ceMode == cloudEventsBinary {
+			return f.cloudEventBinary(data), nil
+		}
+		body, err := json.Marshal(f.cloudEvent(data))
+		if err != nil {
+			return nil, err
+		}
+		return &encodedPayload{body: body, contentType: "application/cloudevents+json"}, nil
+	case formatSlack, formatDiscord, formatTeams:
+		return f.encodeChat(data)
+	case formatForm:
+		return f.encodeForm(data), nil
+	case formatMultipart:
+		return f.encodeMultipart(data)
+	case formatXML:
+		return f.encodeXML(data), nil
+	case formatProtobuf:
+		return f.encodeProtobuf(data), nil
+	case formatMsgpack:
+		return f.encodeMsgpack(data), nil
+	case formatStandardWebhooks:
+		return &encodedPayload{body: data, contentType: "application/json", header: webhookHeader()}, nil
+	}
+	if f.body != nil {
+		body, contentType, err := f.body.Encode(data)
+		if err != nil {
+			return nil, err
+		}
+		return &encodedPayload{body: body, contentType: contentType}, nil
+	}
+	return &encodedPayload{body: data, contentType: "application/json"}, nil
+}
+
+// encodeBatch renders several payloads as one JSON array.
+func (f *payloadFormat) encodeBatch(items [][]byte) (*encodedPayload, error) {
+	contentType := "application/json"
+	elems := make([]any, 0, len(items))
+	for _, data := range items {
+		if f.name == formatCloudEvents {
+			elems = append(elems, f.cloudEvent(data))
+			continue
+		}
+		if json.Valid(data) {
+			elems = append(elems, json.RawMessage(data))
+			continue
+		}
+		elems = append(elems, string(data))
+	}
+	if f.name == formatCloudEvents {
+		contentType = "application/cloudevents-batch+json"
+	}
+	body, err := json.Marshal(elems)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == formatStandardWebhooks {
+		return &encodedPayload{body: body, contentType: contentType, header: webhookHeader()}, nil
+	}
+	return &encodedPayload{body: body, contentType: contentType}, nil
+}
+
+// cloudEvent is a CloudEvents 1.0 structured-mode envelope.
+type cloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            string          `json:"time"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      []byte          `json:"data_base64,omitempty"`
+}
+
+func (f *payloadFormat) cloudEvent(data []byte) *cloudEvent {
+	ev := &cloudEvent{
+		SpecVersion: "1.0",
+		ID:          generateID(),
+		Source:      f.ceSource,
+		Type:        f.ceType,
+		Time:        timeNow().UTC().Format(time.RFC3339Nano),
+	}
+	if json.Valid(data) {
+		ev.DataContentType = "application/json"
+		ev.Data = data
+	} else {
+		ev.DataContentType = "application/octet-stream"
+		ev.DataBase64 = data
+	}
+	return ev
+}
+
+func (f *payloadFormat) cloudEventBinary(data []byte) *encodedPayload {
+	h := make(http.Header)
+	h.Set("ce-specversion", "1.0")
+	h.Set("ce-id", generateID())
+	h.Set("ce-source", f.ceSource)
+	h.Set("ce-type", f.ceType)
+	h.Set("ce-time", timeNow().UTC().Format(time.RFC3339Nano))
+	contentType := "application/json"
+	if !json.Valid(data) {
+		contentType = "application/octet-stream"
+	}
+	return &encodedPayload{body: data, contentType: contentType, header: h}
+}
@@ -0,0 +1,240 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"testing"
+	"time"
+)
+
+func TestPayloadFormat(t *testing.T) {
+	generateID = func() string { return "evt-1" }
+	timeNow = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() {
+		generateID = newUUID
+		timeNow = time.Now
+	}()
+
+	tests := []struct {
+		name        string
+		config      Config
+		data        string
+		expectBody  string
+		expectType  string
+		expectHead  map[string]string
+		expectErr   error
+		expectBatch bool
+	}{
+		{
+			name:        "default json",
+			data:        `{"a":1}`,
+			expectBody:  `{"a":1}`,
+			expectType:  "application/json",
+			expectBatch: true,
+		},
+		{
+			name:        "cloudevents structured",
+			config:      Config{Format: "cloudevents", CloudEventsType: "order.created"},
+			data:        `{"a":1}`,
+			expectBody:  `{"specversion":"1.0","id":"evt-1","source":"/header2post/test","type":"order.created","time":"2024-01-02T03:04:05Z","datacontenttype":"application/json","data":{"a":1}}`,
+			expectType:  "application/cloudevents+json",
+			expectBatch: true,
+		},
+		{
+			name:        "cloudevents structured binary data",
+			config:      Config{Format: "cloudevents", CloudEventsSource: "/orders"},
+			data:        "raw",
+			expectBody:  `{"specversion":"1.0","id":"evt-1","source":"/orders","type":"com.github.arwoosa.header2post.notification","time":"2024-01-02T03:04:05Z","datacontenttype":"application/octet-stream","data_base64":"cmF3"}`,
+			expectType:  "application/cloudevents+json",
+			expectBatch: true,
+		},
+		{
+			name:       "cloudevents binary",
+			config:     Config{Format: "cloudevents", CloudEventsMode: "binary"},
+			data:       `{"a":1}`,
+			expectBody: `{"a":1}`,
+			expectType: "application/json",
+			expectHead: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Id":          "evt-1",
+				"Ce-Source":      "/header2post/test",
+				"Ce-Time":        "2024-01-02T03:04:05Z",
+			},
+		},
+		{
+			name:      "invalid cloudevents mode",
+			config:    Config{Format: "cloudevents", CloudEventsMode: "mixed"},
+			expectErr: errors.New(`invalid cloudeventsmode: "mixed"`),
+		},
+		{
+			name:       "form",
+			config:     Config{Format: "form", FormFields: map[string]string{"source": "gateway", "token": "a b"}},
+			data:       `{"a":"x&y"}`,
+			expectBody: "payload=%7B%22a%22%3A%22x%26y%22%7D&source=gateway&token=a+b",
+			expectType: "application/x-www-form-urlencoded",
+		},
+		{
+			name:       "form field",
+			config:     Config{Format: "form", FormField: "event"},
+			data:       `{"a":1}`,
+			expectBody: "event=%7B%22a%22%3A1%7D",
+			expectType: "application/x-www-form-urlencoded",
+		},
+		{
+			name:      "form empty field name",
+			config:    Config{Format: "form", FormFields: map[string]string{" ": "x"}},
+			expectErr: errors.New("formfields names cannot be empty"),
+		},
+		{
+			name:       "xml",
+			config:     Config{Format: "xml"},
+			data:       `{"order":{"id":12,"lines":[{"sku":"a&b"},{"sku":"c"}],"paid":true,"note":null},"1st key":[[1,2]]}`,
+			expectBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<notification><_1st_key><item>1</item><item>2</item></_1st_key><order><id>12</id><lines><sku>a&amp;b</sku></lines><lines><sku>c</sku></lines><note/><paid>true</paid></order></notification>`,
+			expectType: "application/xml",
+		},
+		{
+			name:       "xml array",
+			config:     Config{Format: "xml", XmlRootElement: "events"},
+			data:       `[1,"x"]`,
+			expectBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<events><item>1</item><item>x</item></events>`,
+			expectType: "application/xml",
+		},
+		{
+			name:       "xml raw",
+			config:     Config{Format: "xml"},
+			data:       "a]]>b",
+			expectBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<notification><![CDATA[a]]]]><![CDATA[>b]]></notification>`,
+			expectType: "application/xml",
+		},
+		{
+			name:      "invalid xml root",
+			config:    Config{Format: "xml", XmlRootElement: "my root"},
+			expectErr: errors.New(`invalid xmlrootelement: "my root"`),
+		},
+		{
+			name:      "unsupported format",
+			config:    Config{Format: "yaml"},
+			expectErr: errors.New(`unsupported format: "yaml"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := newPayloadFormat(&tt.config, "test")
+			if tt.expectErr != nil {
+				if err == nil || err.Error() != tt.expectErr.Error() {
+					t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			p, err := f.encode([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(p.body) != tt.expectBody {
+				t.Errorf("expected body %s, got %s", tt.expectBody, p.body)
+			}
+			if p.contentType != tt.expectType {
+				t.Errorf("expected content type %q, got %q", tt.expectType, p.contentType)
+			}
+			for k, v := range tt.expectHead {
+				if p.header.Get(k) != v {
+					t.Errorf("expected header %s=%q, got %q", k, v, p.header.Get(k))
+				}
+			}
+			if f.batchable() != tt.expectBatch {
+				t.Errorf("expected batchable %v", tt.expectBatch)
+			}
+		})
+	}
+}
+
+func TestPayloadFormatBatch(t *testing.T) {
+	generateID = func() string { return "evt-1" }
+	timeNow = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	defer func() {
+		generateID = newUUID
+		timeNow = time.Now
+	}()
+
+	f, _ := newPayloadFormat(&Config{Format: "cloudevents"}, "test")
+	p, err := f.encodeBatch([][]byte{[]byte(`{"a":1}`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `[{"specversion":"1.0","id":"evt-1","source":"/header2post/test","type":"com.github.arwoosa.header2post.notification","time":"2024-01-02T03:04:05Z","datacontenttype":"application/json","data":{"a":1}}]`
+	if string(p.body) != expect {
+		t.Errorf("expected body %s, got %s", expect, p.body)
+	}
+	if p.contentType != "application/cloudevents-batch+json" {
+		t.Errorf("unexpected content type %q", p.contentType)
+	}
+}
+
+func TestPayloadFormatMultipart(t *testing.T) {
+	f, err := newPayloadFormat(&Config{Format: "multipart", FormFields: map[string]string{"source": "gateway", "kind": "order"}}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.batchable() {
+		t.Errorf("expected multipart not batchable")
+	}
+	p, err := f.encode([]byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(p.contentType)
+	if err != nil || mediaType != "multipart/form-data" {
+		t.Fatalf("unexpected content type %q", p.contentType)
+	}
+	form, err := multipart.NewReader(bytes.NewReader(p.body), params["boundary"]).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if form.Value["source"][0] != "gateway" || form.Value["kind"][0] != "order" {
+		t.Errorf("unexpected fields %v", form.Value)
+	}
+	file := form.File["file"][0]
+	if file.Filename != "payload.json" || file.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected file part %v %v", file.Filename, file.Header)
+	}
+	r, _ := file.Open()
+	body, _ := io.ReadAll(r)
+	if string(body) != `{"a":1}` {
+		t.Errorf("unexpected file content %q", body)
+	}
+}
+
+func TestPayloadFormatProtobuf(t *testing.T) {
+	generateID = func() string { return "evt-1" }
+	timeNow = func() time.Time { return time.Unix(0, 1700000000000000000) }
+	defer func() {
+		generateID = newUUID
+		timeNow = time.Now
+	}()
+	f, err := newPayloadFormat(&Config{Format: "protobuf"}, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := f.encode([]byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.contentType != "application/x-protobuf" || f.batchable() {
+		t.Errorf("unexpected content type %q or batchable", p.contentType)
+	}
+	for field, expect := range map[int]string{1: "evt-1", 2: "/header2post/orders", 4: "application/json", 5: `{"a":1}`} {
+		if got, err := protoField(p.body, field); err != nil || string(got) != expect {
+			t.Errorf("expected field %d %q, got %q %v", field, expect, got, err)
+		}
+	}
+	if !bytes.Contains(p.body, binary.AppendUvarint([]byte{3 << 3}, 1700000000000000000)) {
+		t.Errorf("missing time field in %x", p.body)
+	}
+}
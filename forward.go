@@ -0,0 +1,202 @@
+package header2post
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// redacted replaces the value of sensitive headers in logged dumps.
+const redacted = "[REDACTED]"
+
+// sensitiveHeaders carry credentials. They are never matched by a pattern
+// and must be named explicitly to be forwarded.
+var sensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+func sensitiveHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	for _, h := range sensitiveHeaders {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultRequestIdHeaders are propagated unless configured otherwise.
+var defaultRequestIdHeaders = []string{"X-Request-Id", "X-Amzn-Trace-Id"}
+
+// traceContextHeaders carry W3C trace context and B3 propagation.
+var traceContextHeaders = []string{
+	"Traceparent", "Tracestate",
+	"B3", "X-B3-Traceid", "X-B3-Spanid", "X-B3-Parentspanid", "X-B3-Sampled", "X-B3-Flags",
+}
+
+// headerSelector matches header names against a list of exact names and
+// glob patterns such as X-Tenant-*. Matching is case-insensitive.
+type headerSelector struct {
+	names    []string
+	patterns []string
+}
+
+// newHeaderSelector compiles the entries of the named option. Entries
+// containing *, ? or [ are treated as patterns.
+func newHeaderSelector(option string, entries []string) (*headerSelector, error) {
+	s := &headerSelector{}
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if !strings.ContainsAny(e, "*?[") {
+			s.names = append(s.names, http.CanonicalHeaderKey(e))
+			continue
+		}
+		pattern := strings.ToLower(e)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid %s pattern: %q", option, e)
+		}
+		s.patterns = append(s.patterns, pattern)
+	}
+	return s, nil
+}
+
+// match reports whether name is selected.
+func (s *headerSelector) match(name string) bool {
+	if s == nil {
+		return false
+	}
+	name = http.CanonicalHeaderKey(name)
+	for _, n := range s.names {
+		if n == name {
+			return true
+		}
+	}
+	lower := strings.ToLower(name)
+	for _, p := range s.patterns {
+		if ok, _ := path.Match(p, lower); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// copy sets the trimmed, non-empty values of the selected headers of src
+// on dst, skipping headers matched by deny. Patterns never select
+// sensitive headers.
+func (s *headerSelector) copy(dst, src http.Header, deny *headerSelector) {
+	if s == nil {
+		return
+	}
+	for _, h := range s.names {
+		if deny.match(h) {
+			continue
+		}
+		if headerValu := strings.TrimSpace(src.Get(h)); headerValu != "" {
+			dst.Set(h, headerValu)
+		}
+	}
+	if len(s.patterns) == 0 {
+		return
+	}
+	for h := range src {
+		if sensitiveHeader(h) || deny.match(h) || !s.match(h) {
+			continue
+		}
+		if headerValu := strings.TrimSpace(src.Get(h)); headerValu != "" {
+			dst.Set(h, headerValu)
+		}
+	}
+}
+
+// strip deletes the selected headers from h.
+func (s *headerSelector) strip(h http.Header) {
+	if s == nil {
+		return
+	}
+	for name := range h {
+		if s.match(name) {
+			h.Del(name)
+		}
+	}
+}
+
+// newHeaderMap canonicalizes the source and destination names of a
+// ForwardHeaderMap.
+func newHeaderMap(m map[string]string) (map[string]string, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(m))
+	for src, dst := range m {
+		src, dst = strings.TrimSpace(src), strings.TrimSpace(dst)
+		if src == "" || dst == "" {
+			return nil, fmt.Errorf("forwardheadermap names cannot be empty")
+		}
+		out[http.CanonicalHeaderKey(src)] = http.CanonicalHeaderKey(dst)
+	}
+	return out, nil
+}
+
+// forwarded returns the trace context, request id and configured forward
+// headers present on the incoming request and, after them, on the
+// upstream response.
+func (a *notify) forwarded(ex *exchange) http.Header {
+	out := http.Header{}
+	a.traceContext.copy(out, ex.req.Header, a.denyForwardHeaders)
+	a.requestIdHeaders.copy(out, ex.req.Header, a.denyForwardHeaders)
+	a.forwardHeaders.copy(out, ex.req.Header, a.denyForwardHeaders)
+	for src := range a.forwardHeaderMap {
+		out.Del(src)
+	}
+	for src, dst := range a.forwardHeaderMap {
+		if a.denyForwardHeaders.match(src) {
+			continue
+		}
+		if headerValu := strings.TrimSpace(ex.req.Header.Get(src)); headerValu != "" {
+			out.Set(dst, headerValu)
+		}
+	}
+	if cookie := a.cookies(ex.req); cookie != "" {
+		out.Set("Cookie", cookie)
+	}
+	if ex.respHeader != nil {
+		a.forwardResponseHeaders.copy(out, ex.respHeader, a.denyForwardHeaders)
+	}
+	return out
+}
+
+// cookies rebuilds a Cookie header holding only the ForwardCookies
+// present on req.
+func (a *notify) cookies(req *http.Request) string {
+	if len(a.forwardCookies) == 0 || a.denyForwardHeaders.match("Cookie") {
+		return ""
+	}
+	var parts []string
+	for _, name := range a.forwardCookies {
+		if c, err := req.Cookie(name); err == nil {
+			parts = append(parts, (&http.Cookie{Name: c.Name, Value: c.Value}).String())
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// redact returns a loggable copy of forwarded headers with the values of
+// sensitive headers, including those renamed by ForwardHeaderMap, replaced
+// by [REDACTED].
+func (a *notify) redact(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k := range h {
+		out[k] = h.Get(k)
+		if sensitiveHeader(k) {
+			out[k] = redacted
+		}
+	}
+	for src, dst := range a.forwardHeaderMap {
+		if _, ok := out[dst]; ok && sensitiveHeader(src) {
+			out[dst] = redacted
+		}
+	}
+	return out
+}
@@ -0,0 +1,317 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwarded(t *testing.T) {
+	tests := []struct {
+		name       string
+		reqHeader  http.Header
+		respHeader http.Header
+		expect     http.Header
+	}{
+		{
+			name:       "request and response",
+			reqHeader:  http.Header{"X-Tenant": {"acme"}},
+			respHeader: http.Header{"X-Resource-Id": {"42"}, "Etag": {` "v1" `}},
+			expect:     http.Header{"X-Tenant": {"acme"}, "X-Resource-Id": {"42"}, "Etag": {`"v1"`}},
+		},
+		{
+			name:       "response overrides request",
+			reqHeader:  http.Header{"X-Tenant": {"acme"}, "X-Resource-Id": {"1"}},
+			respHeader: http.Header{"X-Resource-Id": {"42"}},
+			expect:     http.Header{"X-Tenant": {"acme"}, "X-Resource-Id": {"42"}},
+		},
+		{
+			name:      "renamed",
+			reqHeader: http.Header{"Authorization": {"Bearer user"}, "X-Tenant": {"acme"}},
+			expect:    http.Header{"X-Original-Authorization": {"Bearer user"}, "X-Tenant": {"acme"}},
+		},
+		{
+			name:      "request trigger",
+			reqHeader: http.Header{"X-Tenant": {"acme"}, "X-Resource-Id": {"1"}},
+			expect:    http.Header{"X-Tenant": {"acme"}},
+		},
+	}
+	a := &notify{
+		forwardHeaders:         &headerSelector{names: []string{"X-Tenant", "Authorization"}},
+		forwardResponseHeaders: &headerSelector{names: []string{"X-Resource-Id", "Etag"}},
+		forwardHeaderMap:       map[string]string{"Authorization": "X-Original-Authorization"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := a.forwarded(&exchange{req: &http.Request{Header: tt.reqHeader}, respHeader: tt.respHeader})
+			if len(got) != len(tt.expect) {
+				t.Fatalf("expected %v, got %v", tt.expect, got)
+			}
+			for k := range tt.expect {
+				if got.Get(k) != tt.expect.Get(k) {
+					t.Errorf("expected %s %q, got %q", k, tt.expect.Get(k), got.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestHeaderSelector(t *testing.T) {
+	tests := []struct {
+		name      string
+		entries   []string
+		src       http.Header
+		expect    http.Header
+		expectErr string
+	}{
+		{
+			name:    "exact names",
+			entries: []string{"x-tenant", " X-Trace ", ""},
+			src:     http.Header{"X-Tenant": {"acme"}, "X-Trace": {"t1"}, "X-Other": {"o"}},
+			expect:  http.Header{"X-Tenant": {"acme"}, "X-Trace": {"t1"}},
+		},
+		{
+			name:    "prefix pattern",
+			entries: []string{"X-Tenant-*"},
+			src:     http.Header{"X-Tenant-Id": {"1"}, "X-Tenant-Region": {"eu"}, "X-Tenant": {"acme"}, "X-Tenant-Empty": {" "}},
+			expect:  http.Header{"X-Tenant-Id": {"1"}, "X-Tenant-Region": {"eu"}},
+		},
+		{
+			name:    "sensitive headers need explicit names",
+			entries: []string{"*", "Cookie"},
+			src:     http.Header{"Authorization": {"Bearer x"}, "Cookie": {"a=b"}, "X-Tenant": {"acme"}},
+			expect:  http.Header{"Cookie": {"a=b"}, "X-Tenant": {"acme"}},
+		},
+		{
+			name:    "denied",
+			entries: []string{"X-*", "X-Api-Key"},
+			src:     http.Header{"X-Api-Key": {"secret"}, "X-Internal-Token": {"t"}, "X-Tenant": {"acme"}},
+			expect:  http.Header{"X-Tenant": {"acme"}},
+		},
+		{
+			name:    "case-insensitive pattern",
+			entries: []string{"x-trace-*", "X-B3-?pan*"},
+			src:     http.Header{"X-Trace-Id": {"t1"}, "X-B3-Spanid": {"s1"}, "X-Tracer": {"no"}},
+			expect:  http.Header{"X-Trace-Id": {"t1"}, "X-B3-Spanid": {"s1"}},
+		},
+		{name: "bad pattern", entries: []string{"X-["}, expectErr: `invalid forwardheaders pattern: "X-["`},
+	}
+	deny, _ := newHeaderSelector("denyforwardheaders", []string{"X-Api-Key", "*-token"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newHeaderSelector("forwardheaders", tt.entries)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := http.Header{}
+			s.copy(got, tt.src, deny)
+			if len(got) != len(tt.expect) {
+				t.Fatalf("expected %v, got %v", tt.expect, got)
+			}
+			for k := range tt.expect {
+				if got.Get(k) != tt.expect.Get(k) {
+					t.Errorf("expected %s %q, got %q", k, tt.expect.Get(k), got.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestNewHeaderMap(t *testing.T) {
+	got, err := newHeaderMap(map[string]string{"authorization": " x-original-authorization "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["Authorization"] != "X-Original-Authorization" {
+		t.Errorf("unexpected map %v", got)
+	}
+	if _, err := newHeaderMap(map[string]string{"Authorization": ""}); err == nil || err.Error() != "forwardheadermap names cannot be empty" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRequestIdHeaders(t *testing.T) {
+	captureLog(t)
+	src := http.Header{"X-Request-Id": {"r1"}, "X-Amzn-Trace-Id": {"Root=1-abc"}, "X-Correlation-Id": {"c1"}}
+	tests := []struct {
+		name   string
+		config Config
+		expect http.Header
+	}{
+		{
+			name:   "defaults",
+			expect: http.Header{"X-Request-Id": {"r1"}, "X-Amzn-Trace-Id": {"Root=1-abc"}},
+		},
+		{
+			name:   "configured",
+			config: Config{RequestIdHeaders: []string{"X-Correlation-Id"}},
+			expect: http.Header{"X-Correlation-Id": {"c1"}},
+		},
+		{
+			name:   "disabled",
+			config: Config{DisableRequestIdPropagation: true},
+			expect: http.Header{},
+		},
+		{
+			name:   "denied",
+			config: Config{DenyForwardHeaders: []string{"X-Amzn-Trace-Id"}},
+			expect: http.Header{"X-Request-Id": {"r1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := handler.(*notify).forwarded(&exchange{req: &http.Request{Header: src}})
+			if len(got) != len(tt.expect) {
+				t.Fatalf("expected %v, got %v", tt.expect, got)
+			}
+			for k := range tt.expect {
+				if got.Get(k) != tt.expect.Get(k) {
+					t.Errorf("expected %s %q, got %q", k, tt.expect.Get(k), got.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestTracePropagation(t *testing.T) {
+	src := http.Header{
+		"Traceparent":  {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		"Tracestate":   {"vendor=opaque"},
+		"X-B3-Traceid": {"463ac35c9f6413ad"},
+		"B3":           {"463ac35c9f6413ad-0020000000000001-1"},
+	}
+	tests := []struct {
+		name    string
+		disable bool
+		expect  int
+	}{
+		{name: "enabled", expect: len(src)},
+		{name: "disabled", disable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			handler, err := New(context.Background(), http.NotFoundHandler(), &Config{
+				NotifyHeader:            "X-Notify",
+				NotifyUrl:               "https://example.com/notification",
+				DisableTracePropagation: tt.disable,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := handler.(*notify).forwarded(&exchange{req: &http.Request{Header: src}})
+			if len(got) != tt.expect {
+				t.Fatalf("expected %d headers, got %v", tt.expect, got)
+			}
+			for k := range got {
+				if got.Get(k) != src.Get(k) {
+					t.Errorf("expected %s %q, got %q", k, src.Get(k), got.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestForwardCookies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Cookie", "session=abc; theme=dark; tracking=xyz")
+	req.Header.Set("X-Tenant", "acme")
+	tests := []struct {
+		name   string
+		a      *notify
+		expect string
+	}{
+		{
+			name:   "named cookies only",
+			a:      &notify{forwardCookies: []string{"session", "theme", "missing"}},
+			expect: "session=abc; theme=dark",
+		},
+		{
+			name:   "replaces forwarded cookie header",
+			a:      &notify{forwardHeaders: &headerSelector{names: []string{"Cookie"}}, forwardCookies: []string{"session"}},
+			expect: "session=abc",
+		},
+		{
+			name:   "denied",
+			a:      &notify{forwardCookies: []string{"session"}, denyForwardHeaders: &headerSelector{names: []string{"Cookie"}}},
+			expect: "",
+		},
+		{
+			name:   "no cookies configured",
+			a:      &notify{},
+			expect: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.a.forwarded(&exchange{req: req})
+			if got.Get("Cookie") != tt.expect {
+				t.Errorf("expected cookie %q, got %q", tt.expect, got.Get("Cookie"))
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	a := &notify{forwardHeaderMap: map[string]string{"Authorization": "X-Original-Authorization"}}
+	got := a.redact(http.Header{
+		"X-Original-Authorization": {"Bearer user"},
+		"Cookie":                   {"a=b"},
+		"X-Tenant":                 {"acme"},
+	})
+	expect := map[string]string{"X-Original-Authorization": redacted, "Cookie": redacted, "X-Tenant": "acme"}
+	if len(got) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, got)
+	}
+	for k, v := range expect {
+		if got[k] != v {
+			t.Errorf("expected %s %q, got %q", k, v, got[k])
+		}
+	}
+}
+
+func TestServeHTTPForwardResponseHeaders(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Resource-Id", "42")
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte("hello world")))
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:           "X-Notify",
+		NotifyUrl:              "https://example.com/notification",
+		ForwardResponseHeaders: []string{"X-Resource-Id"},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forwarded string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		forwarded = req.Header.Get("X-Resource-Id")
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/orders", nil))
+
+	if forwarded != "42" {
+		t.Errorf("expected X-Resource-Id 42, got %q", forwarded)
+	}
+	if w.Header().Get("X-Resource-Id") != "42" {
+		t.Errorf("response header removed from client response")
+	}
+}
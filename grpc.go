@@ -0,0 +1,119 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// grpcNotifyMethod is the full method name of Notifier.Notify, see
+// proto/notify.proto.
+const grpcNotifyMethod = "/header2post.v1.Notifier/Notify"
+
+// grpcSink calls Notifier.Notify over HTTP/2, encoding the request message
+// by hand so no protobuf runtime is needed.
+type grpcSink struct {
+	url       string
+	client    *http.Client
+	userAgent string
+}
+
+func newGrpcSink(config *Config) (*grpcSink, error) {
+	if config.GrpcTarget == "" {
+		return nil, fmt.Errorf("grpctarget cannot be empty")
+	}
+	if config.GrpcPlaintext && !h2cSupported {
+		return nil, fmt.Errorf("grpcplaintext requires go1.24 or later")
+	}
+	transport := &http.Transport{}
+	scheme := "https"
+	if config.GrpcPlaintext {
+		scheme = "http"
+	} else {
+		transport.TLSClientConfig = &tls.Config{
+			InsecureSkipVerify: config.GrpcInsecureSkipVerify, // #nosec G402 -- explicit opt-in
+		}
+	}
+	forceHTTP2(transport)
+	u := &url.URL{Scheme: scheme, Host: config.GrpcTarget, Path: grpcNotifyMethod}
+	return &grpcSink{url: u.String(), client: &http.Client{Transport: transport}, userAgent: configUserAgent(config)}, nil
+}
+
+func (s *grpcSink) Target() string {
+	return s.url
+}
+
+func (s *grpcSink) Send(ctx context.Context, n Notification) error {
+	msg := grpcNotifyRequest(n)
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	frame = append(frame, msg...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(frame))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/grpc+proto")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("User-Agent", s.userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status %d", resp.StatusCode)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	status := resp.Header.Get("Grpc-Status")
+	message := resp.Header.Get("Grpc-Message")
+	if status == "" {
+		status = resp.Trailer.Get("Grpc-Status")
+		message = resp.Trailer.Get("Grpc-Message")
+	}
+	if status == "" {
+		return fmt.Errorf("missing grpc-status")
+	}
+	if status != "0" {
+		if m, err := url.PathUnescape(message); err == nil {
+			message = m
+		}
+		return fmt.Errorf("grpc status %s: %s", status, message)
+	}
+	return nil
+}
+
+// grpcNotifyRequest encodes a NotifyRequest protobuf message.
+func grpcNotifyRequest(n Notification) []byte {
+	var b []byte
+	b = protoBytes(b, 1, n.Body)
+	keys := make([]string, 0, len(n.Header))
+	for k := range n.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = protoBytes(entry, 1, []byte(k))
+		entry = protoBytes(entry, 2, []byte(n.Header.Get(k)))
+		b = protoBytes(b, 2, entry)
+	}
+	if n.ContentType != "" {
+		b = protoBytes(b, 3, []byte(n.ContentType))
+	}
+	return b
+}
+
+// protoBytes appends a length-delimited field.
+func protoBytes(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3|2))
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
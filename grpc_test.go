@@ -0,0 +1,72 @@
+package header2post
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrpcSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		message   string
+		expectErr string
+	}{
+		{name: "ok", status: "0"},
+		{name: "unavailable", status: "14", message: "receiver%20down", expectErr: "grpc status 14: receiver down"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan []byte, 1)
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != grpcNotifyMethod || r.ProtoMajor != 2 {
+					http.Error(w, "bad request", http.StatusBadRequest)
+					return
+				}
+				body, _ := io.ReadAll(r.Body)
+				received <- body
+				w.Header().Set("Content-Type", "application/grpc")
+				w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+				w.WriteHeader(http.StatusOK)
+				w.Header().Set("Grpc-Status", tt.status)
+				w.Header().Set("Grpc-Message", tt.message)
+			}))
+			srv.EnableHTTP2 = true
+			srv.StartTLS()
+			defer srv.Close()
+
+			s, err := newGrpcSink(&Config{GrpcTarget: strings.TrimPrefix(srv.URL, "https://"), GrpcInsecureSkipVerify: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			msg := Notification{Body: []byte(`{"a":1}`), ContentType: "application/json", Header: http.Header{"X-Id": {"1"}}}
+			err = s.Send(ctx, msg)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			body := <-received
+			if body[0] != 0 || string(body[5:]) != string(grpcNotifyRequest(msg)) {
+				t.Errorf("unexpected grpc frame %x", body)
+			}
+		})
+	}
+}
+
+func TestGrpcNotifyRequest(t *testing.T) {
+	msg := Notification{Body: []byte("hi"), ContentType: "text/plain", Header: http.Header{"K": {"v"}}}
+	expect := "\x0a\x02hi" + "\x12\x06\x0a\x01K\x12\x01v" + "\x1a\x0atext/plain"
+	if got := string(grpcNotifyRequest(msg)); got != expect {
+		t.Errorf("expected %q, got %q", expect, got)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build go1.24
+
+package header2post
+
+import "net/http"
+
+// h2cSupported reports whether forceHTTP2 can speak h2c to plaintext
+// urls, which needs the http.Protocols API of go1.24.
+const h2cSupported = true
+
+// forceHTTP2 restricts t to HTTP/2: over TLS for https urls, and h2c with
+// prior knowledge for plaintext ones.
+func forceHTTP2(t *http.Transport) {
+	var protocols http.Protocols
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	t.Protocols = &protocols
+}
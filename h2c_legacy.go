@@ -0,0 +1,15 @@
+//go:build !go1.24
+
+package header2post
+
+import "net/http"
+
+// h2cSupported reports whether forceHTTP2 can speak h2c to plaintext
+// urls. The standard library has no h2c client before go1.24.
+const h2cSupported = false
+
+// forceHTTP2 makes t attempt HTTP/2 over TLS even with a custom
+// TLSClientConfig. Plaintext urls stay on HTTP/1.1.
+func forceHTTP2(t *http.Transport) {
+	t.ForceAttemptHTTP2 = true
+}
@@ -0,0 +1,24 @@
+//go:build !go1.24
+
+package header2post
+
+import "testing"
+
+func TestNewHTTPClientH2CUnsupported(t *testing.T) {
+	_, err := newHTTPClient(&Config{NotifyUrl: "http://example.com/notification", HttpVersion: "2"})
+	expect := "httpversion 2 with a plaintext notifyurl requires go1.24 or later"
+	if err == nil || err.Error() != expect {
+		t.Fatalf("expected error %q, got %v", expect, err)
+	}
+	if _, err := newHTTPClient(&Config{NotifyUrl: "https://example.com/notification", HttpVersion: "2"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGrpcSinkPlaintextUnsupported(t *testing.T) {
+	_, err := newGrpcSink(&Config{GrpcTarget: "localhost:50051", GrpcPlaintext: true})
+	expect := "grpcplaintext requires go1.24 or later"
+	if err == nil || err.Error() != expect {
+		t.Fatalf("expected error %q, got %v", expect, err)
+	}
+}
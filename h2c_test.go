@@ -0,0 +1,77 @@
+//go:build go1.24
+
+package header2post
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClientH2C(t *testing.T) {
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	var proto string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.Config.Protocols = &protocols
+	srv.Start()
+	defer srv.Close()
+
+	tests := []struct {
+		version     string
+		expectProto string
+	}{
+		{version: "", expectProto: "HTTP/1.1"},
+		{version: "1.1", expectProto: "HTTP/1.1"},
+		{version: "2", expectProto: "HTTP/2.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			client, err := newHTTPClient(&Config{NotifyUrl: srv.URL, HttpVersion: tt.version})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := deliverTo(&HTTPSender{URL: srv.URL, Client: client}, Notification{Body: []byte("{}")}); !result.Success {
+				t.Fatalf("delivery failed: %+v", result)
+			}
+			if proto != tt.expectProto {
+				t.Errorf("expected %s, got %s", tt.expectProto, proto)
+			}
+		})
+	}
+}
+
+func TestGrpcSinkPlaintext(t *testing.T) {
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != grpcNotifyMethod || r.ProtoMajor != 2 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.WriteHeader(http.StatusOK)
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	srv.Config.Protocols = &protocols
+	srv.Start()
+	defer srv.Close()
+
+	s, err := newGrpcSink(&Config{GrpcTarget: strings.TrimPrefix(srv.URL, "http://"), GrpcPlaintext: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.Send(ctx, Notification{Body: []byte(`{"a":1}`)}); err != nil {
+		t.Fatal(err)
+	}
+}
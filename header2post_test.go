@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"log"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestNew(t *testing.T) {
@@ -18,6 +22,9 @@ func TestNew(t *testing.T) {
 		name         string
 		notifyHeader string
 		notifyUrl    string
+		sampleRate   *float64
+		dedupTTL     string
+		batchWait    string
 		expectErr    error
 	}{
 		{
@@ -30,12 +37,36 @@ func TestNew(t *testing.T) {
 			notifyUrl: "http://localhost:8000",
 			expectErr: errors.New("notifyheader cannot be empty"),
 		},
+		{
+			name:         "invalid sample rate",
+			notifyHeader: "X-Notify",
+			notifyUrl:    "http://localhost:8000",
+			sampleRate:   ptr(1.5),
+			expectErr:    errors.New("samplerate must be between 0 and 1"),
+		},
+		{
+			name:         "invalid dedup ttl",
+			notifyHeader: "X-Notify",
+			notifyUrl:    "http://localhost:8000",
+			dedupTTL:     "soon",
+			expectErr:    errors.New(`invalid dedupttl: "soon"`),
+		},
+		{
+			name:         "invalid batch max wait",
+			notifyHeader: "X-Notify",
+			notifyUrl:    "http://localhost:8000",
+			batchWait:    "-1s",
+			expectErr:    errors.New(`invalid batchmaxwait: "-1s"`),
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			config := CreateConfig()
 			config.NotifyHeader = tt.notifyHeader
 			config.NotifyUrl = tt.notifyUrl
+			config.SampleRate = tt.sampleRate
+			config.DedupTTL = tt.dedupTTL
+			config.BatchMaxWait = tt.batchWait
 			_, err := New(context.Background(), nil, config, tt.name)
 			if tt.expectErr != nil && err == nil {
 				t.Errorf("New() error = %v, wantErr %v", err, tt.expectErr)
@@ -60,8 +91,7 @@ func TestServeHTTP(t *testing.T) {
 		nextHandler  http.Handler
 		expectedCode int
 		expectedBody string
-		mockPost     func(t *testing.T, req *http.Request) (*http.Response, error)
-		mockRead     func(r io.Reader) ([]byte, error)
+		transport    roundTripFunc
 		expectHeader map[string]string
 	}{
 		{
@@ -90,7 +120,7 @@ func TestServeHTTP(t *testing.T) {
 				w.WriteHeader(http.StatusBadRequest)
 				w.Write([]byte("hello world"))
 			}),
-			mockPost: func(t *testing.T, req *http.Request) (*http.Response, error) {
+			transport: func(req *http.Request) (*http.Response, error) {
 				return &http.Response{
 					StatusCode: http.StatusAccepted,
 				}, nil
@@ -108,7 +138,7 @@ func TestServeHTTP(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte("hello world"))
 			}),
-			mockPost: func(t *testing.T, req *http.Request) (*http.Response, error) {
+			transport: func(req *http.Request) (*http.Response, error) {
 				return nil, errors.New("post error")
 			},
 			expectedCode: http.StatusOK,
@@ -125,7 +155,7 @@ func TestServeHTTP(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte("hello world"))
 			}),
-			mockPost: func(t *testing.T, req *http.Request) (*http.Response, error) {
+			transport: func(req *http.Request) (*http.Response, error) {
 				return &http.Response{
 					StatusCode: http.StatusBadRequest,
 					Body:       io.NopCloser(bytes.NewBufferString("invalid notify url")),
@@ -141,34 +171,25 @@ func TestServeHTTP(t *testing.T) {
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte("hello world"))
 			}),
-			mockPost: func(t *testing.T, req *http.Request) (*http.Response, error) {
+			transport: func(req *http.Request) (*http.Response, error) {
 				return &http.Response{
 					StatusCode: http.StatusBadRequest,
-					Body:       io.NopCloser(bytes.NewBufferString("invalid notify url")),
+					Body:       io.NopCloser(errReader{}),
 				}, nil
 			},
-			mockRead: func(r io.Reader) ([]byte, error) {
-				return nil, errors.New("read body error")
-			},
 			expectedCode: http.StatusOK,
 			expectedBody: "hello world",
 		},
 	}
 
 	for _, tt := range tests {
-		defer func() {
-			mockPost = nil
-			mockRead = nil
-		}()
 		t.Run(tt.name, func(t *testing.T) {
-			logBuf := &bytes.Buffer{}
-			log.SetOutput(logBuf)
+			captureLog(t)
 			notify, err := New(nil, tt.nextHandler, &Config{NotifyHeader: notifyHeaderKey, NotifyUrl: "https://example.com/notification"}, "header2post")
 			if err != nil {
 				t.Errorf("failed to create notify: %v", err)
 			}
-			mockPost = tt.mockPost
-			mockRead = tt.mockRead
+			useTransport(notify, tt.transport)
 			req, err := http.NewRequest("GET", "/", nil)
 			if err != nil {
 				t.Fatal(err)
@@ -197,8 +218,6 @@ func TestServeHTTP(t *testing.T) {
 }
 
 func TestServeHTTPWithForardHeaders(t *testing.T) {
-	apiT = t
-	defer func() { apiT = nil }()
 	notifyHeaderKey := "X-Notify"
 	tests := []struct {
 		name           string
@@ -206,8 +225,7 @@ func TestServeHTTPWithForardHeaders(t *testing.T) {
 		forwardHeaders string
 		expectedCode   int
 		expectedBody   string
-		mockPost       func(t *testing.T, req *http.Request) (*http.Response, error)
-		mockRead       func(r io.Reader) ([]byte, error)
+		transport      func(t *testing.T, req *http.Request) (*http.Response, error)
 		expectHeader   map[string]string
 	}{
 		{
@@ -220,7 +238,7 @@ func TestServeHTTPWithForardHeaders(t *testing.T) {
 				w.Write([]byte("hello world"))
 			}),
 			forwardHeaders: "X-Test-Forward-A,X-Test-Forward-B,X-Test-Forward-C",
-			mockPost: func(t *testing.T, req *http.Request) (*http.Response, error) {
+			transport: func(t *testing.T, req *http.Request) (*http.Response, error) {
 				if req.Header.Get("X-Test-Forward-A") != "a" {
 					t.Errorf("X-Test-Forward-A header not forwarded")
 				}
@@ -235,29 +253,22 @@ func TestServeHTTPWithForardHeaders(t *testing.T) {
 					Body:       io.NopCloser(bytes.NewBufferString("ok")),
 				}, nil
 			},
-			mockRead: func(r io.Reader) ([]byte, error) {
-				return nil, errors.New("read body error")
-			},
 			expectedCode: http.StatusOK,
 			expectedBody: "hello world",
 		},
 	}
 
 	for _, tt := range tests {
-		defer func() {
-			mockPost = nil
-			mockRead = nil
-		}()
 		t.Run(tt.name, func(t *testing.T) {
-			logBuf := &bytes.Buffer{}
-			log.SetOutput(logBuf)
+			captureLog(t)
 
 			notify, err := New(nil, tt.nextHandler, &Config{NotifyHeader: notifyHeaderKey, NotifyUrl: "https://example.com/notification", ForwardHeaders: strings.Split(tt.forwardHeaders, ",")}, "header2post")
 			if err != nil {
 				t.Errorf("failed to create notify: %v", err)
 			}
-			mockPost = tt.mockPost
-			mockRead = tt.mockRead
+			useTransport(notify, func(req *http.Request) (*http.Response, error) {
+				return tt.transport(t, req)
+			})
 			req, err := http.NewRequest("GET", "/", nil)
 			if err != nil {
 				t.Fatal(err)
@@ -284,3 +295,634 @@ func TestServeHTTPWithForardHeaders(t *testing.T) {
 		})
 	}
 }
+
+// ptr returns a pointer to v, for optional options.
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSampleRate(t *testing.T) {
+	defer func() { randFloat64 = rand.Float64 }()
+	tests := []struct {
+		name       string
+		sampleRate *float64
+		random     float64
+		expectPost bool
+	}{
+		{name: "unset", random: 0.99, expectPost: true},
+		{name: "full sampling", sampleRate: ptr(1.0), random: 0.99, expectPost: true},
+		{name: "sampled in", sampleRate: ptr(0.5), random: 0.2, expectPost: true},
+		{name: "sampled out", sampleRate: ptr(0.5), random: 0.7, expectPost: false},
+		{name: "none", sampleRate: ptr(0.0), random: 0, expectPost: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			posted := false
+			randFloat64 = func() float64 { return tt.random }
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Notify", base64.StdEncoding.EncodeToString([]byte("hello world")))
+			})
+			notify, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", SampleRate: tt.sampleRate}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(notify, func(req *http.Request) (*http.Response, error) {
+				posted = true
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			notify.ServeHTTP(httptest.NewRecorder(), req)
+			if posted != tt.expectPost {
+				t.Errorf("expected post %v, got %v", tt.expectPost, posted)
+			}
+		})
+	}
+}
+
+func TestServeHTTPCleanup(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	tests := []struct {
+		name      string
+		value     string
+		transport roundTripFunc
+		config    Config
+	}{
+		{name: "no notify header"},
+		{name: "base64 decode error", value: "%%%"},
+		{
+			name:  "post error",
+			value: encoded,
+			transport: func(req *http.Request) (*http.Response, error) {
+				return nil, errors.New("post error")
+			},
+		},
+		{
+			name:  "read body error",
+			value: encoded,
+			transport: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(errReader{})}, nil
+			},
+		},
+		{
+			name:  "notify failed",
+			value: encoded,
+			transport: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(bytes.NewBufferString("bad"))}, nil
+			},
+		},
+		{
+			name:   "sampled out",
+			value:  encoded,
+			config: Config{SampleRate: ptr(0.5)},
+		},
+		{
+			name:  "success",
+			value: encoded,
+			transport: func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			randFloat64 = func() float64 { return 0.9 }
+			defer func() { randFloat64 = rand.Float64 }()
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.value != "" {
+					w.Header().Set("X-Notify", tt.value)
+				}
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("hello "))
+				w.Write([]byte("world"))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			if config.NotifyUrl == "" {
+				config.NotifyUrl = "https://example.com/notification"
+			}
+			notify, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(notify, tt.transport)
+			rec := &countingRecorder{ResponseRecorder: httptest.NewRecorder()}
+			notify.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Header().Get("X-Notify") != "" {
+				t.Errorf("notify header leaked to client")
+			}
+			if rec.writeHeaders != 1 {
+				t.Errorf("expected response to be flushed once, got %d", rec.writeHeaders)
+			}
+			if rec.Code != http.StatusCreated {
+				t.Errorf("expected status code %d, got %d", http.StatusCreated, rec.Code)
+			}
+			if rec.Body.String() != "hello world" {
+				t.Errorf("expected body %q, got %q", "hello world", rec.Body.String())
+			}
+		})
+	}
+}
+
+// errReader fails every read, standing in for a broken response body.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) {
+	return 0, errors.New("read body error")
+}
+
+type countingRecorder struct {
+	*httptest.ResponseRecorder
+	writeHeaders int
+}
+
+func (r *countingRecorder) WriteHeader(code int) {
+	r.writeHeaders++
+	r.ResponseRecorder.WriteHeader(code)
+}
+
+func TestWrappedResponseWriterHeaderOnce(t *testing.T) {
+	for _, buffer := range []bool{true, false} {
+		rec := &countingRecorder{ResponseRecorder: httptest.NewRecorder()}
+		w := newResponseWriter(rec, buffer, 0, func(http.Header) {})
+		w.Write([]byte("bo"))
+		w.Flush()
+		w.Write([]byte("dy"))
+		w.finish()
+		w.finish()
+		if rec.writeHeaders != 1 {
+			t.Errorf("buffer %v: expected one WriteHeader call, got %d", buffer, rec.writeHeaders)
+		}
+		if rec.Body.String() != "body" {
+			t.Errorf("buffer %v: expected body %q, got %q", buffer, "body", rec.Body.String())
+		}
+	}
+}
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (r *pushRecorder) Push(target string, opts *http.PushOptions) error {
+	r.pushed = append(r.pushed, target)
+	return nil
+}
+
+func TestResponseWriterPush(t *testing.T) {
+	push := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	writers := map[string]http.ResponseWriter{
+		"wrapped":   newResponseWriter(push, true, 0, func(http.Header) {}),
+		"stripping": &strippingResponseWriter{ResponseWriter: push},
+	}
+	for name, w := range writers {
+		if err := w.(http.Pusher).Push("/"+name+".css", nil); err != nil {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+	if len(push.pushed) != 2 {
+		t.Errorf("expected two pushes, got %v", push.pushed)
+	}
+	w := newResponseWriter(httptest.NewRecorder(), true, 0, func(http.Header) {})
+	if err := w.Push("/app.css", nil); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected http.ErrNotSupported, got %v", err)
+	}
+}
+
+type readFromRecorder struct {
+	*httptest.ResponseRecorder
+	readFrom int
+}
+
+func (r *readFromRecorder) ReadFrom(src io.Reader) (int64, error) {
+	r.readFrom++
+	return io.Copy(r.ResponseRecorder, src)
+}
+
+func TestResponseWriterReadFrom(t *testing.T) {
+	tests := []struct {
+		name           string
+		writer         func(http.ResponseWriter) http.ResponseWriter
+		expectReadFrom int
+	}{
+		{name: "streamed", writer: func(w http.ResponseWriter) http.ResponseWriter {
+			return newResponseWriter(w, false, 0, func(h http.Header) { h.Del("X-Notify") })
+		}, expectReadFrom: 1},
+		{name: "buffered", writer: func(w http.ResponseWriter) http.ResponseWriter {
+			return newResponseWriter(w, true, 0, func(h http.Header) { h.Del("X-Notify") })
+		}},
+		{name: "stripping", writer: func(w http.ResponseWriter) http.ResponseWriter {
+			strip, _ := newHeaderSelector("stripresponseheaders", []string{"X-Notify"})
+			return &strippingResponseWriter{ResponseWriter: w, strip: strip}
+		}, expectReadFrom: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &readFromRecorder{ResponseRecorder: httptest.NewRecorder()}
+			w := tt.writer(rec)
+			w.Header().Set("X-Notify", "e30=")
+			// a plain reader, so io.Copy goes through ReadFrom rather than WriteTo
+			n, err := io.Copy(w, io.LimitReader(strings.NewReader("body"), 64))
+			if rw, ok := w.(*wrappedResponseWriter); ok {
+				rw.finish()
+			}
+			if n != 4 || err != nil || rec.Body.String() != "body" || rec.Header().Get("X-Notify") != "" {
+				t.Errorf("unexpected copy %d %v %q %v", n, err, rec.Body.String(), rec.Header())
+			}
+			if rec.readFrom != tt.expectReadFrom {
+				t.Errorf("expected %d ReadFrom calls, got %d", tt.expectReadFrom, rec.readFrom)
+			}
+		})
+	}
+}
+
+func TestIsUpgrade(t *testing.T) {
+	tests := []struct {
+		header http.Header
+		expect bool
+	}{
+		{header: http.Header{}},
+		{header: http.Header{"Upgrade": {"websocket"}}},
+		{header: http.Header{"Connection": {"Upgrade"}}},
+		{header: http.Header{"Connection": {"keep-alive, Upgrade"}, "Upgrade": {"websocket"}}, expect: true},
+		{header: http.Header{"Connection": {"upgrade"}, "Upgrade": {"h2c"}}, expect: true},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header = tt.header
+		if got := isUpgrade(req); got != tt.expect {
+			t.Errorf("%v: expected %v, got %v", tt.header, tt.expect, got)
+		}
+	}
+}
+
+func TestServeHTTPUpgradeNotBuffered(t *testing.T) {
+	captureLog(t)
+	var buffered bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buffered = !w.(*wrappedResponseWriter).committed()
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:       "X-Notify",
+		NotifyUrl:          "https://example.com/notification",
+		ExposeStatusHeader: true,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if buffered {
+		t.Error("expected an upgrade response not to be buffered")
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !buffered {
+		t.Error("expected a plain response to be buffered")
+	}
+}
+
+func TestServeHTTPFlush(t *testing.T) {
+	captureLog(t)
+	rec := httptest.NewRecorder()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+		w.Write([]byte("data: 1\n\n"))
+		w.(http.Flusher).Flush()
+		if !rec.Flushed || rec.Body.String() != "data: 1\n\n" || rec.Header().Get("X-Notify") != "" {
+			t.Errorf("expected the first event to be flushed, got %q %v", rec.Body.String(), rec.Header())
+		}
+		w.Write([]byte("data: 2\n\n"))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:       "X-Notify",
+		NotifyUrl:          "https://example.com/notification",
+		ExposeStatusHeader: true,
+		FailureMode:        failClosed,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	delivered := 0
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		delivered++
+		return &http.Response{StatusCode: http.StatusInternalServerError, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("down"))}, nil
+	})
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	// the failed notification can no longer replace a flushed response
+	if delivered != 1 || rec.Code != http.StatusOK || rec.Body.String() != "data: 1\n\ndata: 2\n\n" {
+		t.Errorf("unexpected response %d %q after %d deliveries", rec.Code, rec.Body.String(), delivered)
+	}
+}
+
+func TestServeHTTPStreamsResponse(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name         string
+		config       Config
+		expectStream bool
+	}{
+		{name: "plain", config: Config{}, expectStream: true},
+		{name: "expose status", config: Config{ExposeStatusHeader: true}},
+		{name: "correlation id", config: Config{CorrelationId: true}},
+		{name: "enrich", config: Config{EnrichMode: enrichMerge}},
+		{name: "failclosed", config: Config{FailureMode: "failclosed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			var streamed bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"b":2}`))
+				// the body reached the client before the handler returned
+				streamed = rec.Body.Len() > 0
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			delivered := 0
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				delivered++
+				return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if streamed != tt.expectStream {
+				t.Errorf("expected streamed %v, got %v", tt.expectStream, streamed)
+			}
+			if rec.Code != http.StatusCreated || rec.Header().Get("X-Notify") != "" {
+				t.Errorf("unexpected response %d %v", rec.Code, rec.Header())
+			}
+			if delivered != 1 || rec.Body.String() != `{"b":2}` {
+				t.Errorf("unexpected delivery count %d and body %q", delivered, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestServeHTTPPartitionKey(t *testing.T) {
+	captureLog(t)
+	var mu sync.Mutex
+	var delivered []string
+	payloads := []string{`{"order":"a","seq":1}`, `{"order":"a","seq":2}`, `{"order":"a","seq":3}`}
+	i := 0
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(payloads[i])))
+	})
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", PartitionKeyField: "order"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		delivered = append(delivered, string(body))
+		mu.Unlock()
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+	for i = range payloads {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	handler.(*notify).partitions.wait()
+
+	if strings.Join(delivered, ",") != strings.Join(payloads, ",") {
+		t.Errorf("expected ordered delivery %v, got %v", payloads, delivered)
+	}
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// useTransport routes the handler's notify url posts through fn; a nil fn
+// fails every post instead of reaching the network.
+func useTransport(h http.Handler, fn roundTripFunc) {
+	if fn == nil {
+		fn = func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New("no transport")
+		}
+	}
+	WithHTTPDoer(&http.Client{Transport: fn})(h.(*notify))
+}
+
+func TestServeHTTPRequestTrigger(t *testing.T) {
+	captureLog(t)
+	var events []string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Notify") != "" {
+			t.Errorf("notify header forwarded upstream")
+		}
+		events = append(events, "upstream")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", TriggerSource: "request"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		body, _ := io.ReadAll(req.Body)
+		events = append(events, "notify "+string(body))
+		return &http.Response{StatusCode: http.StatusAccepted}, nil
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+
+	expect := []string{`notify {"id":1}`, "upstream", "upstream"}
+	if strings.Join(events, ",") != strings.Join(expect, ",") {
+		t.Errorf("expected %v, got %v", expect, events)
+	}
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, rec.Code)
+	}
+
+	_, err = New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", TriggerSource: "both"}, "header2post")
+	if err == nil || err.Error() != `invalid triggersource: "both"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestServeHTTPKeepNotifyHeader(t *testing.T) {
+	captureLog(t)
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	tests := []struct {
+		name   string
+		source string
+		keep   bool
+		expect string
+	}{
+		{name: "response removed", source: "response"},
+		{name: "response kept", source: "response", keep: true, expect: payload},
+		{name: "request removed", source: "request"},
+		{name: "request kept", source: "request", keep: true, expect: payload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var upstream string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = r.Header.Get("X-Notify")
+				if tt.source == "response" {
+					w.Header().Set("X-Notify", payload)
+				}
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:     "X-Notify",
+				NotifyUrl:        "https://example.com/notification",
+				TriggerSource:    tt.source,
+				KeepNotifyHeader: tt.keep,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			notified := false
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				notified = true
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.source == "request" {
+				req.Header.Set("X-Notify", payload)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if !notified {
+				t.Errorf("expected notification")
+			}
+			got := rec.Header().Get("X-Notify")
+			if tt.source == "request" {
+				got = upstream
+			}
+			if got != tt.expect {
+				t.Errorf("expected header %q, got %q", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestServeHTTPStripResponseHeaders(t *testing.T) {
+	captureLog(t)
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	for _, source := range []string{"response", "request"} {
+		t.Run(source, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", payload)
+				w.Header().Set("X-Notify-Type", "order.created")
+				w.Header().Set("X-Internal-Event-Id", "42")
+				w.Header().Set("X-Resource-Id", "7")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("created"))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:         "X-Notify",
+				NotifyUrl:            "https://example.com/notification",
+				TriggerSource:        source,
+				KeepNotifyHeader:     true,
+				StripResponseHeaders: []string{"X-Notify", "x-notify-type", "X-Internal-*"},
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("X-Notify", payload)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			for _, h := range []string{"X-Notify", "X-Notify-Type", "X-Internal-Event-Id"} {
+				if v := rec.Header().Get(h); v != "" {
+					t.Errorf("expected %s stripped, got %q", h, v)
+				}
+			}
+			if rec.Header().Get("X-Resource-Id") != "7" || rec.Code != http.StatusCreated || rec.Body.String() != "created" {
+				t.Errorf("unexpected response %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+			}
+		})
+	}
+
+	_, err := New(context.Background(), http.NotFoundHandler(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", StripResponseHeaders: []string{"X-["}}, "header2post")
+	if err == nil || err.Error() != `invalid stripresponseheaders pattern: "X-["` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestServeHTTPFanoutUrls(t *testing.T) {
+	log := captureLog(t)
+	reached := make(chan struct{})
+	first := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only answers once the second target has been reached
+		select {
+		case <-reached:
+			w.WriteHeader(http.StatusAccepted)
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusGatewayTimeout)
+		}
+	}))
+	defer first.Close()
+	second := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(reached)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer second.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader: "X-Notify",
+		NotifyUrl:    first.URL,
+		FanoutUrls:   []string{second.URL, failing.URL},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report struct {
+		Delivered int              `json:"delivered"`
+		Failed    int              `json:"failed"`
+		Results   []deliveryResult `json:"results"`
+	}
+	for _, line := range strings.Split(strings.TrimSpace(log.String()), "\n") {
+		if strings.Contains(line, "delivery report") {
+			json.Unmarshal([]byte(line), &report)
+		}
+	}
+	if report.Delivered != 2 || report.Failed != 1 || len(report.Results) != 3 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	for i, target := range []string{first.URL, second.URL, failing.URL} {
+		if report.Results[i].Target != target || report.Results[i].Success != (i < 2) {
+			t.Errorf("unexpected result %d %+v", i, report.Results[i])
+		}
+	}
+
+	for _, fanout := range []string{"unix:///tmp/notify.sock", "example.com", first.URL} {
+		_, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: first.URL, FanoutUrls: []string{fanout}}, "header2post")
+		if expect := fmt.Sprintf("invalid fanouturls: %q", fanout); err == nil || err.Error() != expect {
+			t.Errorf("expected error %q, got %v", expect, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errEndpointUnhealthy short-circuits deliveries while the health probe
+// reports the notify url down.
+var errEndpointUnhealthy = errors.New("notify endpoint unhealthy")
+
+// healthProbe periodically requests a path of the notify url and tracks
+// whether it answers with the expected status.
+type healthProbe struct {
+	url      string
+	client   HTTPDoer
+	expect   int
+	interval time.Duration
+	log      *slog.Logger
+	down     atomic.Bool
+
+	mu        sync.Mutex
+	onRecover []func()
+}
+
+// newHealthProbe returns nil unless HealthCheckInterval is set. sender
+// is the NotifyUrl sender whose host and client the probe uses.
+func newHealthProbe(config *Config, sender *HTTPSender, log *slog.Logger) (*healthProbe, error) {
+	if config.HealthCheckInterval == "" {
+		return nil, nil
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("healthcheckinterval requires notifyurl")
+	}
+	if sender.srv != nil {
+		return nil, fmt.Errorf("healthcheckinterval cannot be combined with an srv notifyurl")
+	}
+	if sender.pool != nil {
+		return nil, fmt.Errorf("healthcheckinterval cannot be combined with discoverymode")
+	}
+	interval, err := parseDuration("healthcheckinterval", config.HealthCheckInterval, 0)
+	if err != nil {
+		return nil, err
+	}
+	path := config.HealthCheckPath
+	if path == "" {
+		path = "/"
+	}
+	u, err := url.Parse(sender.URL)
+	if err != nil || !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid healthcheckpath: %q", config.HealthCheckPath)
+	}
+	if u, err = u.Parse(path); err != nil {
+		return nil, fmt.Errorf("invalid healthcheckpath: %q", config.HealthCheckPath)
+	}
+	expect := config.HealthCheckStatus
+	if expect == 0 {
+		expect = http.StatusOK
+	}
+	if expect < 100 || expect > 599 {
+		return nil, fmt.Errorf("invalid healthcheckstatus: %d", config.HealthCheckStatus)
+	}
+	var client HTTPDoer = http.DefaultClient
+	if sender.Client != nil {
+		client = sender.Client
+	}
+	return &healthProbe{url: u.String(), client: client, expect: expect, interval: interval, log: log}, nil
+}
+
+// healthy reports whether the last probe succeeded. The endpoint is
+// assumed healthy until a probe fails.
+func (p *healthProbe) healthy() bool {
+	return p == nil || !p.down.Load()
+}
+
+// whenRecovered registers fn to run each time the endpoint comes back.
+func (p *healthProbe) whenRecovered(fn func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onRecover = append(p.onRecover, fn)
+}
+
+// run probes every interval until ctx is done.
+func (p *healthProbe) run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.set(p.check(ctx))
+		}
+	}
+}
+
+// check makes one probe request, bounded by the interval.
+func (p *healthProbe) check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, p.interval)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != p.expect {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// set records a probe outcome, logging transitions and running the
+// recovery callbacks when the endpoint comes back.
+func (p *healthProbe) set(err error) {
+	if err != nil {
+		if !p.down.Swap(true) {
+			p.log.Warn("notify endpoint unhealthy", "url", p.url, "error", err)
+		}
+		return
+	}
+	if p.down.Swap(false) {
+		p.log.Info("notify endpoint recovered", "url", p.url)
+		p.mu.Lock()
+		callbacks := p.onRecover
+		p.mu.Unlock()
+		for _, fn := range callbacks {
+			fn()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestServeHTTPHealthCheck(t *testing.T) {
+	log := captureLog(t)
+	var up atomic.Bool
+	var notified atomic.Int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if up.Load() {
+				w.WriteHeader(http.StatusNoContent)
+			} else {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		notified.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:        "X-Notify",
+		NotifyUrl:           receiver.URL + "/events?source=gw",
+		MaxRetries:          3,
+		HealthCheckInterval: "1h",
+		HealthCheckPath:     "/healthz",
+		HealthCheckStatus:   http.StatusNoContent,
+		ExposeStatusHeader:  true,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	probe := handler.(*notify).health
+	if probe.url != receiver.URL+"/healthz" {
+		t.Errorf("unexpected probe url %q", probe.url)
+	}
+	recovered := 0
+	probe.whenRecovered(func() { recovered++ })
+
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rec.Header().Get("X-Notify-Result")
+	}
+	probe.set(probe.check(context.Background()))
+	if probe.healthy() {
+		t.Fatal("expected the endpoint to be unhealthy")
+	}
+	if result := serve(); result != resultFailed || notified.Load() != 0 {
+		t.Errorf("expected a short-circuited failure, got %q after %d requests", result, notified.Load())
+	}
+
+	up.Store(true)
+	probe.set(probe.check(context.Background()))
+	if !probe.healthy() || recovered != 1 {
+		t.Fatalf("expected one recovery, got %d", recovered)
+	}
+	if result := serve(); result != resultDelivered || notified.Load() != 1 {
+		t.Errorf("expected a delivery, got %q after %d requests", result, notified.Load())
+	}
+	probe.set(nil)
+	if recovered != 1 {
+		t.Errorf("recovery callbacks ran again")
+	}
+	for _, record := range []string{"notify endpoint unhealthy", "notify endpoint recovered"} {
+		if !strings.Contains(log.String(), `"msg":"`+record+`"`) {
+			t.Errorf("missing %q record", record)
+		}
+	}
+}
+
+func TestNewHealthProbeErrors(t *testing.T) {
+	sender := &HTTPSender{URL: "https://example.com/events"}
+	tests := []struct {
+		config Config
+		sender *HTTPSender
+		expect string
+	}{
+		{config: Config{HealthCheckInterval: "10s"}, expect: "healthcheckinterval requires notifyurl"},
+		{config: Config{HealthCheckInterval: "often"}, sender: sender, expect: `invalid healthcheckinterval: "often"`},
+		{config: Config{HealthCheckInterval: "10s", HealthCheckPath: "healthz"}, sender: sender, expect: `invalid healthcheckpath: "healthz"`},
+		{config: Config{HealthCheckInterval: "10s", HealthCheckStatus: 42}, sender: sender, expect: "invalid healthcheckstatus: 42"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expect, func(t *testing.T) {
+			if _, err := newHealthProbe(&tt.config, tt.sender, nil); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package header2post
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+const defaultIdempotencyKeyHeader = "Idempotency-Key"
+
+// idempotencyKey derives the key of a notification from the request id
+// of the incoming request and its payload. Every attempt to deliver the
+// notification carries the same key.
+func idempotencyKey(requestID string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(requestID))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// requestID returns the first of the IdempotencyKey request id headers
+// set on req.
+func (a *notify) requestID(req *http.Request) string {
+	for _, name := range a.idempotencyRequestIds {
+		if id := req.Header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// setIdempotencyKey sets the idempotency key header of msg, if enabled.
+func (a *notify) setIdempotencyKey(msg *Notification, requestID string, data []byte) {
+	if a.idempotencyHeader == "" {
+		return
+	}
+	if msg.Header == nil {
+		msg.Header = http.Header{}
+	}
+	msg.Header.Set(a.idempotencyHeader, idempotencyKey(requestID, data))
+}
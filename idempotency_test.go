@@ -0,0 +1,57 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPIdempotencyKey(t *testing.T) {
+	captureLog(t)
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	defer func() { sleep = sleepContext }()
+
+	payload := `{"order":"a"}`
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(payload)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:   "X-Notify",
+		NotifyUrl:      "https://example.com/notification",
+		IdempotencyKey: true,
+		MaxRetries:     1,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		keys = append(keys, req.Header.Get("Idempotency-Key"))
+		status := http.StatusAccepted
+		if len(keys)%2 == 1 {
+			status = http.StatusServiceUnavailable
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	serve := func(requestID string) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Id", requestID)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	serve("r1")
+	serve("r2")
+	if len(keys) != 4 {
+		t.Fatalf("expected four attempts, got %q", keys)
+	}
+	if keys[0] != idempotencyKey("r1", []byte(payload)) || keys[0] != keys[1] {
+		t.Errorf("expected retries of one delivery to share a key, got %q", keys)
+	}
+	if keys[2] != keys[3] || keys[2] == keys[0] {
+		t.Errorf("expected another request to get another key, got %q", keys)
+	}
+}
@@ -0,0 +1,36 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	invalidJsonDrop       = "drop"
+	invalidJsonSendAnyway = "send-anyway"
+	invalidJsonWrap       = "wrap"
+)
+
+// newInvalidJsonPolicy returns the InvalidJsonPolicy, or "" when
+// RequireValidJson is not set.
+func newInvalidJsonPolicy(config *Config) (string, error) {
+	switch config.InvalidJsonPolicy {
+	case "":
+		if config.RequireValidJson {
+			return invalidJsonDrop, nil
+		}
+		return "", nil
+	case invalidJsonDrop, invalidJsonSendAnyway, invalidJsonWrap:
+		if !config.RequireValidJson {
+			return "", nil
+		}
+		return config.InvalidJsonPolicy, nil
+	}
+	return "", fmt.Errorf("invalid invalidjsonpolicy: %q", config.InvalidJsonPolicy)
+}
+
+// wrapInvalidJson wraps raw bytes in {"raw": "<base64>"}.
+func wrapInvalidJson(data []byte) []byte {
+	out, _ := json.Marshal(map[string][]byte{"raw": data})
+	return out
+}
@@ -0,0 +1,54 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPRequireValidJson(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		payload    string
+		expectBody string
+	}{
+		{name: "valid", config: Config{RequireValidJson: true}, payload: `{"id":1}`, expectBody: `{"id":1}`},
+		{name: "drop", config: Config{RequireValidJson: true}, payload: "raw"},
+		{name: "send anyway", config: Config{RequireValidJson: true, InvalidJsonPolicy: "send-anyway"}, payload: "raw", expectBody: "raw"},
+		{name: "wrap", config: Config{RequireValidJson: true, InvalidJsonPolicy: "wrap"}, payload: "raw", expectBody: `{"raw":"cmF3"}`},
+		{name: "not required", config: Config{InvalidJsonPolicy: "wrap"}, payload: "raw", expectBody: "raw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.payload)))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			if body != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, body)
+			}
+		})
+	}
+
+	if _, err := newInvalidJsonPolicy(&Config{RequireValidJson: true, InvalidJsonPolicy: "fix"}); err == nil || err.Error() != `invalid invalidjsonpolicy: "fix"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
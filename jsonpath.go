@@ -0,0 +1,86 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// lookupField resolves a dotted path (e.g. "order.id") inside a JSON
+// document and returns its value.
+func lookupField(data []byte, path string) (any, bool) {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+	return lookupPath(doc, path)
+}
+
+// lookupPath resolves a dotted path inside an already decoded JSON value.
+func lookupPath(doc any, path string) (any, bool) {
+	cur := doc
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := cur.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		cur, ok = obj[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// parseFieldPath splits a JSON pointer such as /user/email or a dotted
+// path such as user.email into its segments.
+func parseFieldPath(option, field string) ([]string, error) {
+	var path []string
+	if strings.HasPrefix(field, "/") {
+		for _, p := range strings.Split(field[1:], "/") {
+			path = append(path, strings.ReplaceAll(strings.ReplaceAll(p, "~1", "/"), "~0", "~"))
+		}
+	} else {
+		path = strings.Split(field, ".")
+	}
+	for _, p := range path {
+		if p == "" {
+			return nil, fmt.Errorf("invalid %s path: %q", option, field)
+		}
+	}
+	return path, nil
+}
+
+// setFields adds top-level fields to a JSON object document. Other
+// documents are returned unchanged.
+func setFields(data []byte, fields map[string]any) []byte {
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil || obj == nil {
+		return data
+	}
+	for k, v := range fields {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return data
+		}
+		obj[k] = b
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// fieldString resolves a dotted path and formats the value as a string,
+// suitable for use as a map key.
+func fieldString(data []byte, path string) (string, bool) {
+	v, ok := lookupField(data, path)
+	if !ok || v == nil {
+		return "", false
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	return fmt.Sprint(v), true
+}
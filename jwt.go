@@ -0,0 +1,194 @@
+package header2post
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	jwtHS256 = "HS256"
+	jwtRS256 = "RS256"
+	jwtES256 = "ES256"
+
+	defaultJwtTTL = time.Minute
+)
+
+// jwtSigner mints a short-lived JWT for every notification request.
+type jwtSigner struct {
+	alg      string
+	keyID    string
+	hmacKey  []byte
+	signer   crypto.Signer
+	issuer   string
+	audience string
+	ttl      time.Duration
+	claims   map[string]string
+}
+
+// newJwtSigner returns nil when no signing key is configured. The key is
+// read from JwtSigningKeyFile or the environment variable named by
+// JwtSigningKeyEnv: a shared secret for HS256, a PEM private key for RS256
+// and ES256.
+func newJwtSigner(config *Config) (*jwtSigner, error) {
+	if config.JwtSigningKeyFile == "" && config.JwtSigningKeyEnv == "" {
+		return nil, nil
+	}
+	var raw []byte
+	if config.JwtSigningKeyFile != "" {
+		b, err := os.ReadFile(config.JwtSigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read jwtsigningkeyfile: %w", err)
+		}
+		raw = b
+	} else {
+		raw = []byte(os.Getenv(config.JwtSigningKeyEnv))
+		if len(raw) == 0 {
+			return nil, fmt.Errorf("environment variable %s is empty", config.JwtSigningKeyEnv)
+		}
+	}
+	ttl, err := parseDuration("jwtttl", config.JwtTTL, defaultJwtTTL)
+	if err != nil {
+		return nil, err
+	}
+	s := &jwtSigner{
+		alg:      config.JwtAlgorithm,
+		keyID:    config.JwtKeyId,
+		issuer:   config.JwtIssuer,
+		audience: config.JwtAudience,
+		ttl:      ttl,
+		claims:   config.JwtClaims,
+	}
+	switch s.alg {
+	case "", jwtHS256:
+		s.alg = jwtHS256
+		s.hmacKey = raw
+	case jwtRS256, jwtES256:
+		key, err := parsePrivateKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("jwt signing key: %w", err)
+		}
+		_, isRSA := key.(*rsa.PrivateKey)
+		_, isEC := key.(*ecdsa.PrivateKey)
+		if (s.alg == jwtRS256 && !isRSA) || (s.alg == jwtES256 && !isEC) {
+			return nil, fmt.Errorf("jwt signing key does not match jwtalgorithm %s", s.alg)
+		}
+		s.signer = key
+	default:
+		return nil, fmt.Errorf("unsupported jwtalgorithm: %q", config.JwtAlgorithm)
+	}
+	return s, nil
+}
+
+// authorize sets a freshly minted token as the Bearer credential of req.
+func (s *jwtSigner) authorize(ctx context.Context, req *http.Request) error {
+	now := timeNow()
+	claims := map[string]any{
+		"iat": now.Unix(),
+		"exp": now.Add(s.ttl).Unix(),
+		"jti": generateID(),
+	}
+	if s.issuer != "" {
+		claims["iss"] = s.issuer
+	}
+	if s.audience != "" {
+		claims["aud"] = s.audience
+	}
+	for k, v := range s.claims {
+		claims[k] = v
+	}
+	token, err := signJWT(s.alg, s.keyID, s.hmacKey, s.signer, claims)
+	if err != nil {
+		return fmt.Errorf("sign jwt: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// signJWT encodes and signs claims as a compact JWS. hmacKey is used for
+// HS256, signer for RS256 and ES256.
+func signJWT(alg, keyID string, hmacKey []byte, signer crypto.Signer, claims map[string]any) (string, error) {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if keyID != "" {
+		header["kid"] = keyID
+	}
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	var sig []byte
+	switch alg {
+	case jwtHS256:
+		mac := hmac.New(sha256.New, hmacKey)
+		mac.Write([]byte(signingInput))
+		sig = mac.Sum(nil)
+	case jwtRS256:
+		sum := sha256.Sum256([]byte(signingInput))
+		key, ok := signer.(*rsa.PrivateKey)
+		if !ok {
+			return "", errors.New("RS256 requires an RSA key")
+		}
+		if sig, err = rsa.SignPKCS1v15(cryptorand.Reader, key, crypto.SHA256, sum[:]); err != nil {
+			return "", err
+		}
+	case jwtES256:
+		sum := sha256.Sum256([]byte(signingInput))
+		key, ok := signer.(*ecdsa.PrivateKey)
+		if !ok {
+			return "", errors.New("ES256 requires an ECDSA key")
+		}
+		r, s, err := ecdsa.Sign(cryptorand.Reader, key, sum[:])
+		if err != nil {
+			return "", err
+		}
+		// JWS uses the fixed-size r || s encoding
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	default:
+		return "", fmt.Errorf("unsupported algorithm %s", alg)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// parsePrivateKey decodes a PEM encoded PKCS#8, PKCS#1 or SEC 1 private
+// key.
+func parsePrivateKey(raw []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported private key format")
+}
@@ -0,0 +1,142 @@
+package header2post
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJwtSigner(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	dir := t.TempDir()
+	writeKey := func(name string, key any) string {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600)
+		return path
+	}
+	rsaFile, ecFile := writeKey("rsa.pem", rsaKey), writeKey("ec.pem", ecKey)
+	t.Setenv("NOTIFY_JWT_SECRET", "shared-secret")
+
+	tests := []struct {
+		name      string
+		config    Config
+		verify    func(signingInput string, sig []byte) bool
+		expectErr string
+	}{
+		{
+			name:   "hs256 from env",
+			config: Config{JwtSigningKeyEnv: "NOTIFY_JWT_SECRET"},
+			verify: func(signingInput string, sig []byte) bool {
+				mac := hmac.New(sha256.New, []byte("shared-secret"))
+				mac.Write([]byte(signingInput))
+				return hmac.Equal(sig, mac.Sum(nil))
+			},
+		},
+		{
+			name:   "rs256 from file",
+			config: Config{JwtSigningKeyFile: rsaFile, JwtAlgorithm: "RS256"},
+			verify: func(signingInput string, sig []byte) bool {
+				sum := sha256.Sum256([]byte(signingInput))
+				return rsa.VerifyPKCS1v15(&rsaKey.PublicKey, crypto.SHA256, sum[:], sig) == nil
+			},
+		},
+		{
+			name:   "es256 from file",
+			config: Config{JwtSigningKeyFile: ecFile, JwtAlgorithm: "ES256"},
+			verify: func(signingInput string, sig []byte) bool {
+				sum := sha256.Sum256([]byte(signingInput))
+				r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+				return len(sig) == 64 && ecdsa.Verify(&ecKey.PublicKey, sum[:], r, s)
+			},
+		},
+		{name: "empty env", config: Config{JwtSigningKeyEnv: "NOTIFY_JWT_UNSET"}, expectErr: "environment variable NOTIFY_JWT_UNSET is empty"},
+		{name: "missing file", config: Config{JwtSigningKeyFile: filepath.Join(dir, "missing")}, expectErr: "read jwtsigningkeyfile: "},
+		{name: "key mismatch", config: Config{JwtSigningKeyFile: ecFile, JwtAlgorithm: "RS256"}, expectErr: "jwt signing key does not match jwtalgorithm RS256"},
+		{name: "not pem", config: Config{JwtSigningKeyEnv: "NOTIFY_JWT_SECRET", JwtAlgorithm: "ES256"}, expectErr: "jwt signing key: no PEM private key found"},
+		{name: "unsupported algorithm", config: Config{JwtSigningKeyEnv: "NOTIFY_JWT_SECRET", JwtAlgorithm: "none"}, expectErr: `unsupported jwtalgorithm: "none"`},
+		{name: "invalid ttl", config: Config{JwtSigningKeyEnv: "NOTIFY_JWT_SECRET", JwtTTL: "-1m"}, expectErr: `invalid jwtttl: "-1m"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.JwtIssuer = "gateway"
+			config.JwtAudience = "notify"
+			config.JwtKeyId = "k1"
+			config.JwtClaims = map[string]string{"env": "prod"}
+			s, err := newJwtSigner(&config)
+			if tt.expectErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.expectErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodPost, "http://notify", nil)
+			if err := s.authorize(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			parts := strings.Split(token, ".")
+			if len(parts) != 3 {
+				t.Fatalf("malformed token %q", token)
+			}
+			sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			if !tt.verify(parts[0]+"."+parts[1], sig) {
+				t.Errorf("signature does not verify")
+			}
+			var header map[string]string
+			var claims map[string]any
+			headerJSON, _ := base64.RawURLEncoding.DecodeString(parts[0])
+			claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+			json.Unmarshal(headerJSON, &header)
+			json.Unmarshal(claimsJSON, &claims)
+			if header["alg"] != s.alg || header["kid"] != "k1" {
+				t.Errorf("unexpected header %v", header)
+			}
+			if claims["iss"] != "gateway" || claims["aud"] != "notify" || claims["env"] != "prod" ||
+				claims["iat"] != float64(now.Unix()) || claims["exp"] != float64(now.Add(time.Minute).Unix()) || claims["jti"] == "" {
+				t.Errorf("unexpected claims %v", claims)
+			}
+		})
+	}
+}
+
+func TestJwtSignerWithTokenUrl(t *testing.T) {
+	t.Setenv("NOTIFY_JWT_SECRET", "shared-secret")
+	_, err := newSenders(&Config{
+		NotifyUrl:        "https://example.com/notification",
+		TokenUrl:         "https://auth.example.com/token",
+		ClientId:         "gateway",
+		JwtSigningKeyEnv: "NOTIFY_JWT_SECRET",
+	}, "header2post")
+	if err == nil || err.Error() != "jwt signing cannot be combined with tokenurl" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
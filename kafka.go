@@ -0,0 +1,544 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// kafkaSink is a minimal Kafka producer speaking the wire protocol
+// directly: a Metadata (v1) request locates the partition leaders and a
+// Produce (v3, acks=1) request appends a single record batch. The
+// metadata and one connection per broker are kept across notifications;
+// the metadata is refreshed when a leader moves or a broker connection
+// fails.
+type kafkaSink struct {
+	brokers     []string
+	topic       string
+	key         *keyTemplate
+	maxResponse int
+	correlation int32
+	roundRobin  uint32
+
+	mu    sync.Mutex
+	meta  *kafkaMetadata
+	conns map[string]*kafkaConn
+}
+
+// kafkaConn is a broker connection carrying one request at a time.
+type kafkaConn struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+const (
+	kafkaApiProduce  = 0
+	kafkaApiMetadata = 3
+
+	// produce error codes after which the partition leader is looked up
+	// again
+	kafkaUnknownTopicOrPartition = 3
+	kafkaLeaderNotAvailable      = 5
+	kafkaNotLeaderForPartition   = 6
+
+	defaultKafkaMaxResponseBytes = 4 << 20
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+var errKafkaNoLeader = errors.New("no leader for partition")
+
+// kafkaProduceError is an error code of a Produce response.
+type kafkaProduceError int16
+
+func (e kafkaProduceError) Error() string {
+	return fmt.Sprintf("produce error code %d", int16(e))
+}
+
+// staleMetadata reports whether err, from produce, calls for the
+// partition leader to be looked up again: the leader moved or the
+// connection to a broker failed.
+func staleMetadata(err error) bool {
+	var code kafkaProduceError
+	if errors.As(err, &code) {
+		return code == kafkaUnknownTopicOrPartition || code == kafkaLeaderNotAvailable || code == kafkaNotLeaderForPartition
+	}
+	var netErr net.Error
+	return errors.Is(err, errKafkaNoLeader) || errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+func newKafkaSink(config *Config) (*kafkaSink, error) {
+	if len(config.KafkaBrokers) == 0 {
+		return nil, fmt.Errorf("kafkabrokers cannot be empty")
+	}
+	if config.KafkaTopic == "" {
+		return nil, fmt.Errorf("kafkatopic cannot be empty")
+	}
+	if config.KafkaMaxResponseBytes < 0 {
+		return nil, fmt.Errorf("kafkamaxresponsebytes cannot be negative")
+	}
+	key, err := newKeyTemplate("kafkakeytemplate", config.KafkaKeyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	maxResponse := config.KafkaMaxResponseBytes
+	if maxResponse == 0 {
+		maxResponse = defaultKafkaMaxResponseBytes
+	}
+	return &kafkaSink{brokers: config.KafkaBrokers, topic: config.KafkaTopic, key: key, maxResponse: maxResponse, conns: make(map[string]*kafkaConn)}, nil
+}
+
+func (k *kafkaSink) Target() string {
+	return "kafka://" + k.topic
+}
+
+func (k *kafkaSink) Send(ctx context.Context, n Notification) error {
+	key, err := k.key.render(n.Payload)
+	if err != nil {
+		return fmt.Errorf("render key: %w", err)
+	}
+	headers := make([][2]string, 0, len(n.Header)+1)
+	headers = append(headers, [2]string{"content-type", n.ContentType})
+	for name, values := range n.Header {
+		for _, v := range values {
+			headers = append(headers, [2]string{name, v})
+		}
+	}
+	batch := kafkaRecordBatch([]byte(key), n.Body, headers, timeNow().UnixMilli())
+
+	err = k.produce(ctx, key, batch)
+	if err != nil && staleMetadata(err) {
+		k.forgetMetadata()
+		if ctx.Err() == nil {
+			err = k.produce(ctx, key, batch)
+		}
+	}
+	return err
+}
+
+// produce appends batch to the partition of key on its leader.
+func (k *kafkaSink) produce(ctx context.Context, key string, batch []byte) error {
+	meta, err := k.cachedMetadata(ctx)
+	if err != nil {
+		return err
+	}
+	if len(meta.partitions) == 0 {
+		return fmt.Errorf("topic %q has no partitions", k.topic)
+	}
+	part := meta.partitions[k.partition(key, len(meta.partitions))]
+	leader, ok := meta.brokers[part.leader]
+	if !ok {
+		return fmt.Errorf("%w %d", errKafkaNoLeader, part.id)
+	}
+
+	var req kafkaEncoder
+	req.nullableString(nil) // transactional_id
+	req.int16(1)            // acks
+	req.int32(int32(defaultSendTimeout.Milliseconds()))
+	req.int32(1)
+	req.string(k.topic)
+	req.int32(1)
+	req.int32(part.id)
+	req.bytes(batch)
+
+	resp, err := k.roundTrip(ctx, leader, kafkaApiProduce, 3, req.buf)
+	if err != nil {
+		return err
+	}
+	d := kafkaDecoder{buf: resp}
+	for topics := d.int32(); topics > 0; topics-- {
+		d.string()
+		for parts := d.int32(); parts > 0; parts-- {
+			d.int32()
+			if code := d.int16(); code != 0 {
+				return kafkaProduceError(code)
+			}
+			d.int64()
+			d.int64()
+		}
+	}
+	return d.err
+}
+
+// partition picks a partition for key: the murmur2 hash the Java
+// producer uses for keyed messages, round robin otherwise.
+func (k *kafkaSink) partition(key string, n int) int {
+	if key == "" {
+		return int(atomic.AddUint32(&k.roundRobin, 1) % uint32(n))
+	}
+	return int((murmur2([]byte(key)) & 0x7fffffff) % uint32(n))
+}
+
+// murmur2 is the key hash of the Kafka default partitioner.
+func murmur2(data []byte) uint32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	h := uint32(seed) ^ uint32(len(data))
+	tail := len(data) &^ 3
+	for i := 0; i < tail; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	switch len(data) & 3 {
+	case 3:
+		h ^= uint32(data[tail+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(data[tail+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(data[tail])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+type kafkaPartition struct {
+	id     int32
+	leader int32
+}
+
+type kafkaMetadata struct {
+	brokers    map[int32]string
+	partitions []kafkaPartition
+}
+
+// cachedMetadata returns the topic layout, querying it when not known.
+func (k *kafkaSink) cachedMetadata(ctx context.Context) (*kafkaMetadata, error) {
+	k.mu.Lock()
+	meta := k.meta
+	k.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+	meta, err := k.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	k.mu.Lock()
+	k.meta = meta
+	k.mu.Unlock()
+	return meta, nil
+}
+
+func (k *kafkaSink) forgetMetadata() {
+	k.mu.Lock()
+	k.meta = nil
+	k.mu.Unlock()
+}
+
+// metadata queries the bootstrap brokers in turn for the topic layout.
+func (k *kafkaSink) metadata(ctx context.Context) (*kafkaMetadata, error) {
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(k.topic)
+
+	var lastErr error
+	for _, broker := range k.brokers {
+		resp, err := k.roundTrip(ctx, broker, kafkaApiMetadata, 1, req.buf)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return k.parseMetadata(resp)
+	}
+	return nil, fmt.Errorf("metadata: %w", lastErr)
+}
+
+func (k *kafkaSink) parseMetadata(resp []byte) (*kafkaMetadata, error) {
+	d := kafkaDecoder{buf: resp}
+	meta := &kafkaMetadata{brokers: make(map[int32]string)}
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.nullableString() // rack
+		meta.brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller_id
+	for n := d.int32(); n > 0 && d.err == nil; n-- {
+		code := d.int16()
+		name := d.string()
+		d.int8() // is_internal
+		if code != 0 && name == k.topic {
+			return nil, fmt.Errorf("metadata error code %d for topic %q", code, name)
+		}
+		for p := d.int32(); p > 0 && d.err == nil; p-- {
+			d.int16() // partition error code
+			part := kafkaPartition{id: d.int32(), leader: d.int32()}
+			d.skipInt32Array() // replicas
+			d.skipInt32Array() // isr
+			if name == k.topic {
+				meta.partitions = append(meta.partitions, part)
+			}
+		}
+	}
+	return meta, d.err
+}
+
+// roundTrip sends one request to addr over the kept connection, dialing
+// it first when needed, and returns the response body following the
+// correlation id. A connection that fails is closed and dialed again by
+// the next request.
+func (k *kafkaSink) roundTrip(ctx context.Context, addr string, apiKey, apiVersion int16, body []byte) ([]byte, error) {
+	c, err := k.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		// closed by a failed request while this one waited
+		return nil, net.ErrClosed
+	}
+	conn := c.conn
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	// a deadline in the past unblocks the exchange once ctx is canceled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	correlation := atomic.AddInt32(&k.correlation, 1)
+	var req kafkaEncoder
+	req.int32(0) // size, patched below
+	req.int16(apiKey)
+	req.int16(apiVersion)
+	req.int32(correlation)
+	req.string(clientName)
+	req.buf = append(req.buf, body...)
+	binary.BigEndian.PutUint32(req.buf, uint32(len(req.buf)-4))
+	resp, err := c.exchange(req.buf, correlation, k.maxResponse)
+	if err != nil {
+		k.dropConn(addr, c)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// conn returns the connection to addr, dialing it when there is none.
+func (k *kafkaSink) conn(ctx context.Context, addr string) (*kafkaConn, error) {
+	k.mu.Lock()
+	c, ok := k.conns[addr]
+	k.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c = &kafkaConn{conn: conn, r: bufio.NewReader(conn)}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if existing, ok := k.conns[addr]; ok {
+		// another request dialed concurrently
+		conn.Close()
+		return existing, nil
+	}
+	k.conns[addr] = c
+	return c, nil
+}
+
+// dropConn closes c, the connection to addr, after a failed request.
+// Called with c.mu held.
+func (k *kafkaSink) dropConn(addr string, c *kafkaConn) {
+	k.mu.Lock()
+	if k.conns[addr] == c {
+		delete(k.conns, addr)
+	}
+	k.mu.Unlock()
+	c.conn.Close()
+	c.conn = nil
+}
+
+// exchange writes req and reads the response matching correlation,
+// refusing one over max bytes.
+func (c *kafkaConn) exchange(req []byte, correlation int32, max int) ([]byte, error) {
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, err
+	}
+	var size int32
+	if err := binary.Read(c.r, binary.BigEndian, &size); err != nil {
+		return nil, err
+	}
+	if size < 4 {
+		return nil, errors.New("short kafka response")
+	}
+	if int64(size) > int64(max) {
+		return nil, fmt.Errorf("kafka response of %d bytes exceeds kafkamaxresponsebytes", size)
+	}
+	resp := make([]byte, size)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	if got := int32(binary.BigEndian.Uint32(resp)); got != correlation {
+		return nil, fmt.Errorf("unexpected correlation id %d", got)
+	}
+	return resp[4:], nil
+}
+
+// kafkaRecordBatch encodes a v2 record batch holding a single record.
+func kafkaRecordBatch(key, value []byte, headers [][2]string, timestamp int64) []byte {
+	var rec kafkaEncoder
+	rec.int8(0)   // attributes
+	rec.varint(0) // timestamp delta
+	rec.varint(0) // offset delta
+	if len(key) == 0 {
+		rec.varint(-1)
+	} else {
+		rec.varint(int64(len(key)))
+		rec.buf = append(rec.buf, key...)
+	}
+	rec.varint(int64(len(value)))
+	rec.buf = append(rec.buf, value...)
+	rec.varint(int64(len(headers)))
+	for _, h := range headers {
+		rec.varint(int64(len(h[0])))
+		rec.buf = append(rec.buf, h[0]...)
+		rec.varint(int64(len(h[1])))
+		rec.buf = append(rec.buf, h[1]...)
+	}
+
+	// fields covered by the crc
+	var body kafkaEncoder
+	body.int16(0) // attributes
+	body.int32(0) // last offset delta
+	body.int64(timestamp)
+	body.int64(timestamp)
+	body.int64(-1) // producer id
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(1)  // record count
+	body.varint(int64(len(rec.buf)))
+	body.buf = append(body.buf, rec.buf...)
+
+	var batch kafkaEncoder
+	batch.int64(0) // base offset
+	batch.int32(int32(4 + 1 + 4 + len(body.buf)))
+	batch.int32(-1) // partition leader epoch
+	batch.int8(2)   // magic
+	batch.int32(int32(crc32.Checksum(body.buf, crc32c)))
+	batch.buf = append(batch.buf, body.buf...)
+	return batch.buf
+}
+
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8)   { e.buf = append(e.buf, byte(v)) }
+func (e *kafkaEncoder) int16(v int16) { e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v)) }
+func (e *kafkaEncoder) int32(v int32) { e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v)) }
+func (e *kafkaEncoder) int64(v int64) { e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v)) }
+func (e *kafkaEncoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *kafkaEncoder) nullableString(s *string) {
+	if s == nil {
+		e.int16(-1)
+		return
+	}
+	e.string(*s)
+}
+
+func (e *kafkaEncoder) bytes(b []byte) {
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// kafkaDecoder reads big-endian protocol fields; the first short read
+// sets err and makes every later read return zero values.
+type kafkaDecoder struct {
+	buf []byte
+	err error
+}
+
+func (d *kafkaDecoder) take(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.buf) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	b := d.take(1)
+	if b == nil {
+		return 0
+	}
+	return int8(b[0])
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	b := d.take(2)
+	if b == nil {
+		return 0
+	}
+	return int16(binary.BigEndian.Uint16(b))
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	b := d.take(4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	b := d.take(8)
+	if b == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) nullableString() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.take(int(n)))
+}
+
+func (d *kafkaDecoder) skipInt32Array() {
+	n := d.int32()
+	d.take(int(n) * 4)
+}
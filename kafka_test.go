@@ -0,0 +1,308 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKafkaBroker answers Metadata and Produce requests for one topic with
+// a single partition led by itself, recording produced record batches.
+// errCodes are the error codes of the successive produce responses, 0
+// once used up; closeAfterProduce closes a connection once it carried a
+// produce request, like a restarting broker.
+type fakeKafkaBroker struct {
+	ln                net.Listener
+	batches           chan []byte
+	errCodes          []int16
+	closeAfterProduce bool
+
+	mu       sync.Mutex
+	conns    int
+	metadata int
+}
+
+func newFakeKafkaBroker(t *testing.T) *fakeKafkaBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeKafkaBroker{ln: ln, batches: make(chan []byte, 4)}
+	go b.serve()
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeKafkaBroker) serve() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		b.conns++
+		b.mu.Unlock()
+		go b.handle(conn)
+	}
+}
+
+func (b *fakeKafkaBroker) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		apiKey, ok := b.request(conn)
+		if !ok || apiKey == kafkaApiProduce && b.closeAfterProduce {
+			return
+		}
+	}
+}
+
+// counts returns the connections accepted and metadata requests served.
+func (b *fakeKafkaBroker) counts() (conns, metadata int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conns, b.metadata
+}
+
+// request answers one request, returning its api key.
+func (b *fakeKafkaBroker) request(conn net.Conn) (int16, bool) {
+	var size int32
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return 0, false
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return 0, false
+	}
+	d := kafkaDecoder{buf: buf}
+	apiKey := d.int16()
+	d.int16()
+	correlation := d.int32()
+	d.string()
+
+	var resp kafkaEncoder
+	resp.int32(correlation)
+	switch apiKey {
+	case kafkaApiMetadata:
+		b.mu.Lock()
+		b.metadata++
+		b.mu.Unlock()
+		host, port, _ := net.SplitHostPort(b.ln.Addr().String())
+		p, _ := strconv.Atoi(port)
+		resp.int32(1)
+		resp.int32(7)
+		resp.string(host)
+		resp.int32(int32(p))
+		resp.nullableString(nil)
+		resp.int32(7)
+		resp.int32(1)
+		resp.int16(0)
+		resp.string("events")
+		resp.int8(0)
+		resp.int32(1)
+		resp.int16(0)
+		resp.int32(0)
+		resp.int32(7)
+		resp.int32(0)
+		resp.int32(0)
+	case kafkaApiProduce:
+		d.nullableString()
+		d.int16()
+		d.int32()
+		d.int32()
+		d.string()
+		d.int32()
+		d.int32()
+		n := d.int32()
+		b.batches <- d.take(int(n))
+		var code int16
+		b.mu.Lock()
+		if len(b.errCodes) > 0 {
+			code, b.errCodes = b.errCodes[0], b.errCodes[1:]
+		}
+		b.mu.Unlock()
+		resp.int32(1)
+		resp.string("events")
+		resp.int32(1)
+		resp.int32(0)
+		resp.int16(code)
+		resp.int64(0)
+		resp.int64(-1)
+		resp.int32(0)
+	}
+	var out kafkaEncoder
+	out.bytes(resp.buf)
+	_, err := conn.Write(out.buf)
+	return apiKey, err == nil
+}
+
+func TestKafkaSink(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	s, err := newKafkaSink(&Config{
+		KafkaBrokers:     []string{broker.ln.Addr().String()},
+		KafkaTopic:       "events",
+		KafkaKeyTemplate: "{{.Payload.order}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	payload := []byte(`{"order":"o-1"}`)
+	if err := s.Send(ctx, Notification{Body: payload, ContentType: "application/json", Payload: payload}); err != nil {
+		t.Fatal(err)
+	}
+
+	batch := <-broker.batches
+	if batch[16] != 2 {
+		t.Fatalf("expected magic 2, got %d", batch[16])
+	}
+	crc := binary.BigEndian.Uint32(batch[17:21])
+	if crc != crc32.Checksum(batch[21:], crc32c) {
+		t.Errorf("record batch crc mismatch")
+	}
+	if !bytes.Contains(batch, []byte("o-1")) || !bytes.Contains(batch, payload) {
+		t.Errorf("record batch does not contain key and value")
+	}
+	if !bytes.Contains(batch, []byte("content-type")) {
+		t.Errorf("record batch does not contain content-type header")
+	}
+}
+
+func TestKafkaSinkProduceError(t *testing.T) {
+	tests := []struct {
+		name           string
+		errCodes       []int16
+		expectErr      string
+		expectMetadata int
+	}{
+		{name: "not leader refreshes metadata", errCodes: []int16{6}, expectMetadata: 2},
+		{name: "not leader twice", errCodes: []int16{6, 6}, expectErr: "produce error code 6", expectMetadata: 2},
+		{name: "other error", errCodes: []int16{2}, expectErr: "produce error code 2", expectMetadata: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeKafkaBroker(t)
+			broker.errCodes = tt.errCodes
+			s, _ := newKafkaSink(&Config{KafkaBrokers: []string{broker.ln.Addr().String()}, KafkaTopic: "events"})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err := s.Send(ctx, Notification{Body: []byte("{}"), Payload: []byte("{}")})
+			if tt.expectErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectErr != "" && (err == nil || err.Error() != tt.expectErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+			if _, metadata := broker.counts(); metadata != tt.expectMetadata {
+				t.Errorf("expected %d metadata requests, got %d", tt.expectMetadata, metadata)
+			}
+		})
+	}
+}
+
+func TestKafkaSinkReusesConnection(t *testing.T) {
+	tests := []struct {
+		name              string
+		closeAfterProduce bool
+		expectConns       int
+		expectMetadata    int
+	}{
+		{name: "kept", expectConns: 1, expectMetadata: 1},
+		// the broker closing the connection causes a redial and a
+		// metadata refresh
+		{name: "closed by broker", closeAfterProduce: true, expectConns: 3, expectMetadata: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			broker := newFakeKafkaBroker(t)
+			broker.closeAfterProduce = tt.closeAfterProduce
+			s, _ := newKafkaSink(&Config{KafkaBrokers: []string{broker.ln.Addr().String()}, KafkaTopic: "events"})
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for range 3 {
+				if err := s.Send(ctx, Notification{Body: []byte("{}"), Payload: []byte("{}")}); err != nil {
+					t.Fatal(err)
+				}
+				<-broker.batches
+			}
+			if conns, metadata := broker.counts(); conns != tt.expectConns || metadata != tt.expectMetadata {
+				t.Errorf("expected %d connections and %d metadata requests, got %d and %d", tt.expectConns, tt.expectMetadata, conns, metadata)
+			}
+		})
+	}
+}
+
+func TestKafkaSinkOversizedResponse(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.ReadFull(conn, make([]byte, 4))
+		// a length prefix claiming 1 GiB
+		binary.Write(conn, binary.BigEndian, int32(1<<30))
+		io.Copy(io.Discard, conn)
+	}()
+
+	s, _ := newKafkaSink(&Config{KafkaBrokers: []string{ln.Addr().String()}, KafkaTopic: "events"})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = s.Send(ctx, Notification{Body: []byte("{}"), Payload: []byte("{}")})
+	if expect := "metadata: kafka response of 1073741824 bytes exceeds kafkamaxresponsebytes"; err == nil || err.Error() != expect {
+		t.Errorf("expected error %q, got %v", expect, err)
+	}
+}
+
+func TestMurmur2(t *testing.T) {
+	// the expected values of the Java client's murmur2 test
+	tests := []struct {
+		key    string
+		expect int32
+	}{
+		{key: "21", expect: -973932308},
+		{key: "foobar", expect: -790332482},
+		{key: "a-little-bit-long-string", expect: -985981536},
+		{key: "a-little-bit-longer-string", expect: -1486304829},
+		{key: "lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8", expect: -58897971},
+		{key: "abc", expect: 479470107},
+	}
+	for _, tt := range tests {
+		if got := int32(murmur2([]byte(tt.key))); got != tt.expect {
+			t.Errorf("%s: expected %d, got %d", tt.key, tt.expect, got)
+		}
+	}
+}
+
+func TestNewKafkaSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "missing brokers", config: Config{KafkaTopic: "t"}, expectErr: "kafkabrokers cannot be empty"},
+		{name: "missing topic", config: Config{KafkaBrokers: []string{"b:9092"}}, expectErr: "kafkatopic cannot be empty"},
+		{name: "negative max response", config: Config{KafkaBrokers: []string{"b:9092"}, KafkaTopic: "t", KafkaMaxResponseBytes: -1}, expectErr: "kafkamaxresponsebytes cannot be negative"},
+		{name: "bad key template", config: Config{KafkaBrokers: []string{"b:9092"}, KafkaTopic: "t", KafkaKeyTemplate: "{{"}, expectErr: "invalid kafkakeytemplate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newKafkaSink(&tt.config)
+			if err == nil || !bytes.HasPrefix([]byte(err.Error()), []byte(tt.expectErr)) {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package header2post
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	lenientPadding    = "padding"
+	lenientURLSafe    = "urlsafe"
+	lenientWhitespace = "whitespace"
+)
+
+var urlSafeBase64 = strings.NewReplacer("-", "+", "_", "/")
+
+// lenientCodec rewrites a header value into padded standard base64 before
+// handing it to a base64 based codec, so values produced with base64url,
+// with stripped padding or with surrounding whitespace still decode.
+type lenientCodec struct {
+	PayloadCodec
+	padding    bool
+	urlSafe    bool
+	whitespace bool
+}
+
+// newLenientCodec wraps codec with the Base64Lenient options, returning
+// codec unchanged when none are set.
+func newLenientCodec(config *Config, encoding string, codec PayloadCodec) (PayloadCodec, error) {
+	if len(config.Base64Lenient) == 0 {
+		return codec, nil
+	}
+	switch encoding {
+	case codecBase64, codecGzip, codecProtobuf:
+	default:
+		return nil, fmt.Errorf("base64lenient cannot be combined with headerencoding %q", encoding)
+	}
+	l := &lenientCodec{PayloadCodec: codec}
+	for _, option := range config.Base64Lenient {
+		switch option {
+		case lenientPadding:
+			l.padding = true
+		case lenientURLSafe:
+			l.urlSafe = true
+		case lenientWhitespace:
+			l.whitespace = true
+		default:
+			return nil, fmt.Errorf("invalid base64lenient: %q", option)
+		}
+	}
+	return l, nil
+}
+
+func (l *lenientCodec) Decode(value string) ([]byte, error) {
+	return l.PayloadCodec.Decode(l.normalize(value))
+}
+
+func (l *lenientCodec) normalize(value string) string {
+	if l.whitespace {
+		value = strings.TrimSpace(value)
+	}
+	if l.urlSafe {
+		value = urlSafeBase64.Replace(value)
+	}
+	if l.padding {
+		if r := len(value) % 4; r != 0 {
+			value += strings.Repeat("=", 4-r)
+		}
+	}
+	return value
+}
@@ -0,0 +1,67 @@
+package header2post
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestLenientCodec(t *testing.T) {
+	payload := []byte(`{"q":"a>b?c~"}`)
+	tests := []struct {
+		name      string
+		options   []string
+		value     string
+		expectErr bool
+	}{
+		{name: "strict", value: base64.StdEncoding.EncodeToString(payload)},
+		{name: "missing padding", options: []string{"padding"}, value: base64.RawStdEncoding.EncodeToString(payload)},
+		{name: "missing padding strict", value: base64.RawStdEncoding.EncodeToString(payload), expectErr: true},
+		{name: "urlsafe", options: []string{"urlsafe"}, value: base64.URLEncoding.EncodeToString(payload)},
+		{name: "urlsafe strict", value: base64.URLEncoding.EncodeToString(payload), expectErr: true},
+		{name: "raw urlsafe", options: []string{"urlsafe", "padding"}, value: base64.RawURLEncoding.EncodeToString(payload)},
+		{name: "whitespace", options: []string{"whitespace"}, value: " " + base64.StdEncoding.EncodeToString(payload) + "\t"},
+		{name: "whitespace strict", value: " " + base64.StdEncoding.EncodeToString(payload), expectErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := newLenientCodec(&Config{Base64Lenient: tt.options}, codecBase64, base64Codec{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := codec.Decode(tt.value)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != string(payload) {
+				t.Errorf("expected %s, got %s", payload, got)
+			}
+		})
+	}
+}
+
+func TestNewLenientCodecErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		encoding  string
+		options   []string
+		expectErr string
+	}{
+		{name: "option", encoding: codecBase64, options: []string{"loose"}, expectErr: `invalid base64lenient: "loose"`},
+		{name: "json", encoding: codecJSON, options: []string{"padding"}, expectErr: `base64lenient cannot be combined with headerencoding "json"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, _ := lookupPayloadCodec(tt.encoding)
+			_, err := newLenientCodec(&Config{Base64Lenient: tt.options}, tt.encoding, codec)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+const (
+	oversizeDrop     = "drop"
+	oversizeTruncate = "truncate"
+	oversizeErrorLog = "error-log"
+)
+
+// payloadLimit caps the size of decoded payloads.
+type payloadLimit struct {
+	max    int
+	policy string
+}
+
+// newPayloadLimit returns nil when MaxPayloadBytes is zero.
+func newPayloadLimit(config *Config) (*payloadLimit, error) {
+	if config.MaxPayloadBytes < 0 {
+		return nil, fmt.Errorf("maxpayloadbytes cannot be negative")
+	}
+	switch config.OversizePayloadPolicy {
+	case "", oversizeDrop, oversizeTruncate, oversizeErrorLog:
+	default:
+		return nil, fmt.Errorf("invalid oversizepayloadpolicy: %q", config.OversizePayloadPolicy)
+	}
+	if config.MaxPayloadBytes == 0 {
+		return nil, nil
+	}
+	return &payloadLimit{max: config.MaxPayloadBytes, policy: config.OversizePayloadPolicy}, nil
+}
+
+func (l *payloadLimit) exceeded(data []byte) bool {
+	return l != nil && len(data) > l.max
+}
+
+// truncate wraps the first max bytes of data, cut on a character
+// boundary, in an object flagged as truncated.
+func (l *payloadLimit) truncate(data []byte) []byte {
+	n := l.max
+	for n > 0 && !utf8.RuneStart(data[n]) {
+		n--
+	}
+	out, _ := json.Marshal(map[string]any{
+		"truncated":     true,
+		"original_size": len(data),
+		"data":          string(data[:n]),
+	})
+	return out
+}
@@ -0,0 +1,71 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPMaxPayloadBytes(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     Config
+		payload    string
+		expectBody string
+		expectLog  string
+	}{
+		{name: "within limit", config: Config{MaxPayloadBytes: 16}, payload: `{"id":1}`, expectBody: `{"id":1}`},
+		{name: "drop", config: Config{MaxPayloadBytes: 4}, payload: `{"id":1}`},
+		{name: "error log", config: Config{MaxPayloadBytes: 4, OversizePayloadPolicy: "error-log"}, payload: `{"id":1}`, expectLog: `"level":"ERROR","msg":"payload too large"`},
+		{name: "truncate", config: Config{MaxPayloadBytes: 4, OversizePayloadPolicy: "truncate"}, payload: `{"id":1}`, expectBody: `{"data":"{\"id","original_size":8,"truncated":true}`, expectLog: `"msg":"payload truncated"`},
+		{name: "truncate on rune boundary", config: Config{MaxPayloadBytes: 3, OversizePayloadPolicy: "truncate"}, payload: `"héllo"`, expectBody: `{"data":"\"h","original_size":8,"truncated":true}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.payload)))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if body != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, body)
+			}
+			if !strings.Contains(logs.String(), tt.expectLog) {
+				t.Errorf("expected %q in\n%s", tt.expectLog, logs)
+			}
+		})
+	}
+}
+
+func TestNewPayloadLimitErrors(t *testing.T) {
+	tests := []struct {
+		config Config
+		expect string
+	}{
+		{config: Config{MaxPayloadBytes: -1}, expect: "maxpayloadbytes cannot be negative"},
+		{config: Config{MaxPayloadBytes: 10, OversizePayloadPolicy: "split"}, expect: `invalid oversizepayloadpolicy: "split"`},
+	}
+	for _, tt := range tests {
+		if _, err := newPayloadLimit(&tt.config); err == nil || err.Error() != tt.expect {
+			t.Errorf("expected error %q, got %v", tt.expect, err)
+		}
+	}
+}
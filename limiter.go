@@ -0,0 +1,90 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+var errQueueFull = errors.New("delivery queue full")
+
+// deliveryLimiter bounds the deliveries in flight to one target and the
+// deliveries waiting for a slot, so a slow receiver only ever holds its
+// own share of the middleware.
+type deliveryLimiter struct {
+	slots chan struct{}
+	// handoff passes a released slot straight to an urgent waiter.
+	handoff  chan struct{}
+	waiting  atomic.Int64
+	maxQueue int64
+}
+
+// newDeliveryLimiters returns a limiter per sender target when
+// MaxConcurrentDeliveries is set.
+func newDeliveryLimiters(config *Config, senders []Sender) (map[string]*deliveryLimiter, error) {
+	if config.MaxConcurrentDeliveries < 0 {
+		return nil, fmt.Errorf("maxconcurrentdeliveries cannot be negative")
+	}
+	if config.MaxQueuedDeliveries < 0 {
+		return nil, fmt.Errorf("maxqueueddeliveries cannot be negative")
+	}
+	if config.MaxConcurrentDeliveries == 0 {
+		if config.MaxQueuedDeliveries != 0 {
+			return nil, fmt.Errorf("maxqueueddeliveries requires maxconcurrentdeliveries")
+		}
+		return nil, nil
+	}
+	limiters := make(map[string]*deliveryLimiter, len(senders))
+	for _, s := range senders {
+		limiters[senderTarget(s)] = &deliveryLimiter{
+			slots:    make(chan struct{}, config.MaxConcurrentDeliveries),
+			handoff:  make(chan struct{}),
+			maxQueue: int64(config.MaxQueuedDeliveries),
+		}
+	}
+	return limiters, nil
+}
+
+// acquire takes a delivery slot, waiting for one unless maxQueue
+// deliveries already are. An urgent delivery is handed the next released
+// slot ahead of the others waiting. Every successful acquire must be
+// released.
+func (l *deliveryLimiter) acquire(ctx context.Context, urgent bool) error {
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	default:
+	}
+	if n := l.waiting.Add(1); l.maxQueue > 0 && n > l.maxQueue {
+		l.waiting.Add(-1)
+		return errQueueFull
+	}
+	defer l.waiting.Add(-1)
+	handoff := l.handoff
+	if !urgent {
+		handoff = nil
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return nil
+	case <-handoff:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees the slot, or passes it on to an urgent waiter.
+func (l *deliveryLimiter) release() {
+	select {
+	case l.handoff <- struct{}{}:
+	default:
+		<-l.slots
+	}
+}
+
+// depth returns the number of deliveries waiting for a slot.
+func (l *deliveryLimiter) depth() int {
+	return int(l.waiting.Load())
+}
@@ -0,0 +1,147 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDeliveryLimiter(t *testing.T) {
+	s := SenderFunc(nil)
+	limiters, err := newDeliveryLimiters(&Config{MaxConcurrentDeliveries: 1, MaxQueuedDeliveries: 1}, []Sender{s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := limiters[senderTarget(s)]
+	ctx := context.Background()
+	if err := l.acquire(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	queued := make(chan error)
+	go func() { queued <- l.acquire(ctx, false) }()
+	for l.depth() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := l.acquire(ctx, false); !errors.Is(err, errQueueFull) {
+		t.Errorf("expected queue full, got %v", err)
+	}
+	l.release()
+	if err := <-queued; err != nil {
+		t.Fatal(err)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := l.acquire(canceled, false); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled, got %v", err)
+	}
+	l.release()
+}
+
+func TestDeliveryLimiterUrgent(t *testing.T) {
+	s := SenderFunc(nil)
+	limiters, err := newDeliveryLimiters(&Config{MaxConcurrentDeliveries: 1}, []Sender{s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := limiters[senderTarget(s)]
+	ctx := context.Background()
+	if err := l.acquire(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	normal := make(chan error, 1)
+	go func() { normal <- l.acquire(ctx, false) }()
+	for l.depth() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	urgent := make(chan error, 1)
+	go func() { urgent <- l.acquire(ctx, true) }()
+	for l.depth() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	// the urgent waiter may not be receiving on handoff yet
+	time.Sleep(10 * time.Millisecond)
+	l.release()
+	if err := <-urgent; err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-normal:
+		t.Fatal("normal delivery got the slot before the urgent one")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.release()
+	if err := <-normal; err != nil {
+		t.Fatal(err)
+	}
+	l.release()
+}
+
+func TestNewDeliveryLimitersErrors(t *testing.T) {
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{MaxConcurrentDeliveries: -1}, expectErr: "maxconcurrentdeliveries cannot be negative"},
+		{config: Config{MaxConcurrentDeliveries: 1, MaxQueuedDeliveries: -1}, expectErr: "maxqueueddeliveries cannot be negative"},
+		{config: Config{MaxQueuedDeliveries: 1}, expectErr: "maxqueueddeliveries requires maxconcurrentdeliveries"},
+	}
+	for _, tt := range tests {
+		_, err := newDeliveryLimiters(&tt.config, nil)
+		if err == nil || err.Error() != tt.expectErr {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
+
+func TestServeHTTPDeliveryLimiter(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:            "X-Notify",
+		NotifyUrl:               "https://fast.example.com/notification",
+		FanoutUrls:              []string{"https://slow.example.com/notification"},
+		MaxConcurrentDeliveries: 1,
+		MaxQueuedDeliveries:     1,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	var fast atomic.Int32
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "slow.example.com" {
+			<-release
+		} else {
+			fast.Add(1)
+		}
+		return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+	})
+	slow := handler.(*notify).limiters["https://slow.example.com/notification"]
+
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		}()
+	}
+	for slow.depth() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	// the slow target is saturated, the fast one still takes deliveries
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := fast.Load(); got != 3 {
+		t.Errorf("expected 3 fast deliveries, got %d", got)
+	}
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package header2post
+
+import "os"
+
+// lockFile is a no-op where flock is not available, leaving instances
+// that share a spool file unsynchronized.
+func lockFile(*os.File) error {
+	return nil
+}
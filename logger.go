@@ -0,0 +1,211 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	logOutputStdout = "stdout"
+	logOutputStderr = "stderr"
+
+	defaultLogMaxSizeMB  = 100
+	defaultLogMaxBackups = 3
+)
+
+// logStdout and logStderr back the "stdout" and "stderr" log outputs.
+var (
+	logStdout io.Writer = os.Stdout
+	logStderr io.Writer = os.Stderr
+)
+
+// stdWriter writes to *w at the time of each write.
+type stdWriter struct{ w *io.Writer }
+
+func (s stdWriter) Write(p []byte) (int, error) {
+	return (*s.w).Write(p)
+}
+
+// newLogger returns a JSON logger whose records carry the middleware name.
+func newLogger(name string, level slog.Level, w io.Writer) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})).With("middleware", name)
+}
+
+// discardLogger returns a logger dropping every record, for components
+// that log only once the middleware hands them its logger.
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewJSONHandler(io.Discard, nil))
+}
+
+// newSplitLogger is newLogger writing the records at level error, failed
+// delivery reports among them, to errW instead of w.
+func newSplitLogger(name string, level slog.Level, w, errW io.Writer) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: level}
+	h := splitHandler{below: slog.NewJSONHandler(w, opts), above: slog.NewJSONHandler(errW, opts), level: slog.LevelError}
+	return slog.New(h).With("middleware", name)
+}
+
+// splitHandler passes records from level up to above and the others to
+// below.
+type splitHandler struct {
+	below, above slog.Handler
+	level        slog.Level
+}
+
+func (h splitHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if level >= h.level {
+		return h.above.Enabled(ctx, level)
+	}
+	return h.below.Enabled(ctx, level)
+}
+
+func (h splitHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level >= h.level {
+		return h.above.Handle(ctx, r)
+	}
+	return h.below.Handle(ctx, r)
+}
+
+func (h splitHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return splitHandler{below: h.below.WithAttrs(attrs), above: h.above.WithAttrs(attrs), level: h.level}
+}
+
+func (h splitHandler) WithGroup(name string) slog.Handler {
+	return splitHandler{below: h.below.WithGroup(name), above: h.above.WithGroup(name), level: h.level}
+}
+
+// parseLogLevel reads a LogLevel option; empty means info.
+func parseLogLevel(value string) (slog.Level, error) {
+	switch strings.ToLower(value) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return 0, fmt.Errorf("invalid loglevel: %q", value)
+}
+
+// newLogWriter returns the destination selected by LogOutput.
+func newLogWriter(config *Config) (io.Writer, error) {
+	return openLogOutput("logoutput", config.LogOutput, config)
+}
+
+// newErrorLogWriter returns the destination selected by ErrorLogOutput,
+// or nil when error records go to LogOutput.
+func newErrorLogWriter(config *Config) (io.Writer, error) {
+	if config.ErrorLogOutput == "" {
+		return nil, nil
+	}
+	return openLogOutput("errorlogoutput", config.ErrorLogOutput, config)
+}
+
+// openLogOutput opens output, "stdout", "stderr" or a file path, naming
+// option in its errors.
+func openLogOutput(option, output string, config *Config) (io.Writer, error) {
+	switch output {
+	case "", logOutputStdout:
+		return stdWriter{&logStdout}, nil
+	case logOutputStderr:
+		return stdWriter{&logStderr}, nil
+	}
+	if config.LogMaxSizeMB < 0 {
+		return nil, fmt.Errorf("logmaxsizemb cannot be negative")
+	}
+	if config.LogMaxBackups < 0 {
+		return nil, fmt.Errorf("logmaxbackups cannot be negative")
+	}
+	maxSize := int64(config.LogMaxSizeMB) << 20
+	if maxSize == 0 {
+		maxSize = defaultLogMaxSizeMB << 20
+	}
+	backups := config.LogMaxBackups
+	if backups == 0 {
+		backups = defaultLogMaxBackups
+	}
+	f, err := openLogFile(output, maxSize, backups)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", option, err)
+	}
+	return f, nil
+}
+
+// logFiles shares one writer per path between middleware instances, so
+// they do not rotate the same file independently.
+var (
+	logFilesMu sync.Mutex
+	logFiles   = map[string]*rotatingFile{}
+)
+
+// rotatingFile appends to path and renames it to path.1 (shifting older
+// backups) once it would grow beyond maxSize.
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func openLogFile(path string, maxSize int64, backups int) (*rotatingFile, error) {
+	logFilesMu.Lock()
+	defer logFilesMu.Unlock()
+	if f, ok := logFiles[path]; ok {
+		f.mu.Lock()
+		f.maxSize, f.backups = maxSize, backups
+		f.mu.Unlock()
+		return f, nil
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	logFiles[path] = f
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	for i := f.backups; i > 1; i-- {
+		os.Rename(f.path+"."+strconv.Itoa(i-1), f.path+"."+strconv.Itoa(i))
+	}
+	os.Rename(f.path, f.path+".1")
+	return f.open()
+}
@@ -0,0 +1,246 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureLog redirects the stdout log output for the rest of the test.
+func captureLog(t testing.TB) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	prev := logStdout
+	logStdout = buf
+	t.Cleanup(func() { logStdout = prev })
+	return buf
+}
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		value     string
+		expect    slog.Level
+		expectErr string
+	}{
+		{value: "", expect: slog.LevelInfo},
+		{value: "debug", expect: slog.LevelDebug},
+		{value: "INFO", expect: slog.LevelInfo},
+		{value: "warn", expect: slog.LevelWarn},
+		{value: "error", expect: slog.LevelError},
+		{value: "trace", expectErr: `invalid loglevel: "trace"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseLogLevel(tt.value)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil || got != tt.expect {
+				t.Errorf("expected %v, got %v %v", tt.expect, got, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPLogLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		level    string
+		status   int
+		expect   []string
+		unexpect []string
+	}{
+		{
+			name:   "debug logs every delivery",
+			level:  "debug",
+			status: http.StatusAccepted,
+			expect: []string{`"msg":"payload decoded"`, `"msg":"delivery"`, `"payload_size":8`, `"msg":"delivery report"`},
+		},
+		{
+			name:     "info logs the report",
+			status:   http.StatusAccepted,
+			expect:   []string{`"level":"INFO","msg":"delivery report"`},
+			unexpect: []string{`"msg":"delivery"`},
+		},
+		{
+			name:     "error skips successes",
+			level:    "error",
+			status:   http.StatusAccepted,
+			unexpect: []string{`"msg":"delivery report"`, `"msg":"middleware initialized"`},
+		},
+		{
+			name:   "error keeps failures",
+			level:  "error",
+			status: http.StatusBadGateway,
+			expect: []string{`"level":"ERROR","msg":"delivery report"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logBuf := captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader: "X-Notify",
+				NotifyUrl:    "https://example.com/notification",
+				LogLevel:     tt.level,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for _, s := range tt.expect {
+				if !strings.Contains(logBuf.String(), s) {
+					t.Errorf("expected %s in log %s", s, logBuf.String())
+				}
+			}
+			for _, s := range tt.unexpect {
+				if strings.Contains(logBuf.String(), s) {
+					t.Errorf("unexpected %s in log %s", s, logBuf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestServeHTTPErrorLogOutput(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		expectStdout []string
+		expectStderr []string
+	}{
+		{
+			name:         "success",
+			status:       http.StatusAccepted,
+			expectStdout: []string{`"msg":"middleware initialized"`, `"level":"INFO","msg":"delivery report"`},
+		},
+		{
+			name:         "failure",
+			status:       http.StatusBadGateway,
+			expectStdout: []string{`"msg":"middleware initialized"`, `"level":"WARN","msg":"delivery attempt failed"`},
+			expectStderr: []string{`"level":"ERROR","msg":"delivery report","middleware":"header2post"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout := captureLog(t)
+			stderr := &bytes.Buffer{}
+			prev := logStderr
+			logStderr = stderr
+			t.Cleanup(func() { logStderr = prev })
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:   "X-Notify",
+				NotifyUrl:      "https://example.com/notification",
+				ErrorLogOutput: "stderr",
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tt.status, Body: http.NoBody}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			for out, expect := range map[*bytes.Buffer][]string{stdout: tt.expectStdout, stderr: tt.expectStderr} {
+				lines := strings.Count(out.String(), "\n")
+				if lines != len(expect) {
+					t.Errorf("expected %d records, got %s", len(expect), out.String())
+				}
+				for _, s := range expect {
+					if !strings.Contains(out.String(), s) {
+						t.Errorf("expected %s in log %s", s, out.String())
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewLogWriter(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "stdout"},
+		{name: "stderr", config: Config{LogOutput: "stderr"}},
+		{name: "file", config: Config{LogOutput: filepath.Join(dir, "notify.log")}},
+		{name: "missing dir", config: Config{LogOutput: filepath.Join(dir, "missing", "notify.log")}, expectErr: "open logoutput: "},
+		{name: "negative size", config: Config{LogOutput: filepath.Join(dir, "a.log"), LogMaxSizeMB: -1}, expectErr: "logmaxsizemb cannot be negative"},
+		{name: "negative backups", config: Config{LogOutput: filepath.Join(dir, "a.log"), LogMaxBackups: -1}, expectErr: "logmaxbackups cannot be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := newLogWriter(&tt.config)
+			if tt.expectErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.expectErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil || w == nil {
+				t.Fatalf("unexpected writer %v %v", w, err)
+			}
+		})
+	}
+}
+
+func TestNewErrorLogWriter(t *testing.T) {
+	dir := t.TempDir()
+	if w, err := newErrorLogWriter(&Config{}); w != nil || err != nil {
+		t.Errorf("expected no writer, got %v %v", w, err)
+	}
+	if w, err := newErrorLogWriter(&Config{ErrorLogOutput: filepath.Join(dir, "errors.log")}); w == nil || err != nil {
+		t.Errorf("unexpected writer %v %v", w, err)
+	}
+	_, err := newErrorLogWriter(&Config{ErrorLogOutput: filepath.Join(dir, "missing", "errors.log")})
+	if err == nil || !strings.HasPrefix(err.Error(), "open errorlogoutput: ") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify.log")
+	f, err := openLogFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := openLogFile(path, 10, 2); again != f {
+		t.Errorf("expected the writer to be shared")
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, expect := range map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	} {
+		b, err := os.ReadFile(name)
+		if err != nil || string(b) != expect {
+			t.Errorf("expected %s to hold %q, got %q %v", name, expect, b, err)
+		}
+	}
+}
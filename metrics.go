@@ -0,0 +1,228 @@
+package header2post
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	metricsNamespace = "header2post_"
+
+	metricSent     = metricsNamespace + "notifications_sent_total"
+	metricDuration = metricsNamespace + "delivery_duration_seconds"
+	metricRetries  = metricsNamespace + "retries_total"
+	metricDropped  = metricsNamespace + "dropped_total"
+	metricQueue    = metricsNamespace + "queue_depth"
+
+	dropSampled     = "sampled"
+	dropDuplicate   = "duplicate"
+	dropDecode      = "decode_error"
+	dropEncode      = "encode_error"
+	dropTooLarge    = "too_large"
+	dropInvalidJson = "invalid_json"
+	dropCondition   = "condition"
+	dropSkipHeader  = "skip_header"
+	dropDebounced   = "debounced"
+)
+
+// durationBuckets are the delivery_duration_seconds histogram buckets.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metricNames is the exposition order.
+var metricNames = []string{metricSent, metricRetries, metricDropped, metricDuration, metricQueue}
+
+var metricHelp = map[string]string{
+	metricSent:     "Notifications delivered to a target, by status.",
+	metricDuration: "Time spent delivering a notification to a target.",
+	metricRetries:  "Repeated delivery attempts.",
+	metricDropped:  "Notifications dropped before delivery, by reason.",
+	metricQueue:    "Notifications waiting in the partition queue or batch.",
+}
+
+// metricsRecorder receives delivery outcomes, e.g. to expose them to
+// Prometheus or send them to StatsD.
+type metricsRecorder interface {
+	delivery(middleware string, r deliveryResult)
+	dropped(middleware, reason string)
+}
+
+// delivered records r with every configured recorder.
+func (a *notify) delivered(r deliveryResult) {
+	for _, rec := range a.recorders {
+		rec.delivery(a.name, r)
+	}
+}
+
+// dropped records a notification discarded for reason.
+func (a *notify) dropped(reason string) {
+	for _, rec := range a.recorders {
+		rec.dropped(a.name, reason)
+	}
+}
+
+// metrics holds the series of every middleware instance, so they survive
+// configuration reloads. Series are keyed by their rendered label set.
+type metrics struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	kind   string
+	series map[string]*sample
+}
+
+// sample is a counter value, a histogram or a gauge function.
+type sample struct {
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+	fn     func() float64
+}
+
+var defaultMetrics = newMetrics()
+
+func newMetrics() *metrics {
+	return &metrics{families: map[string]*family{}}
+}
+
+// labelEscaper escapes label values as the Prometheus text format
+// requires: only backslash, double quote and line feed.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels renders name="value" pairs in the given order.
+func labels(kv ...string) string {
+	pairs := make([]string, 0, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		pairs = append(pairs, kv[i]+`="`+labelEscaper.Replace(kv[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// targetLabel is target without the credentials a notify url may carry
+// in its userinfo or query.
+func targetLabel(target string) string {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" {
+		return target
+	}
+	u.User, u.RawQuery, u.ForceQuery, u.Fragment = nil, "", false, ""
+	return u.String()
+}
+
+// sample returns the series of name, creating it if needed. m.mu must be
+// held.
+func (m *metrics) sample(name, kind, series string) *sample {
+	f := m.families[name]
+	if f == nil {
+		f = &family{kind: kind, series: map[string]*sample{}}
+		m.families[name] = f
+	}
+	s := f.series[series]
+	if s == nil {
+		s = &sample{}
+		if kind == "histogram" {
+			s.counts = make([]uint64, len(durationBuckets))
+		}
+		f.series[series] = s
+	}
+	return s
+}
+
+func (m *metrics) add(name, series string, v float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sample(name, "counter", series).value += v
+}
+
+func (m *metrics) observe(name, series string, v float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.sample(name, "histogram", series)
+	for i, b := range durationBuckets {
+		if v <= b {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// gauge registers fn to be read at scrape time, replacing any previous
+// function for the same series.
+func (m *metrics) gauge(name, series string, fn func() float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sample(name, "gauge", series).fn = fn
+}
+
+// delivery records the outcome of one delivery.
+func (m *metrics) delivery(middleware string, r deliveryResult) {
+	status := "success"
+	if !r.Success {
+		status = "failure"
+	}
+	target := targetLabel(r.Target)
+	m.add(metricSent, labels("middleware", middleware, "target", target, "status", status), 1)
+	m.observe(metricDuration, labels("middleware", middleware, "target", target), float64(r.DurationMs)/1000)
+	if r.Retries > 0 {
+		m.add(metricRetries, labels("middleware", middleware, "target", target), float64(r.Retries))
+	}
+}
+
+// dropped records a notification discarded before delivery.
+func (m *metrics) dropped(middleware, reason string) {
+	m.add(metricDropped, labels("middleware", middleware, "reason", reason), 1)
+}
+
+// write renders every series in the Prometheus text exposition format.
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range metricNames {
+		f := m.families[name]
+		if f == nil {
+			continue
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, metricHelp[name], name, f.kind)
+		keys := make([]string, 0, len(f.series))
+		for k := range f.series {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s := f.series[k]
+			switch f.kind {
+			case "counter":
+				fmt.Fprintf(w, "%s%s %s\n", name, k, formatFloat(s.value))
+			case "gauge":
+				fmt.Fprintf(w, "%s%s %s\n", name, k, formatFloat(s.fn()))
+			case "histogram":
+				inner := strings.TrimSuffix(strings.TrimPrefix(k, "{"), "}")
+				for i, b := range durationBuckets {
+					fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", name, inner, formatFloat(b), s.counts[i])
+				}
+				fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, inner, s.count)
+				fmt.Fprintf(w, "%s_sum%s %s\n", name, k, formatFloat(s.sum))
+				fmt.Fprintf(w, "%s_count%s %d\n", name, k, s.count)
+			}
+		}
+	}
+}
+
+// ServeHTTP serves the metrics for scraping.
+func (m *metrics) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(rw)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,228 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// mqttSink publishes payloads to an MQTT 3.1.1 broker with QoS 0, 1 or 2.
+type mqttSink struct {
+	url   *url.URL
+	topic *keyTemplate
+	qos   byte
+}
+
+const (
+	mqttConnect    = 0x10
+	mqttConnack    = 0x20
+	mqttPublish    = 0x30
+	mqttPuback     = 0x40
+	mqttPubrec     = 0x50
+	mqttPubrel     = 0x62
+	mqttPubcomp    = 0x70
+	mqttDisconnect = 0xE0
+)
+
+func newMqttSink(config *Config) (*mqttSink, error) {
+	if config.MqttBrokerUrl == "" {
+		return nil, fmt.Errorf("mqttbrokerurl cannot be empty")
+	}
+	u, err := url.Parse(config.MqttBrokerUrl)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid mqttbrokerurl: %q", config.MqttBrokerUrl)
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt", "ssl", "tls", "mqtts":
+	default:
+		return nil, fmt.Errorf("invalid mqttbrokerurl: %q", config.MqttBrokerUrl)
+	}
+	if config.MqttTopic == "" {
+		return nil, fmt.Errorf("mqtttopic cannot be empty")
+	}
+	if config.MqttQos < 0 || config.MqttQos > 2 {
+		return nil, fmt.Errorf("mqttqos must be 0, 1 or 2")
+	}
+	topic, err := newKeyTemplate("mqtttopic", config.MqttTopic)
+	if err != nil {
+		return nil, err
+	}
+	return &mqttSink{url: u, topic: topic, qos: byte(config.MqttQos)}, nil
+}
+
+func (s *mqttSink) Target() string {
+	return s.url.Scheme + "://" + s.url.Host
+}
+
+func (s *mqttSink) secure() bool {
+	return s.url.Scheme == "ssl" || s.url.Scheme == "tls" || s.url.Scheme == "mqtts"
+}
+
+func (s *mqttSink) Send(ctx context.Context, n Notification) error {
+	topic, err := s.topic.render(n.Payload)
+	if err != nil {
+		return fmt.Errorf("render topic: %w", err)
+	}
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return fmt.Errorf("invalid topic %q", topic)
+	}
+
+	addr := s.url.Host
+	if s.url.Port() == "" {
+		port := "1883"
+		if s.secure() {
+			port = "8883"
+		}
+		addr = net.JoinHostPort(s.url.Hostname(), port)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	if s.secure() {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: s.url.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+
+	if err := s.connect(conn, r); err != nil {
+		return err
+	}
+
+	var pub []byte
+	pub = mqttString(pub, topic)
+	const packetID = 1
+	if s.qos > 0 {
+		pub = binary.BigEndian.AppendUint16(pub, packetID)
+	}
+	pub = append(pub, n.Body...)
+	if err := mqttWrite(conn, mqttPublish|s.qos<<1, pub); err != nil {
+		return err
+	}
+
+	switch s.qos {
+	case 1:
+		if _, err := mqttExpect(r, mqttPuback); err != nil {
+			return err
+		}
+	case 2:
+		if _, err := mqttExpect(r, mqttPubrec); err != nil {
+			return err
+		}
+		if err := mqttWrite(conn, mqttPubrel, binary.BigEndian.AppendUint16(nil, packetID)); err != nil {
+			return err
+		}
+		if _, err := mqttExpect(r, mqttPubcomp); err != nil {
+			return err
+		}
+	}
+	return mqttWrite(conn, mqttDisconnect, nil)
+}
+
+func (s *mqttSink) connect(conn net.Conn, r *bufio.Reader) error {
+	var body []byte
+	body = mqttString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	flags := byte(0x02)    // clean session
+	var user, pass string
+	hasPass := false
+	if s.url.User != nil {
+		user = s.url.User.Username()
+		flags |= 0x80
+		pass, hasPass = s.url.User.Password()
+		if hasPass {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	body = binary.BigEndian.AppendUint16(body, 60)
+	body = mqttString(body, clientName+"-"+strings.ReplaceAll(generateID(), "-", "")[:12])
+	if s.url.User != nil {
+		body = mqttString(body, user)
+		if hasPass {
+			body = mqttString(body, pass)
+		}
+	}
+	if err := mqttWrite(conn, mqttConnect, body); err != nil {
+		return err
+	}
+	ack, err := mqttExpect(r, mqttConnack)
+	if err != nil {
+		return err
+	}
+	if len(ack) < 2 {
+		return errors.New("malformed connack")
+	}
+	if ack[1] != 0 {
+		return fmt.Errorf("connection refused: code %d", ack[1])
+	}
+	return nil
+}
+
+func mqttString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// mqttWrite writes a control packet with its variable length header.
+func mqttWrite(w io.Writer, header byte, body []byte) error {
+	pkt := []byte{header}
+	n := len(body)
+	for {
+		b := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			b |= 0x80
+		}
+		pkt = append(pkt, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(pkt, body...))
+	return err
+}
+
+// mqttExpect reads the next packet and checks its type.
+func mqttExpect(r *bufio.Reader, typ byte) ([]byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	size, mult := 0, 1
+	for i := 0; i < 4; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		size += int(b&0x7f) * mult
+		mult *= 128
+		if b&0x80 == 0 {
+			break
+		}
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	if header&0xF0 != typ&0xF0 {
+		return nil, fmt.Errorf("unexpected packet type 0x%x", header)
+	}
+	return body, nil
+}
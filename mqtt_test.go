@@ -0,0 +1,123 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeMqttBroker accepts one client, acknowledges the connection with
+// connackCode and completes the publish handshake for the client's QoS.
+func fakeMqttBroker(t *testing.T, connackCode byte) (string, chan []byte) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	published := make(chan []byte, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		if _, err := mqttExpect(r, mqttConnect); err != nil {
+			return
+		}
+		mqttWrite(conn, mqttConnack, []byte{0, connackCode})
+		header, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		qos := (header[0] >> 1) & 0x03
+		body, err := mqttExpect(r, mqttPublish)
+		if err != nil {
+			return
+		}
+		published <- body
+		id := []byte{0, 1}
+		switch qos {
+		case 1:
+			mqttWrite(conn, mqttPuback, id)
+		case 2:
+			mqttWrite(conn, mqttPubrec, id)
+			mqttExpect(r, mqttPubrel)
+			mqttWrite(conn, mqttPubcomp, id)
+		}
+		mqttExpect(r, mqttDisconnect)
+	}()
+	return "tcp://user:pass@" + ln.Addr().String(), published
+}
+
+func TestMqttSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		qos       int
+		connack   byte
+		expectErr string
+	}{
+		{name: "qos 0", qos: 0},
+		{name: "qos 1", qos: 1},
+		{name: "qos 2", qos: 2},
+		{name: "connection refused", qos: 1, connack: 5, expectErr: "connection refused: code 5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, published := fakeMqttBroker(t, tt.connack)
+			s, err := newMqttSink(&Config{MqttBrokerUrl: addr, MqttTopic: "devices/{{.Payload.device}}/events", MqttQos: tt.qos})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			data := []byte(`{"device":"d1"}`)
+			err = s.Send(ctx, Notification{Body: data, Payload: data})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := <-published
+			n := binary.BigEndian.Uint16(body)
+			if topic := string(body[2 : 2+n]); topic != "devices/d1/events" {
+				t.Errorf("unexpected topic %q", topic)
+			}
+			rest := body[2+n:]
+			if tt.qos > 0 {
+				rest = rest[2:]
+			}
+			if string(rest) != string(data) {
+				t.Errorf("unexpected payload %q", rest)
+			}
+		})
+	}
+}
+
+func TestNewMqttSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "missing url", config: Config{MqttTopic: "t"}, expectErr: "mqttbrokerurl cannot be empty"},
+		{name: "invalid scheme", config: Config{MqttBrokerUrl: "http://localhost", MqttTopic: "t"}, expectErr: `invalid mqttbrokerurl: "http://localhost"`},
+		{name: "missing topic", config: Config{MqttBrokerUrl: "tcp://localhost"}, expectErr: "mqtttopic cannot be empty"},
+		{name: "invalid qos", config: Config{MqttBrokerUrl: "tcp://localhost", MqttTopic: "t", MqttQos: 3}, expectErr: "mqttqos must be 0, 1 or 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newMqttSink(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
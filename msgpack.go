@@ -0,0 +1,129 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+)
+
+const formatMsgpack = "msgpack"
+
+// encodeMsgpack re-encodes a JSON payload as MessagePack, with map keys in
+// sorted order. Payloads that are not JSON are sent as a bin value.
+func (f *payloadFormat) encodeMsgpack(data []byte) *encodedPayload {
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var b []byte
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		b = msgpackBin(b, data)
+	} else {
+		b = msgpackAppend(b, doc)
+	}
+	return &encodedPayload{body: b, contentType: "application/msgpack"}
+}
+
+func msgpackAppend(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0)
+	case bool:
+		if v {
+			return append(b, 0xc3)
+		}
+		return append(b, 0xc2)
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return msgpackInt(b, i)
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return binary.BigEndian.AppendUint64(append(b, 0xcf), u)
+		}
+		fl, _ := v.Float64()
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(fl))
+	case string:
+		return msgpackString(b, v)
+	case []any:
+		b = msgpackHeader(b, len(v), 0x90, 0xdc, 0xdd)
+		for _, e := range v {
+			b = msgpackAppend(b, e)
+		}
+		return b
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = msgpackHeader(b, len(v), 0x80, 0xde, 0xdf)
+		for _, k := range keys {
+			b = msgpackString(b, k)
+			b = msgpackAppend(b, v[k])
+		}
+		return b
+	}
+	return append(b, 0xc0)
+}
+
+func msgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f, i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(b, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(i))
+	case i >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func msgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+func msgpackBin(b []byte, data []byte) []byte {
+	switch n := len(data); {
+	case n <= math.MaxUint8:
+		b = append(b, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xc5), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xc6), uint32(n))
+	}
+	return append(b, data...)
+}
+
+// msgpackHeader appends an array or map header: the fix type for up to
+// 15 entries, else the 16 or 32 bit variant.
+func msgpackHeader(b []byte, n int, fix, b16, b32 byte) []byte {
+	switch {
+	case n <= 15:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, b16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, b32), uint32(n))
+}
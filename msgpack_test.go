@@ -0,0 +1,39 @@
+package header2post
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestPayloadFormatMsgpack(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		expect string
+	}{
+		{name: "object", data: `{"b":[true,false,null],"a":1}`, expect: "82a16101a16293c3c2c0"},
+		{name: "integers", data: `[127,128,65536,-1,-33,-129,-40000,4294967296,18446744073709551615]`, expect: "99" + "7f" + "cc80" + "ce00010000" + "ff" + "d0df" + "d1ff7f" + "d2ffff63c0" + "cf0000000100000000" + "cfffffffffffffffff"},
+		{name: "float", data: `1.5`, expect: "cb3ff8000000000000"},
+		{name: "long string", data: `"` + strings.Repeat("x", 32) + `"`, expect: "d920" + strings.Repeat("78", 32)},
+		{name: "not json", data: "raw", expect: "c403726177"},
+	}
+	f, err := newPayloadFormat(&Config{Format: "msgpack"}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := f.encode([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := hex.EncodeToString(p.body); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+			if p.contentType != "application/msgpack" {
+				t.Errorf("unexpected content type %q", p.contentType)
+			}
+		})
+	}
+}
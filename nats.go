@@ -0,0 +1,214 @@
+package header2post
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// natsSink publishes payloads using the NATS text protocol. Core NATS
+// publishes are confirmed with a PING/PONG round trip; JetStream publishes
+// wait for the stream's publish acknowledgement.
+type natsSink struct {
+	url       *url.URL
+	subject   *keyTemplate
+	jetStream bool
+}
+
+func newNatsSink(config *Config) (*natsSink, error) {
+	if config.NatsUrl == "" {
+		return nil, fmt.Errorf("natsurl cannot be empty")
+	}
+	u, err := url.Parse(config.NatsUrl)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid natsurl: %q", config.NatsUrl)
+	}
+	if config.NatsSubject == "" {
+		return nil, fmt.Errorf("natssubject cannot be empty")
+	}
+	subject, err := newKeyTemplate("natssubject", config.NatsSubject)
+	if err != nil {
+		return nil, err
+	}
+	return &natsSink{url: u, subject: subject, jetStream: config.NatsJetStream}, nil
+}
+
+func (s *natsSink) Target() string {
+	return "nats://" + s.url.Host
+}
+
+func (s *natsSink) Send(ctx context.Context, n Notification) error {
+	subject, err := s.subject.render(n.Payload)
+	if err != nil {
+		return fmt.Errorf("render subject: %w", err)
+	}
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n") {
+		return fmt.Errorf("invalid subject %q", subject)
+	}
+
+	conn, r, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	reply := ""
+	if s.jetStream {
+		reply = "_INBOX." + strings.ReplaceAll(generateID(), "-", "")
+		fmt.Fprintf(&buf, "SUB %s 1\r\n", reply)
+	}
+	s.writePublish(&buf, subject, reply, n)
+	if !s.jetStream {
+		buf.WriteString("PING\r\n")
+	}
+	if _, err := conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "PING":
+			if _, err := io.WriteString(conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case line == "PONG" && !s.jetStream:
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		case strings.HasPrefix(line, "MSG ") && s.jetStream:
+			return s.readAck(r, line)
+		}
+	}
+}
+
+// writePublish encodes a PUB, or an HPUB when the payload carries headers.
+func (s *natsSink) writePublish(buf *bytes.Buffer, subject, reply string, n Notification) {
+	target := subject
+	if reply != "" {
+		target += " " + reply
+	}
+	if len(n.Header) == 0 && n.ContentType == "" {
+		fmt.Fprintf(buf, "PUB %s %d\r\n", target, len(n.Body))
+	} else {
+		var hdr bytes.Buffer
+		hdr.WriteString("NATS/1.0\r\n")
+		if n.ContentType != "" {
+			fmt.Fprintf(&hdr, "Content-Type: %s\r\n", n.ContentType)
+		}
+		for k, values := range n.Header {
+			for _, v := range values {
+				fmt.Fprintf(&hdr, "%s: %s\r\n", k, v)
+			}
+		}
+		hdr.WriteString("\r\n")
+		fmt.Fprintf(buf, "HPUB %s %d %d\r\n", target, hdr.Len(), hdr.Len()+len(n.Body))
+		buf.Write(hdr.Bytes())
+	}
+	buf.Write(n.Body)
+	buf.WriteString("\r\n")
+}
+
+// readAck reads the JetStream publish acknowledgement following a MSG
+// control line.
+func (s *natsSink) readAck(r *bufio.Reader, line string) error {
+	fields := strings.Fields(line)
+	size, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return fmt.Errorf("malformed ack: %q", line)
+	}
+	body := make([]byte, size+2)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return err
+	}
+	var ack struct {
+		Stream string `json:"stream"`
+		Error  *struct {
+			Description string `json:"description"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body[:size], &ack); err != nil {
+		return fmt.Errorf("malformed ack: %w", err)
+	}
+	if ack.Error != nil {
+		return fmt.Errorf("jetstream: %s", ack.Error.Description)
+	}
+	if ack.Stream == "" {
+		return errors.New("jetstream: no stream acknowledged the message")
+	}
+	return nil
+}
+
+// connect dials the server, reads INFO, upgrades to TLS when required and
+// sends CONNECT.
+func (s *natsSink) connect(ctx context.Context) (net.Conn, *bufio.Reader, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.url.Host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	line, err := r.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		conn.Close()
+		return nil, nil, fmt.Errorf("unexpected greeting: %q", strings.TrimSpace(line))
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info)
+	if info.TLSRequired || s.url.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: s.url.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tlsConn
+		r = bufio.NewReader(conn)
+	}
+
+	opts := map[string]any{
+		"verbose":  false,
+		"pedantic": false,
+		"name":     clientName,
+		"lang":     "go",
+		"version":  Version,
+		"protocol": 1,
+		"headers":  true,
+	}
+	if s.url.User != nil {
+		if pass, ok := s.url.User.Password(); ok {
+			opts["user"] = s.url.User.Username()
+			opts["pass"] = pass
+		} else {
+			opts["auth_token"] = s.url.User.Username()
+		}
+	}
+	b, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", b); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, r, nil
+}
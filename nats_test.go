@@ -0,0 +1,121 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNatsServer accepts one connection, records the published message and
+// replies according to mode: "pong", "err" or a JetStream ack body.
+func fakeNatsServer(t *testing.T, reply string) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	published := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\"}\r\n")
+		r := bufio.NewReader(conn)
+		inbox := ""
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch fields[0] {
+			case "SUB":
+				inbox = fields[1]
+			case "PUB", "HPUB":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				body := make([]byte, size+2)
+				io.ReadFull(r, body)
+				published <- line + string(body[:size])
+				if inbox != "" {
+					fmt.Fprintf(conn, "MSG %s 1 %d\r\n%s\r\n", inbox, len(reply), reply)
+				}
+			case "PING":
+				if reply == "err" {
+					fmt.Fprint(conn, "-ERR 'Permissions Violation'\r\n")
+				} else {
+					fmt.Fprint(conn, "PONG\r\n")
+				}
+			}
+		}
+	}()
+	return "nats://" + ln.Addr().String(), published
+}
+
+func TestNatsSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		jetStream bool
+		expectErr string
+	}{
+		{name: "core publish", reply: "pong"},
+		{name: "core publish error", reply: "err", expectErr: "'Permissions Violation'"},
+		{name: "jetstream ack", reply: `{"stream":"EVENTS","seq":1}`, jetStream: true},
+		{name: "jetstream error", reply: `{"error":{"code":503,"description":"no responders"}}`, jetStream: true, expectErr: "jetstream: no responders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, published := fakeNatsServer(t, tt.reply)
+			s, err := newNatsSink(&Config{NatsUrl: addr, NatsSubject: "orders.{{.Payload.type}}", NatsJetStream: tt.jetStream})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			data := []byte(`{"type":"created"}`)
+			err = s.Send(ctx, Notification{Body: data, ContentType: "application/json", Payload: data})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			msg := <-published
+			if !strings.HasPrefix(msg, "HPUB orders.created ") {
+				t.Errorf("unexpected publish line %q", msg)
+			}
+			if !strings.Contains(msg, "Content-Type: application/json\r\n") || !strings.HasSuffix(msg, string(data)) {
+				t.Errorf("unexpected publish %q", msg)
+			}
+		})
+	}
+}
+
+func TestNewNatsSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "missing url", config: Config{NatsSubject: "s"}, expectErr: "natsurl cannot be empty"},
+		{name: "invalid url", config: Config{NatsUrl: "localhost", NatsSubject: "s"}, expectErr: `invalid natsurl: "localhost"`},
+		{name: "missing subject", config: Config{NatsUrl: "nats://localhost:4222"}, expectErr: "natssubject cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newNatsSink(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// negotiation falls back to other body formats for receivers that reject
+// the configured one, remembering per receiver the format it accepted.
+type negotiation struct {
+	formats []*payloadFormat
+
+	mu       sync.Mutex
+	accepted map[string]int
+}
+
+func newNegotiation(config *Config, name string) (*negotiation, error) {
+	if len(config.FallbackFormats) == 0 {
+		return nil, nil
+	}
+	n := &negotiation{accepted: map[string]int{}}
+	for _, format := range config.FallbackFormats {
+		c := *config
+		c.Format = format
+		f, err := newPayloadFormat(&c, name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fallbackformats: %q", format)
+		}
+		n.formats = append(n.formats, f)
+	}
+	return n, nil
+}
+
+// format returns the index of the fallback format target accepted, or -1
+// while it uses the configured format.
+func (n *negotiation) format(target string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if i, ok := n.accepted[target]; ok {
+		return i
+	}
+	return -1
+}
+
+func (n *negotiation) accept(target string, i int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.accepted[target] = i
+}
+
+// unsupportedFormat reports whether a receiver answered status because it
+// cannot handle the body format.
+func unsupportedFormat(status int) bool {
+	return status == http.StatusUnsupportedMediaType || status == http.StatusNotAcceptable
+}
+
+// deliverNegotiated sends msg to s in the format s accepted, trying the
+// fallback formats in order while s rejects the current one.
+func (a *notify) deliverNegotiated(ctx context.Context, s Sender, msg Notification) deliveryResult {
+	if a.negotiation == nil {
+		return deliverRetry(ctx, s, msg, a.retry, a.notifyTimeout, a.log)
+	}
+	target := senderTarget(s)
+	i := a.negotiation.format(target)
+	m := msg
+	if i >= 0 {
+		var err error
+		if m, err = a.reencode(msg, a.negotiation.formats[i]); err != nil {
+			return deliveryResult{Target: target, Error: err.Error()}
+		}
+	}
+	result := deliverRetry(ctx, s, m, a.retry, a.notifyTimeout, a.log)
+	for i++; !result.Success && unsupportedFormat(result.Status) && i < len(a.negotiation.formats); i++ {
+		f := a.negotiation.formats[i]
+		m, err := a.reencode(msg, f)
+		if err != nil {
+			a.log.Warn("fallback format encode error", "target", target, "format", f.name, "error", err, "event_ids", msg.EventIDs)
+			continue
+		}
+		a.log.Info("receiver rejected format, trying fallback", "target", target, "status", result.Status, "format", f.name, "event_ids", msg.EventIDs)
+		result = deliverRetry(ctx, s, m, a.retry, a.notifyTimeout, a.log)
+		if result.Success {
+			a.negotiation.accept(target, i)
+		}
+	}
+	return result
+}
+
+// reencode returns msg with its body rendered in f from its payload.
+func (a *notify) reencode(msg Notification, f *payloadFormat) (Notification, error) {
+	payload, err := a.encode(f, msg.Payload)
+	if err != nil {
+		return msg, err
+	}
+	header := msg.Header.Clone()
+	for k := range msg.formatHeader {
+		header.Del(k)
+	}
+	for k, v := range payload.header {
+		if header == nil {
+			header = http.Header{}
+		}
+		header[k] = v
+	}
+	msg.Body, msg.ContentType, msg.Header, msg.formatHeader = payload.body, payload.contentType, header, payload.header.Clone()
+	return msg, nil
+}
@@ -0,0 +1,72 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFallbackFormats(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:    "X-Notify",
+		NotifyUrl:       "https://example.com/notification",
+		Format:          formatMsgpack,
+		NotifyAccept:    "application/json",
+		FallbackFormats: []string{formatXML, formatJSON},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		contentType := req.Header.Get("Content-Type")
+		got = append(got, contentType)
+		if req.Header.Get("Accept") != "application/json" {
+			t.Errorf("expected accept header, got %q", req.Header.Get("Accept"))
+		}
+		status := http.StatusUnsupportedMediaType
+		if contentType == "application/json" {
+			status = http.StatusAccepted
+		}
+		return &http.Response{StatusCode: status, Body: http.NoBody}, nil
+	})
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expect := []string{"application/msgpack", "application/xml", "application/json", "application/json"}
+	if len(got) != len(expect) {
+		t.Fatalf("expected content types %q, got %q", expect, got)
+	}
+	for i := range expect {
+		if got[i] != expect[i] {
+			t.Errorf("request %d: expected content type %q, got %q", i, expect[i], got[i])
+		}
+	}
+}
+
+func TestNewFallbackFormatsErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "format", config: Config{FallbackFormats: []string{"yaml"}}, expectErr: `invalid fallbackformats: "yaml"`},
+		{name: "batching", config: Config{FallbackFormats: []string{formatJSON}, BatchMaxSize: 10}, expectErr: "fallbackformats cannot be combined with batching"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.NotifyHeader, tt.config.NotifyUrl = "X-Notify", "https://example.com/notification"
+			_, err := New(context.Background(), http.NotFoundHandler(), &tt.config, "header2post")
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ErrDropped is wrapped by the error Build returns when the payload is
+// dropped, e.g. by Condition or deduplication, rather than failing.
+var ErrDropped = errors.New("notification dropped")
+
+// Notifier exposes the header to POST behavior outside of Traefik: as a
+// net/http middleware through Wrap, or step by step through Decode, Build
+// and Send.
+type Notifier struct {
+	n *notify
+}
+
+// NewNotifier builds a Notifier from config. Options work as with
+// NewWithOptions; Rules are not supported since a Notifier runs a single
+// notification flow.
+func NewNotifier(ctx context.Context, config *Config, name string, opts ...Option) (*Notifier, error) {
+	if len(config.Rules) > 0 {
+		return nil, errors.New("rules cannot be combined with NewNotifier")
+	}
+	h, err := NewWithOptions(ctx, http.NotFoundHandler(), config, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Notifier{n: h.(*notify)}, nil
+}
+
+// Wrap returns a handler calling next and notifying from its requests or
+// responses exactly as the Traefik middleware does.
+func (nt *Notifier) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		nt.n.serve(next, rw, req)
+	})
+}
+
+// Decode decodes a notify header value with the configured HeaderEncoding.
+func (nt *Notifier) Decode(value string) ([]byte, error) {
+	return nt.n.decoder.Decode(value)
+}
+
+// Build runs a decoded payload through the same pipeline as the
+// middleware (MaxPayloadBytes, Condition, RedactFields, deduplication,
+// Transform, PayloadTemplate, AddFields, ...) and encodes it in the
+// configured Format, encrypting it when enabled, into a notification
+// ready for Send. Conditions and templates see an empty request. The
+// error wraps ErrDropped when the pipeline drops the payload.
+func (nt *Notifier) Build(data []byte) (Notification, error) {
+	a := nt.n
+	ex := newBuildExchange()
+	data, reason, _ := a.preparePayload(data, ex, nil)
+	if reason != "" {
+		a.dropped(reason)
+		return Notification{}, fmt.Errorf("%w: %s", ErrDropped, reason)
+	}
+	payload, err := a.encode(a.format, data)
+	if err != nil {
+		a.settleDedup([]string{ex.dedupKey}, false)
+		return Notification{}, err
+	}
+	msg := newNotification(payload, data, a.eventIDs(data))
+	msg.expires = a.expiry(timeNow())
+	if ex.dedupKey != "" {
+		msg.dedupKeys = []string{ex.dedupKey}
+	}
+	a.setIdempotencyKey(&msg, "", data)
+	return msg, nil
+}
+
+// newBuildExchange returns the exchange Build runs the pipeline with: a
+// bodiless GET / request and no response.
+func newBuildExchange() *exchange {
+	return &exchange{
+		req:          &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/"}, Header: http.Header{}},
+		clientHeader: http.Header{},
+		received:     timeNow(),
+	}
+}
+
+// Send delivers msg to every configured target and logs the delivery
+// report. It returns an error listing the targets that failed.
+func (nt *Notifier) Send(ctx context.Context, msg Notification) error {
+	a := nt.n
+	report := newDeliveryReport(msg.EventIDs)
+	a.setEventId(&msg, report)
+	a.dispatch(ctx, msg, report)
+	report.log(a.log)
+	var errs []error
+	for _, r := range report.Results {
+		if !r.Success {
+			errs = append(errs, fmt.Errorf("notify %s: %s", r.Target, r.Error))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// AdminHandler returns a handler serving the same JSON snapshot as
+// AdminPath, for the host to mount behind its own access control.
+func (nt *Notifier) AdminHandler() http.Handler {
+	return http.HandlerFunc(nt.n.writeAdmin)
+}
@@ -0,0 +1,134 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotifierWrap(t *testing.T) {
+	captureLog(t)
+	var got []string
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		got = append(got, string(b))
+		return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+	})
+	nt, err := NewNotifier(context.Background(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification"}, "header2post", WithHTTPDoer(doer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := nt.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Header().Get("X-Notify") != "" {
+		t.Errorf("notify header not removed")
+	}
+	if len(got) != 1 || got[0] != `{"a":1}` {
+		t.Errorf("expected one notification, got %q", got)
+	}
+}
+
+func TestNotifierSend(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name      string
+		status    int
+		expectErr string
+	}{
+		{name: "delivered", status: http.StatusAccepted},
+		{name: "failed", status: http.StatusInternalServerError, expectErr: "notify https://example.com/notification"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				got = string(b)
+				return &http.Response{StatusCode: tt.status, Body: http.NoBody}, nil
+			})
+			nt, err := NewNotifier(context.Background(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification"}, "header2post", WithHTTPDoer(doer))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := nt.Decode(base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := nt.Build(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = nt.Send(context.Background(), msg)
+			if tt.expectErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectErr != "" && (err == nil || !strings.Contains(err.Error(), tt.expectErr)) {
+				t.Fatalf("expected error containing %q, got %v", tt.expectErr, err)
+			}
+			if got != `{"a":1}` {
+				t.Errorf("expected payload %s, got %s", `{"a":1}`, got)
+			}
+		})
+	}
+}
+
+func TestNotifierBuild(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name      string
+		config    Config
+		data      string
+		expect    string
+		expectErr string
+	}{
+		{name: "plain", data: `{"amount":5}`, expect: `{"amount":5}`},
+		{name: "condition met", config: Config{Condition: "payload.amount > 1"}, data: `{"amount":5}`, expect: `{"amount":5}`},
+		{name: "condition not met", config: Config{Condition: "payload.amount > 10"}, data: `{"amount":5}`, expectErr: "notification dropped: condition"},
+		{name: "redacted", config: Config{RedactFields: []string{"email"}}, data: `{"amount":5,"email":"a@example.com"}`, expect: `{"amount":5}`},
+		{name: "too large", config: Config{MaxPayloadBytes: 4}, data: `{"amount":5}`, expectErr: "notification dropped: too_large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			nt, err := NewNotifier(context.Background(), &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := nt.Build([]byte(tt.data))
+			if tt.expectErr != "" {
+				if !errors.Is(err, ErrDropped) || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(msg.Body) != tt.expect {
+				t.Errorf("expected body %s, got %s", tt.expect, msg.Body)
+			}
+		})
+	}
+}
+
+func TestNewNotifierRules(t *testing.T) {
+	_, err := NewNotifier(context.Background(), &Config{Rules: []Rule{{NotifyHeader: "X-A", NotifyUrl: "https://example.com"}}}, "header2post")
+	if err == nil || err.Error() != "rules cannot be combined with NewNotifier" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package header2post
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const defaultEventTypeField = "event_type"
+
+// notifyValue is one notify header found on a request or response.
+type notifyValue struct {
+	// event is the part of the header name matched by a wildcard
+	// NotifyHeader, in lower case; it is empty otherwise.
+	event string
+	value string
+	// parts are the values of AggregateHeaders, decoded and merged in
+	// place of value.
+	parts []aggregatePart
+	// payload is a generated payload, such as an error report, used as is
+	// in place of value.
+	payload []byte
+}
+
+// parseNotifyHeader splits a NotifyHeader such as X-Notify-* into its
+// canonical prefix. ok is false when the header is not a wildcard.
+func parseNotifyHeader(header string) (prefix string, ok bool) {
+	if !strings.HasSuffix(header, "*") {
+		return "", false
+	}
+	return http.CanonicalHeaderKey(strings.TrimSuffix(header, "*")), true
+}
+
+// newNotifyAliases returns the canonical NotifyHeaderAliases. wildcard
+// tells whether NotifyHeader is a pattern.
+func newNotifyAliases(config *Config, wildcard bool) ([]string, error) {
+	if len(config.NotifyHeaderAliases) == 0 {
+		return nil, nil
+	}
+	if config.NotifyHeader == "" {
+		return nil, fmt.Errorf("notifyheaderaliases requires notifyheader")
+	}
+	if wildcard {
+		return nil, fmt.Errorf("notifyheaderaliases cannot be combined with a wildcard notifyheader")
+	}
+	aliases := make([]string, 0, len(config.NotifyHeaderAliases))
+	for _, alias := range config.NotifyHeaderAliases {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			return nil, fmt.Errorf("notifyheaderaliases names cannot be empty")
+		}
+		aliases = append(aliases, http.CanonicalHeaderKey(alias))
+	}
+	return aliases, nil
+}
+
+// getHeaderFold is h.Get also matching the keys a handler set in h
+// without canonicalizing them, such as X-NOTIFY.
+func getHeaderFold(h http.Header, name string) string {
+	if v := h.Get(name); v != "" {
+		return v
+	}
+	for k, v := range h {
+		if len(v) > 0 && v[0] != "" && strings.EqualFold(k, name) {
+			return v[0]
+		}
+	}
+	return ""
+}
+
+// delHeaderFold deletes name from h in any case.
+func delHeaderFold(h http.Header, name string) {
+	for k := range h {
+		if strings.EqualFold(k, name) {
+			delete(h, k)
+		}
+	}
+}
+
+// hasPrefixFold reports whether s starts with prefix in any case.
+func hasPrefixFold(s, prefix string) bool {
+	return len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix)
+}
+
+// notifyValues returns the notify headers of h, sorted by name when
+// NotifyHeader is a wildcard. NotifyHeaderAliases are tried in order when
+// NotifyHeader is absent.
+func (a *notify) notifyValues(h http.Header) []notifyValue {
+	if a.aggregate != nil {
+		if v, ok := a.aggregateValue(h); ok {
+			return []notifyValue{v}
+		}
+		return nil
+	}
+	if a.notifyPrefix == "" {
+		if value := getHeaderFold(h, a.notifyHeader); value != "" {
+			return []notifyValue{{value: value}}
+		}
+		for _, alias := range a.notifyAliases {
+			if value := getHeaderFold(h, alias); value != "" {
+				return []notifyValue{{value: value}}
+			}
+		}
+		return nil
+	}
+	var out []notifyValue
+	for k, v := range h {
+		if len(k) > len(a.notifyPrefix) && hasPrefixFold(k, a.notifyPrefix) && len(v) > 0 && v[0] != "" {
+			out = append(out, notifyValue{event: strings.ToLower(k[len(a.notifyPrefix):]), value: v[0]})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].event < out[j].event })
+	return out
+}
+
+// removeNotifyHeaders deletes the notify headers and their aliases in any
+// case, AggregateHeaders,
+// SkipHeader and PriorityHeader from h.
+func (a *notify) removeNotifyHeaders(h http.Header) {
+	if a.notifyPrefix == "" && a.notifyHeader != "" {
+		delHeaderFold(h, a.notifyHeader)
+		for _, alias := range a.notifyAliases {
+			delHeaderFold(h, alias)
+		}
+	}
+	for k := range h {
+		if a.notifyPrefix != "" && hasPrefixFold(k, a.notifyPrefix) {
+			delete(h, k)
+		}
+	}
+	for _, f := range a.aggregate {
+		h.Del(f.header)
+	}
+	if a.skipHeader != "" {
+		h.Del(a.skipHeader)
+	}
+	if a.priorities != nil {
+		h.Del(a.priorities.header)
+	}
+}
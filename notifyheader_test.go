@@ -0,0 +1,183 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPWildcardNotifyHeader(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		source string
+		config Config
+		expect []string
+	}{
+		{
+			name:   "response",
+			source: "response",
+			config: Config{NotifyUrl: "https://example.com/events/{event}"},
+			expect: []string{
+				`/events/order-created {"event_type":"order-created","id":1}`,
+				`/events/order-paid {"event_type":"order-paid","id":2}`,
+				`/events/raw plain`,
+			},
+		},
+		{
+			name:   "request",
+			source: "request",
+			config: Config{NotifyUrl: "https://example.com/events", EventTypeField: "type"},
+			expect: []string{
+				`/events {"id":1,"type":"order-created"}`,
+				`/events {"id":2,"type":"order-paid"}`,
+				`/events plain`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			headers := http.Header{}
+			headers.Set("X-Event-Order-Paid", encode(`{"id":2}`))
+			headers.Set("X-Event-Order-Created", encode(`{"id":1}`))
+			headers.Set("X-Event-Raw", encode(`plain`))
+			headers.Set("X-Event-", encode(`{"id":3}`))
+			headers.Set("X-Events", encode(`{"id":4}`))
+
+			var upstream http.Header
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = r.Header
+				if tt.source == "response" {
+					for k, v := range headers {
+						w.Header()[k] = v
+					}
+				}
+			})
+			config := tt.config
+			config.NotifyHeader = "x-event-*"
+			config.TriggerSource = tt.source
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				got = append(got, req.URL.Path+" "+string(b))
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.source == "request" {
+				for k, v := range headers {
+					req.Header[k] = v
+				}
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if strings.Join(got, "\n") != strings.Join(tt.expect, "\n") {
+				t.Errorf("expected\n%s\ngot\n%s", strings.Join(tt.expect, "\n"), strings.Join(got, "\n"))
+			}
+			remaining := rec.Header()
+			if tt.source == "request" {
+				remaining = upstream
+			}
+			for k := range remaining {
+				if strings.HasPrefix(k, "X-Event-") {
+					t.Errorf("notify header %s not removed", k)
+				}
+			}
+			if remaining.Get("X-Events") == "" {
+				t.Errorf("unrelated header removed")
+			}
+		})
+	}
+}
+
+func TestNewWildcardNotifyHeaderErrors(t *testing.T) {
+	for config, expect := range map[*Config]string{
+		{NotifyHeader: "*", NotifyUrl: "https://example.com"}:                                   `invalid notifyheader: "*"`,
+		{NotifyHeader: "X-Event-*", NotifyUrl: "https://example.com/{event}", BatchMaxSize: 10}: "notifyurl {event} cannot be combined with batching",
+	} {
+		if _, err := New(context.Background(), http.NotFoundHandler(), config, "header2post"); err == nil || err.Error() != expect {
+			t.Errorf("expected error %q, got %v", expect, err)
+		}
+	}
+}
+
+func TestServeHTTPNotifyHeaderAliases(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name    string
+		headers map[string]string
+		expect  string
+	}{
+		{name: "notify header", headers: map[string]string{"X-Notify": `{"id":1}`, "X-Event": `{"id":2}`}, expect: `{"id":1}`},
+		{name: "upper case", headers: map[string]string{"X-NOTIFY": `{"id":1}`}, expect: `{"id":1}`},
+		{name: "first alias", headers: map[string]string{"X-Event": `{"id":2}`, "X-Legacy-Notify": `{"id":3}`}, expect: `{"id":2}`},
+		{name: "lower case alias", headers: map[string]string{"x-legacy-notify": `{"id":3}`}, expect: `{"id":3}`},
+		{name: "none", headers: map[string]string{"X-Other": `{"id":4}`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// set without canonicalizing, as some frameworks do
+				for k, v := range tt.headers {
+					w.Header()[k] = []string{encode(v)}
+				}
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:        "X-Notify",
+				NotifyHeaderAliases: []string{"x-event", "X-Legacy-Notify"},
+				NotifyUrl:           "https://example.com/notification",
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				got = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if got != tt.expect {
+				t.Errorf("expected %q, got %q", tt.expect, got)
+			}
+			for k := range rec.Header() {
+				if k != "X-Other" {
+					t.Errorf("notify header %s not removed", k)
+				}
+			}
+		})
+	}
+}
+
+func TestNewNotifyHeaderAliasesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "wildcard", config: Config{NotifyHeader: "X-Event-*", NotifyHeaderAliases: []string{"X-Notify"}}, expectErr: "notifyheaderaliases cannot be combined with a wildcard notifyheader"},
+		{name: "no notify header", config: Config{ErrorStatusCodes: []string{"5xx"}, NotifyHeaderAliases: []string{"X-Notify"}}, expectErr: "notifyheaderaliases requires notifyheader"},
+		{name: "empty", config: Config{NotifyHeader: "X-Notify", NotifyHeaderAliases: []string{" "}}, expectErr: "notifyheaderaliases names cannot be empty"},
+		{name: "invalid", config: Config{NotifyHeader: "X-Notify", NotifyHeaderAliases: []string{"X Event"}}, expectErr: `invalid notifyheaderaliases header name: "X Event"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyUrl = "https://example.com/notification"
+			_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package header2post
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTokenLifetime is assumed for tokens issued without expires_in.
+const defaultTokenLifetime = 5 * time.Minute
+
+// oauth2TokenSource obtains access tokens with the OAuth2 client
+// credentials grant and caches them until shortly before they expire.
+type oauth2TokenSource struct {
+	mu           sync.Mutex
+	client       *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	userAgent    string
+	cached       string
+	expires      time.Time
+}
+
+// newOAuth2TokenSource returns nil when no token url is configured.
+func newOAuth2TokenSource(config *Config, client *http.Client) (*oauth2TokenSource, error) {
+	if config.TokenUrl == "" {
+		return nil, nil
+	}
+	if u, err := url.Parse(config.TokenUrl); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid tokenurl: %q", config.TokenUrl)
+	}
+	if config.ClientId == "" {
+		return nil, fmt.Errorf("clientid cannot be empty")
+	}
+	return &oauth2TokenSource{
+		client:       client,
+		userAgent:    configUserAgent(config),
+		tokenURL:     config.TokenUrl,
+		clientID:     config.ClientId,
+		clientSecret: config.ClientSecret,
+		scopes:       config.Scopes,
+	}, nil
+}
+
+// authorize sets a bearer token on req.
+func (ts *oauth2TokenSource) authorize(ctx context.Context, req *http.Request) error {
+	token, err := ts.token(ctx)
+	if err != nil {
+		return fmt.Errorf("oauth2 token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (ts *oauth2TokenSource) token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.cached != "" && timeNow().Add(time.Minute).Before(ts.expires) {
+		return ts.cached, nil
+	}
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if len(ts.scopes) > 0 {
+		form.Set("scope", strings.Join(ts.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ts.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", ts.userAgent)
+	req.SetBasicAuth(url.QueryEscape(ts.clientID), url.QueryEscape(ts.clientSecret))
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	var tok struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		if json.Unmarshal(body, &tok) == nil && tok.Error != "" {
+			return "", fmt.Errorf("token endpoint: %s", strings.TrimSpace(tok.Error+" "+tok.ErrorDescription))
+		}
+		return "", fmt.Errorf("token endpoint: http status %d", resp.StatusCode)
+	}
+	if err := json.Unmarshal(body, &tok); err != nil {
+		return "", fmt.Errorf("token endpoint: %w", err)
+	}
+	if tok.AccessToken == "" {
+		return "", fmt.Errorf("token endpoint: no access_token in response")
+	}
+	lifetime := defaultTokenLifetime
+	if tok.ExpiresIn > 0 {
+		lifetime = time.Duration(tok.ExpiresIn) * time.Second
+	}
+	ts.cached = tok.AccessToken
+	ts.expires = timeNow().Add(lifetime)
+	return ts.cached, nil
+}
@@ -0,0 +1,96 @@
+package header2post
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuth2TokenSource(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	issued := 0
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		r.ParseForm()
+		if id != "gateway" || secret != "s3cret" || r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("scope") != "notify:write audit" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad credentials"}`)
+			return
+		}
+		issued++
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":300}`, issued)
+	}))
+	defer tokenSrv.Close()
+
+	var auth []string
+	notifySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = append(auth, r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer notifySrv.Close()
+
+	config := &Config{NotifyUrl: notifySrv.URL, TokenUrl: tokenSrv.URL, ClientId: "gateway", ClientSecret: "s3cret", Scopes: []string{"notify:write", "audit"}}
+	senders, err := newSenders(config, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	send := func() deliveryResult {
+		return deliverTo(senders[0], Notification{Body: []byte("{}")})
+	}
+	send()
+	now = now.Add(3 * time.Minute)
+	send()
+	now = now.Add(90 * time.Second) // within a minute of expiry
+	send()
+	expect := []string{"Bearer token-1", "Bearer token-1", "Bearer token-2"}
+	if strings.Join(auth, ",") != strings.Join(expect, ",") {
+		t.Errorf("expected %v, got %v", expect, auth)
+	}
+
+	config.ClientSecret = "wrong"
+	senders, err = newSenders(config, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := send()
+	if result.Success || result.Error != "oauth2 token: token endpoint: invalid_client bad credentials" {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestNewOAuth2TokenSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr error
+	}{
+		{name: "disabled"},
+		{name: "valid", config: Config{TokenUrl: "https://auth.example.com/token", ClientId: "gateway"}},
+		{name: "invalid url", config: Config{TokenUrl: "auth/token", ClientId: "gateway"}, expectErr: errors.New(`invalid tokenurl: "auth/token"`)},
+		{name: "missing client id", config: Config{TokenUrl: "https://auth.example.com/token"}, expectErr: errors.New("clientid cannot be empty")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := newOAuth2TokenSource(&tt.config, http.DefaultClient)
+			if tt.expectErr != nil {
+				if err == nil || err.Error() != tt.expectErr.Error() {
+					t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (ts != nil) != (tt.config.TokenUrl != "") {
+				t.Errorf("unexpected token source %v", ts)
+			}
+		})
+	}
+}
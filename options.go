@@ -0,0 +1,23 @@
+package header2post
+
+// Option adjusts a middleware built by NewWithOptions.
+type Option func(*notify)
+
+// WithHTTPDoer sends the requests to NotifyUrl and FanoutUrls, and the
+// health probe, through d instead of the client built from the
+// configuration, e.g. to stub the receiver in tests.
+func WithHTTPDoer(d HTTPDoer) Option {
+	return func(n *notify) {
+		for _, s := range n.senders {
+			if hs, ok := s.(*HTTPSender); ok {
+				hs.Client = d
+			}
+			if f, ok := s.(*urlsFile); ok {
+				f.setClient(d)
+			}
+		}
+		if n.health != nil {
+			n.health.client = d
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// doerFunc adapts a function to HTTPDoer.
+type doerFunc func(req *http.Request) (*http.Response, error)
+
+func (f doerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestNewWithHTTPDoer(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-A", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+		w.Header().Set("X-B", base64.StdEncoding.EncodeToString([]byte(`{"b":1}`)))
+	})
+	var mu sync.Mutex
+	var got []string
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, req.URL.String()+" "+string(b))
+		return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+	})
+	handler, err := NewWithOptions(context.Background(), next, &Config{
+		NotifyUrl:  "https://example.com/a",
+		FanoutUrls: []string{"https://example.com/fanout"},
+		Rules: []Rule{
+			{NotifyHeader: "X-A"},
+			{NotifyHeader: "X-B", NotifyUrl: "https://example.com/b"},
+		},
+	}, "header2post", WithHTTPDoer(doer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// deliveries of one notification run in parallel
+	joined := strings.Join(got, "\n")
+	for _, expect := range []string{
+		`https://example.com/a {"a":1}`,
+		`https://example.com/fanout {"a":1}`,
+		`https://example.com/b {"b":1}`,
+		`https://example.com/fanout {"b":1}`,
+	} {
+		if !strings.Contains(joined, expect) {
+			t.Errorf("missing delivery %q in\n%s", expect, joined)
+		}
+	}
+	if len(got) != 4 {
+		t.Errorf("expected 4 deliveries, got %d", len(got))
+	}
+}
@@ -0,0 +1,99 @@
+package header2post
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// statusOverrides maps notify reply statuses to client response statuses.
+// A zero client status passes the notify status through.
+type statusOverrides struct {
+	codes   map[int]int
+	classes map[int]int
+}
+
+// newStatusOverrides parses NotifyStatusOverrides. It returns nil when no
+// override is configured.
+func newStatusOverrides(m map[string]int) (*statusOverrides, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	o := &statusOverrides{codes: map[int]int{}, classes: map[int]int{}}
+	for key, status := range m {
+		if status != 0 && (status < 100 || status > 599) {
+			return nil, fmt.Errorf("invalid notifystatusoverrides status: %d", status)
+		}
+		k := strings.ToLower(strings.TrimSpace(key))
+		if len(k) == 3 && strings.HasSuffix(k, "xx") && k[0] >= '1' && k[0] <= '5' {
+			o.classes[int(k[0]-'0')] = status
+			continue
+		}
+		code, err := strconv.Atoi(k)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid notifystatusoverrides key: %q", key)
+		}
+		o.codes[code] = status
+	}
+	return o, nil
+}
+
+// lookup returns the client status for a notify reply status.
+func (o *statusOverrides) lookup(status int) (int, bool) {
+	if o == nil {
+		return 0, false
+	}
+	override, ok := o.codes[status]
+	if !ok {
+		override, ok = o.classes[status/100]
+	}
+	if !ok {
+		return 0, false
+	}
+	if override == 0 {
+		override = status
+	}
+	return override, true
+}
+
+// replaceResponse writes the response chosen from the notification
+// outcome, a status override or the failclosed response, in place of the
+// upstream response. It reports whether it did.
+func (a *notify) replaceResponse(w http.ResponseWriter, ex *exchange) bool {
+	if rw, ok := w.(*wrappedResponseWriter); ok && rw.committed() {
+		return false
+	}
+	if ex.reply != nil && ex.reply.status != 0 {
+		if status, ok := a.statusOverrides.lookup(ex.reply.status); ok {
+			writeResponse(w, status, ex.reply.contentType, ex.reply.body)
+			return true
+		}
+	}
+	if a.failure != nil && ex.result == resultFailed {
+		writeResponse(w, a.failure.status, a.failure.contentType, a.failure.body)
+		return true
+	}
+	return false
+}
+
+// writeResponse replaces whatever was buffered for the client. Headers
+// describing the upstream body are dropped; the others, such as
+// X-Notify-Result, are kept.
+func writeResponse(w http.ResponseWriter, status int, contentType string, body []byte) {
+	if rw, ok := w.(*wrappedResponseWriter); ok {
+		rw.buf.Reset()
+	}
+	h := w.Header()
+	for name := range h {
+		if strings.HasPrefix(name, "Content-") || name == "Etag" || name == "Last-Modified" {
+			h.Del(name)
+		}
+	}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,90 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPNotifyStatusOverrides(t *testing.T) {
+	overrides := map[string]int{"409": 0, "5xx": http.StatusServiceUnavailable, "503": http.StatusBadGateway}
+	tests := []struct {
+		name           string
+		source         string
+		status         int
+		expectCode     int
+		expectBody     string
+		expectUpstream bool
+	}{
+		{name: "pass through", status: http.StatusConflict, expectCode: http.StatusConflict, expectBody: "receiver says", expectUpstream: true},
+		{name: "class", status: http.StatusInternalServerError, expectCode: http.StatusServiceUnavailable, expectBody: "receiver says", expectUpstream: true},
+		{name: "exact wins", status: http.StatusServiceUnavailable, expectCode: http.StatusBadGateway, expectBody: "receiver says", expectUpstream: true},
+		{name: "not matched", status: http.StatusBadRequest, expectCode: http.StatusCreated, expectBody: "created", expectUpstream: true},
+		{name: "accepted", status: http.StatusAccepted, expectCode: http.StatusCreated, expectBody: "created", expectUpstream: true},
+		{name: "request", source: "request", status: http.StatusConflict, expectCode: http.StatusConflict, expectBody: "receiver says"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			payload := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+			upstream := false
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = true
+				w.Header().Set("X-Notify", payload)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("created"))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:          "X-Notify",
+				NotifyUrl:             "https://example.com/notification",
+				TriggerSource:         tt.source,
+				NotifyStatusOverrides: overrides,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				header := http.Header{"Content-Type": []string{"text/plain"}}
+				return &http.Response{StatusCode: tt.status, Header: header, Body: io.NopCloser(strings.NewReader("receiver says"))}, nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("X-Notify", payload)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if upstream != tt.expectUpstream {
+				t.Errorf("expected upstream called %v, got %v", tt.expectUpstream, upstream)
+			}
+			if w.Code != tt.expectCode || w.Body.String() != tt.expectBody {
+				t.Errorf("expected %d %q, got %d %q", tt.expectCode, tt.expectBody, w.Code, w.Body.String())
+			}
+			if tt.expectBody == "receiver says" && w.Header().Get("Content-Type") != "text/plain" {
+				t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestNewStatusOverrides(t *testing.T) {
+	tests := []struct {
+		name   string
+		m      map[string]int
+		expect string
+	}{
+		{name: "key", m: map[string]int{"conflict": 0}, expect: `invalid notifystatusoverrides key: "conflict"`},
+		{name: "class", m: map[string]int{"6xx": 0}, expect: `invalid notifystatusoverrides key: "6xx"`},
+		{name: "status", m: map[string]int{"409": 1000}, expect: "invalid notifystatusoverrides status: 1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newStatusOverrides(tt.m); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
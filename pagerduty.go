@@ -0,0 +1,150 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultPagerdutyEndpoint = "https://events.pagerduty.com/v2/enqueue"
+	defaultPagerdutySeverity = "error"
+	defaultPagerdutySummary  = "header2post notification"
+)
+
+// pagerdutySink triggers PagerDuty Events v2 alerts; the decoded payload
+// becomes the event's custom details.
+type pagerdutySink struct {
+	endpoint      string
+	routingKey    string
+	source        string
+	severityField string
+	summary       *keyTemplate
+	dedupKey      *keyTemplate
+	client        *http.Client
+	userAgent     string
+}
+
+func newPagerdutySink(config *Config, name string) (*pagerdutySink, error) {
+	if config.PagerdutyRoutingKey == "" {
+		return nil, fmt.Errorf("pagerdutyroutingkey cannot be empty")
+	}
+	summary, err := newKeyTemplate("pagerdutysummarytemplate", config.PagerdutySummaryTemplate)
+	if err != nil {
+		return nil, err
+	}
+	dedupKey, err := newKeyTemplate("pagerdutydedupkeytemplate", config.PagerdutyDedupKeyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	s := &pagerdutySink{
+		endpoint:      config.PagerdutyEndpoint,
+		routingKey:    config.PagerdutyRoutingKey,
+		source:        config.PagerdutySource,
+		severityField: config.PagerdutySeverityField,
+		summary:       summary,
+		dedupKey:      dedupKey,
+		client:        &http.Client{},
+		userAgent:     configUserAgent(config),
+	}
+	if s.endpoint == "" {
+		s.endpoint = defaultPagerdutyEndpoint
+	}
+	if s.source == "" {
+		s.source = name
+	}
+	return s, nil
+}
+
+func (s *pagerdutySink) Target() string {
+	return s.endpoint
+}
+
+// severity maps the configured payload field onto a PagerDuty severity.
+func (s *pagerdutySink) severity(data []byte) string {
+	if s.severityField == "" {
+		return defaultPagerdutySeverity
+	}
+	v, _ := fieldString(data, s.severityField)
+	switch v = strings.ToLower(v); v {
+	case "critical", "error", "warning", "info":
+		return v
+	case "fatal", "emergency", "alert":
+		return "critical"
+	case "warn":
+		return "warning"
+	case "debug", "notice":
+		return "info"
+	}
+	return defaultPagerdutySeverity
+}
+
+func (s *pagerdutySink) Send(ctx context.Context, n Notification) error {
+	data := n.Payload
+	summary, err := s.summary.render(data)
+	if err != nil {
+		return fmt.Errorf("render summary: %w", err)
+	}
+	if summary == "" {
+		summary, _ = fieldString(data, "summary")
+	}
+	if summary == "" {
+		summary = defaultPagerdutySummary
+	}
+	if len(summary) > 1024 {
+		summary = summary[:1024]
+	}
+	dedupKey, err := s.dedupKey.render(data)
+	if err != nil {
+		return fmt.Errorf("render dedup key: %w", err)
+	}
+
+	var details any = string(data)
+	if json.Valid(data) {
+		details = json.RawMessage(data)
+	}
+	event := map[string]any{
+		"routing_key":  s.routingKey,
+		"event_action": "trigger",
+		"payload": map[string]any{
+			"summary":        summary,
+			"source":         s.source,
+			"severity":       s.severity(data),
+			"custom_details": details,
+		},
+	}
+	if dedupKey != "" {
+		event["dedup_key"] = dedupKey
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", s.userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr struct {
+		Message string   `json:"message"`
+		Errors  []string `json:"errors"`
+	}
+	if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Message != "" {
+		return fmt.Errorf("http status %d: %s %s", resp.StatusCode, apiErr.Message, strings.Join(apiErr.Errors, "; "))
+	}
+	return fmt.Errorf("http status %d", resp.StatusCode)
+}
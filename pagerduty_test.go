@@ -0,0 +1,90 @@
+package header2post
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPagerdutySink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		data      string
+		status    int
+		response  string
+		expect    map[string]any
+		expectErr string
+	}{
+		{
+			name:   "mapped severity and templates",
+			config: Config{PagerdutySeverityField: "level", PagerdutySummaryTemplate: "payment {{.Payload.id}} failed", PagerdutyDedupKeyTemplate: "pay-{{.Payload.id}}"},
+			data:   `{"id":"p1","level":"FATAL"}`,
+			status: http.StatusAccepted,
+			expect: map[string]any{"summary": "payment p1 failed", "severity": "critical", "source": "header2post", "dedup_key": "pay-p1"},
+		},
+		{
+			name:   "defaults",
+			data:   `{"summary":"disk full"}`,
+			status: http.StatusAccepted,
+			expect: map[string]any{"summary": "disk full", "severity": "error"},
+		},
+		{
+			name:      "invalid event",
+			data:      `{}`,
+			status:    http.StatusBadRequest,
+			response:  `{"status":"invalid event","message":"Event object is invalid","errors":["Length of 'routing_key' is incorrect"]}`,
+			expectErr: "http status 400: Event object is invalid Length of 'routing_key' is incorrect",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var event struct {
+				RoutingKey  string         `json:"routing_key"`
+				EventAction string         `json:"event_action"`
+				DedupKey    string         `json:"dedup_key"`
+				Payload     map[string]any `json:"payload"`
+			}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				json.Unmarshal(body, &event)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.response)
+			}))
+			defer srv.Close()
+
+			tt.config.PagerdutyRoutingKey = "rk"
+			tt.config.PagerdutyEndpoint = srv.URL
+			s, err := newPagerdutySink(&tt.config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Send(context.Background(), Notification{Body: []byte(tt.data), Payload: []byte(tt.data)})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if event.RoutingKey != "rk" || event.EventAction != "trigger" {
+				t.Errorf("unexpected event %+v", event)
+			}
+			for k, v := range tt.expect {
+				got := event.Payload[k]
+				if k == "dedup_key" {
+					got = event.DedupKey
+				}
+				if got != v {
+					t.Errorf("expected %s=%v, got %v", k, v, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+const (
+	panicPropagate = "propagate"
+	panicRecover   = "recover"
+)
+
+func parsePanicMode(mode string) (string, error) {
+	switch mode {
+	case "", panicPropagate:
+		return panicPropagate, nil
+	case panicRecover:
+		return panicRecover, nil
+	}
+	return "", fmt.Errorf("invalid panicmode: %q", mode)
+}
+
+// serveNext runs next. A panic in it is logged, notified with
+// PanicNotify and, in PanicMode "recover", answered with a 500 when the
+// response has not reached the client yet; otherwise it propagates to
+// Traefik. It reports whether a panic was recovered.
+func (a *notify) serveNext(next http.Handler, rw http.ResponseWriter, req *http.Request) (recovered bool) {
+	if a.panicMode == panicPropagate && !a.panicNotify {
+		next.ServeHTTP(rw, req)
+		return false
+	}
+	w, ok := rw.(*wrappedResponseWriter)
+	if !ok {
+		w = newResponseWriter(rw, false, 0, func(http.Header) {})
+	}
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		if v == http.ErrAbortHandler {
+			// the handler aborted the response on purpose
+			panic(v)
+		}
+		a.log.Error("next handler panicked", "panic", fmt.Sprint(v), "method", req.Method, "path", req.URL.Path, "stack", string(debug.Stack()))
+		if a.panicNotify {
+			a.notifyPanic(req, w, v)
+		}
+		if a.panicMode != panicRecover {
+			panic(v)
+		}
+		w.fail(http.StatusInternalServerError)
+		recovered = true
+	}()
+	next.ServeHTTP(w, req)
+	return false
+}
+
+// notifyPanic delivers a notification describing the panic v.
+func (a *notify) notifyPanic(req *http.Request, w *wrappedResponseWriter, v any) {
+	data, err := json.Marshal(map[string]any{
+		"panic":  fmt.Sprint(v),
+		"method": req.Method,
+		"host":   req.Host,
+		"path":   req.URL.Path,
+	})
+	if err != nil {
+		return
+	}
+	for _, f := range a.flows() {
+		f.trigger(notifyValue{payload: data}, &exchange{req: req, status: http.StatusInternalServerError, clientHeader: w.Header()})
+	}
+}
+
+// entityHeaders describe the upstream body, so they are dropped along
+// with it.
+var entityHeaders = []string{"Content-Encoding", "Content-Length", "Content-Range", "Content-Disposition", "Content-Language", "Content-Location", "Content-MD5", "ETag", "Last-Modified", "Trailer"}
+
+// fail replaces a response that has not reached the client yet with an
+// error status, dropping what the upstream buffered and the headers
+// describing it.
+func (w *wrappedResponseWriter) fail(code int) {
+	if w.wroteHeader {
+		return
+	}
+	if w.buf != nil {
+		w.buf.release()
+		w.buf = nil
+	}
+	for _, name := range entityHeaders {
+		w.Header().Del(name)
+	}
+	http.Error(w, http.StatusText(code), code)
+}
@@ -0,0 +1,142 @@
+package header2post
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPPanic(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        Config
+		handler       http.HandlerFunc
+		expectPanic   bool
+		expectStatus  int
+		expectBody    string
+		expectPayload map[string]any
+	}{
+		{
+			name:         "recover",
+			config:       Config{PanicMode: "recover"},
+			handler:      func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectStatus: http.StatusInternalServerError,
+			expectBody:   "Internal Server Error\n",
+		},
+		{
+			name:   "recover buffered response",
+			config: Config{PanicMode: "recover", EnrichMode: "header"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Header().Set("ETag", `"v1"`)
+				w.Header().Set("Content-Disposition", "attachment")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("partial"))
+				panic("boom")
+			},
+			expectStatus: http.StatusInternalServerError,
+			expectBody:   "Internal Server Error\n",
+		},
+		{
+			name:   "recover after the response was sent",
+			config: Config{PanicMode: "recover"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("partial"))
+				panic("boom")
+			},
+			expectStatus: http.StatusOK,
+			expectBody:   "partial",
+		},
+		{
+			name:         "recover in request trigger mode",
+			config:       Config{PanicMode: "recover", TriggerSource: "request"},
+			handler:      func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectStatus: http.StatusInternalServerError,
+			expectBody:   "Internal Server Error\n",
+		},
+		{
+			name:        "propagate",
+			handler:     func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectPanic: true,
+		},
+		{
+			name:        "abort handler",
+			config:      Config{PanicMode: "recover"},
+			handler:     func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) },
+			expectPanic: true,
+		},
+		{
+			name:          "notify and propagate",
+			config:        Config{PanicNotify: true},
+			handler:       func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectPanic:   true,
+			expectPayload: map[string]any{"panic": "boom", "method": "GET", "host": "example.com", "path": "/orders"},
+		},
+		{
+			name:          "notify and recover",
+			config:        Config{PanicMode: "recover", PanicNotify: true},
+			handler:       func(w http.ResponseWriter, r *http.Request) { panic("boom") },
+			expectStatus:  http.StatusInternalServerError,
+			expectBody:    "Internal Server Error\n",
+			expectPayload: map[string]any{"panic": "boom", "method": "GET", "host": "example.com", "path": "/orders"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://notify.example.com/notification"
+			handler, err := New(context.Background(), tt.handler, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var payload map[string]any
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				json.Unmarshal(b, &payload)
+				return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+			})
+
+			rec := httptest.NewRecorder()
+			panicked := func() (panicked bool) {
+				defer func() { panicked = recover() != nil }()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://example.com/orders", nil))
+				return false
+			}()
+			if panicked != tt.expectPanic {
+				t.Fatalf("expected panic %v, got %v", tt.expectPanic, panicked)
+			}
+			if !tt.expectPanic {
+				if rec.Code != tt.expectStatus || rec.Body.String() != tt.expectBody {
+					t.Errorf("expected %d %q, got %d %q", tt.expectStatus, tt.expectBody, rec.Code, rec.Body.String())
+				}
+				if rec.Code == http.StatusInternalServerError {
+					// the upstream headers describing the dropped body go with it
+					for _, name := range []string{"Content-Encoding", "ETag", "Content-Disposition"} {
+						if v := rec.Header().Get(name); v != "" {
+							t.Errorf("unexpected %s: %s", name, v)
+						}
+					}
+				}
+			}
+			if tt.expectPayload != nil {
+				for k, v := range tt.expectPayload {
+					if payload[k] != v {
+						t.Errorf("expected payload %s=%v, got %v", k, v, payload)
+					}
+				}
+			} else if payload != nil {
+				t.Errorf("unexpected notification %v", payload)
+			}
+		})
+	}
+
+	_, err := New(context.Background(), http.NotFoundHandler(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://notify.example.com", PanicMode: "ignore"}, "header2post")
+	if err == nil || err.Error() != `invalid panicmode: "ignore"` {
+		t.Errorf("expected invalid panicmode error, got %v", err)
+	}
+}
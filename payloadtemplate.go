@@ -0,0 +1,98 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"text/template"
+)
+
+// payloadTemplate builds the delivered JSON from the decoded payload and
+// the exchange that triggered it.
+type payloadTemplate struct {
+	tmpl *template.Template
+}
+
+var templateFuncs = template.FuncMap{
+	// json renders a value as JSON, e.g. {{json .Payload.order}}.
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+func newPayloadTemplate(text string) (*payloadTemplate, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("payloadtemplate").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payloadtemplate: %w", err)
+	}
+	return &payloadTemplate{tmpl: tmpl}, nil
+}
+
+// templateRequest exposes the incoming request to templates.
+type templateRequest struct {
+	Method     string
+	Host       string
+	Path       string
+	Query      string
+	RemoteAddr string
+	Header     http.Header
+}
+
+// templateResponse exposes the upstream response to templates. It is
+// empty in request trigger mode.
+type templateResponse struct {
+	Status int
+	Header http.Header
+}
+
+// templateData is the value templates are executed against.
+type templateData struct {
+	// Payload is the decoded JSON payload, or the raw string when it is
+	// not JSON.
+	Payload  any
+	Raw      string
+	Request  templateRequest
+	Response templateResponse
+	// Headers are the headers forwarded to the notify url.
+	Headers http.Header
+}
+
+func (a *notify) templateData(data []byte, ex *exchange) *templateData {
+	var payload any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		payload = string(data)
+	}
+	return &templateData{
+		Payload: payload,
+		Raw:     string(data),
+		Request: templateRequest{
+			Method:     ex.req.Method,
+			Host:       ex.req.Host,
+			Path:       ex.req.URL.Path,
+			Query:      ex.req.URL.RawQuery,
+			RemoteAddr: ex.req.RemoteAddr,
+			Header:     ex.req.Header,
+		},
+		Response: templateResponse{Status: ex.status, Header: ex.respHeader},
+		Headers:  a.forwarded(ex),
+	}
+}
+
+// render executes the template; the result must be valid JSON.
+func (p *payloadTemplate) render(td *templateData) ([]byte, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := p.tmpl.Execute(buf, td); err != nil {
+		return nil, err
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, errors.New("payload template did not render valid json")
+	}
+	return bytes.Clone(buf.Bytes()), nil
+}
@@ -0,0 +1,73 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPPayloadTemplate(t *testing.T) {
+	tests := []struct {
+		name       string
+		template   string
+		source     string
+		expectBody string
+	}{
+		{
+			name:       "response",
+			template:   `{"order":{{json .Payload.order}},"status":{{.Response.Status}},"path":{{json .Request.Path}},"host":"{{.Request.Host}}","tenant":{{json (.Headers.Get "X-Tenant")}},"etag":{{json (.Response.Header.Get "Etag")}}}`,
+			expectBody: `{"order":{"id":7},"status":201,"path":"/orders","host":"example.com","tenant":"t1","etag":"v1"}`,
+		},
+		{
+			name:       "request",
+			template:   `{"raw":{{json .Raw}},"method":"{{.Request.Method}}","status":{{.Response.Status}}}`,
+			source:     "request",
+			expectBody: `{"raw":"{\"order\":{\"id\":7}}","method":"POST","status":0}`,
+		},
+		{
+			name:     "invalid json",
+			template: `{"order":{{.Payload.order}}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			payload := base64.StdEncoding.EncodeToString([]byte(`{"order":{"id":7}}`))
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", payload)
+				w.Header().Set("ETag", "v1")
+				w.WriteHeader(http.StatusCreated)
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:    "X-Notify",
+				NotifyUrl:       "https://example.com/notification",
+				ForwardHeaders:  []string{"X-Tenant"},
+				TriggerSource:   tt.source,
+				PayloadTemplate: tt.template,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+			req.Header.Set("X-Notify", payload)
+			req.Header.Set("X-Tenant", "t1")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if body != tt.expectBody {
+				t.Errorf("expected body %s, got %s", tt.expectBody, body)
+			}
+		})
+	}
+
+	if _, err := newPayloadTemplate("{{"); err == nil {
+		t.Errorf("expected template parse error")
+	}
+}
@@ -0,0 +1,73 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+const (
+	defaultPhaseField   = "phase"
+	defaultPhaseIdField = "phase_id"
+
+	phaseStart  = "start"
+	phaseFinish = "finish"
+)
+
+// phases pairs a start notification sent when the request arrives with
+// the notifications of its response.
+type phases struct {
+	field   string
+	idField string
+}
+
+func newPhases(config *Config) (*phases, error) {
+	if !config.NotifyOnRequest {
+		return nil, nil
+	}
+	if config.TriggerSource == triggerRequest {
+		return nil, fmt.Errorf("notifyonrequest requires triggersource response")
+	}
+	p := &phases{field: config.PhaseField, idField: config.PhaseIdField}
+	if p.field == "" {
+		p.field = defaultPhaseField
+	}
+	if p.idField == "" {
+		p.idField = defaultPhaseIdField
+	}
+	return p, nil
+}
+
+// startPhase sends the start notification of req and returns the id linking it
+// to the response notifications, or "" when NotifyOnRequest is off.
+func (a *notify) startPhase(rw http.ResponseWriter, req *http.Request) string {
+	if a.phases == nil {
+		return ""
+	}
+	id := generateID()
+	data, err := json.Marshal(map[string]any{
+		"method": req.Method,
+		"host":   req.Host,
+		"path":   req.URL.Path,
+	})
+	if err != nil {
+		return ""
+	}
+	for _, f := range a.flows() {
+		f.trigger(notifyValue{payload: data}, &exchange{req: req, clientHeader: rw.Header(), phase: phaseStart, phaseID: id})
+	}
+	return id
+}
+
+// phaseFields returns the fields marking a notification of ex as part of a
+// start/finish pair, if any.
+func (a *notify) phaseFields(ex *exchange) map[string]any {
+	if a.phases == nil || ex.phaseID == "" {
+		return nil
+	}
+	phase := ex.phase
+	if phase == "" {
+		phase = phaseFinish
+	}
+	return map[string]any{a.phases.field: phase, a.phases.idField: ex.phaseID}
+}
@@ -0,0 +1,72 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNotifyOnRequest(t *testing.T) {
+	captureLog(t)
+	for _, onWriteHeader := range []bool{false, true} {
+		t.Run(map[bool]string{false: "buffered", true: "onwriteheader"}[onWriteHeader], func(t *testing.T) {
+			var got []map[string]any
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if len(got) != 1 {
+					t.Errorf("expected the start notification before the upstream, got %d", len(got))
+				}
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:         "X-Notify",
+				NotifyUrl:            "https://example.com/notification",
+				NotifyOnRequest:      true,
+				CorrelationId:        true,
+				TriggerOnWriteHeader: onWriteHeader,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				var m map[string]any
+				b, _ := io.ReadAll(req.Body)
+				if err := json.Unmarshal(b, &m); err != nil {
+					t.Error(err)
+				}
+				got = append(got, m)
+				return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/jobs", nil))
+
+			if len(got) != 2 {
+				t.Fatalf("expected 2 notifications, got %v", got)
+			}
+			start, finish := got[0], got[1]
+			if start["phase"] != "start" || start["method"] != http.MethodPost || start["path"] != "/jobs" {
+				t.Errorf("unexpected start notification: %v", start)
+			}
+			if finish["phase"] != "finish" || finish["a"] != float64(1) {
+				t.Errorf("unexpected finish notification: %v", finish)
+			}
+			id := start["phase_id"]
+			if id == nil || finish["phase_id"] != id || start["correlation_id"] != id || finish["correlation_id"] != id {
+				t.Errorf("notifications not linked: %v, %v", start, finish)
+			}
+			if rec.Header().Get("X-Correlation-Id") != id {
+				t.Errorf("expected correlation header %v, got %q", id, rec.Header().Get("X-Correlation-Id"))
+			}
+		})
+	}
+}
+
+func TestNewPhasesErrors(t *testing.T) {
+	_, err := newPhases(&Config{NotifyOnRequest: true, TriggerSource: triggerRequest})
+	if err == nil || err.Error() != "notifyonrequest requires triggersource response" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
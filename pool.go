@@ -0,0 +1,30 @@
+package header2post
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer returned to the pool, so a single
+// large response does not pin its memory for the life of the process.
+const maxPooledBuffer = 1 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *bytes.Buffer {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBuffer returns buf to the pool. buf, and any slice obtained from it,
+// must not be used afterwards.
+func putBuffer(buf *bytes.Buffer) {
+	if buf == nil || buf.Cap() > maxPooledBuffer {
+		return
+	}
+	bufferPool.Put(buf)
+}
@@ -0,0 +1,61 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferPool(t *testing.T) {
+	buf := getBuffer()
+	buf.WriteString("stale")
+	putBuffer(buf)
+	if buf := getBuffer(); buf.Len() != 0 {
+		t.Errorf("expected an empty buffer, got %q", buf.String())
+	}
+	// oversized buffers and nil are dropped without panicking
+	putBuffer(bytes.NewBuffer(make([]byte, 0, maxPooledBuffer+1)))
+	putBuffer(nil)
+}
+
+func benchmarkServeHTTP(b *testing.B, config *Config) {
+	captureLog(b)
+	body := bytes.Repeat([]byte("x"), 32<<10)
+	value := base64.StdEncoding.EncodeToString([]byte(`{"order":"a","total":42}`))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", value)
+		w.Write(body)
+	})
+	config.NotifyHeader = "X-Notify"
+	config.NotifyUrl = "https://example.com/notification"
+	handler, err := New(context.Background(), next, config, "header2post")
+	if err != nil {
+		b.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func BenchmarkServeHTTPStreamed(b *testing.B) {
+	benchmarkServeHTTP(b, &Config{})
+}
+
+func BenchmarkServeHTTPBuffered(b *testing.B) {
+	benchmarkServeHTTP(b, &Config{ExposeStatusHeader: true})
+}
+
+func BenchmarkServeHTTPPayloadTemplate(b *testing.B) {
+	benchmarkServeHTTP(b, &Config{PayloadTemplate: `{"order":{{json .Payload.order}},"status":{{.Response.Status}}}`})
+}
@@ -0,0 +1,74 @@
+package header2post
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	priorityHigh   = "high"
+	priorityNormal = "normal"
+	priorityLow    = "low"
+)
+
+// priorities reads the priority a backend gives a notification with
+// PriorityHeader and how low priority notifications are thinned out.
+type priorities struct {
+	header        string
+	lowSampleRate float64
+	lowBatchWait  time.Duration
+}
+
+func newPriorities(config *Config) (*priorities, error) {
+	header := http.CanonicalHeaderKey(strings.TrimSpace(config.PriorityHeader))
+	if header == "" {
+		if config.LowPrioritySampleRate != 0 {
+			return nil, fmt.Errorf("lowprioritysamplerate requires priorityheader")
+		}
+		if config.LowPriorityBatchMaxWait != "" {
+			return nil, fmt.Errorf("lowprioritybatchmaxwait requires priorityheader")
+		}
+		return nil, nil
+	}
+	p := &priorities{header: header, lowSampleRate: config.LowPrioritySampleRate}
+	if config.LowPriorityBatchMaxWait != "" {
+		d, err := time.ParseDuration(config.LowPriorityBatchMaxWait)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid lowprioritybatchmaxwait: %q", config.LowPriorityBatchMaxWait)
+		}
+		p.lowBatchWait = d
+	}
+	return p, nil
+}
+
+// priority returns the priority PriorityHeader sets in h: "high", "low",
+// or "normal" when the header is missing or holds another value. It is
+// "" when PriorityHeader is not configured.
+func (a *notify) priority(h http.Header) string {
+	if a.priorities == nil {
+		return ""
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get(a.priorities.header))) {
+	case priorityHigh:
+		return priorityHigh
+	case priorityLow:
+		return priorityLow
+	}
+	return priorityNormal
+}
+
+// batchFor returns the batcher collecting notifications of priority, or
+// nil when they are delivered one by one.
+func (a *notify) batchFor(priority string) *batcher {
+	switch priority {
+	case priorityHigh:
+		return nil
+	case priorityLow:
+		if a.lowBatch != nil {
+			return a.lowBatch
+		}
+	}
+	return a.batch
+}
@@ -0,0 +1,122 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestPriority(t *testing.T) {
+	a := &notify{priorities: &priorities{header: "X-Notify-Priority"}}
+	tests := []struct {
+		value  string
+		expect string
+	}{
+		{value: "", expect: priorityNormal},
+		{value: "high", expect: priorityHigh},
+		{value: " HIGH ", expect: priorityHigh},
+		{value: "low", expect: priorityLow},
+		{value: "urgent", expect: priorityNormal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			h := http.Header{}
+			if tt.value != "" {
+				h.Set("X-Notify-Priority", tt.value)
+			}
+			if got := a.priority(h); got != tt.expect {
+				t.Errorf("expected %q, got %q", tt.expect, got)
+			}
+		})
+	}
+	if got := (&notify{}).priority(http.Header{"X-Notify-Priority": {"high"}}); got != "" {
+		t.Errorf("expected no priority without priorityheader, got %q", got)
+	}
+}
+
+func TestServeHTTPPriority(t *testing.T) {
+	captureLog(t)
+	defer func() { randFloat64 = rand.Float64 }()
+	randFloat64 = func() float64 { return 0.6 }
+
+	tests := []struct {
+		name         string
+		config       Config
+		priority     string
+		expectSent   bool
+		expectQueued int
+	}{
+		{name: "normal sampled out", config: Config{SampleRate: ptr(0.5)}, priority: "normal"},
+		{name: "high never sampled", config: Config{SampleRate: ptr(0.5)}, priority: "high", expectSent: true},
+		{name: "low sample rate", config: Config{SampleRate: ptr(0.9), LowPrioritySampleRate: 0.5}, priority: "low"},
+		{name: "low default sample rate", config: Config{SampleRate: ptr(0.9)}, priority: "low", expectSent: true},
+		{name: "normal batched", config: Config{BatchMaxWait: "1h"}, priority: "", expectQueued: 1},
+		{name: "high not batched", config: Config{BatchMaxWait: "1h"}, priority: "high", expectSent: true},
+		{name: "low batched alone", config: Config{LowPriorityBatchMaxWait: "1h"}, priority: "low", expectQueued: 1},
+		{name: "normal not batched with low batch", config: Config{LowPriorityBatchMaxWait: "1h"}, priority: "normal", expectSent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+				if tt.priority != "" {
+					w.Header().Set("X-Notify-Priority", tt.priority)
+				}
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			config.PriorityHeader = "X-Notify-Priority"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var mu sync.Mutex
+			sent := 0
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				sent++
+				return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Header().Get("X-Notify-Priority") != "" {
+				t.Errorf("priority header not removed")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if (sent == 1) != tt.expectSent {
+				t.Errorf("expected sent %v, got %d deliveries", tt.expectSent, sent)
+			}
+			if depth := handler.(*notify).queueDepth(); depth != tt.expectQueued {
+				t.Errorf("expected %d queued, got %d", tt.expectQueued, depth)
+			}
+		})
+	}
+}
+
+func TestNewPrioritiesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "sample rate without header", config: Config{LowPrioritySampleRate: 0.5}, expectErr: "lowprioritysamplerate requires priorityheader"},
+		{name: "batch wait without header", config: Config{LowPriorityBatchMaxWait: "1m"}, expectErr: "lowprioritybatchmaxwait requires priorityheader"},
+		{name: "invalid batch wait", config: Config{PriorityHeader: "X-Notify-Priority", LowPriorityBatchMaxWait: "soon"}, expectErr: `invalid lowprioritybatchmaxwait: "soon"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newPriorities(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
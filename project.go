@@ -0,0 +1,94 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// fieldProjection keeps only the IncludeFields of the decoded payload.
+type fieldProjection struct {
+	leaf     bool
+	children map[string]*fieldProjection
+}
+
+// newFieldProjection returns nil when IncludeFields is empty.
+func newFieldProjection(fields []string) (*fieldProjection, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	root := &fieldProjection{children: map[string]*fieldProjection{}}
+	for _, field := range fields {
+		path, err := parseFieldPath("includefields", field)
+		if err != nil {
+			return nil, err
+		}
+		node := root
+		for _, p := range path {
+			child, ok := node.children[p]
+			if !ok {
+				child = &fieldProjection{children: map[string]*fieldProjection{}}
+				node.children[p] = child
+			}
+			node = child
+		}
+		node.leaf = true
+	}
+	return root, nil
+}
+
+// apply returns the projection of data. Payloads that are not JSON
+// objects or arrays are returned unchanged.
+func (p *fieldProjection) apply(data []byte) []byte {
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return data
+	}
+	switch doc.(type) {
+	case map[string]any, []any:
+	default:
+		return data
+	}
+	out, ok := p.project(doc)
+	if !ok {
+		if _, isArray := doc.([]any); isArray {
+			return []byte("[]")
+		}
+		return []byte("{}")
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		return data
+	}
+	return b
+}
+
+// project keeps the selected parts of v. Array elements are projected
+// one by one; elements with nothing selected are dropped.
+func (p *fieldProjection) project(v any) (any, bool) {
+	if p.leaf {
+		return v, true
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		out := map[string]any{}
+		for key, child := range p.children {
+			if val, ok := v[key]; ok {
+				if projected, ok := child.project(val); ok {
+					out[key] = projected
+				}
+			}
+		}
+		return out, len(out) > 0
+	case []any:
+		var out []any
+		for _, e := range v {
+			if projected, ok := p.project(e); ok {
+				out = append(out, projected)
+			}
+		}
+		return out, len(out) > 0
+	}
+	return nil, false
+}
@@ -0,0 +1,39 @@
+package header2post
+
+import "testing"
+
+func TestFieldProjection(t *testing.T) {
+	data := `{"id":7,"user":{"email":"a@b.c","name":"ann"},"lines":[{"sku":"a","price":1.50},{"price":2}],"meta":{"trace":"x"}}`
+	tests := []struct {
+		name      string
+		fields    []string
+		data      string
+		expect    string
+		expectErr string
+	}{
+		{name: "dotted", fields: []string{"id", "user.name", "lines.sku"}, data: data, expect: `{"id":7,"lines":[{"sku":"a"}],"user":{"name":"ann"}}`},
+		{name: "pointer", fields: []string{"/meta", "/lines/price"}, data: data, expect: `{"lines":[{"price":1.50},{"price":2}],"meta":{"trace":"x"}}`},
+		{name: "nothing matched", fields: []string{"missing"}, data: data, expect: `{}`},
+		{name: "array", fields: []string{"id"}, data: `[{"id":1,"x":2},{"x":3}]`, expect: `[{"id":1}]`},
+		{name: "not json", fields: []string{"id"}, data: "raw", expect: "raw"},
+		{name: "scalar", fields: []string{"id"}, data: "12", expect: "12"},
+		{name: "empty segment", fields: []string{"user."}, expectErr: `invalid includefields path: "user."`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newFieldProjection(tt.fields)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(p.apply([]byte(tt.data))); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package header2post
+
+import (
+	"encoding/binary"
+	"encoding/json"
+)
+
+const formatProtobuf = "protobuf"
+
+// encodeProtobuf wraps the payload in the NotifyEnvelope message from
+// proto/notify.proto.
+func (f *payloadFormat) encodeProtobuf(data []byte) *encodedPayload {
+	contentType := "application/json"
+	if !json.Valid(data) {
+		contentType = "application/octet-stream"
+	}
+	var b []byte
+	b = protoBytes(b, 1, []byte(generateID()))
+	b = protoBytes(b, 2, []byte(f.source))
+	b = protoVarint(b, 3, uint64(timeNow().UnixNano()))
+	b = protoBytes(b, 4, []byte(contentType))
+	b = protoBytes(b, 5, data)
+	return &encodedPayload{body: b, contentType: "application/x-protobuf"}
+}
+
+// protoVarint appends a varint field.
+func protoVarint(b []byte, field int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(field<<3))
+	return binary.AppendUvarint(b, v)
+}
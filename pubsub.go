@@ -0,0 +1,245 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pubsubScope       = "https://www.googleapis.com/auth/pubsub"
+	gceMetadataToken  = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+	defaultPubsubHost = "https://pubsub.googleapis.com"
+)
+
+// pubsubSink publishes payloads to a Google Cloud Pub/Sub topic through the
+// REST API. Access tokens come from a service account key file (or
+// GOOGLE_APPLICATION_CREDENTIALS) and otherwise from the GCE/GKE metadata
+// server. With PUBSUB_EMULATOR_HOST set no token is requested.
+type pubsubSink struct {
+	topic       string
+	publishURL  string
+	orderingKey *keyTemplate
+	client      *http.Client
+	tokens      *gcpTokenSource
+}
+
+func newPubsubSink(config *Config) (*pubsubSink, error) {
+	if config.PubsubProject == "" {
+		return nil, fmt.Errorf("pubsubproject cannot be empty")
+	}
+	if config.PubsubTopic == "" {
+		return nil, fmt.Errorf("pubsubtopic cannot be empty")
+	}
+	orderingKey, err := newKeyTemplate("pubsuborderingkey", config.PubsubOrderingKey)
+	if err != nil {
+		return nil, err
+	}
+	s := &pubsubSink{
+		topic:       "projects/" + config.PubsubProject + "/topics/" + config.PubsubTopic,
+		orderingKey: orderingKey,
+		client:      &http.Client{},
+	}
+	host := defaultPubsubHost
+	if emulator := os.Getenv("PUBSUB_EMULATOR_HOST"); emulator != "" {
+		host = "http://" + emulator
+	} else {
+		credentialsFile := config.PubsubCredentialsFile
+		if credentialsFile == "" {
+			credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		s.tokens, err = newGcpTokenSource(credentialsFile, s.client)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if config.PubsubEndpoint != "" {
+		host = strings.TrimSuffix(config.PubsubEndpoint, "/")
+	}
+	s.publishURL = host + "/v1/" + s.topic + ":publish"
+	return s, nil
+}
+
+func (s *pubsubSink) Target() string {
+	return "pubsub://" + s.topic
+}
+
+func (s *pubsubSink) Send(ctx context.Context, n Notification) error {
+	orderingKey, err := s.orderingKey.render(n.Payload)
+	if err != nil {
+		return fmt.Errorf("render ordering key: %w", err)
+	}
+	attributes := map[string]string{}
+	if n.ContentType != "" {
+		attributes["content-type"] = n.ContentType
+	}
+	for k := range n.Header {
+		attributes[strings.ToLower(k)] = n.Header.Get(k)
+	}
+	type message struct {
+		Data        []byte            `json:"data"`
+		Attributes  map[string]string `json:"attributes,omitempty"`
+		OrderingKey string            `json:"orderingKey,omitempty"` //nolint:tagliatelle // Pub/Sub API field
+	}
+	body, err := json.Marshal(map[string][]message{
+		"messages": {{Data: n.Body, Attributes: attributes, OrderingKey: orderingKey}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.publishURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.tokens != nil {
+		token, err := s.tokens.token(ctx)
+		if err != nil {
+			return fmt.Errorf("access token: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var apiErr struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error.Message != "" {
+		return fmt.Errorf("http status %d: %s", resp.StatusCode, apiErr.Error.Message)
+	}
+	return fmt.Errorf("http status %d", resp.StatusCode)
+}
+
+// gcpTokenSource obtains and caches OAuth2 access tokens for Google APIs.
+type gcpTokenSource struct {
+	mu      sync.Mutex
+	client  *http.Client
+	key     *gcpServiceAccountKey
+	rsaKey  *rsa.PrivateKey
+	cached  string
+	expires time.Time
+}
+
+type gcpServiceAccountKey struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// metadataTokenURL is the token endpoint used without a key file; it is a
+// variable so tests can point it at a fake metadata server.
+var metadataTokenURL = gceMetadataToken
+
+func newGcpTokenSource(credentialsFile string, client *http.Client) (*gcpTokenSource, error) {
+	ts := &gcpTokenSource{client: client}
+	if credentialsFile == "" {
+		return ts, nil
+	}
+	raw, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("read credentials file: %w", err)
+	}
+	var key gcpServiceAccountKey
+	if err := json.Unmarshal(raw, &key); err != nil {
+		return nil, fmt.Errorf("parse credentials file: %w", err)
+	}
+	block, _ := pem.Decode([]byte(key.PrivateKey))
+	if block == nil {
+		return nil, errors.New("credentials file has no private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+	rsaKey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("private key is not RSA")
+	}
+	if key.TokenURI == "" {
+		key.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	ts.key = &key
+	ts.rsaKey = rsaKey
+	return ts, nil
+}
+
+func (ts *gcpTokenSource) token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.cached != "" && timeNow().Add(time.Minute).Before(ts.expires) {
+		return ts.cached, nil
+	}
+	var req *http.Request
+	var err error
+	if ts.key != nil {
+		assertion, err := ts.assertion()
+		if err != nil {
+			return "", err
+		}
+		form := url.Values{}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, ts.key.TokenURI, strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+	}
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: http status %d", resp.StatusCode)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tok); err != nil {
+		return "", fmt.Errorf("token endpoint: %w", err)
+	}
+	ts.cached = tok.AccessToken
+	ts.expires = timeNow().Add(time.Duration(tok.ExpiresIn) * time.Second)
+	return ts.cached, nil
+}
+
+// assertion builds the RS256-signed JWT exchanged for an access token.
+func (ts *gcpTokenSource) assertion() (string, error) {
+	now := timeNow().Unix()
+	return signJWT(jwtRS256, "", nil, ts.rsaKey, map[string]any{
+		"iss":   ts.key.ClientEmail,
+		"scope": pubsubScope,
+		"aud":   ts.key.TokenURI,
+		"iat":   now,
+		"exp":   now + 3600,
+	})
+}
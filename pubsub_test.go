@@ -0,0 +1,117 @@
+package header2post
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPubsubSink(t *testing.T) {
+	var published struct {
+		Messages []struct {
+			Data        []byte            `json:"data"`
+			Attributes  map[string]string `json:"attributes"`
+			OrderingKey string            `json:"orderingKey"` //nolint:tagliatelle
+		} `json:"messages"`
+	}
+	tokenCalls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			tokenCalls++
+			r.ParseForm()
+			if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.Form.Get("assertion"), ".") != 2 {
+				t.Errorf("unexpected token request %v", r.Form)
+			}
+			fmt.Fprint(w, `{"access_token":"tok","expires_in":3600}`)
+		case "/v1/projects/p/topics/events:publish":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+			}
+			body, _ := io.ReadAll(r.Body)
+			json.Unmarshal(body, &published)
+			fmt.Fprint(w, `{"messageIds":["1"]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	creds, _ := json.Marshal(map[string]string{
+		"client_email": "svc@p.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    srv.URL + "/token",
+	})
+	credsFile := filepath.Join(t.TempDir(), "creds.json")
+	os.WriteFile(credsFile, creds, 0o600)
+
+	s, err := newPubsubSink(&Config{
+		PubsubProject:         "p",
+		PubsubTopic:           "events",
+		PubsubOrderingKey:     "{{.Payload.order}}",
+		PubsubCredentialsFile: credsFile,
+		PubsubEndpoint:        srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"order":"o-1"}`)
+	for i := 0; i < 2; i++ {
+		if err := s.Send(context.Background(), Notification{Body: data, ContentType: "application/json", Payload: data}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if tokenCalls != 1 {
+		t.Errorf("expected cached token, token endpoint called %d times", tokenCalls)
+	}
+	if len(published.Messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(published.Messages))
+	}
+	m := published.Messages[0]
+	if string(m.Data) != string(data) || m.OrderingKey != "o-1" || m.Attributes["content-type"] != "application/json" {
+		t.Errorf("unexpected message %+v", m)
+	}
+}
+
+func TestPubsubSinkMetadataToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				t.Errorf("missing Metadata-Flavor header")
+			}
+			fmt.Fprint(w, `{"access_token":"meta","expires_in":3600}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer meta" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"error":{"message":"permission denied"}}`)
+	}))
+	defer srv.Close()
+	metadataTokenURL = srv.URL + "/token"
+	defer func() { metadataTokenURL = gceMetadataToken }()
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+
+	s, err := newPubsubSink(&Config{PubsubProject: "p", PubsubTopic: "events", PubsubEndpoint: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Send(context.Background(), Notification{Body: []byte("{}")})
+	if err == nil || err.Error() != "http status 403: permission denied" {
+		t.Errorf("expected permission error, got %v", err)
+	}
+}
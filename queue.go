@@ -0,0 +1,77 @@
+package header2post
+
+import "sync"
+
+// keyedQueue runs jobs serially per key while jobs for different keys run
+// concurrently. A worker goroutine exists only while its key has pending
+// jobs.
+type keyedQueue struct {
+	mu      sync.Mutex
+	pending map[string][]func()
+	// first counts the urgent jobs at the head of each pending list.
+	first map[string]int
+	wg    sync.WaitGroup
+}
+
+func newKeyedQueue() *keyedQueue {
+	return &keyedQueue{pending: make(map[string][]func()), first: make(map[string]int)}
+}
+
+// enqueue schedules job after every job previously enqueued for key or,
+// when urgent, after the urgent ones only.
+func (q *keyedQueue) enqueue(key string, urgent bool, job func()) {
+	q.wg.Add(1)
+	q.mu.Lock()
+	jobs, running := q.pending[key]
+	if urgent {
+		i := q.first[key]
+		jobs = append(jobs[:i], append([]func(){job}, jobs[i:]...)...)
+		q.first[key] = i + 1
+	} else {
+		jobs = append(jobs, job)
+	}
+	q.pending[key] = jobs
+	q.mu.Unlock()
+	if !running {
+		go q.run(key)
+	}
+}
+
+func (q *keyedQueue) run(key string) {
+	for {
+		q.mu.Lock()
+		jobs := q.pending[key]
+		if len(jobs) == 0 {
+			delete(q.pending, key)
+			q.mu.Unlock()
+			return
+		}
+		job := jobs[0]
+		q.pending[key] = jobs[1:]
+		if n := q.first[key]; n > 1 {
+			q.first[key] = n - 1
+		} else {
+			delete(q.first, key)
+		}
+		q.mu.Unlock()
+
+		job()
+		q.wg.Done()
+	}
+}
+
+// depth returns the number of jobs waiting to run.
+func (q *keyedQueue) depth() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	n := 0
+	for _, jobs := range q.pending {
+		n += len(jobs)
+	}
+	return n
+}
+
+// wait blocks until every enqueued job has run.
+func (q *keyedQueue) wait() {
+	q.wg.Wait()
+}
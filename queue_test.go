@@ -0,0 +1,77 @@
+package header2post
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyedQueue(t *testing.T) {
+	q := newKeyedQueue()
+	var mu sync.Mutex
+	got := make(map[string][]int)
+	for i := 0; i < 50; i++ {
+		key := fmt.Sprintf("k%d", i%3)
+		i := i
+		q.enqueue(key, false, func() {
+			if i%7 == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			mu.Lock()
+			got[key] = append(got[key], i)
+			mu.Unlock()
+		})
+	}
+	q.wait()
+
+	for key, seq := range got {
+		for j := 1; j < len(seq); j++ {
+			if seq[j] < seq[j-1] {
+				t.Errorf("key %s delivered out of order: %v", key, seq)
+				break
+			}
+		}
+	}
+	if len(got) != 3 {
+		t.Errorf("expected 3 keys, got %d", len(got))
+	}
+}
+
+func TestKeyedQueueConcurrentKeys(t *testing.T) {
+	q := newKeyedQueue()
+	block := make(chan struct{})
+	done := make(chan struct{})
+	q.enqueue("slow", false, func() { <-block })
+	q.enqueue("fast", false, func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("job for a different key was blocked")
+	}
+	close(block)
+	q.wait()
+}
+
+func TestKeyedQueueUrgent(t *testing.T) {
+	q := newKeyedQueue()
+	block := make(chan struct{})
+	var got []string
+	q.enqueue("k", false, func() { <-block })
+	for _, job := range []struct {
+		name   string
+		urgent bool
+	}{{"normal 1", false}, {"urgent 1", true}, {"normal 2", false}, {"urgent 2", true}} {
+		q.enqueue("k", job.urgent, func() { got = append(got, job.name) })
+	}
+	close(block)
+	q.wait()
+
+	expect := []string{"urgent 1", "urgent 2", "normal 1", "normal 2"}
+	if fmt.Sprint(got) != fmt.Sprint(expect) {
+		t.Errorf("expected %v, got %v", expect, got)
+	}
+	if len(q.first) != 0 {
+		t.Errorf("urgent counts left behind: %v", q.first)
+	}
+}
@@ -0,0 +1,111 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+const (
+	redactRemove = "remove"
+	redactMask   = "mask"
+)
+
+// fieldRedactor removes or masks fields of the decoded payload.
+type fieldRedactor struct {
+	paths [][]string
+	mask  bool
+}
+
+// newFieldRedactor parses RedactFields entries: JSON pointers such as
+// /user/email or dotted paths such as user.email. It returns nil when no
+// field is configured.
+func newFieldRedactor(config *Config) (*fieldRedactor, error) {
+	r := &fieldRedactor{}
+	switch config.RedactMode {
+	case "", redactRemove:
+	case redactMask:
+		r.mask = true
+	default:
+		return nil, fmt.Errorf("invalid redactmode: %q", config.RedactMode)
+	}
+	for _, field := range config.RedactFields {
+		path, err := parseFieldPath("redactfields", field)
+		if err != nil {
+			return nil, err
+		}
+		r.paths = append(r.paths, path)
+	}
+	if len(r.paths) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// apply returns data with the configured fields redacted. Payloads that
+// are not JSON are returned unchanged.
+func (r *fieldRedactor) apply(data []byte) []byte {
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if dec.Decode(&doc) != nil {
+		return data
+	}
+	changed := false
+	for _, path := range r.paths {
+		if r.redact(doc, path) {
+			changed = true
+		}
+	}
+	if !changed {
+		return data
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return data
+	}
+	return out
+}
+
+// redact walks path inside v. A path segment that is not an index applies
+// to every element of an array.
+func (r *fieldRedactor) redact(v any, path []string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) > 1 {
+			return r.redact(child, path[1:])
+		}
+		if r.mask {
+			v[path[0]] = redacted
+		} else {
+			delete(v, path[0])
+		}
+		return true
+	case []any:
+		i, err := strconv.Atoi(path[0])
+		if err != nil {
+			changed := false
+			for _, e := range v {
+				if r.redact(e, path) {
+					changed = true
+				}
+			}
+			return changed
+		}
+		if i < 0 || i >= len(v) {
+			return false
+		}
+		if len(path) > 1 {
+			return r.redact(v[i], path[1:])
+		}
+		// removing an element would shift the others, so it is masked
+		v[i] = redacted
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package header2post
+
+import "testing"
+
+func TestFieldRedactor(t *testing.T) {
+	data := `{"user":{"email":"a@b.c","name":"ann"},"contacts":[{"phone":"1","kind":"home"},{"phone":"2"}],"tags":["x","y"],"a/b":1,"total":12.50}`
+	tests := []struct {
+		name      string
+		config    Config
+		data      string
+		expect    string
+		expectErr string
+	}{
+		{
+			name:   "remove dotted",
+			config: Config{RedactFields: []string{"user.email", "contacts.phone"}},
+			data:   data,
+			expect: `{"a/b":1,"contacts":[{"kind":"home"},{}],"tags":["x","y"],"total":12.50,"user":{"name":"ann"}}`,
+		},
+		{
+			name:   "mask pointer",
+			config: Config{RedactFields: []string{"/user/email", "/contacts/1/phone", "/tags/0", "/a~1b"}, RedactMode: "mask"},
+			data:   data,
+			expect: `{"a/b":"[REDACTED]","contacts":[{"kind":"home","phone":"1"},{"phone":"[REDACTED]"}],"tags":["[REDACTED]","y"],"total":12.50,"user":{"email":"[REDACTED]","name":"ann"}}`,
+		},
+		{name: "missing", config: Config{RedactFields: []string{"user.phone"}}, data: data, expect: data},
+		{name: "not json", config: Config{RedactFields: []string{"user"}}, data: "raw", expect: "raw"},
+		{name: "empty segment", config: Config{RedactFields: []string{"user..email"}}, expectErr: `invalid redactfields path: "user..email"`},
+		{name: "mode", config: Config{RedactFields: []string{"user"}, RedactMode: "hash"}, expectErr: `invalid redactmode: "hash"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newFieldRedactor(&tt.config)
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(r.apply([]byte(tt.data))); got != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
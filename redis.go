@@ -0,0 +1,117 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// redisSink delivers payloads with PUBLISH to a channel or XADD to a
+// stream, speaking RESP directly.
+type redisSink struct {
+	addr     string
+	password string
+	channel  *keyTemplate
+	stream   *keyTemplate
+}
+
+func newRedisSink(config *Config) (*redisSink, error) {
+	if config.RedisAddr == "" {
+		return nil, fmt.Errorf("redisaddr cannot be empty")
+	}
+	if (config.RedisChannel == "") == (config.RedisStream == "") {
+		return nil, fmt.Errorf("exactly one of redischannel or redisstream must be set")
+	}
+	channel, err := newKeyTemplate("redischannel", config.RedisChannel)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := newKeyTemplate("redisstream", config.RedisStream)
+	if err != nil {
+		return nil, err
+	}
+	return &redisSink{addr: config.RedisAddr, password: config.RedisPassword, channel: channel, stream: stream}, nil
+}
+
+func (s *redisSink) Target() string {
+	return "redis://" + s.addr
+}
+
+func (s *redisSink) Send(ctx context.Context, n Notification) error {
+	var cmd []string
+	if s.channel != nil {
+		channel, err := s.channel.render(n.Payload)
+		if err != nil {
+			return fmt.Errorf("render channel: %w", err)
+		}
+		cmd = []string{"PUBLISH", channel, string(n.Body)}
+	} else {
+		stream, err := s.stream.render(n.Payload)
+		if err != nil {
+			return fmt.Errorf("render stream: %w", err)
+		}
+		cmd = []string{"XADD", stream, "*", "payload", string(n.Body)}
+		if n.ContentType != "" {
+			cmd = append(cmd, "content_type", n.ContentType)
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	if s.password != "" {
+		if _, err := redisCall(conn, r, "AUTH", s.password); err != nil {
+			return fmt.Errorf("auth: %w", err)
+		}
+	}
+	_, err = redisCall(conn, r, cmd...)
+	return err
+}
+
+// redisCall writes a command as a RESP array and reads a single reply
+// line, returning server errors as Go errors.
+func redisCall(conn net.Conn, r *bufio.Reader, args ...string) (string, error) {
+	var b strings.Builder
+	b.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		b.WriteString("$" + strconv.Itoa(len(a)) + "\r\n" + a + "\r\n")
+	}
+	if _, err := conn.Write([]byte(b.String())); err != nil {
+		return "", err
+	}
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty reply")
+	}
+	switch line[0] {
+	case '-':
+		return "", errors.New(line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return "", nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return line[1:], nil
+}
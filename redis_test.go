@@ -0,0 +1,126 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedisServer records each received command and answers with the
+// next entry of replies.
+func fakeRedisServer(t *testing.T, replies ...string) (string, chan []string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	commands := make(chan []string, len(replies))
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for _, reply := range replies {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, 0, n)
+			for i := 0; i < n; i++ {
+				hdr, _ := r.ReadString('\n')
+				size, _ := strconv.Atoi(strings.TrimSpace(hdr[1:]))
+				buf := make([]byte, size+2)
+				io.ReadFull(r, buf)
+				args = append(args, string(buf[:size]))
+			}
+			commands <- args
+			fmt.Fprint(conn, reply)
+		}
+	}()
+	return ln.Addr().String(), commands
+}
+
+func TestRedisSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		replies   []string
+		expect    []string
+		expectErr string
+	}{
+		{
+			name:    "publish",
+			config:  Config{RedisChannel: "orders.{{.Payload.type}}"},
+			replies: []string{":1\r\n"},
+			expect:  []string{"PUBLISH orders.created {\"type\":\"created\"}"},
+		},
+		{
+			name:    "xadd with auth",
+			config:  Config{RedisStream: "events", RedisPassword: "secret"},
+			replies: []string{"+OK\r\n", "$15\r\n1700000000000-0\r\n"},
+			expect:  []string{"AUTH secret", "XADD events * payload {\"type\":\"created\"} content_type application/json"},
+		},
+		{
+			name:      "server error",
+			config:    Config{RedisStream: "events"},
+			replies:   []string{"-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"},
+			expect:    []string{"XADD events * payload {\"type\":\"created\"} content_type application/json"},
+			expectErr: "WRONGTYPE Operation against a key holding the wrong kind of value",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, commands := fakeRedisServer(t, tt.replies...)
+			tt.config.RedisAddr = addr
+			s, err := newRedisSink(&tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			data := []byte(`{"type":"created"}`)
+			err = s.Send(ctx, Notification{Body: data, ContentType: "application/json", Payload: data})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.expect {
+				if got := strings.Join(<-commands, " "); got != want {
+					t.Errorf("expected command %q, got %q", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRedisSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "missing addr", config: Config{RedisChannel: "c"}, expectErr: "redisaddr cannot be empty"},
+		{name: "missing target", config: Config{RedisAddr: "localhost:6379"}, expectErr: "exactly one of redischannel or redisstream must be set"},
+		{name: "both targets", config: Config{RedisAddr: "localhost:6379", RedisChannel: "c", RedisStream: "s"}, expectErr: "exactly one of redischannel or redisstream must be set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRedisSink(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package header2post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// replyCheck validates the successful replies of a notify url against
+// ReplyContentType and ReplySchema.
+type replyCheck struct {
+	mediaType string
+	schema    map[string]any
+}
+
+// newReplyCheck returns nil unless ReplyContentType or ReplySchema is set.
+func newReplyCheck(config *Config) (*replyCheck, error) {
+	if config.ReplyContentType == "" && config.ReplySchema == "" {
+		return nil, nil
+	}
+	if len(config.ChainUrls) > 0 {
+		return nil, fmt.Errorf("replycontenttype and replyschema cannot be combined with chainurls")
+	}
+	c := &replyCheck{}
+	if config.ReplyContentType != "" {
+		mediaType, _, err := mime.ParseMediaType(config.ReplyContentType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid replycontenttype: %q", config.ReplyContentType)
+		}
+		c.mediaType = mediaType
+	}
+	if config.ReplySchema != "" {
+		if err := json.Unmarshal([]byte(config.ReplySchema), &c.schema); err != nil || c.schema == nil {
+			return nil, fmt.Errorf("invalid replyschema: must be a JSON object")
+		}
+		if err := checkSchema(c.schema, "replyschema"); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// check validates resp. body is the reply body when already read, nil
+// otherwise.
+func (c *replyCheck) check(resp *http.Response, body []byte) error {
+	if c.mediaType != "" {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		if mediaType != c.mediaType {
+			return fmt.Errorf("invalid reply: content type %q, expected %q", resp.Header.Get("Content-Type"), c.mediaType)
+		}
+	}
+	if c.schema == nil {
+		return nil
+	}
+	if body == nil {
+		var err error
+		if body, err = io.ReadAll(io.LimitReader(resp.Body, maxReplyBytes)); err != nil {
+			return fmt.Errorf("read resp body error: %w", err)
+		}
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return errors.New("invalid reply: body is not valid json")
+	}
+	if err := matchSchema(v, c.schema, "reply"); err != nil {
+		return fmt.Errorf("invalid reply: %w", err)
+	}
+	return nil
+}
+
+var schemaTypes = map[string]bool{"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true}
+
+// checkSchema checks the keywords of a reply schema: type, required,
+// properties, items and enum. Other keywords are ignored.
+func checkSchema(schema map[string]any, path string) error {
+	invalid := fmt.Errorf("invalid %s", path)
+	switch t := schema["type"].(type) {
+	case nil:
+	case string:
+		if !schemaTypes[t] {
+			return fmt.Errorf("%w: unsupported type %q", invalid, t)
+		}
+	case []any:
+		for _, t := range t {
+			if s, ok := t.(string); !ok || !schemaTypes[s] {
+				return fmt.Errorf("%w: unsupported type %v", invalid, t)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: type must be a string or a list", invalid)
+	}
+	if required, ok := schema["required"]; ok {
+		names, ok := required.([]any)
+		if !ok {
+			return fmt.Errorf("%w: required must be a list", invalid)
+		}
+		for _, name := range names {
+			if _, ok := name.(string); !ok {
+				return fmt.Errorf("%w: required must list names", invalid)
+			}
+		}
+	}
+	if enum, ok := schema["enum"]; ok {
+		if _, ok := enum.([]any); !ok {
+			return fmt.Errorf("%w: enum must be a list", invalid)
+		}
+	}
+	if properties, ok := schema["properties"]; ok {
+		m, ok := properties.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: properties must be an object", invalid)
+		}
+		for name, property := range m {
+			sub, ok := property.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: properties.%s must be an object", invalid, name)
+			}
+			if err := checkSchema(sub, path+".properties."+name); err != nil {
+				return err
+			}
+		}
+	}
+	if items, ok := schema["items"]; ok {
+		sub, ok := items.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: items must be an object", invalid)
+		}
+		return checkSchema(sub, path+".items")
+	}
+	return nil
+}
+
+// matchSchema reports how v, decoded JSON at path, does not match schema,
+// a schema accepted by checkSchema.
+func matchSchema(v any, schema map[string]any, path string) error {
+	switch t := schema["type"].(type) {
+	case string:
+		if !hasSchemaType(v, t) {
+			return fmt.Errorf("%s is not of type %s", path, t)
+		}
+	case []any:
+		if !slices.ContainsFunc(t, func(t any) bool { return hasSchemaType(v, t.(string)) }) {
+			return fmt.Errorf("%s is not of type %v", path, t)
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok {
+		if !slices.ContainsFunc(enum, func(e any) bool { return reflect.DeepEqual(e, v) }) {
+			return fmt.Errorf("%s is not one of %v", path, enum)
+		}
+	}
+	if obj, ok := v.(map[string]any); ok {
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := obj[name.(string)]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, property := range properties {
+			if value, ok := obj[name]; ok {
+				if err := matchSchema(value, property.(map[string]any), path+"."+name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if arr, ok := v.([]any); ok {
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range arr {
+				if err := matchSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func hasSchemaType(v any, t string) bool {
+	switch v := v.(type) {
+	case map[string]any:
+		return t == "object"
+	case []any:
+		return t == "array"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && v == math.Trunc(v))
+	case bool:
+		return t == "boolean"
+	case nil:
+		return t == "null"
+	}
+	return false
+}
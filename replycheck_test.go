@@ -0,0 +1,72 @@
+package header2post
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReplyCheck(t *testing.T) {
+	schema := `{"type":"object","required":["id"],"properties":{"id":{"type":"string"},"status":{"enum":["queued","sent"]},"tags":{"type":"array","items":{"type":"string"}}}}`
+	tests := []struct {
+		name        string
+		config      Config
+		contentType string
+		body        string
+		expectErr   string
+	}{
+		{name: "content type", config: Config{ReplyContentType: "application/json"}, contentType: "application/json; charset=utf-8"},
+		{name: "wrong content type", config: Config{ReplyContentType: "application/json"}, contentType: "text/html", expectErr: `invalid reply: content type "text/html", expected "application/json"`},
+		{name: "schema", config: Config{ReplySchema: schema}, body: `{"id":"a","status":"queued","tags":["x"]}`},
+		{name: "not json", config: Config{ReplySchema: schema}, body: `<html>`, expectErr: "invalid reply: body is not valid json"},
+		{name: "missing", config: Config{ReplySchema: schema}, body: `{}`, expectErr: "invalid reply: reply.id is required"},
+		{name: "type", config: Config{ReplySchema: schema}, body: `{"id":1}`, expectErr: "invalid reply: reply.id is not of type string"},
+		{name: "enum", config: Config{ReplySchema: schema}, body: `{"id":"a","status":"lost"}`, expectErr: "invalid reply: reply.status is not one of [queued sent]"},
+		{name: "items", config: Config{ReplySchema: schema}, body: `{"id":"a","tags":[true]}`, expectErr: "invalid reply: reply.tags[0] is not of type string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newHTTPSender(&tt.config, "https://example.com/notification", doerFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusAccepted,
+					Header:     http.Header{"Content-Type": {tt.contentType}},
+					Body:       io.NopCloser(strings.NewReader(tt.body)),
+				}, nil
+			}), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = s.Send(context.Background(), Notification{Body: []byte(`{}`)})
+			if tt.expectErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.expectErr != "" && (err == nil || err.Error() != tt.expectErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNewReplyCheckErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "content type", config: Config{ReplyContentType: "application/"}, expectErr: `invalid replycontenttype: "application/"`},
+		{name: "not an object", config: Config{ReplySchema: `[]`}, expectErr: "invalid replyschema: must be a JSON object"},
+		{name: "type", config: Config{ReplySchema: `{"properties":{"id":{"type":"text"}}}`}, expectErr: `invalid replyschema.properties.id: unsupported type "text"`},
+		{name: "required", config: Config{ReplySchema: `{"required":"id"}`}, expectErr: "invalid replyschema: required must be a list"},
+		{name: "chain", config: Config{ReplySchema: `{}`, ChainUrls: []string{"https://example.com/next"}}, expectErr: "replycontenttype and replyschema cannot be combined with chainurls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newReplyCheck(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
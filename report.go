@@ -0,0 +1,87 @@
+package header2post
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// deliveryResult is the outcome of a single delivery attempt to one target.
+type deliveryResult struct {
+	Target     string `json:"target"`
+	Success    bool   `json:"success"`
+	Status     int    `json:"status,omitempty"`
+	Retries    int    `json:"retries,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	// Cached is set when the delivery was skipped because the target
+	// accepted the same payload within DeliveryCacheTTL.
+	Cached bool `json:"cached,omitempty"`
+	// Ack is the event id the receiver echoed, with SendEventId.
+	Ack string `json:"ack,omitempty"`
+}
+
+// deliveryReport aggregates every delivery triggered by one request so it
+// can be logged as a single structured record.
+type deliveryReport struct {
+	mu             sync.Mutex
+	Version        string
+	Path           string
+	EventIDs       []string
+	NotificationID string
+	CorrelationIDs []string
+	Headers        map[string]string
+	Delivered      int
+	Failed         int
+	Results        []deliveryResult
+}
+
+func newDeliveryReport(eventIDs []string) *deliveryReport {
+	return &deliveryReport{Version: Version, EventIDs: eventIDs}
+}
+
+func (r *deliveryReport) add(results ...deliveryResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, res := range results {
+		if res.Success {
+			r.Delivered++
+		} else {
+			r.Failed++
+		}
+		r.Results = append(r.Results, res)
+	}
+}
+
+// log writes the report as one record, at error level when a delivery
+// failed.
+func (r *deliveryReport) log(l *slog.Logger) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	level := slog.LevelInfo
+	if r.Failed > 0 {
+		level = slog.LevelError
+	}
+	attrs := []slog.Attr{slog.String("version", r.Version)}
+	if r.Path != "" {
+		attrs = append(attrs, slog.String("path", r.Path))
+	}
+	if len(r.CorrelationIDs) > 0 {
+		attrs = append(attrs, slog.Any("correlation_ids", r.CorrelationIDs))
+	}
+	if len(r.EventIDs) > 0 {
+		attrs = append(attrs, slog.Any("event_ids", r.EventIDs))
+	}
+	if r.NotificationID != "" {
+		attrs = append(attrs, slog.String("notification_id", r.NotificationID))
+	}
+	if len(r.Headers) > 0 {
+		attrs = append(attrs, slog.Any("headers", r.Headers))
+	}
+	attrs = append(attrs,
+		slog.Int("delivered", r.Delivered),
+		slog.Int("failed", r.Failed),
+		slog.Any("results", r.Results),
+	)
+	l.LogAttrs(context.Background(), level, "delivery report", attrs...)
+}
@@ -0,0 +1,52 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestDeliveryReport(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	r := newDeliveryReport([]string{"evt-1"})
+	r.Path = "/orders"
+	r.add(
+		deliveryResult{Target: "http://a", Success: true, Status: 202},
+		deliveryResult{Target: "http://b", Error: "post error: boom"},
+	)
+	r.log(newLogger("header2post", slog.LevelInfo, buf))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single log record, got %d", len(lines))
+	}
+	var got struct {
+		Time       string           `json:"time"`
+		Level      string           `json:"level"`
+		Msg        string           `json:"msg"`
+		Middleware string           `json:"middleware"`
+		Path       string           `json:"path"`
+		EventIDs   []string         `json:"event_ids"`
+		Delivered  int              `json:"delivered"`
+		Failed     int              `json:"failed"`
+		Results    []deliveryResult `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Time == "" || got.Level != "ERROR" || got.Msg != "delivery report" {
+		t.Errorf("unexpected record: %+v", got)
+	}
+	if got.Middleware != "header2post" || got.Path != "/orders" || len(got.EventIDs) != 1 || got.EventIDs[0] != "evt-1" {
+		t.Errorf("unexpected report header: %+v", got)
+	}
+	if got.Delivered != 1 || got.Failed != 1 || len(got.Results) != 2 {
+		t.Errorf("unexpected report counts: %+v", got)
+	}
+	if got.Results[1].Target != "http://b" || got.Results[1].Error != "post error: boom" {
+		t.Errorf("unexpected result: %+v", got.Results[1])
+	}
+}
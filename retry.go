@@ -0,0 +1,178 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryBackoff    = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+	defaultMaxRetryAfter   = time.Minute
+)
+
+// sleep waits d between delivery attempts, returning ctx.Err() early
+// when ctx is done; tests replace it.
+var sleep = sleepContext
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// retryPolicy decides whether and when a failed delivery is attempted
+// again.
+type retryPolicy struct {
+	max           int
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	maxRetryAfter time.Duration
+	retryOn       statusSet
+	noRetry       statusSet
+	budget        *retryBudget
+}
+
+// newRetryPolicy returns nil when MaxRetries is zero.
+func newRetryPolicy(config *Config) (*retryPolicy, error) {
+	if config.MaxRetries < 0 {
+		return nil, fmt.Errorf("maxretries cannot be negative")
+	}
+	budget, err := newRetryBudget(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxRetries == 0 {
+		return nil, nil
+	}
+	p := &retryPolicy{max: config.MaxRetries, budget: budget}
+	if p.backoff, err = parseDuration("retrybackoff", config.RetryBackoff, defaultRetryBackoff); err != nil {
+		return nil, err
+	}
+	if p.maxBackoff, err = parseDuration("retrymaxbackoff", config.RetryMaxBackoff, defaultRetryMaxBackoff); err != nil {
+		return nil, err
+	}
+	if p.maxRetryAfter, err = parseDuration("maxretryafter", config.MaxRetryAfter, defaultMaxRetryAfter); err != nil {
+		return nil, err
+	}
+	if p.retryOn, err = parseStatusSet("retryonstatuscodes", config.RetryOnStatusCodes); err != nil {
+		return nil, err
+	}
+	if p.noRetry, err = parseStatusSet("noretrystatuscodes", config.NoRetryStatusCodes); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// retryable reports whether a delivery that failed with err may be
+// attempted again. Failures without a receiver status, such as connection
+// errors, are retryable, unless the health probe reports the endpoint
+// down. Unless the status lists say otherwise, client errors are
+// permanent rejections, except 408 and 429.
+func (p *retryPolicy) retryable(err error) bool {
+	if errors.Is(err, errEndpointUnhealthy) {
+		return false
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	if p.noRetry.has(statusErr.StatusCode) {
+		return false
+	}
+	if len(p.retryOn) > 0 {
+		return p.retryOn.has(statusErr.StatusCode)
+	}
+	switch code := statusErr.StatusCode; {
+	case code == http.StatusRequestTimeout, code == http.StatusTooManyRequests:
+		return true
+	case code >= 400 && code < 500:
+		return false
+	}
+	return true
+}
+
+// delay is the exponential backoff before retry number attempt, counting
+// from zero.
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 0; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	return d
+}
+
+// wait is the time to wait before retry number attempt after err: the
+// receiver's Retry-After, bounded by maxRetryAfter, or the backoff.
+func (p *retryPolicy) wait(attempt int, err error) time.Duration {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+		if statusErr.RetryAfter > p.maxRetryAfter {
+			return p.maxRetryAfter
+		}
+		return statusErr.RetryAfter
+	}
+	return p.delay(attempt)
+}
+
+// parseRetryAfter reads a Retry-After value in seconds or as an HTTP
+// date relative to now. It returns zero when value is absent or invalid.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// statusSet holds inclusive status code ranges.
+type statusSet [][2]int
+
+// parseStatusSet reads entries such as "429" or "502-504".
+func parseStatusSet(option string, entries []string) (statusSet, error) {
+	var set statusSet
+	for _, e := range entries {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(e), "-")
+		from, err := strconv.Atoi(strings.TrimSpace(lo))
+		to := from
+		if err == nil && isRange {
+			to, err = strconv.Atoi(strings.TrimSpace(hi))
+		}
+		if err != nil || from < 100 || to > 599 || from > to {
+			return nil, fmt.Errorf("invalid %s: %q", option, e)
+		}
+		set = append(set, [2]int{from, to})
+	}
+	return set, nil
+}
+
+func (s statusSet) has(code int) bool {
+	for _, r := range s {
+		if code >= r[0] && code <= r[1] {
+			return true
+		}
+	}
+	return false
+}
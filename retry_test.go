@@ -0,0 +1,311 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliverRetry(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	defer func() { sleep = sleepContext }()
+
+	tests := []struct {
+		name          string
+		config        Config
+		replies       []int
+		expectSuccess bool
+		expectStatus  int
+		expectRetries int
+		expectSlept   []time.Duration
+	}{
+		{name: "recovers", config: Config{MaxRetries: 3}, replies: []int{503, 0, 202}, expectSuccess: true, expectRetries: 2, expectSlept: []time.Duration{500 * time.Millisecond, time.Second}},
+		{name: "exhausted", config: Config{MaxRetries: 2, RetryBackoff: "1s", RetryMaxBackoff: "1500ms"}, replies: []int{500, 500, 500, 202}, expectStatus: 500, expectRetries: 2, expectSlept: []time.Duration{time.Second, 1500 * time.Millisecond}},
+		{name: "no retry status", config: Config{MaxRetries: 3, NoRetryStatusCodes: []string{"400", "422"}}, replies: []int{422, 202}, expectStatus: 422},
+		{name: "retry on listed", config: Config{MaxRetries: 3, RetryOnStatusCodes: []string{"429", "502-504"}}, replies: []int{429, 504, 202}, expectSuccess: true, expectRetries: 2, expectSlept: []time.Duration{500 * time.Millisecond, time.Second}},
+		{name: "retry on unlisted", config: Config{MaxRetries: 3, RetryOnStatusCodes: []string{"429", "502-504"}}, replies: []int{500, 202}, expectStatus: 500},
+		{name: "connection errors retried", config: Config{MaxRetries: 3, RetryOnStatusCodes: []string{"429"}}, replies: []int{0, 202}, expectSuccess: true, expectRetries: 1, expectSlept: []time.Duration{500 * time.Millisecond}},
+		{name: "client error", config: Config{MaxRetries: 3}, replies: []int{422, 202}, expectStatus: 422},
+		{name: "client error retryable", config: Config{MaxRetries: 3}, replies: []int{408, 429, 202}, expectSuccess: true, expectRetries: 2, expectSlept: []time.Duration{500 * time.Millisecond, time.Second}},
+		{name: "client error listed", config: Config{MaxRetries: 3, RetryOnStatusCodes: []string{"409"}}, replies: []int{409, 202}, expectSuccess: true, expectRetries: 1, expectSlept: []time.Duration{500 * time.Millisecond}},
+		{name: "disabled", replies: []int{503, 202}, expectStatus: 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slept = nil
+			p, err := newRetryPolicy(&tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			attempt := 0
+			s := SenderFunc(func(ctx context.Context, n Notification) error {
+				status := tt.replies[attempt]
+				attempt++
+				switch status {
+				case 0:
+					return errors.New("connection refused")
+				case 202:
+					return nil
+				}
+				return &StatusError{StatusCode: status, Body: strconv.Itoa(status)}
+			})
+			result := deliverRetry(context.Background(), s, Notification{}, p, time.Second, nil)
+			if result.Success != tt.expectSuccess || result.Status != tt.expectStatus || result.Retries != tt.expectRetries {
+				t.Errorf("unexpected result %+v", result)
+			}
+			if len(slept) != len(tt.expectSlept) {
+				t.Fatalf("expected backoff %v, got %v", tt.expectSlept, slept)
+			}
+			for i := range slept {
+				if slept[i] != tt.expectSlept[i] {
+					t.Errorf("expected backoff %v, got %v", tt.expectSlept, slept)
+				}
+			}
+		})
+	}
+}
+
+func TestNewRetryPolicyErrors(t *testing.T) {
+	tests := []struct {
+		config Config
+		expect string
+	}{
+		{config: Config{MaxRetries: -1}, expect: "maxretries cannot be negative"},
+		{config: Config{MaxRetries: 1, RetryBackoff: "soon"}, expect: `invalid retrybackoff: "soon"`},
+		{config: Config{MaxRetries: 1, RetryOnStatusCodes: []string{"504-502"}}, expect: `invalid retryonstatuscodes: "504-502"`},
+		{config: Config{MaxRetries: 1, NoRetryStatusCodes: []string{"4xx"}}, expect: `invalid noretrystatuscodes: "4xx"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expect, func(t *testing.T) {
+			if _, err := newRetryPolicy(&tt.config); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		expect time.Duration
+	}{
+		{value: "", expect: 0},
+		{value: "7", expect: 7 * time.Second},
+		{value: "-1", expect: 0},
+		{value: "Mon, 01 Jan 2024 00:00:30 GMT", expect: 30 * time.Second},
+		{value: "Sun, 31 Dec 2023 23:59:00 GMT", expect: 0},
+		{value: "later", expect: 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expect {
+			t.Errorf("%q: expected %v, got %v", tt.value, tt.expect, got)
+		}
+	}
+}
+
+func TestServeHTTPRetryAfter(t *testing.T) {
+	captureLog(t)
+	var slept []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	defer func() { sleep = sleepContext }()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:  "X-Notify",
+		NotifyUrl:     "https://example.com/notification",
+		MaxRetries:    3,
+		MaxRetryAfter: "10s",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replies := []*http.Response{
+		{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": []string{"3"}}},
+		{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": []string{"120"}}},
+		{StatusCode: http.StatusBadGateway, Header: http.Header{"Retry-After": []string{"3"}}},
+		{StatusCode: http.StatusAccepted},
+	}
+	attempt := 0
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		resp := replies[attempt]
+		attempt++
+		resp.Body = io.NopCloser(strings.NewReader(""))
+		return resp, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expect := []time.Duration{3 * time.Second, 10 * time.Second, 2 * time.Second}
+	if len(slept) != len(expect) || slept[0] != expect[0] || slept[1] != expect[1] || slept[2] != expect[2] {
+		t.Errorf("expected waits %v, got %v", expect, slept)
+	}
+}
+
+func TestServeHTTPRetryAfterSync(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader: "X-Notify",
+		NotifyUrl:    "https://example.com/notification",
+		MaxRetries:   2,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Header:     http.Header{"Retry-After": []string{"30"}},
+			Body:       io.NopCloser(strings.NewReader("")),
+		}, nil
+	})
+
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("response held for the retry, took %v", elapsed)
+	}
+	if attempts != 1 {
+		t.Errorf("expected one attempt, got %d", attempts)
+	}
+}
+
+func TestServeHTTPDeliveryContext(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	tests := []struct {
+		name           string
+		config         Config
+		expectCanceled bool
+	}{
+		{name: "sync", config: Config{NotifyTimeout: "2s"}, expectCanceled: true},
+		{name: "sync detached", config: Config{NotifyTimeout: "2s", DetachSyncNotify: true}},
+		{name: "queued detached", config: Config{NotifyTimeout: "2s", PartitionKeyField: "id"}},
+		{name: "queued tied", config: Config{NotifyTimeout: "2s", PartitionKeyField: "id", CancelWithRequest: true}, expectCanceled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", payload)
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			release := make(chan struct{})
+			var deadline time.Duration
+			var canceled bool
+			handler.(*notify).senders = []Sender{SenderFunc(func(ctx context.Context, n Notification) error {
+				<-release
+				d, _ := ctx.Deadline()
+				deadline = time.Until(d)
+				canceled = ctx.Err() == context.Canceled
+				return nil
+			})}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+			if config.PartitionKeyField == "" {
+				cancel()
+				close(release)
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+			} else {
+				handler.ServeHTTP(httptest.NewRecorder(), req)
+				cancel()
+				close(release)
+				handler.(*notify).partitions.wait()
+			}
+			if canceled != tt.expectCanceled {
+				t.Errorf("expected canceled %v, got %v", tt.expectCanceled, canceled)
+			}
+			if deadline <= time.Second || deadline > 2*time.Second {
+				t.Errorf("expected a 2s deadline, got %v", deadline)
+			}
+		})
+	}
+
+	_, err := New(context.Background(), http.NotFoundHandler(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", NotifyTimeout: "0s"}, "header2post")
+	if err == nil || err.Error() != `invalid notifytimeout: "0s"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDeliverRetryCanceledDuringBackoff(t *testing.T) {
+	attempts := 0
+	s := SenderFunc(func(ctx context.Context, n Notification) error {
+		attempts++
+		return &StatusError{StatusCode: http.StatusServiceUnavailable}
+	})
+	p := &retryPolicy{max: 3, backoff: time.Hour, maxBackoff: time.Hour, maxRetryAfter: time.Minute}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	result := deliverRetry(ctx, s, Notification{}, p, time.Second, nil)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("backoff not interrupted, took %v", elapsed)
+	}
+	if result.Success || attempts != 1 || result.Retries != 0 {
+		t.Errorf("expected one failed attempt, got %d attempts %+v", attempts, result)
+	}
+}
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := sleepContext(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("expected canceled, got %v", err)
+	}
+}
+
+func TestDeliverRetryAfterBeyondDeadline(t *testing.T) {
+	var slept []time.Duration
+	sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return ctx.Err()
+	}
+	defer func() { sleep = sleepContext }()
+
+	attempts := 0
+	s := SenderFunc(func(ctx context.Context, n Notification) error {
+		attempts++
+		return &StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}
+	})
+	p := &retryPolicy{max: 3, backoff: time.Millisecond, maxBackoff: time.Second, maxRetryAfter: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result := deliverRetry(ctx, s, Notification{}, p, time.Second, nil)
+	if result.Success || attempts != 1 || len(slept) != 0 {
+		t.Errorf("expected one attempt and no wait, got %d attempts, waits %v", attempts, slept)
+	}
+	if result.Status != http.StatusTooManyRequests {
+		t.Errorf("expected the 429 outcome, got %+v", result)
+	}
+}
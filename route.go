@@ -0,0 +1,79 @@
+package header2post
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// parseStatusClasses reads entries such as "404", "400-499" or the status
+// class "5xx".
+func parseStatusClasses(option string, entries []string) (statusSet, error) {
+	var set statusSet
+	for _, e := range entries {
+		c := strings.ToLower(strings.TrimSpace(e))
+		if len(c) != 3 || !strings.HasSuffix(c, "xx") {
+			ranges, err := parseStatusSet(option, []string{e})
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, ranges...)
+			continue
+		}
+		class := int(c[0] - '0')
+		if class < 1 || class > 5 {
+			return nil, fmt.Errorf("invalid %s: %q", option, e)
+		}
+		set = append(set, [2]int{class * 100, class*100 + 99})
+	}
+	return set, nil
+}
+
+// newStatusRoutes builds one sender per StatusRoutes entry, limited to the
+// statuses of its key. Keys may not overlap, so every status has at most
+// one route.
+func newStatusRoutes(config *Config, client HTTPDoer, hooks []requestHook) ([]Sender, error) {
+	var out []Sender
+	var claimed statusSet
+	for _, key := range sortedKeys(config.StatusRoutes) {
+		statuses, err := parseStatusClasses("statusroutes status", []string{key})
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range claimed {
+			if statuses[0][0] <= r[1] && statuses[0][1] >= r[0] {
+				return nil, fmt.Errorf("overlapping statusroutes status: %q", key)
+			}
+		}
+		claimed = append(claimed, statuses...)
+		raw := config.StatusRoutes[key]
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("invalid statusroutes url for %q: %q", key, raw)
+		}
+		sender, err := newHTTPSender(config, raw, client, hooks)
+		if err != nil {
+			return nil, err
+		}
+		sender.statuses = statuses
+		out = append(out, sender)
+	}
+	return out, nil
+}
+
+// routed returns the senders msg is delivered to: the status route
+// matching its response status, or else every sender without a route.
+func routed(senders []Sender, status int) []Sender {
+	var fallback []Sender
+	for _, s := range senders {
+		hs, ok := s.(*HTTPSender)
+		if !ok || hs.statuses == nil {
+			fallback = append(fallback, s)
+			continue
+		}
+		if hs.statuses.has(status) {
+			return []Sender{s}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,117 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewStatusRoutesErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "overlap", config: Config{StatusRoutes: map[string]string{"4xx": "https://a.example.com", "404": "https://b.example.com"}}, err: `overlapping statusroutes status: "4xx"`},
+		{name: "bad url", config: Config{StatusRoutes: map[string]string{"5xx": "ftp://a.example.com"}}, err: `invalid statusroutes url for "5xx": "ftp://a.example.com"`},
+		{name: "bad status", config: Config{StatusRoutes: map[string]string{"oops": "https://a.example.com"}}, err: `invalid statusroutes status: "oops"`},
+		{name: "request trigger", config: Config{TriggerSource: triggerRequest, StatusRoutes: map[string]string{"5xx": "https://a.example.com"}}, err: "statusroutes requires triggersource response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPStatusRoutes(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		status int
+		expect []string
+	}{
+		{status: http.StatusOK, expect: []string{"https://pipeline.example.com/events"}},
+		{status: http.StatusCreated, expect: []string{"https://pipeline.example.com/events"}},
+		{status: http.StatusBadGateway, expect: []string{"https://alerts.example.com/events"}},
+		{status: http.StatusNotFound, expect: []string{"https://example.com/fanout", "https://example.com/notification"}},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+				w.WriteHeader(tt.status)
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader: "X-Notify",
+				NotifyUrl:    "https://example.com/notification",
+				FanoutUrls:   []string{"https://example.com/fanout"},
+				StatusRoutes: map[string]string{
+					"2xx": "https://pipeline.example.com/events",
+					"5xx": "https://alerts.example.com/events",
+				},
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var mu sync.Mutex
+			var got []string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				mu.Lock()
+				got = append(got, req.URL.String())
+				mu.Unlock()
+				return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestParseStatusClasses(t *testing.T) {
+	tests := []struct {
+		key    string
+		expect statusSet
+		err    string
+	}{
+		{key: "2xx", expect: statusSet{{200, 299}}},
+		{key: "5XX", expect: statusSet{{500, 599}}},
+		{key: "404", expect: statusSet{{404, 404}}},
+		{key: "400-499", expect: statusSet{{400, 499}}},
+		{key: "6xx", err: `invalid statusroutes status: "6xx"`},
+		{key: "xxx", err: `invalid statusroutes status: "xxx"`},
+		{key: "99", err: `invalid statusroutes status: "99"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := parseStatusClasses("statusroutes status", []string{tt.key})
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("expected error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.expect) {
+				t.Errorf("expected %v, got %v", tt.expect, got)
+			}
+		})
+	}
+}
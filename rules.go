@@ -0,0 +1,169 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+// Rule is one notification flow of Config.Rules. Empty fields take the
+// top-level value.
+type Rule struct {
+	// Name identifies the rule in logs and metrics; it defaults to the
+	// rule index.
+	Name           string   `yaml:"name" json:"name" toml:"name"`
+	NotifyHeader   string   `yaml:"notifyheader" json:"notifyheader" toml:"notifyheader"`
+	NotifyUrl      string   `yaml:"notifyurl" json:"notifyurl" toml:"notifyurl"`
+	NotifyMethod   string   `yaml:"notifymethod" json:"notifymethod" toml:"notifymethod"`
+	Condition      string   `yaml:"condition" json:"condition" toml:"condition"`
+	ForwardHeaders []string `yaml:"forwardheaders" json:"forwardheaders" toml:"forwardheaders"`
+	// MaxConcurrentDeliveries and MaxQueuedDeliveries size the delivery
+	// slots and queue of the rule's targets.
+	MaxConcurrentDeliveries int `yaml:"maxconcurrentdeliveries" json:"maxconcurrentdeliveries" toml:"maxconcurrentdeliveries"`
+	MaxQueuedDeliveries     int `yaml:"maxqueueddeliveries" json:"maxqueueddeliveries" toml:"maxqueueddeliveries"`
+}
+
+// newRules builds one flow per rule and returns the first, which serves
+// them all: the response is wrapped once and every rule reads its notify
+// headers from the same captured header set. The rules share the spool
+// and the health probe of a notify url; LogOutput, ErrorLogOutput and
+// AuditFile files are shared by path.
+func newRules(ctx context.Context, next http.Handler, config *Config, name string, opts []Option) (http.Handler, error) {
+	seen := make(map[string]bool, len(config.Rules))
+	shared := &ruleResources{probes: make(map[string]*healthProbe)}
+	flows := make([]*notify, 0, len(config.Rules))
+	for i, rule := range config.Rules {
+		ruleName := rule.Name
+		if ruleName == "" {
+			ruleName = strconv.Itoa(i)
+		}
+		if seen[ruleName] {
+			return nil, fmt.Errorf("duplicate rule name: %q", ruleName)
+		}
+		seen[ruleName] = true
+
+		c := *config
+		c.Rules = nil
+		if rule.NotifyHeader != "" {
+			c.NotifyHeader = rule.NotifyHeader
+		}
+		if rule.NotifyUrl != "" {
+			c.NotifyUrl = rule.NotifyUrl
+		}
+		if rule.NotifyMethod != "" {
+			c.NotifyMethod = rule.NotifyMethod
+		}
+		if rule.Condition != "" {
+			c.Condition = rule.Condition
+		}
+		if len(rule.ForwardHeaders) > 0 {
+			c.ForwardHeaders = rule.ForwardHeaders
+		}
+		if rule.MaxConcurrentDeliveries != 0 {
+			c.MaxConcurrentDeliveries = rule.MaxConcurrentDeliveries
+		}
+		if rule.MaxQueuedDeliveries != 0 {
+			c.MaxQueuedDeliveries = rule.MaxQueuedDeliveries
+		}
+		n, err := newNotify(ctx, next, &c, name+"."+ruleName, opts, shared)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %w", ruleName, err)
+		}
+		flows = append(flows, n)
+	}
+	flows[0].rules = flows
+	return flows[0], nil
+}
+
+// ruleResources are the resources the flows of Rules share instead of
+// each opening its own. A nil *ruleResources shares nothing.
+type ruleResources struct {
+	probes map[string]*healthProbe
+	spool  *spool
+}
+
+// healthProbe returns the probe of sender's url, building it for the
+// first flow targeting that url. owned reports whether the caller built
+// it and so runs it.
+func (r *ruleResources) healthProbe(config *Config, sender *HTTPSender, log *slog.Logger) (probe *healthProbe, owned bool, err error) {
+	if r != nil && sender != nil {
+		if probe, ok := r.probes[sender.URL]; ok {
+			return probe, false, nil
+		}
+	}
+	if probe, err = newHealthProbe(config, sender, log); err != nil || probe == nil {
+		return nil, false, err
+	}
+	if r != nil {
+		r.probes[sender.URL] = probe
+	}
+	return probe, true, nil
+}
+
+// openSpool returns the spool of SpoolFile, opening it for the first
+// flow. The spool replays to a single notify url, so the flows sharing it
+// must target the same one. owned reports whether the caller opened it
+// and so configures its replay.
+func (r *ruleResources) openSpool(config *Config, sender *HTTPSender) (s *spool, owned bool, err error) {
+	if r != nil && r.spool != nil {
+		if sender == nil || sender.URL != r.spool.sender.URL || sender.Method != r.spool.sender.Method {
+			return nil, false, fmt.Errorf("spoolfile cannot be shared by rules with different notifyurls or notifymethods")
+		}
+		return r.spool, false, nil
+	}
+	if s, err = newSpool(config, sender); err != nil || s == nil {
+		return nil, false, err
+	}
+	if r != nil {
+		r.spool = s
+	}
+	return s, true, nil
+}
+
+// flows returns the notification flows a serves: one per rule, or a
+// itself without rules.
+func (a *notify) flows() []*notify {
+	if len(a.rules) > 0 {
+		return a.rules
+	}
+	return []*notify{a}
+}
+
+// flowValue is a notify value read for one flow.
+type flowValue struct {
+	flow  *notify
+	value notifyValue
+}
+
+// flowValues returns the notify values of h for every flow, grouped by
+// flow in rule order. All flows read h before any removes its headers,
+// so that rules sharing a NotifyHeader all notify.
+func (a *notify) flowValues(h http.Header) []flowValue {
+	var out []flowValue
+	for _, f := range a.flows() {
+		for _, v := range f.notifyValues(h) {
+			out = append(out, flowValue{flow: f, value: v})
+		}
+	}
+	return out
+}
+
+// removeFlowHeaders removes the notify headers of every flow from h.
+func (a *notify) removeFlowHeaders(h http.Header) {
+	for _, f := range a.flows() {
+		f.removeNotifyHeaders(h)
+	}
+}
+
+// skipFlows suppresses the notifications of values, once per flow.
+func (a *notify) skipFlows(values []flowValue, ex *exchange) {
+	var last *notify
+	for _, v := range values {
+		if v.flow != last {
+			v.flow.skipByHeader(ex)
+			last = v.flow
+		}
+	}
+}
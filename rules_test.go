@@ -0,0 +1,141 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestServeHTTPRules(t *testing.T) {
+	captureLog(t)
+	var mu sync.Mutex
+	var got []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r.Method+" "+r.URL.Path+" "+r.Header.Get("X-Tenant")+r.Header.Get("X-User")+" "+string(b))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Order-Event", encode(`{"order":1}`))
+		w.Header().Set("X-User-Event", encode(r.Header.Get("X-Payload")))
+		w.Write([]byte("ok"))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyUrl:      receiver.URL + "/default",
+		ForwardHeaders: []string{"X-Tenant"},
+		Rules: []Rule{
+			{Name: "orders", NotifyHeader: "X-Order-Event"},
+			{NotifyHeader: "X-User-Event", NotifyUrl: receiver.URL + "/users", NotifyMethod: "put", Condition: "payload.active", ForwardHeaders: []string{"X-User"}},
+		},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, payload := range []string{`{"active":true}`, `{"active":false}`} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("X-Tenant", "t1")
+		req.Header.Set("X-User", "u1")
+		req.Header.Set("X-Payload", payload)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Body.String() != "ok" || rec.Header().Get("X-Order-Event") != "" || rec.Header().Get("X-User-Event") != "" {
+			t.Errorf("unexpected response %q %v", rec.Body.String(), rec.Header())
+		}
+	}
+	expect := []string{
+		`POST /default t1 {"order":1}`,
+		`PUT /users u1 {"active":true}`,
+		`POST /default t1 {"order":1}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestServeHTTPRulesSharedHeader(t *testing.T) {
+	captureLog(t)
+	var mu sync.Mutex
+	var got []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		got = append(got, r.URL.Path+" "+string(b))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	spoolFile := filepath.Join(t.TempDir(), "spool.jsonl")
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:        "X-Notify",
+		NotifyUrl:           receiver.URL + "/a",
+		SpoolFile:           spoolFile,
+		HealthCheckInterval: "1h",
+		Rules: []Rule{
+			{Name: "a"},
+			{Name: "b", NotifyMethod: "put"},
+			{Name: "c", NotifyUrl: receiver.URL + "/c"},
+		},
+	}, "header2post")
+	if err == nil || err.Error() != "rule b: spoolfile cannot be shared by rules with different notifyurls or notifymethods" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler, err = New(context.Background(), next, &Config{
+		NotifyHeader:        "X-Notify",
+		NotifyUrl:           receiver.URL + "/a",
+		SpoolFile:           spoolFile,
+		HealthCheckInterval: "1h",
+		Rules:               []Rule{{Name: "a"}, {Name: "b", Condition: "payload.id == 1"}},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := handler.(*notify).rules
+	if len(rules) != 2 || rules[0].spool != rules[1].spool || rules[0].health != rules[1].health {
+		t.Fatalf("rules do not share their spool and health probe")
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Header().Get("X-Notify") != "" {
+		t.Errorf("notify header not removed")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(got, " ") != `/a {"id":1} /a {"id":1}` {
+		t.Errorf("expected both rules to notify, got %v", got)
+	}
+}
+
+func TestNewRulesErrors(t *testing.T) {
+	tests := []struct {
+		rules  []Rule
+		expect string
+	}{
+		{rules: []Rule{{NotifyHeader: "X-A"}, {}}, expect: "rule 1: notifyheader cannot be empty"},
+		{rules: []Rule{{Name: "a", NotifyHeader: "X-A"}, {Name: "a", NotifyHeader: "X-B"}}, expect: `duplicate rule name: "a"`},
+		{rules: []Rule{{Name: "a", NotifyHeader: "X-A", NotifyMethod: "GET"}}, expect: `rule a: invalid notifymethod: "GET"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expect, func(t *testing.T) {
+			_, err := New(context.Background(), http.NotFoundHandler(), &Config{NotifyUrl: "https://example.com/notification", Rules: tt.rules}, "header2post")
+			if err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
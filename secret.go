@@ -0,0 +1,92 @@
+package header2post
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+)
+
+// resolveSecret returns value, or the secret it references: env:NAME reads
+// the environment variable NAME and file:/path reads a file, without its
+// trailing newline. option names the option in errors.
+func resolveSecret(option, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok || name == "" {
+			return "", fmt.Errorf("%s: environment variable %q is not set", option, name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretFilePrefix):
+		b, err := os.ReadFile(strings.TrimPrefix(value, secretFilePrefix))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", option, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+	return value, nil
+}
+
+// resolveSecrets returns a copy of config with the secret references of
+// its sensitive options resolved.
+func resolveSecrets(config *Config) (*Config, error) {
+	c := *config
+	for _, s := range []struct {
+		option string
+		value  *string
+	}{
+		{"clientkeypem", &c.ClientKeyPEM},
+		{"clientsecret", &c.ClientSecret},
+		{"encryptionkey", &c.EncryptionKey},
+		{"signingkey", &c.SigningKey},
+		{"redispassword", &c.RedisPassword},
+		{"smtppassword", &c.SmtpPassword},
+		{"pagerdutyroutingkey", &c.PagerdutyRoutingKey},
+		{"discoverytoken", &c.DiscoveryToken},
+		{"webhooksecret", &c.WebhookSecret},
+	} {
+		v, err := resolveSecret(s.option, *s.value)
+		if err != nil {
+			return nil, err
+		}
+		*s.value = v
+	}
+	for _, m := range []struct {
+		option string
+		values *map[string]string
+	}{
+		{"tenantsigningkeys", &c.TenantSigningKeys},
+		{"tenantwebhooksecrets", &c.TenantWebhookSecrets},
+		{"tenantapikeys", &c.TenantApiKeys},
+	} {
+		if len(*m.values) == 0 {
+			continue
+		}
+		resolved := make(map[string]string, len(*m.values))
+		for k, v := range *m.values {
+			secret, err := resolveSecret(m.option+" "+k, v)
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = secret
+		}
+		*m.values = resolved
+	}
+	if len(config.StaticNotifyHeaders) > 0 {
+		c.StaticNotifyHeaders = make(map[string]string, len(config.StaticNotifyHeaders))
+		for k, v := range config.StaticNotifyHeaders {
+			resolved, err := resolveSecret("staticnotifyheaders "+k, v)
+			if err != nil {
+				return nil, err
+			}
+			c.StaticNotifyHeaders[k] = resolved
+		}
+	}
+	return &c, nil
+}
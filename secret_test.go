@@ -0,0 +1,62 @@
+package header2post
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("HEADER2POST_TEST_SECRET", "s3cret")
+	file := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		value     string
+		expect    string
+		expectErr string
+	}{
+		{value: "plain", expect: "plain"},
+		{value: "env:HEADER2POST_TEST_SECRET", expect: "s3cret"},
+		{value: "env:HEADER2POST_TEST_MISSING", expectErr: `smtppassword: environment variable "HEADER2POST_TEST_MISSING" is not set`},
+		{value: "file:" + file, expect: "from-file"},
+		{value: "file:" + file + ".missing", expectErr: "smtppassword: open " + file + ".missing: no such file or directory"},
+	}
+	for _, tt := range tests {
+		got, err := resolveSecret("smtppassword", tt.value)
+		if got != tt.expect || (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+			t.Errorf("%s: unexpected %q %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("HEADER2POST_TEST_TOKEN", "Bearer t0ken")
+	config := &Config{
+		ClientSecret:        "env:HEADER2POST_TEST_TOKEN",
+		StaticNotifyHeaders: map[string]string{"Authorization": "env:HEADER2POST_TEST_TOKEN"},
+	}
+	resolved, err := resolveSecrets(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.ClientSecret != "Bearer t0ken" || resolved.StaticNotifyHeaders["Authorization"] != "Bearer t0ken" {
+		t.Errorf("unexpected resolved config %+v", resolved)
+	}
+	// the caller's configuration is left untouched
+	if config.ClientSecret != "env:HEADER2POST_TEST_TOKEN" || config.StaticNotifyHeaders["Authorization"] != "env:HEADER2POST_TEST_TOKEN" {
+		t.Errorf("config was modified: %+v", config)
+	}
+
+	_, err = New(context.Background(), http.NotFoundHandler(), &Config{
+		NotifyHeader:        "X-Notify",
+		NotifyUrl:           "https://example.com/notification",
+		StaticNotifyHeaders: map[string]string{"Authorization": "env:HEADER2POST_TEST_MISSING"},
+	}, "header2post")
+	if err == nil || err.Error() != `staticnotifyheaders Authorization: environment variable "HEADER2POST_TEST_MISSING" is not set` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,441 @@
+package header2post
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification is one encoded notification handed to a Sender.
+type Notification struct {
+	// Body is the encoded request body.
+	Body []byte
+	// ContentType describes Body.
+	ContentType string
+	// Header carries format specific metadata, such as CloudEvents
+	// attributes in binary mode, that every transport should propagate.
+	Header http.Header
+	// ForwardHeader holds the request headers selected by ForwardHeaders.
+	// Only the HTTP sender copies them, so credentials meant for the notify
+	// service do not leak into message brokers.
+	ForwardHeader http.Header
+	// Payload is the decoded header value the body was built from; senders
+	// use it to render keys, subjects and topics.
+	Payload []byte
+	// EventIDs are the event ids extracted from Payload, if configured.
+	EventIDs []string
+
+	// parent is the incoming trace context delivery spans join.
+	parent *spanContext
+	// reply, when set, receives the reply of the HTTP sender.
+	reply *notifyReply
+	// event replaces {event} in the HTTP sender url.
+	event string
+	// status is the response status, selecting a StatusRoutes sender.
+	status int
+	// ack, when set, receives the event id echoed by the HTTP sender's
+	// receiver.
+	ack *notifyAck
+	// expires is when NotificationTTL expires the notification, if set.
+	expires time.Time
+	// tenant selects the per tenant credentials of TenantHeader.
+	tenant string
+	// priority is set by PriorityHeader; "high" takes a free delivery slot
+	// before the other waiting notifications.
+	priority string
+	// formatHeader holds the Header entries set by the body format, which
+	// a fallback format replaces.
+	formatHeader http.Header
+	// dedupKeys are the DedupTTL keys recorded for the notification,
+	// settled once it is dispatched.
+	dedupKeys []string
+	// maxRetryWait, when set, is the longest wait before a retry; a
+	// synchronous delivery sets it so the client response is not held
+	// for a long Retry-After.
+	maxRetryWait time.Duration
+}
+
+// Sender delivers notifications to one destination.
+type Sender interface {
+	Send(ctx context.Context, n Notification) error
+}
+
+// SenderFunc adapts an ordinary function to the Sender interface.
+type SenderFunc func(ctx context.Context, n Notification) error
+
+// Send calls f(ctx, n).
+func (f SenderFunc) Send(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+// SenderFactory builds a Sender from the middleware configuration. name is
+// the middleware instance name.
+type SenderFactory func(config *Config, name string) (Sender, error)
+
+var (
+	sendersMu sync.RWMutex
+	senders   = map[string]SenderFactory{}
+)
+
+// RegisterSender makes a sender available under name, selected with the
+// Sink option. Registering an existing name replaces it, which lets
+// programs embedding the middleware swap out a built-in transport.
+func RegisterSender(name string, factory SenderFactory) {
+	if name == "" || factory == nil {
+		panic("header2post: RegisterSender requires a name and a factory")
+	}
+	sendersMu.Lock()
+	defer sendersMu.Unlock()
+	senders[name] = factory
+}
+
+func lookupSender(name string) (SenderFactory, bool) {
+	sendersMu.RLock()
+	defer sendersMu.RUnlock()
+	factory, ok := senders[name]
+	return factory, ok
+}
+
+// HTTPDoer sends an http request and returns its response. *http.Client
+// implements it; tests and embedding programs may supply their own.
+type HTTPDoer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// HTTPSender POSTs notifications to URL. It is the default sender and the
+// one used for NotifyUrl.
+type HTTPSender struct {
+	URL string
+	// Method defaults to POST.
+	Method string
+	// Client performs the request; http.DefaultClient when nil.
+	Client HTTPDoer
+	// Header is added to every request, after any forwarded headers.
+	Header http.Header
+	// UserAgent defaults to header2post/<version>.
+	UserAgent string
+	// Compress gzips the body and sets Content-Encoding: gzip.
+	Compress bool
+	// MaxErrorBody bounds how much of a failed response body is kept in
+	// the StatusError; defaultMaxErrorBody when zero.
+	MaxErrorBody int
+
+	// target replaces URL in delivery reports, e.g. for unix sockets.
+	target string
+	// hooks adjust every request before it is sent, e.g. to add
+	// credentials.
+	hooks []requestHook
+	// health short-circuits requests while the endpoint is down.
+	health *healthProbe
+	// srv picks the host of an srv:// NotifyUrl for each request.
+	srv *srvTarget
+	// pool picks the host from the discovered receivers for each request.
+	pool *endpointPool
+	// chain receives the reply body of each request in turn, see Send.
+	chain []*HTTPSender
+	// statuses, when set, limits the sender to notifications of responses
+	// with these statuses, which no other sender then receives.
+	statuses statusSet
+	// replyCheck, when set, validates successful replies.
+	replyCheck *replyCheck
+}
+
+type requestHook func(ctx context.Context, req *http.Request) error
+
+const (
+	// maxDrainBytes bounds how much of an unread response body is
+	// discarded to keep its connection alive.
+	maxDrainBytes = 64 << 10
+	// defaultMaxErrorBody bounds the failed response body kept for logs.
+	defaultMaxErrorBody = 4 << 10
+)
+
+// StatusError reports a notify url response other than 202 Accepted.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	// Truncated is set when Body holds only the start of a longer reply.
+	Truncated bool
+	// RetryAfter is the wait requested by a 429 or 503 reply with a
+	// Retry-After header, zero otherwise.
+	RetryAfter time.Duration
+}
+
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	err := &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		err.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), timeNow())
+	}
+	return err
+}
+
+func (e *StatusError) Error() string {
+	return "notify failed: " + e.Body
+}
+
+// Target returns the notify url for delivery reports.
+func (s *HTTPSender) Target() string {
+	if s.target != "" {
+		return s.target
+	}
+	return s.URL
+}
+
+// Send posts n and expects a 202 Accepted response. With a chain, URL
+// and every chain sender but the last must instead answer 2xx with the
+// body posted to the next one; the last expects 202.
+func (s *HTTPSender) Send(ctx context.Context, n Notification) error {
+	if len(s.chain) == 0 {
+		return s.send(ctx, n)
+	}
+	final := n.reply
+	step := s
+	for i := 0; ; i++ {
+		m := n
+		if i < len(s.chain) {
+			m.reply = &notifyReply{acceptAny: true}
+		} else {
+			m.reply = final
+		}
+		if err := step.send(ctx, m); err != nil {
+			if i > 0 {
+				return fmt.Errorf("chain step %d: %w", i, err)
+			}
+			return err
+		}
+		if i == len(s.chain) {
+			return nil
+		}
+		n.Body, n.Payload, n.Header, n.ack = m.reply.body, m.reply.body, nil, nil
+		if m.reply.contentType != "" {
+			n.ContentType = m.reply.contentType
+		}
+		step = s.chain[i]
+	}
+}
+
+// send makes one request to URL.
+func (s *HTTPSender) send(ctx context.Context, n Notification) error {
+	if !s.health.healthy() {
+		return errEndpointUnhealthy
+	}
+	body := n.Body
+	if s.Compress {
+		buf := getBuffer()
+		zw := gzip.NewWriter(buf)
+		_, err := zw.Write(body)
+		if err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			putBuffer(buf)
+			return fmt.Errorf("compress body error: %w", err)
+		}
+		// the transport may still read the body after Do returns
+		body = bytes.Clone(buf.Bytes())
+		putBuffer(buf)
+	}
+	method := s.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	target := strings.ReplaceAll(s.URL, "{event}", url.PathEscape(n.event))
+	ctx = withTenant(ctx, n.tenant)
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create http request error: %w", err)
+	}
+	if s.srv != nil {
+		if err := s.srv.route(ctx, req); err != nil {
+			return err
+		}
+	}
+	if s.pool != nil {
+		if err := s.pool.route(ctx, req); err != nil {
+			return err
+		}
+	}
+	for k, v := range n.Header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", n.ContentType)
+	ua := s.UserAgent
+	if ua == "" {
+		ua = userAgent()
+	}
+	req.Header.Set("User-Agent", ua)
+	for k, v := range n.ForwardHeader {
+		req.Header[k] = v
+	}
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	if s.Compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for _, hook := range s.hooks {
+		if err := hook(ctx, req); err != nil {
+			return err
+		}
+	}
+	var client HTTPDoer = http.DefaultClient
+	if s.Client != nil {
+		client = s.Client
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post error: %w", err)
+	}
+	defer func() {
+		// drain what is left so the connection can be reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainBytes))
+		resp.Body.Close()
+	}()
+	if n.ack != nil {
+		n.ack.value = resp.Header.Get(n.ack.header)
+	}
+	if n.reply != nil {
+		bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxReplyBytes))
+		if err != nil {
+			return fmt.Errorf("read resp body error: %w", err)
+		}
+		n.reply.record(resp.StatusCode, resp.Header.Get("Content-Type"), bodyBytes)
+		if resp.StatusCode == http.StatusAccepted || (n.reply.acceptAny && resp.StatusCode/100 == 2) {
+			return s.checkReply(resp, bodyBytes)
+		}
+		return s.statusError(resp, bodyBytes)
+	}
+	if resp.StatusCode == http.StatusAccepted {
+		return s.checkReply(resp, nil)
+	}
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, int64(s.maxErrorBody())+1))
+	if err != nil {
+		return fmt.Errorf("read resp body error: %w", err)
+	}
+	return s.statusError(resp, bodyBytes)
+}
+
+// checkReply validates a successful reply, if enabled.
+func (s *HTTPSender) checkReply(resp *http.Response, body []byte) error {
+	if s.replyCheck == nil {
+		return nil
+	}
+	return s.replyCheck.check(resp, body)
+}
+
+func (s *HTTPSender) maxErrorBody() int {
+	if s.MaxErrorBody == 0 {
+		return defaultMaxErrorBody
+	}
+	return s.MaxErrorBody
+}
+
+// statusError reports a failed response, keeping at most MaxErrorBody
+// bytes of its body.
+func (s *HTTPSender) statusError(resp *http.Response, body []byte) *StatusError {
+	max := s.maxErrorBody()
+	if len(body) <= max {
+		return newStatusError(resp, body)
+	}
+	err := newStatusError(resp, body[:max])
+	err.Truncated = true
+	return err
+}
+
+// senderTarget describes s in delivery reports. Senders may implement
+// Target() string; others are identified by their type.
+func senderTarget(s Sender) string {
+	if t, ok := s.(interface{ Target() string }); ok {
+		return t.Target()
+	}
+	return fmt.Sprintf("%T", s)
+}
+
+// deliverTo sends n with s once and returns its outcome.
+func deliverTo(s Sender, n Notification) deliveryResult {
+	return deliverRetry(context.Background(), s, n, nil, defaultSendTimeout, nil)
+}
+
+// deliverRetry sends n with s, attempting failed deliveries again as
+// allowed by p, and returns the outcome of the last attempt. Each attempt
+// is bounded by timeout; no attempt is made once ctx is done, nor waited
+// for when it would start after the ctx deadline or wait longer than
+// n.maxRetryWait. Failed attempts are logged to l, if set.
+func deliverRetry(ctx context.Context, s Sender, n Notification, p *retryPolicy, timeout time.Duration, l *slog.Logger) (result deliveryResult) {
+	result.Target = senderTarget(s)
+	if p != nil {
+		p.budget.attempt()
+	}
+	start := timeNow()
+	defer func() { result.DurationMs = timeNow().Sub(start).Milliseconds() }()
+
+	for {
+		err := send(ctx, s, n, timeout)
+		if err == nil {
+			result.Success = true
+			result.Status, result.Error = 0, ""
+			return result
+		}
+		var statusErr *StatusError
+		result.Status = 0
+		if errors.As(err, &statusErr) {
+			result.Status = statusErr.StatusCode
+		}
+		result.Error = err.Error()
+		if l != nil {
+			attrs := []any{"target", result.Target, "attempt", result.Retries + 1, "status", result.Status, "error", err, "event_ids", n.EventIDs}
+			if statusErr != nil && statusErr.Truncated {
+				attrs = append(attrs, "body_truncated", true)
+			}
+			l.Warn("delivery attempt failed", attrs...)
+		}
+		if p == nil || result.Retries >= p.max || !p.retryable(err) || ctx.Err() != nil {
+			return result
+		}
+		if !p.budget.allow() {
+			if l != nil {
+				l.Warn("retry budget exhausted", "target", result.Target, "attempt", result.Retries+1, "event_ids", n.EventIDs)
+			}
+			return result
+		}
+		wait := p.wait(result.Retries, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			// the retry could not start before ctx ends, e.g. after a
+			// long Retry-After
+			if l != nil {
+				l.Warn("retry abandoned: wait exceeds the delivery deadline", "target", result.Target, "wait", wait.String(), "event_ids", n.EventIDs)
+			}
+			return result
+		}
+		if n.maxRetryWait > 0 && wait > n.maxRetryWait {
+			if l != nil {
+				l.Warn("retry abandoned: wait exceeds the synchronous delivery bound", "target", result.Target, "wait", wait.String(), "event_ids", n.EventIDs)
+			}
+			return result
+		}
+		if sleep(ctx, wait) != nil {
+			return result
+		}
+		if n.expired() {
+			result.Status, result.Error = 0, errNotificationExpired.Error()
+			return result
+		}
+		result.Retries++
+	}
+}
+
+// send makes a single delivery attempt.
+func send(ctx context.Context, s Sender, n Notification, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return s.Send(ctx, n)
+}
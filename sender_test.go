@@ -0,0 +1,287 @@
+package header2post
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPSender(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		expectErr    string
+		expectStatus int
+	}{
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "rejected", status: http.StatusBadRequest, expectErr: "notify failed: bad", expectStatus: http.StatusBadRequest},
+		{name: "ok is not accepted", status: http.StatusOK, expectErr: "notify failed: bad", expectStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *http.Request
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r
+				w.WriteHeader(tt.status)
+				w.Write([]byte("bad"))
+			}))
+			defer srv.Close()
+
+			s := &HTTPSender{URL: srv.URL, Header: http.Header{"X-Environment": {"prod"}}}
+			result := deliverTo(s, Notification{
+				Body:          []byte(`{"a":1}`),
+				ContentType:   "application/json",
+				Header:        http.Header{"Ce-Id": {"1"}},
+				ForwardHeader: http.Header{"X-Tenant": {"t1"}},
+			})
+			if result.Target != srv.URL {
+				t.Errorf("expected target %q, got %q", srv.URL, result.Target)
+			}
+			if result.Success != (tt.expectErr == "") || result.Error != tt.expectErr || result.Status != tt.expectStatus {
+				t.Errorf("unexpected result %+v", result)
+			}
+			for k, v := range map[string]string{
+				"Content-Type":  "application/json",
+				"User-Agent":    userAgent(),
+				"Ce-Id":         "1",
+				"X-Tenant":      "t1",
+				"X-Environment": "prod",
+			} {
+				if got.Header.Get(k) != v {
+					t.Errorf("expected header %s %q, got %q", k, v, got.Header.Get(k))
+				}
+			}
+		})
+	}
+}
+
+func TestStaticNotifyHeaders(t *testing.T) {
+	senders, err := newSenders(&Config{
+		NotifyUrl:           "https://example.com/notification",
+		StaticNotifyHeaders: map[string]string{"x-environment": "prod", "X-Cluster": " eu-1 "},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ua := senders[0].(*HTTPSender).UserAgent; ua != userAgent() {
+		t.Errorf("expected user agent %q, got %q", userAgent(), ua)
+	}
+	h := senders[0].(*HTTPSender).Header
+	if h.Get("X-Environment") != "prod" || h.Get("X-Cluster") != "eu-1" {
+		t.Errorf("unexpected headers %v", h)
+	}
+	_, err = newSenders(&Config{
+		NotifyUrl:           "https://example.com/notification",
+		StaticNotifyHeaders: map[string]string{" ": "prod"},
+	}, "header2post")
+	if err == nil || err.Error() != "staticnotifyheaders names cannot be empty" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestHTTPSenderCompress(t *testing.T) {
+	var encoding, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		b, _ := io.ReadAll(zr)
+		body = string(b)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	senders, err := newSenders(&Config{NotifyUrl: srv.URL, CompressNotifyBody: true}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := deliverTo(senders[0], Notification{Body: []byte(`{"a":1}`), ContentType: "application/json"})
+	if !result.Success || encoding != "gzip" || body != `{"a":1}` {
+		t.Errorf("unexpected delivery %+v %q %q", result, encoding, body)
+	}
+}
+
+func TestHTTPSenderErrorBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		max         int
+		reply       string
+		expectErr   string
+		expectTrunc bool
+	}{
+		{name: "short", reply: "bad request", expectErr: "notify failed: bad request"},
+		{name: "default limit", reply: strings.Repeat("x", 5000), expectErr: "notify failed: " + strings.Repeat("x", 4096), expectTrunc: true},
+		{name: "configured limit", max: 3, reply: "bad request", expectErr: "notify failed: bad", expectTrunc: true},
+		{name: "exact limit", max: 11, reply: "bad request", expectErr: "notify failed: bad request"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadGateway)
+				io.WriteString(w, tt.reply)
+			}))
+			defer srv.Close()
+
+			s := &HTTPSender{URL: srv.URL, MaxErrorBody: tt.max}
+			p := &retryPolicy{max: 1}
+			sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+			t.Cleanup(func() { sleep = sleepContext })
+			result := deliverRetry(context.Background(), s, Notification{EventIDs: []string{"e1"}}, p, time.Second, newLogger("header2post", slog.LevelInfo, &logs))
+			if result.Error != tt.expectErr || result.Status != http.StatusBadGateway {
+				t.Errorf("unexpected result %+v", result)
+			}
+			var statusErr *StatusError
+			err := s.Send(context.Background(), Notification{})
+			if !errors.As(err, &statusErr) || statusErr.Truncated != tt.expectTrunc {
+				t.Errorf("expected truncated %v, got %v", tt.expectTrunc, err)
+			}
+			var attempts []map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				var rec map[string]any
+				if json.Unmarshal([]byte(line), &rec) == nil && rec["msg"] == "delivery attempt failed" {
+					attempts = append(attempts, rec)
+				}
+			}
+			if len(attempts) != 2 {
+				t.Fatalf("expected 2 failed attempts logged, got %s", logs.String())
+			}
+			for i, rec := range attempts {
+				if rec["target"] != srv.URL || rec["attempt"] != float64(i+1) || rec["status"] != float64(http.StatusBadGateway) || (rec["body_truncated"] == true) != tt.expectTrunc {
+					t.Errorf("unexpected attempt log %v", rec)
+				}
+			}
+		})
+	}
+}
+
+func TestHTTPSenderChain(t *testing.T) {
+	tests := []struct {
+		name          string
+		lookupStatus  int
+		mailerStatus  int
+		expectErr     string
+		expectMailer  string
+		expectContent string
+	}{
+		{name: "delivered", lookupStatus: http.StatusOK, mailerStatus: http.StatusAccepted, expectMailer: `{"email":"a@example.com"}`, expectContent: "application/json"},
+		{name: "lookup failed", lookupStatus: http.StatusNotFound, expectErr: "notify failed: lookup"},
+		{name: "last step failed", lookupStatus: http.StatusOK, mailerStatus: http.StatusOK, expectErr: "chain step 1: notify failed: mailer", expectMailer: `{"email":"a@example.com"}`, expectContent: "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lookupBody string
+			lookup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				lookupBody = string(b)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.lookupStatus)
+				if tt.lookupStatus == http.StatusOK {
+					io.WriteString(w, `{"email":"a@example.com"}`)
+				} else {
+					io.WriteString(w, "lookup")
+				}
+			}))
+			defer lookup.Close()
+			var mailerBody, mailerContent string
+			mailer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				mailerBody, mailerContent = string(b), r.Header.Get("Content-Type")
+				w.WriteHeader(tt.mailerStatus)
+				io.WriteString(w, "mailer")
+			}))
+			defer mailer.Close()
+
+			senders, err := newSenders(&Config{NotifyUrl: lookup.URL, ChainUrls: []string{mailer.URL}}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = senders[0].Send(context.Background(), Notification{Body: []byte(`{"user":"u1"}`), ContentType: "text/plain"})
+			if (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+			if lookupBody != `{"user":"u1"}` {
+				t.Errorf("expected the payload posted to the first step, got %q", lookupBody)
+			}
+			if mailerBody != tt.expectMailer || mailerContent != tt.expectContent {
+				t.Errorf("expected %q %q posted to the last step, got %q %q", tt.expectMailer, tt.expectContent, mailerBody, mailerContent)
+			}
+		})
+	}
+}
+
+func TestNewSendersChainErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "no notifyurl", config: Config{FanoutUrls: []string{"https://example.com/a"}, ChainUrls: []string{"https://example.com/b"}}, err: "chainurls requires notifyurl"},
+		{name: "bad url", config: Config{NotifyUrl: "https://example.com/a", ChainUrls: []string{"example.com/b"}}, err: `invalid chainurls: "example.com/b"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSenders(&tt.config, "header2post")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+	_, err := New(context.Background(), http.NotFoundHandler(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/a", ChainUrls: []string{"https://example.com/b"}, EnrichMode: enrichHeader}, "header2post")
+	if err == nil || err.Error() != `enrichmode "header" cannot be combined with chainurls` {
+		t.Errorf("expected enrichmode error, got %v", err)
+	}
+}
+
+func TestRegisterSender(t *testing.T) {
+	captureLog(t)
+	var sent []Notification
+	RegisterSender("recorder", func(config *Config, name string) (Sender, error) {
+		return SenderFunc(func(ctx context.Context, n Notification) error {
+			sent = append(sent, n)
+			return nil
+		}), nil
+	})
+	RegisterSender("broken", func(config *Config, name string) (Sender, error) {
+		return nil, errors.New("broken sender")
+	})
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	for sink, expectErr := range map[string]string{
+		"broken":  "broken sender",
+		"missing": `unsupported sink: "missing"`,
+	} {
+		_, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", Sink: sink}, "header2post")
+		if err == nil || err.Error() != expectErr {
+			t.Errorf("sink %s: expected error %q, got %v", sink, expectErr, err)
+		}
+	}
+
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", Sink: "recorder", EventIdField: "id"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if len(sent) != 1 {
+		t.Fatalf("expected one notification, got %d", len(sent))
+	}
+	n := sent[0]
+	if string(n.Body) != `{"id":"e1"}` || n.ContentType != "application/json" || len(n.EventIDs) != 1 || n.EventIDs[0] != "e1" {
+		t.Errorf("unexpected notification %+v", n)
+	}
+}
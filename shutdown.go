@@ -0,0 +1,51 @@
+package header2post
+
+import (
+	"context"
+	"time"
+)
+
+const defaultShutdownGracePeriod = 10 * time.Second
+
+// drain waits for ctx, the context the middleware was created with, to be
+// done and then delivers the pending batch, debounced and partitioned
+// notifications.
+// Detached deliveries still running once grace has elapsed are canceled.
+func (a *notify) drain(ctx context.Context, grace time.Duration) {
+	<-ctx.Done()
+	a.log.Info("draining notifications", "pending", a.queueDepth())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if a.batch != nil {
+			a.batch.close()
+		}
+		if a.lowBatch != nil {
+			a.lowBatch.close()
+		}
+		if a.debounce != nil {
+			a.debounce.close()
+		}
+		if a.partitions != nil {
+			a.partitions.wait()
+		}
+		a.background.Wait()
+		if a.tracer != nil {
+			a.tracer.flush()
+		}
+		for _, s := range a.senders {
+			if s, ok := s.(*streamSink); ok {
+				s.close()
+			}
+		}
+	}()
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-done:
+		a.log.Info("notifications drained")
+	case <-timer.C:
+		a.log.Warn("shutdown grace period elapsed", "pending", a.queueDepth())
+		a.cancelDetached()
+	}
+}
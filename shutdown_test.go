@@ -0,0 +1,62 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShutdownDrain(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	tests := []struct {
+		name      string
+		config    Config
+		block     bool
+		expectLog string
+	}{
+		{name: "batch flushed", config: Config{BatchMaxWait: "1h"}, expectLog: "notifications drained"},
+		{name: "partition drained", config: Config{PartitionKeyField: "id"}, expectLog: "notifications drained"},
+		{name: "grace elapsed", config: Config{PartitionKeyField: "id", ShutdownGracePeriod: "50ms"}, block: true, expectLog: "shutdown grace period elapsed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", payload)
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			a := handler.(*notify)
+			grace, _ := parseDuration("shutdowngraceperiod", config.ShutdownGracePeriod, defaultShutdownGracePeriod)
+			delivered := make(chan error, 1)
+			a.senders = []Sender{SenderFunc(func(ctx context.Context, n Notification) error {
+				if tt.block {
+					<-ctx.Done()
+				}
+				delivered <- ctx.Err()
+				return ctx.Err()
+			})}
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			a.drain(ctx, grace)
+			if err := <-delivered; (err != nil) != tt.block {
+				t.Errorf("unexpected delivery error %v", err)
+			}
+			if a.partitions != nil {
+				a.partitions.wait()
+			}
+			if !strings.Contains(logs.String(), tt.expectLog) {
+				t.Errorf("expected %q in\n%s", tt.expectLog, logs)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package header2post
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	signatureHeader = "X-Notify-Signature"
+	keyIdHeader     = "X-Notify-Key-Id"
+	timestampHeader = "X-Notify-Timestamp"
+	nonceHeader     = "X-Notify-Nonce"
+
+	// DefaultSignatureTolerance is how old a signed delivery VerifySignature
+	// accepts when given no tolerance.
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+// payloadSigner stamps every notify request with a timestamp and a nonce
+// and attaches a detached Ed25519 signature of them and the request body
+// when a signing key is configured.
+type payloadSigner struct {
+	key   ed25519.PrivateKey
+	keyID string
+	// tenants holds the keys of TenantSigningKeys.
+	tenants map[string]ed25519.PrivateKey
+}
+
+// newPayloadSigner reads the signing keys, if any: a PEM PKCS #8 Ed25519
+// private key read from SigningKeyFile or given inline as SigningKey, and
+// likewise for the TenantSigningKeys.
+func newPayloadSigner(config *Config) (*payloadSigner, error) {
+	s := &payloadSigner{keyID: config.SigningKeyId}
+	raw := []byte(config.SigningKey)
+	switch {
+	case config.SigningKeyFile != "" && config.SigningKey != "":
+		return nil, fmt.Errorf("signingkeyfile cannot be combined with signingkey")
+	case config.SigningKeyFile != "":
+		b, err := os.ReadFile(config.SigningKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read signingkeyfile: %w", err)
+		}
+		raw = b
+	}
+	if len(raw) > 0 {
+		key, err := parseSigningKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing key: %w", err)
+		}
+		s.key = key
+	}
+	for tenant, raw := range config.TenantSigningKeys {
+		key, err := parseSigningKey([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tenantsigningkeys %q: %w", tenant, err)
+		}
+		if s.tenants == nil {
+			s.tenants = make(map[string]ed25519.PrivateKey, len(config.TenantSigningKeys))
+		}
+		s.tenants[tenant] = key
+	}
+	return s, nil
+}
+
+func parseSigningKey(raw []byte) (ed25519.PrivateKey, error) {
+	key, err := parsePrivateKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%T is not an ed25519 key", key)
+	}
+	return edKey, nil
+}
+
+// sign sets a timestamp and a random nonce on req and, with the key of its
+// tenant if it has one, the signature of both and the body. Every attempt
+// is stamped and signed afresh.
+func (s *payloadSigner) sign(ctx context.Context, req *http.Request) error {
+	timestamp := strconv.FormatInt(timeNow().Unix(), 10)
+	nonce := generateID()
+	req.Header.Set(timestampHeader, timestamp)
+	req.Header.Set(nonceHeader, nonce)
+	key, keyID := s.key, s.keyID
+	if tenantKey, ok := s.tenants[tenantOf(ctx)]; ok {
+		key, keyID = tenantKey, ""
+	}
+	if key == nil {
+		return nil
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return fmt.Errorf("sign payload: %w", err)
+	}
+	signature := ed25519.Sign(key, signingInput(timestamp, nonce, body))
+	req.Header.Set(signatureHeader, base64.StdEncoding.EncodeToString(signature))
+	if keyID != "" {
+		req.Header.Set(keyIdHeader, keyID)
+	}
+	return nil
+}
+
+// signingInput is the signed message: the timestamp, the nonce and the
+// body joined by dots.
+func signingInput(timestamp, nonce string, body []byte) []byte {
+	return append([]byte(timestamp+"."+nonce+"."), body...)
+}
+
+// VerifySignature checks a delivery signed with SigningKey, given its
+// headers and body, against the matching public key. Deliveries signed
+// more than tolerance ago, DefaultSignatureTolerance when zero, or in the
+// future are rejected. Receivers should also reject a nonce seen within
+// the tolerance window to stop replays.
+func VerifySignature(h http.Header, body []byte, key ed25519.PublicKey, tolerance time.Duration) error {
+	if tolerance == 0 {
+		tolerance = DefaultSignatureTolerance
+	}
+	timestamp, nonce := h.Get(timestampHeader), h.Get(nonceHeader)
+	if timestamp == "" || nonce == "" {
+		return errors.New("missing signature timestamp or nonce")
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid signature timestamp: %q", timestamp)
+	}
+	if age := timeNow().Sub(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp outside tolerance: %s", age.Round(time.Second))
+	}
+	signature, err := base64.StdEncoding.DecodeString(h.Get(signatureHeader))
+	if err != nil || !ed25519.Verify(key, signingInput(timestamp, nonce, body), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// requestBody returns a copy of the body req will send.
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	r, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
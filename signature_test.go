@@ -0,0 +1,129 @@
+package header2post
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testPEM(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+}
+
+func TestNewPayloadSigner(t *testing.T) {
+	_, edKey, _ := ed25519.GenerateKey(cryptorand.Reader)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	file := filepath.Join(t.TempDir(), "signing.pem")
+	os.WriteFile(file, []byte(testPEM(t, edKey)), 0o600)
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{}},
+		{config: Config{SigningKeyFile: file}},
+		{config: Config{SigningKey: testPEM(t, edKey)}},
+		{config: Config{SigningKeyFile: file, SigningKey: testPEM(t, edKey)}, expectErr: "signingkeyfile cannot be combined with signingkey"},
+		{config: Config{SigningKeyFile: file + ".missing"}, expectErr: "read signingkeyfile: open " + file + ".missing: no such file or directory"},
+		{config: Config{SigningKey: "secret"}, expectErr: "invalid signing key: no PEM private key found"},
+		{config: Config{SigningKey: testPEM(t, ecKey)}, expectErr: "invalid signing key: *ecdsa.PrivateKey is not an ed25519 key"},
+	}
+	for _, tt := range tests {
+		_, err := newPayloadSigner(&tt.config)
+		if (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
+
+func TestHTTPSenderSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	public, private, _ := ed25519.GenerateKey(cryptorand.Reader)
+	var body []byte
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		header = r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	senders, err := newSenders(&Config{NotifyUrl: srv.URL, SigningKey: testPEM(t, private), SigningKeyId: "2024-01"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := deliverTo(senders[0], Notification{Body: []byte(`{"a":1}`), ContentType: "application/json"})
+	if !result.Success || header.Get(keyIdHeader) != "2024-01" || header.Get(timestampHeader) != "1700000000" || header.Get(nonceHeader) == "" {
+		t.Fatalf("unexpected delivery %+v with headers %v", result, header)
+	}
+
+	tampered := header.Clone()
+	tampered.Set(nonceHeader, "replayed")
+	retimed := header.Clone()
+	retimed.Set(timestampHeader, "1700000060")
+	tests := []struct {
+		name      string
+		header    http.Header
+		body      string
+		age       time.Duration
+		tolerance time.Duration
+		expectErr string
+	}{
+		{name: "valid", header: header, body: string(body)},
+		{name: "within tolerance", header: header, body: string(body), age: 4 * time.Minute},
+		{name: "too old", header: header, body: string(body), age: 6 * time.Minute, expectErr: "signature timestamp outside tolerance: 6m0s"},
+		{name: "custom tolerance", header: header, body: string(body), age: 6 * time.Minute, tolerance: 10 * time.Minute},
+		{name: "tampered body", header: header, body: `{"a":2}`, expectErr: "invalid signature"},
+		{name: "tampered nonce", header: tampered, body: string(body), expectErr: "invalid signature"},
+		{name: "tampered timestamp", header: retimed, body: string(body), expectErr: "invalid signature"},
+		{name: "unsigned", header: http.Header{}, expectErr: "missing signature timestamp or nonce"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = time.Unix(1700000000, 0).Add(tt.age)
+			err := VerifySignature(tt.header, []byte(tt.body), public, tt.tolerance)
+			if (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestHTTPSenderUnsignedStamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+
+	senders, err := newSenders(&Config{NotifyUrl: srv.URL}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := deliverTo(senders[0], Notification{Body: []byte(`{"a":1}`), ContentType: "application/json"})
+	if !result.Success || header.Get(timestampHeader) != "1700000000" || header.Get(nonceHeader) == "" || header.Get(signatureHeader) != "" {
+		t.Errorf("expected a timestamp and nonce without signature, got %+v with headers %v", result, header)
+	}
+}
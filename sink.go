@@ -0,0 +1,230 @@
+package header2post
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	sinkHTTP      = "http"
+	sinkKafka     = "kafka"
+	sinkNats      = "nats"
+	sinkAmqp      = "amqp"
+	sinkRedis     = "redis"
+	sinkMqtt      = "mqtt"
+	sinkGrpc      = "grpc"
+	sinkAws       = "aws"
+	sinkPubsub    = "pubsub"
+	sinkSmtp      = "smtp"
+	sinkPagerduty = "pagerduty"
+	sinkStream    = "stream"
+	sinkSyslog    = "syslog"
+
+	// clientName identifies the plugin to brokers that support it.
+	clientName = "header2post"
+
+	defaultSendTimeout = 10 * time.Second
+)
+
+func init() {
+	RegisterSender(sinkKafka, func(config *Config, _ string) (Sender, error) { return sinkSender(newKafkaSink(config)) })
+	RegisterSender(sinkNats, func(config *Config, _ string) (Sender, error) { return sinkSender(newNatsSink(config)) })
+	RegisterSender(sinkAmqp, func(config *Config, _ string) (Sender, error) { return sinkSender(newAmqpSink(config)) })
+	RegisterSender(sinkRedis, func(config *Config, _ string) (Sender, error) { return sinkSender(newRedisSink(config)) })
+	RegisterSender(sinkMqtt, func(config *Config, _ string) (Sender, error) { return sinkSender(newMqttSink(config)) })
+	RegisterSender(sinkGrpc, func(config *Config, _ string) (Sender, error) { return sinkSender(newGrpcSink(config)) })
+	RegisterSender(sinkAws, func(config *Config, _ string) (Sender, error) { return sinkSender(newAwsSink(config)) })
+	RegisterSender(sinkPubsub, func(config *Config, _ string) (Sender, error) { return sinkSender(newPubsubSink(config)) })
+	RegisterSender(sinkSmtp, func(config *Config, _ string) (Sender, error) { return sinkSender(newSmtpSink(config)) })
+	RegisterSender(sinkPagerduty, func(config *Config, name string) (Sender, error) { return sinkSender(newPagerdutySink(config, name)) })
+	RegisterSender(sinkStream, func(config *Config, _ string) (Sender, error) { return sinkSender(newStreamSink(config)) })
+	RegisterSender(sinkSyslog, func(config *Config, name string) (Sender, error) { return sinkSender(newSyslogSink(config, name)) })
+}
+
+// sinkSender returns the result of a sink constructor as a Sender, keeping
+// the nil sink of a failed one out of the interface.
+func sinkSender(s Sender, err error) (Sender, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// newSenders builds the senders selected by config: the sender registered
+// for config.Sink, if any, followed by an HTTPSender when NotifyUrl is set,
+// one per FanoutUrls entry and one per StatusRoutes entry.
+func newSenders(config *Config, name string) ([]Sender, error) {
+	if config.DiscoveryMode != "" && config.NotifyUrl == "" {
+		return nil, fmt.Errorf("discoverymode requires notifyurl")
+	}
+	if len(config.ChainUrls) > 0 && config.NotifyUrl == "" {
+		return nil, fmt.Errorf("chainurls requires notifyurl")
+	}
+	var out []Sender
+	if config.Sink != "" && config.Sink != sinkHTTP {
+		factory, ok := lookupSender(config.Sink)
+		if !ok {
+			return nil, fmt.Errorf("unsupported sink: %q", config.Sink)
+		}
+		s, err := factory(config, name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	if config.NotifyUrl == "" && len(config.FanoutUrls) == 0 && len(config.StatusRoutes) == 0 && config.NotifyUrlsFile == "" {
+		return out, nil
+	}
+	if err := validateTenants(config); err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	var hooks []requestHook
+	tokens, err := newOAuth2TokenSource(config, client)
+	if err != nil {
+		return nil, err
+	}
+	if tokens != nil {
+		hooks = append(hooks, tokens.authorize)
+	}
+	signer, err := newJwtSigner(config)
+	if err != nil {
+		return nil, err
+	}
+	if signer != nil {
+		if tokens != nil {
+			return nil, fmt.Errorf("jwt signing cannot be combined with tokenurl")
+		}
+		hooks = append(hooks, signer.authorize)
+	}
+	apiKey, err := newApiKeySource(config)
+	if err != nil {
+		return nil, err
+	}
+	if apiKey != nil {
+		hooks = append(hooks, apiKey.authorize)
+	}
+	payloadSigner, err := newPayloadSigner(config)
+	if err != nil {
+		return nil, err
+	}
+	hooks = append(hooks, payloadSigner.sign)
+	webhookSigner, err := newWebhookSigner(config)
+	if err != nil {
+		return nil, err
+	}
+	if webhookSigner != nil {
+		hooks = append(hooks, webhookSigner.sign)
+	}
+	if config.NotifyUrl != "" {
+		sender, err := newHTTPSender(config, config.NotifyUrl, client, hooks)
+		if err != nil {
+			return nil, err
+		}
+		if socket, requestURL, ok := splitUnixURL(config.NotifyUrl); ok {
+			if socket == "" {
+				return nil, fmt.Errorf("invalid notifyurl: %q", config.NotifyUrl)
+			}
+			sender.URL = requestURL
+			sender.target = config.NotifyUrl
+		}
+		if name, requestURL, ok := splitSRVURL(config.NotifyUrl); ok {
+			if sender.srv, err = newSRVTarget(config, name); err != nil {
+				return nil, err
+			}
+			sender.URL = requestURL
+			sender.target = config.NotifyUrl
+		}
+		if sender.pool, err = newEndpointPool(config); err != nil {
+			return nil, err
+		}
+		if sender.pool != nil && (sender.srv != nil || sender.target != "") {
+			return nil, fmt.Errorf("discoverymode cannot be combined with an srv or unix notifyurl")
+		}
+		for _, raw := range config.ChainUrls {
+			u, err := url.Parse(raw)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+				return nil, fmt.Errorf("invalid chainurls: %q", raw)
+			}
+			step, err := newHTTPSender(config, raw, client, hooks)
+			if err != nil {
+				return nil, err
+			}
+			sender.chain = append(sender.chain, step)
+		}
+		out = append(out, sender)
+	}
+	if len(config.FanoutUrls) > 0 || len(config.StatusRoutes) > 0 || config.NotifyUrlsFile != "" {
+		if _, _, ok := splitUnixURL(config.NotifyUrl); ok {
+			// the notify url client dials its socket whatever the url
+			c := *config
+			c.NotifyUrl = ""
+			if client, err = newHTTPClient(&c); err != nil {
+				return nil, err
+			}
+		}
+		seen := map[string]bool{config.NotifyUrl: true}
+		for _, raw := range config.FanoutUrls {
+			u, err := url.Parse(raw)
+			if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") || seen[raw] {
+				return nil, fmt.Errorf("invalid fanouturls: %q", raw)
+			}
+			seen[raw] = true
+			sender, err := newHTTPSender(config, raw, client, hooks)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sender)
+		}
+		routes, err := newStatusRoutes(config, client, hooks)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, routes...)
+		if config.NotifyUrlsFile != "" {
+			file, err := newUrlsFile(config, client, hooks, seen)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, file)
+		}
+	}
+	return out, nil
+}
+
+// newHTTPSender builds the sender of one notify url, sharing client and
+// hooks with the other urls.
+func newHTTPSender(config *Config, rawURL string, client HTTPDoer, hooks []requestHook) (*HTTPSender, error) {
+	sender := &HTTPSender{URL: rawURL, Client: client, UserAgent: configUserAgent(config), Compress: config.CompressNotifyBody, MaxErrorBody: config.MaxErrorBodyBytes, hooks: hooks}
+	switch method := strings.ToUpper(config.NotifyMethod); method {
+	case "", http.MethodPost, http.MethodPut, http.MethodPatch:
+		sender.Method = method
+	default:
+		return nil, fmt.Errorf("invalid notifymethod: %q", config.NotifyMethod)
+	}
+	replyCheck, err := newReplyCheck(config)
+	if err != nil {
+		return nil, err
+	}
+	sender.replyCheck = replyCheck
+	if config.NotifyAccept != "" {
+		sender.Header = http.Header{"Accept": {config.NotifyAccept}}
+	}
+	for k, v := range config.StaticNotifyHeaders {
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if k == "" {
+			return nil, fmt.Errorf("staticnotifyheaders names cannot be empty")
+		}
+		if sender.Header == nil {
+			sender.Header = http.Header{}
+		}
+		sender.Header.Set(k, v)
+	}
+	return sender, nil
+}
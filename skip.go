@@ -0,0 +1,23 @@
+package header2post
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// skip reports whether SkipHeader asks for the notification to be
+// suppressed.
+func (a *notify) skip(h http.Header) bool {
+	if a.skipHeader == "" {
+		return false
+	}
+	skip, _ := strconv.ParseBool(strings.TrimSpace(h.Get(a.skipHeader)))
+	return skip
+}
+
+func (a *notify) skipByHeader(ex *exchange) {
+	a.log.Debug("notification suppressed by skip header", "path", ex.req.URL.Path)
+	a.dropped(dropSkipHeader)
+	a.expose(ex, resultSkipped, 0)
+}
@@ -0,0 +1,75 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServeHTTPSkipHeader(t *testing.T) {
+	payload := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+	tests := []struct {
+		name       string
+		source     string
+		skip       string
+		expectSent bool
+	}{
+		{name: "response true", source: "response", skip: "true"},
+		{name: "response one", source: "response", skip: "1"},
+		{name: "response false", source: "response", skip: "false", expectSent: true},
+		{name: "response absent", source: "response", expectSent: true},
+		{name: "response garbage", source: "response", skip: "maybe", expectSent: true},
+		{name: "request true", source: "request", skip: "True"},
+		{name: "request absent", source: "request", expectSent: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			var upstream http.Header
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				upstream = r.Header.Clone()
+				if tt.source == "response" {
+					w.Header().Set("X-Notify", payload)
+					if tt.skip != "" {
+						w.Header().Set("X-Notify-Skip", tt.skip)
+					}
+				}
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:       "X-Notify",
+				NotifyUrl:          "https://example.com/notification",
+				TriggerSource:      tt.source,
+				SkipHeader:         "x-notify-skip",
+				ExposeStatusHeader: true,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := false
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				sent = true
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.source == "request" {
+				req.Header.Set("X-Notify", payload)
+				if tt.skip != "" {
+					req.Header.Set("X-Notify-Skip", tt.skip)
+				}
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if sent != tt.expectSent {
+				t.Errorf("expected sent %v, got %v", tt.expectSent, sent)
+			}
+			if !tt.expectSent && rec.Header().Get("X-Notify-Result") != resultSkipped {
+				t.Errorf("expected skipped result, got %q", rec.Header().Get("X-Notify-Result"))
+			}
+			if rec.Header().Get("X-Notify-Skip") != "" || upstream.Get("X-Notify-Skip") != "" {
+				t.Errorf("skip header not removed")
+			}
+		})
+	}
+}
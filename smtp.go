@@ -0,0 +1,154 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const defaultSmtpSubject = "Notification from header2post"
+
+// smtpSink sends each payload as a plain text email.
+type smtpSink struct {
+	server   string
+	from     string
+	to       []string
+	subject  *keyTemplate
+	body     *keyTemplate
+	username string
+	password string
+}
+
+func newSmtpSink(config *Config) (*smtpSink, error) {
+	if config.SmtpServer == "" {
+		return nil, fmt.Errorf("smtpserver cannot be empty")
+	}
+	if _, _, err := net.SplitHostPort(config.SmtpServer); err != nil {
+		return nil, fmt.Errorf("invalid smtpserver: %q", config.SmtpServer)
+	}
+	if config.SmtpFrom == "" {
+		return nil, fmt.Errorf("smtpfrom cannot be empty")
+	}
+	if len(config.SmtpTo) == 0 {
+		return nil, fmt.Errorf("smtpto cannot be empty")
+	}
+	subject := config.SmtpSubjectTemplate
+	if subject == "" {
+		subject = defaultSmtpSubject
+	}
+	subjectTmpl, err := newKeyTemplate("smtpsubjecttemplate", subject)
+	if err != nil {
+		return nil, err
+	}
+	bodyTmpl, err := newKeyTemplate("smtpbodytemplate", config.SmtpBodyTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &smtpSink{
+		server:   config.SmtpServer,
+		from:     config.SmtpFrom,
+		to:       config.SmtpTo,
+		subject:  subjectTmpl,
+		body:     bodyTmpl,
+		username: config.SmtpUsername,
+		password: config.SmtpPassword,
+	}, nil
+}
+
+func (s *smtpSink) Target() string {
+	return "smtp://" + s.server
+}
+
+func (s *smtpSink) Send(ctx context.Context, n Notification) error {
+	msg, err := s.message(n)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.server)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	host, _, _ := net.SplitHostPort(s.server)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", s.username, s.password, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.from); err != nil {
+		return err
+	}
+	for _, rcpt := range s.to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message renders the RFC 5322 message. The body is the body template
+// when configured, otherwise the payload as indented JSON.
+func (s *smtpSink) message(n Notification) ([]byte, error) {
+	subject, err := s.subject.render(n.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("render subject: %w", err)
+	}
+	var body string
+	if s.body != nil {
+		if body, err = s.body.render(n.Payload); err != nil {
+			return nil, fmt.Errorf("render body: %w", err)
+		}
+	} else {
+		var out bytes.Buffer
+		if json.Indent(&out, n.Body, "", "  ") != nil {
+			out.Reset()
+			out.Write(n.Body)
+		}
+		body = out.String()
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.from)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.ReplaceAll(subject, "\n", " ")))
+	fmt.Fprintf(&msg, "Date: %s\r\n", timeNow().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%s@%s>\r\n", generateID(), clientName)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	msg.WriteString("\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return msg.Bytes(), nil
+}
@@ -0,0 +1,116 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSmtpServer accepts one session and returns the received DATA.
+func fakeSmtpServer(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		var envelope []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"):
+				fmt.Fprint(conn, "250-localhost\r\n250 8BITMIME\r\n")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				envelope = append(envelope, strings.TrimSpace(line))
+				fmt.Fprint(conn, "250 OK\r\n")
+			case cmd == "DATA":
+				fmt.Fprint(conn, "354 go ahead\r\n")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				received <- strings.Join(envelope, "\n") + "\n" + data.String()
+				fmt.Fprint(conn, "250 queued\r\n")
+			case cmd == "QUIT":
+				fmt.Fprint(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestSmtpSink(t *testing.T) {
+	addr, received := fakeSmtpServer(t)
+	s, err := newSmtpSink(&Config{
+		SmtpServer:          addr,
+		SmtpFrom:            "gateway@example.com",
+		SmtpTo:              []string{"ops@example.com", "dev@example.com"},
+		SmtpSubjectTemplate: "Order {{.Payload.id}}",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	data := []byte(`{"id":7}`)
+	if err := s.Send(ctx, Notification{Body: data, Payload: data}); err != nil {
+		t.Fatal(err)
+	}
+	msg := <-received
+	for _, want := range []string{
+		"MAIL FROM:<gateway@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<dev@example.com>",
+		"Subject: Order 7\r\n",
+		"To: ops@example.com, dev@example.com\r\n",
+		"{\r\n  \"id\": 7\r\n}",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("message missing %q:\n%s", want, msg)
+		}
+	}
+}
+
+func TestNewSmtpSink(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "missing server", expectErr: "smtpserver cannot be empty"},
+		{name: "invalid server", config: Config{SmtpServer: "mail"}, expectErr: `invalid smtpserver: "mail"`},
+		{name: "missing from", config: Config{SmtpServer: "mail:25"}, expectErr: "smtpfrom cannot be empty"},
+		{name: "missing to", config: Config{SmtpServer: "mail:25", SmtpFrom: "a@b"}, expectErr: "smtpto cannot be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSmtpSink(&tt.config)
+			if err == nil || err.Error() != tt.expectErr {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
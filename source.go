@@ -0,0 +1,18 @@
+package header2post
+
+// notifySource describes where a notification was emitted, set under
+// SourceField.
+func (a *notify) notifySource(ex *exchange) map[string]any {
+	source := map[string]any{
+		"middleware": a.name,
+		"host":       ex.req.Host,
+		"path":       ex.req.URL.Path,
+	}
+	if a.sourceRouter != "" {
+		source["router"] = a.sourceRouter
+	}
+	if a.sourceService != "" {
+		source["service"] = a.sourceService
+	}
+	return source
+}
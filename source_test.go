@@ -0,0 +1,48 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPSourceField(t *testing.T) {
+	captureLog(t)
+	tests := []struct {
+		name   string
+		config Config
+		expect string
+	}{
+		{name: "disabled", expect: `{"id":"e1"}`},
+		{name: "request", config: Config{SourceField: "source"}, expect: `{"id":"e1","source":{"host":"shop.example.com","middleware":"orders-notify","path":"/orders"}}`},
+		{name: "route", config: Config{SourceField: "source", SourceRouter: "orders@docker", SourceService: "orders-svc"}, expect: `{"id":"e1","source":{"host":"shop.example.com","middleware":"orders-notify","path":"/orders","router":"orders@docker","service":"orders-svc"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "orders-notify")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "https://shop.example.com/orders", nil))
+			if body != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, body)
+			}
+		})
+	}
+}
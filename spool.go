@@ -0,0 +1,320 @@
+package header2post
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSpoolReplayRate = 10
+	// spoolCommitBatch is how many replayed notifications are removed
+	// from the spool file in one rewrite.
+	spoolCommitBatch = 100
+)
+
+// spool keeps the notifications NotifyUrl could not take in a JSON lines
+// file and replays them in order once the endpoint is back.
+type spool struct {
+	mu      sync.Mutex
+	path    string
+	pending int
+
+	sender    *HTTPSender
+	interval  time.Duration
+	timeout   time.Duration
+	log       *slog.Logger
+	delivered func(deliveryResult)
+	replaying atomic.Bool
+}
+
+// spooledNotification is one line of the spool file. Forwarded request
+// headers are left out so that credentials never reach the disk.
+type spooledNotification struct {
+	Body        []byte      `json:"body"`
+	ContentType string      `json:"content_type"`
+	Header      http.Header `json:"header,omitempty"`
+	Payload     []byte      `json:"payload,omitempty"`
+	EventIDs    []string    `json:"event_ids,omitempty"`
+	Event       string      `json:"event,omitempty"`
+	SpooledAt   time.Time   `json:"spooled_at"`
+	Expires     *time.Time  `json:"expires,omitempty"`
+	Tenant      string      `json:"tenant,omitempty"`
+}
+
+// newSpool returns nil unless SpoolFile is set. Notifications left in the
+// file by a previous run are replayed like new ones.
+func newSpool(config *Config, sender *HTTPSender) (*spool, error) {
+	if config.SpoolFile == "" {
+		return nil, nil
+	}
+	if sender == nil {
+		return nil, fmt.Errorf("spoolfile requires notifyurl")
+	}
+	if config.SpoolReplayRate < 0 {
+		return nil, fmt.Errorf("spoolreplayrate cannot be negative")
+	}
+	rate := config.SpoolReplayRate
+	if rate == 0 {
+		rate = defaultSpoolReplayRate
+	}
+	s := &spool{path: config.SpoolFile, sender: sender, interval: time.Duration(float64(time.Second) / rate)}
+	err := s.locked(func() error {
+		_, lines, err := s.read()
+		if err != nil {
+			return err
+		}
+		// rewrite the file so that a line cut short by a crash does not
+		// swallow the next one
+		return s.replace(lines)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open spoolfile: %w", err)
+	}
+	return s, nil
+}
+
+// spoolable reports whether a failed delivery is worth replaying: the
+// receiver was unreachable or overloaded rather than rejecting it.
+func spoolable(r deliveryResult) bool {
+	return !r.Success && (r.Status == 0 || r.Status >= 500 || r.Status == http.StatusRequestTimeout || r.Status == http.StatusTooManyRequests)
+}
+
+// add appends n to the spool file.
+func (s *spool) add(n Notification) error {
+	e := spooledNotification{
+		Body:        n.Body,
+		ContentType: n.ContentType,
+		Header:      n.Header,
+		Payload:     n.Payload,
+		EventIDs:    n.EventIDs,
+		Event:       n.event,
+		SpooledAt:   timeNow().UTC(),
+		Tenant:      n.tenant,
+	}
+	if !n.expires.IsZero() {
+		e.Expires = &n.expires
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.locked(func() error {
+		if err := s.write(append(line, '\n'), os.O_APPEND); err != nil {
+			return err
+		}
+		s.pending++
+		return nil
+	})
+}
+
+// depth returns the number of spooled notifications.
+func (s *spool) depth() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// replay delivers the spooled notifications in the background, one per
+// interval, unless a replay is already running. It reads the file once
+// per pass and removes delivered notifications spoolCommitBatch at a
+// time, so a crash or a failure keeps at most that many delivered ones
+// and the following ones spooled. The replay stops at the first failure
+// or when ctx is done.
+func (s *spool) replay(ctx context.Context) {
+	if s.depth() == 0 || !s.replaying.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.replaying.Store(false)
+		replayed := 0
+		for {
+			var entries []spooledNotification
+			var lines [][]byte
+			err := s.locked(func() (err error) {
+				entries, lines, err = s.read()
+				return err
+			})
+			if err != nil {
+				s.log.Error("spool read error", "error", err)
+				return
+			}
+			if len(lines) == 0 {
+				s.log.Info("spool replayed", "count", replayed)
+				return
+			}
+			var done [][]byte
+			for i, e := range entries {
+				n := e.notification()
+				if n.expired() {
+					s.log.Warn("spooled notification expired", "expired_at", n.expires, "event_ids", n.EventIDs)
+					if s.delivered != nil {
+						s.delivered(deliveryResult{Target: s.sender.Target(), Error: errNotificationExpired.Error()})
+					}
+				} else {
+					if replayed > 0 && sleep(ctx, s.interval) != nil {
+						s.commit(done)
+						s.log.Info("spool replay stopped", "replayed", replayed, "pending", s.depth())
+						return
+					}
+					result := deliverRetry(ctx, s.sender, n, nil, s.timeout, s.log)
+					if s.delivered != nil {
+						s.delivered(result)
+					}
+					if !result.Success {
+						s.commit(done)
+						s.log.Warn("spool replay interrupted", "replayed", replayed, "pending", s.depth(), "error", result.Error)
+						return
+					}
+					replayed++
+				}
+				done = append(done, lines[i])
+				if len(done) == spoolCommitBatch {
+					if !s.commit(done) {
+						return
+					}
+					done = nil
+				}
+			}
+			if !s.commit(done) {
+				return
+			}
+		}
+	}()
+}
+
+func (e spooledNotification) notification() Notification {
+	n := Notification{Body: e.Body, ContentType: e.ContentType, Header: e.Header, Payload: e.Payload, EventIDs: e.EventIDs, event: e.Event, tenant: e.Tenant}
+	if e.Expires != nil {
+		n.expires = *e.Expires
+	}
+	return n
+}
+
+// commit removes done, the replayed lines, from the spool file, logging
+// a failure. Lines another instance sharing the file has replayed
+// already are gone, so each is removed at most once.
+func (s *spool) commit(done [][]byte) bool {
+	if len(done) == 0 {
+		return true
+	}
+	err := s.locked(func() error {
+		_, lines, err := s.read()
+		if err != nil {
+			return err
+		}
+		remove := make(map[string]int, len(done))
+		for _, line := range done {
+			remove[string(line)]++
+		}
+		kept := lines[:0]
+		for _, line := range lines {
+			if remove[string(line)] > 0 {
+				remove[string(line)]--
+				continue
+			}
+			kept = append(kept, line)
+		}
+		return s.replace(kept)
+	})
+	if err != nil {
+		s.log.Error("spool write error", "error", err)
+		return false
+	}
+	return true
+}
+
+// locked runs fn holding an exclusive lock on the lock file next to the
+// spool file, so that instances sharing SpoolFile, in this process or
+// another, never interleave their updates.
+func (s *spool) locked(fn func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	// closing f releases the lock
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// read parses the spool file, creating it when missing, and updates the
+// pending count. Lines that cannot be parsed, such as one cut short by a
+// crash, are skipped; lines holds the raw line of each entry.
+func (s *spool) read() (entries []spooledNotification, lines [][]byte, err error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		s.pending = 0
+		return nil, nil, s.write(nil, os.O_APPEND)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var e spooledNotification
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			entries = append(entries, e)
+			lines = append(lines, bytes.Clone(scanner.Bytes()))
+		}
+	}
+	s.pending = len(entries)
+	return entries, lines, nil
+}
+
+// replace swaps the spool file for one holding lines, going through a
+// temporary file so that a crash leaves either version whole, and
+// updates the pending count.
+func (s *spool) replace(lines [][]byte) error {
+	var buf bytes.Buffer
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	s.pending = len(lines)
+	return nil
+}
+
+func (s *spool) write(data []byte, flag int) error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|flag, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
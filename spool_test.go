@@ -0,0 +1,298 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitReplay waits for a background spool replay to finish.
+func waitReplay(t *testing.T, s *spool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for s.replaying.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("spool replay did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServeHTTPSpool(t *testing.T) {
+	captureLog(t)
+	var slept []time.Duration
+	var sleptMu sync.Mutex
+	sleep = func(ctx context.Context, d time.Duration) error {
+		sleptMu.Lock()
+		slept = append(slept, d)
+		sleptMu.Unlock()
+		return ctx.Err()
+	}
+	defer func() { sleep = sleepContext }()
+
+	var mu sync.Mutex
+	var received []string
+	statuses := map[string]int{`{"id":1}`: 503, `{"id":2}`: 400, `{"id":3}`: 503}
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, string(b))
+		status, ok := statuses[string(b)]
+		if !ok {
+			status = http.StatusAccepted
+		}
+		delete(statuses, string(b))
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", r.Header.Get("X-Payload"))
+	})
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:    "X-Notify",
+		NotifyUrl:       receiver.URL,
+		SpoolFile:       path,
+		SpoolReplayRate: 4,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := handler.(*notify).spool
+	for _, payload := range []string{`{"id":1}`, `{"id":2}`, `{"id":3}`} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Payload", base64.StdEncoding.EncodeToString([]byte(payload)))
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if s.depth() != 2 {
+		t.Fatalf("expected 2 spooled notifications, got %d", s.depth())
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != 2 || !strings.Contains(string(data), `"content_type":"application/json"`) {
+		t.Errorf("unexpected spool file %s", data)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Payload", base64.StdEncoding.EncodeToString([]byte(`{"id":4}`)))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	waitReplay(t, s)
+
+	expect := []string{`{"id":1}`, `{"id":2}`, `{"id":3}`, `{"id":4}`, `{"id":1}`, `{"id":3}`}
+	if strings.Join(received, " ") != strings.Join(expect, " ") {
+		t.Errorf("expected deliveries %v, got %v", expect, received)
+	}
+	if len(slept) != 1 || slept[0] != 250*time.Millisecond {
+		t.Errorf("expected one 250ms pause, got %v", slept)
+	}
+	if data, _ := os.ReadFile(path); s.depth() != 0 || len(data) != 0 {
+		t.Errorf("spool not emptied: %d %s", s.depth(), data)
+	}
+}
+
+func TestSpoolReplayInterrupted(t *testing.T) {
+	log := captureLog(t)
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	defer func() { sleep = sleepContext }()
+
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+		if string(b) == "b" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	// a line cut short by a crash is skipped
+	if err := os.WriteFile(path, []byte(`{"body":"YQ==","content_type":"text/plain"}`+"\n"+`{"body":`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s, err := newSpool(&Config{SpoolFile: path}, &HTTPSender{URL: receiver.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.log, s.timeout = newLogger("header2post", slog.LevelInfo, stdWriter{&logStdout}), time.Second
+	if s.depth() != 1 {
+		t.Fatalf("expected 1 notification from the previous run, got %d", s.depth())
+	}
+	for _, body := range []string{"b", "c"} {
+		if err := s.add(Notification{Body: []byte(body), ContentType: "text/plain"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.replay(context.Background())
+	waitReplay(t, s)
+
+	if strings.Join(received, " ") != "a b" || s.depth() != 2 {
+		t.Errorf("unexpected replay %v, %d pending", received, s.depth())
+	}
+	entries, _, err := s.read()
+	if err != nil || len(entries) != 2 || string(entries[0].Body) != "b" || string(entries[1].Body) != "c" {
+		t.Errorf("unexpected spool %+v %v", entries, err)
+	}
+	if !strings.Contains(log.String(), "spool replay interrupted") {
+		t.Errorf("missing interruption record in %s", log)
+	}
+}
+
+func TestSpoolReplaySharedFile(t *testing.T) {
+	captureLog(t)
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	defer func() { sleep = sleepContext }()
+
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	var other *spool
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+		// the notification stays spooled until it is delivered
+		if data, _ := os.ReadFile(path); !strings.Contains(string(data), base64.StdEncoding.EncodeToString(b)) {
+			t.Errorf("%s left the spool before delivery: %s", b, data)
+		}
+		if string(b) == "a" {
+			if err := other.add(Notification{Body: []byte("c")}); err != nil {
+				t.Error(err)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	s, err := newSpool(&Config{SpoolFile: path}, &HTTPSender{URL: receiver.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other, err = newSpool(&Config{SpoolFile: path}, &HTTPSender{URL: receiver.URL}); err != nil {
+		t.Fatal(err)
+	}
+	s.log, s.timeout = discardLogger(), time.Second
+	for _, body := range []string{"a", "b"} {
+		if err := s.add(Notification{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.replay(context.Background())
+	waitReplay(t, s)
+
+	if strings.Join(received, " ") != "a b c" {
+		t.Errorf("unexpected replay %v", received)
+	}
+	if data, _ := os.ReadFile(path); s.depth() != 0 || len(data) != 0 {
+		t.Errorf("spool not emptied: %d %s", s.depth(), data)
+	}
+}
+
+func TestSpoolReplayBatches(t *testing.T) {
+	captureLog(t)
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	defer func() { sleep = sleepContext }()
+
+	total := spoolCommitBatch + 10
+	path := filepath.Join(t.TempDir(), "spool.jsonl")
+	var spooled []int
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		if received == 1 || received == spoolCommitBatch || received == spoolCommitBatch+1 {
+			data, _ := os.ReadFile(path)
+			spooled = append(spooled, bytes.Count(data, []byte("\n")))
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	s, err := newSpool(&Config{SpoolFile: path}, &HTTPSender{URL: receiver.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.log, s.timeout = discardLogger(), time.Second
+	for i := 0; i < total; i++ {
+		if err := s.add(Notification{Body: []byte(strconv.Itoa(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.replay(context.Background())
+	waitReplay(t, s)
+
+	// the file is rewritten once per batch, not once per notification
+	expect := []int{total, total, total - spoolCommitBatch}
+	if received != total || !slices.Equal(spooled, expect) || s.depth() != 0 {
+		t.Errorf("expected %d replayed and spool sizes %v, got %d and %v, %d pending", total, expect, received, spooled, s.depth())
+	}
+}
+
+func TestSpoolReplayCanceled(t *testing.T) {
+	captureLog(t)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	s, err := newSpool(&Config{SpoolFile: filepath.Join(t.TempDir(), "spool.jsonl"), SpoolReplayRate: 0.001}, &HTTPSender{URL: receiver.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.log, s.timeout = discardLogger(), time.Second
+	received := make(chan deliveryResult, 2)
+	s.delivered = func(r deliveryResult) { received <- r }
+	for _, body := range []string{"a", "b"} {
+		if err := s.add(Notification{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.replay(ctx)
+	select {
+	case r := <-received:
+		if !r.Success {
+			t.Fatalf("unexpected result %+v", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("nothing replayed")
+	}
+	cancel()
+	waitReplay(t, s)
+
+	if len(received) != 0 || s.depth() != 1 {
+		t.Errorf("expected 1 delivered and 1 pending, got %d and %d", 1+len(received), s.depth())
+	}
+}
+
+func TestNewSpoolErrors(t *testing.T) {
+	sender := &HTTPSender{URL: "https://example.com"}
+	tests := []struct {
+		config Config
+		sender *HTTPSender
+		expect string
+	}{
+		{config: Config{SpoolFile: "spool.jsonl"}, expect: "spoolfile requires notifyurl"},
+		{config: Config{SpoolFile: "spool.jsonl", SpoolReplayRate: -1}, sender: sender, expect: "spoolreplayrate cannot be negative"},
+		{config: Config{SpoolFile: "/nonexistent/dir/spool.jsonl"}, sender: sender, expect: "open spoolfile: open /nonexistent/dir/spool.jsonl.lock: no such file or directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expect, func(t *testing.T) {
+			if _, err := newSpool(&tt.config, tt.sender); err == nil || err.Error() != tt.expect {
+				t.Errorf("expected error %q, got %v", tt.expect, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// srvTarget picks the notify host of an srv:// NotifyUrl from its SRV
+// records on every delivery.
+type srvTarget struct {
+	name      string
+	lookupSRV func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// splitSRVURL turns an srv://_service._proto.name/path NotifyUrl, or
+// srv+https:// for TLS, into the record name and the http url requested
+// once a host is picked.
+func splitSRVURL(raw string) (name, requestURL string, ok bool) {
+	scheme := "http"
+	rest, found := strings.CutPrefix(raw, "srv://")
+	if !found {
+		if rest, found = strings.CutPrefix(raw, "srv+https://"); !found {
+			return "", "", false
+		}
+		scheme = "https"
+	}
+	name, path, _ := strings.Cut(rest, "/")
+	return name, scheme + "://" + name + "/" + path, true
+}
+
+func newSRVTarget(config *Config, name string) (*srvTarget, error) {
+	if name == "" || strings.ContainsAny(name, ":@?#") {
+		return nil, fmt.Errorf("invalid notifyurl: %q", config.NotifyUrl)
+	}
+	dialTimeout, err := parseDuration("dialtimeout", config.DialTimeout, defaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := newResolver(config, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &srvTarget{name: name, lookupSRV: resolver.LookupSRV}, nil
+}
+
+// route points req at the first record, which the resolver orders by
+// priority and randomizes by weight.
+func (t *srvTarget) route(ctx context.Context, req *http.Request) error {
+	_, records, err := t.lookupSRV(ctx, "", "", t.name)
+	if err != nil {
+		return fmt.Errorf("resolve srv error: %w", err)
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("resolve srv error: no records for %s", t.name)
+	}
+	host := strings.TrimSuffix(records[0].Target, ".")
+	req.URL.Host = net.JoinHostPort(host, strconv.Itoa(int(records[0].Port)))
+	req.Host = req.URL.Host
+	return nil
+}
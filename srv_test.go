@@ -0,0 +1,104 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSplitSRVURL(t *testing.T) {
+	tests := []struct {
+		raw        string
+		expectName string
+		expectURL  string
+		expectOK   bool
+	}{
+		{raw: "srv://_notify._tcp.service.consul/events", expectName: "_notify._tcp.service.consul", expectURL: "http://_notify._tcp.service.consul/events", expectOK: true},
+		{raw: "srv+https://_notify._tcp.service.consul", expectName: "_notify._tcp.service.consul", expectURL: "https://_notify._tcp.service.consul/", expectOK: true},
+		{raw: "https://example.com/events"},
+		{raw: "unix:///run/notify.sock:/events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			name, requestURL, ok := splitSRVURL(tt.raw)
+			if name != tt.expectName || requestURL != tt.expectURL || ok != tt.expectOK {
+				t.Errorf("expected %q %q %v, got %q %q %v", tt.expectName, tt.expectURL, tt.expectOK, name, requestURL, ok)
+			}
+		})
+	}
+}
+
+func TestNewSRVTargetErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "empty name", config: Config{NotifyUrl: "srv:///events"}, err: `invalid notifyurl: "srv:///events"`},
+		{name: "port", config: Config{NotifyUrl: "srv://_notify._tcp.example.com:8080/events"}, err: `invalid notifyurl: "srv://_notify._tcp.example.com:8080/events"`},
+		{name: "health check", config: Config{NotifyUrl: "srv://_notify._tcp.example.com/events", HealthCheckInterval: "10s"}, err: "healthcheckinterval cannot be combined with an srv notifyurl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPSRVNotifyUrl(t *testing.T) {
+	logs := captureLog(t)
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "srv://_notify._tcp.example.com/events"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := handler.(*notify).notifySender()
+	if sender == nil || sender.srv == nil {
+		t.Fatal("expected the srv notify url sender")
+	}
+	var lookupErr error
+	sender.srv.lookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		if name != "_notify._tcp.example.com" {
+			t.Errorf("unexpected srv name %q", name)
+		}
+		return "", []*net.SRV{{Target: u.Hostname() + ".", Port: uint16(port), Priority: 10, Weight: 5}}, lookupErr
+	}
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if gotPath != "/events" {
+		t.Errorf("expected a delivery to /events, got %q", gotPath)
+	}
+
+	gotPath = ""
+	lookupErr = errors.New("no such host")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if gotPath != "" {
+		t.Errorf("expected no delivery, got %q", gotPath)
+	}
+	if !strings.Contains(logs.String(), "resolve srv error: no such host") {
+		t.Errorf("expected the resolve error in the report, got %s", logs.String())
+	}
+}
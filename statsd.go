@@ -0,0 +1,69 @@
+package header2post
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const defaultStatsdPrefix = "header2post."
+
+// statsdClient sends delivery metrics as StatsD lines with DogStatsD tags.
+// Writes are fire and forget; a missing agent never affects delivery.
+type statsdClient struct {
+	conn   net.Conn
+	prefix string
+	tags   []string
+}
+
+func newStatsdClient(config *Config) (*statsdClient, error) {
+	if config.StatsdAddress == "" {
+		return nil, nil
+	}
+	conn, err := net.Dial("udp", config.StatsdAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid statsdaddress: %q", config.StatsdAddress)
+	}
+	c := &statsdClient{conn: conn, prefix: config.StatsdPrefix}
+	if c.prefix == "" {
+		c.prefix = defaultStatsdPrefix
+	}
+	for _, tag := range config.StatsdTags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			c.tags = append(c.tags, statsdEscape(tag))
+		}
+	}
+	return c, nil
+}
+
+// statsdEscape replaces the characters that delimit a StatsD line.
+var statsdEscape = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_").Replace
+
+func (c *statsdClient) send(name, value, kind string, tags ...string) {
+	line := c.prefix + name + ":" + value + "|" + kind
+	all := append([]string{}, c.tags...)
+	for i := 0; i+1 < len(tags); i += 2 {
+		all = append(all, tags[i]+":"+statsdEscape(tags[i+1]))
+	}
+	if len(all) > 0 {
+		line += "|#" + strings.Join(all, ",")
+	}
+	c.conn.Write([]byte(line))
+}
+
+func (c *statsdClient) delivery(middleware string, r deliveryResult) {
+	status := "success"
+	if !r.Success {
+		status = "failure"
+	}
+	c.send("notifications.sent", "1", "c", "middleware", middleware, "target", r.Target, "status", status)
+	c.send("delivery.duration", strconv.FormatInt(r.DurationMs, 10), "ms", "middleware", middleware, "target", r.Target)
+	if r.Retries > 0 {
+		c.send("retries", strconv.Itoa(r.Retries), "c", "middleware", middleware, "target", r.Target)
+	}
+}
+
+func (c *statsdClient) dropped(middleware, reason string) {
+	c.send("dropped", "1", "c", "middleware", middleware, "reason", reason)
+}
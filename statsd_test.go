@@ -0,0 +1,53 @@
+package header2post
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsdClient(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	c, err := newStatsdClient(&Config{StatsdAddress: pc.LocalAddr().String(), StatsdTags: []string{"env:prod", " "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.delivery("mw", deliveryResult{Target: "http://a|b", DurationMs: 12, Retries: 1})
+	c.dropped("mw", dropSampled)
+
+	expect := []string{
+		"header2post.notifications.sent:1|c|#env:prod,middleware:mw,target:http://a_b,status:failure",
+		"header2post.delivery.duration:12|ms|#env:prod,middleware:mw,target:http://a_b",
+		"header2post.retries:1|c|#env:prod,middleware:mw,target:http://a_b",
+		"header2post.dropped:1|c|#env:prod,middleware:mw,reason:sampled",
+	}
+	buf := make([]byte, 512)
+	for _, line := range expect {
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != line {
+			t.Errorf("expected %q, got %q", line, got)
+		}
+	}
+}
+
+func TestNewStatsdClient(t *testing.T) {
+	if c, err := newStatsdClient(&Config{}); c != nil || err != nil {
+		t.Errorf("expected no client, got %v %v", c, err)
+	}
+	if _, err := newStatsdClient(&Config{StatsdAddress: "no-port"}); err == nil || err.Error() != `invalid statsdaddress: "no-port"` {
+		t.Errorf("unexpected error %v", err)
+	}
+	c, err := newStatsdClient(&Config{StatsdAddress: "127.0.0.1:8125", StatsdPrefix: "gw."})
+	if err != nil || c.prefix != "gw." {
+		t.Errorf("unexpected client %v %v", c, err)
+	}
+}
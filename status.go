@@ -0,0 +1,48 @@
+package header2post
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	notifyResultHeader  = "X-Notify-Result"
+	notifyLatencyHeader = "X-Notify-Latency"
+
+	notifyUpstreamLatencyHeader = "X-Notify-Upstream-Latency"
+
+	resultDelivered = "delivered"
+	resultFailed    = "failed"
+	resultSkipped   = "skipped"
+	resultQueued    = "queued"
+)
+
+// expose records the notification outcome on ex and reports it on the
+// client response when ExposeStatusHeader is set. latency is omitted when
+// zero.
+func (a *notify) expose(ex *exchange, result string, latency time.Duration) {
+	ex.result = result
+	if !a.exposeStatus {
+		return
+	}
+	ex.clientHeader.Set(notifyResultHeader, result)
+	if latency > 0 {
+		ex.clientHeader.Set(notifyLatencyHeader, strconv.FormatInt(latency.Milliseconds(), 10)+"ms")
+	}
+	if ex.upstream > 0 {
+		ex.clientHeader.Set(notifyUpstreamLatencyHeader, strconv.FormatInt(ex.upstream.Milliseconds(), 10)+"ms")
+	}
+}
+
+// timing describes how long the request of ex took so far, set under
+// TimingField.
+func (a *notify) timing(ex *exchange) map[string]any {
+	timing := map[string]any{}
+	if !ex.received.IsZero() {
+		timing["middleware_ms"] = timeNow().Sub(ex.received).Milliseconds()
+	}
+	if ex.upstream > 0 {
+		timing["upstream_ms"] = ex.upstream.Milliseconds()
+	}
+	return timing
+}
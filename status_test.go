@@ -0,0 +1,88 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPExposeStatusHeader(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time {
+		now = now.Add(5 * time.Millisecond)
+		return now
+	}
+	defer func() { timeNow = time.Now }()
+	valid := base64.StdEncoding.EncodeToString([]byte(`{"id":1}`))
+
+	tests := []struct {
+		name          string
+		config        Config
+		payload       string
+		status        int
+		expectResult  string
+		expectLatency bool
+	}{
+		{name: "delivered", config: Config{ExposeStatusHeader: true}, payload: valid, status: http.StatusAccepted, expectResult: resultDelivered, expectLatency: true},
+		{name: "failed", config: Config{ExposeStatusHeader: true}, payload: valid, status: http.StatusBadGateway, expectResult: resultFailed, expectLatency: true},
+		{name: "decode error", config: Config{ExposeStatusHeader: true}, payload: "%%%", expectResult: resultFailed},
+		{name: "queued", config: Config{ExposeStatusHeader: true, BatchMaxWait: "1h"}, payload: valid, expectResult: resultQueued},
+		{name: "disabled", payload: valid, status: http.StatusAccepted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", tt.payload)
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				return &http.Response{StatusCode: tt.status, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if got := w.Header().Get(notifyResultHeader); got != tt.expectResult {
+				t.Errorf("expected result %q, got %q", tt.expectResult, got)
+			}
+			if got := w.Header().Get(notifyLatencyHeader); (got != "") != tt.expectLatency || (got != "" && !strings.HasSuffix(got, "ms")) {
+				t.Errorf("unexpected latency %q", got)
+			}
+		})
+	}
+}
+
+func TestServeHTTPExposeStatusHeaderSampled(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:       "X-Notify",
+		NotifyUrl:          "https://example.com/notification",
+		SampleRate:         ptr(0.5),
+		ExposeStatusHeader: true,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	randFloat64 = func() float64 { return 0.9 }
+	defer func() { randFloat64 = rand.Float64 }()
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := w.Header().Get(notifyResultHeader); got != resultSkipped {
+		t.Errorf("expected result %q, got %q", resultSkipped, got)
+	}
+}
@@ -0,0 +1,164 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// streamSink writes each payload as one NDJSON line to the body of a single
+// long-lived POST to StreamUrl. The stream is opened on the first send and
+// reopened when the receiver ends it or a write fails.
+type streamSink struct {
+	url       string
+	client    *http.Client
+	userAgent string
+
+	mu   sync.Mutex
+	conn *streamConn
+}
+
+// streamConn is one open streaming request; done is closed once the
+// request has finished.
+type streamConn struct {
+	w    *io.PipeWriter
+	done chan struct{}
+}
+
+func newStreamSink(config *Config) (*streamSink, error) {
+	if config.StreamUrl == "" {
+		return nil, fmt.Errorf("streamurl cannot be empty")
+	}
+	u, err := url.Parse(config.StreamUrl)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid streamurl: %q", config.StreamUrl)
+	}
+	// the notify url may be a unix socket the stream must not dial
+	clientConfig := *config
+	clientConfig.NotifyUrl = ""
+	client, err := newHTTPClient(&clientConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &streamSink{url: config.StreamUrl, client: client, userAgent: configUserAgent(config)}, nil
+}
+
+func (s *streamSink) Target() string {
+	return s.url
+}
+
+func (s *streamSink) Send(ctx context.Context, n Notification) error {
+	line, err := streamLine(n.Body)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a write to a stream the receiver has just closed fails; retry it
+	// once on a fresh stream
+	for attempt := 0; ; attempt++ {
+		c := s.stream()
+		err = c.write(ctx, line)
+		if err == nil {
+			return nil
+		}
+		s.conn = nil
+		if attempt > 0 || ctx.Err() != nil {
+			return fmt.Errorf("stream write: %w", err)
+		}
+	}
+}
+
+// stream returns the open stream, opening a new one when there is none or
+// the previous request has finished. The caller holds s.mu.
+func (s *streamSink) stream() *streamConn {
+	if s.conn != nil {
+		select {
+		case <-s.conn.done:
+		default:
+			return s.conn
+		}
+	}
+	pr, pw := io.Pipe()
+	c := &streamConn{w: pw, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		pr.CloseWithError(s.post(pr))
+	}()
+	s.conn = c
+	return c
+}
+
+// post runs the streaming request until the receiver responds or the body
+// is closed, returning why the stream ended.
+func (s *streamSink) post(body io.Reader) error {
+	req, err := http.NewRequest(http.MethodPost, s.url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("User-Agent", s.userAgent)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return fmt.Errorf("stream closed: http status %d", resp.StatusCode)
+}
+
+// write writes line to the stream, aborting the stream when ctx is done
+// before the receiver has read it.
+func (c *streamConn) write(ctx context.Context, line []byte) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.w.Write(line)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		if err != nil {
+			c.w.CloseWithError(err)
+		}
+		return err
+	case <-ctx.Done():
+		c.w.CloseWithError(ctx.Err())
+		<-errc
+		return ctx.Err()
+	}
+}
+
+// close ends the open stream, if any, with a clean end of body.
+func (s *streamSink) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		s.conn.w.Close()
+		<-s.conn.done
+		s.conn = nil
+	}
+}
+
+// streamLine renders body as one NDJSON line: JSON is compacted, anything
+// else is written as a JSON string.
+func streamLine(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if json.Valid(body) {
+		if err := json.Compact(&buf, body); err != nil {
+			return nil, err
+		}
+	} else {
+		b, err := json.Marshal(string(body))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(b)
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
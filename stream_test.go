@@ -0,0 +1,127 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStreamLine(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		expect string
+	}{
+		{name: "json", body: "{\n  \"a\": 1\n}", expect: "{\"a\":1}\n"},
+		{name: "text", body: "order\nfailed", expect: "\"order\\nfailed\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := streamLine([]byte(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(line) != tt.expect {
+				t.Errorf("expected %q, got %q", tt.expect, line)
+			}
+		})
+	}
+}
+
+func TestNewStreamSinkErrors(t *testing.T) {
+	tests := []struct {
+		url       string
+		expectErr string
+	}{
+		{url: "", expectErr: "streamurl cannot be empty"},
+		{url: "ftp://example.com", expectErr: `invalid streamurl: "ftp://example.com"`},
+		{url: "http://", expectErr: `invalid streamurl: "http://"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			_, err := newStreamSink(&Config{StreamUrl: tt.url})
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestStreamSink(t *testing.T) {
+	var requests atomic.Int32
+	lines := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Content-Type") != "application/x-ndjson" {
+			http.Error(w, "bad content type", http.StatusBadRequest)
+			return
+		}
+		// respond without draining the rest of the stream
+		http.NewResponseController(w).EnableFullDuplex()
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+			if scanner.Text() == `"bye"` {
+				// end the stream early, the sink has to reopen it
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	s, err := newStreamSink(&Config{StreamUrl: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	if s.Target() != srv.URL {
+		t.Errorf("expected target %q, got %q", srv.URL, s.Target())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	next := func() string {
+		select {
+		case line := <-lines:
+			return line
+		case <-ctx.Done():
+			t.Fatal("line not received")
+			return ""
+		}
+	}
+
+	for _, body := range []string{`{"a":1}`, `{"a":2}`} {
+		if err := s.Send(ctx, Notification{Body: []byte(body)}); err != nil {
+			t.Fatal(err)
+		}
+		if line := next(); line != body {
+			t.Errorf("expected line %q, got %q", body, line)
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Fatalf("expected one streaming request, got %d", got)
+	}
+
+	first := s.conn
+	if err := s.Send(ctx, Notification{Body: []byte("bye")}); err != nil {
+		t.Fatal(err)
+	}
+	next()
+	select {
+	case <-first.done:
+	case <-ctx.Done():
+		t.Fatal("stream not closed by the receiver")
+	}
+	if err := s.Send(ctx, Notification{Body: []byte(`{"a":3}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if line := next(); line != `{"a":3}` {
+		t.Errorf("expected line %q, got %q", `{"a":3}`, line)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("expected the stream to be reopened, got %d requests", got)
+	}
+}
@@ -0,0 +1,71 @@
+package header2post
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// deliverySummary counts delivery outcomes between two summary records,
+// for operators without a metrics pipeline.
+type deliverySummary struct {
+	interval time.Duration
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	retried atomic.Int64
+	drops   atomic.Int64
+}
+
+// newDeliverySummary returns nil unless SummaryInterval is set.
+func newDeliverySummary(config *Config) (*deliverySummary, error) {
+	if config.SummaryInterval == "" {
+		return nil, nil
+	}
+	interval, err := parseDuration("summaryinterval", config.SummaryInterval, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &deliverySummary{interval: interval}, nil
+}
+
+func (s *deliverySummary) delivery(_ string, r deliveryResult) {
+	if r.Success {
+		s.sent.Add(1)
+	} else {
+		s.failed.Add(1)
+	}
+	s.retried.Add(int64(r.Retries))
+}
+
+func (s *deliverySummary) dropped(_, _ string) {
+	s.drops.Add(1)
+}
+
+// run logs a summary every interval until ctx is done. queued reports the
+// notifications waiting at that time.
+func (s *deliverySummary) run(ctx context.Context, l *slog.Logger, queued func() int) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.log(l, queued())
+		}
+	}
+}
+
+// log writes the counts since the last summary and resets them.
+func (s *deliverySummary) log(l *slog.Logger, queued int) {
+	l.Info("delivery summary",
+		"interval", s.interval.String(),
+		"sent", s.sent.Swap(0),
+		"failed", s.failed.Swap(0),
+		"retried", s.retried.Swap(0),
+		"dropped", s.drops.Swap(0),
+		"queued", queued,
+	)
+}
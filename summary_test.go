@@ -0,0 +1,88 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDeliverySummary(t *testing.T) {
+	s, err := newDeliverySummary(&Config{})
+	if s != nil || err != nil {
+		t.Errorf("expected no summary, got %v, %v", s, err)
+	}
+	if _, err := newDeliverySummary(&Config{SummaryInterval: "often"}); err == nil || err.Error() != `invalid summaryinterval: "often"` {
+		t.Errorf("expected interval error, got %v", err)
+	}
+}
+
+func TestDeliverySummaryLog(t *testing.T) {
+	s := &deliverySummary{interval: time.Minute}
+	s.delivery("m", deliveryResult{Success: true})
+	s.delivery("m", deliveryResult{Success: true, Retries: 2})
+	s.delivery("m", deliveryResult{Retries: 3})
+	s.dropped("m", dropSampled)
+
+	var buf bytes.Buffer
+	l := newLogger("header2post", slog.LevelInfo, &buf)
+	s.log(l, 4)
+	s.log(l, 0)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 records, got %s", buf.String())
+	}
+	for i, expect := range []map[string]any{
+		{"sent": 2.0, "failed": 1.0, "retried": 5.0, "dropped": 1.0, "queued": 4.0},
+		{"sent": 0.0, "failed": 0.0, "retried": 0.0, "dropped": 0.0, "queued": 0.0},
+	} {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(lines[i]), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec["msg"] != "delivery summary" || rec["interval"] != "1m0s" {
+			t.Errorf("unexpected record %v", rec)
+		}
+		for k, v := range expect {
+			if rec[k] != v {
+				t.Errorf("record %d: expected %s %v, got %v", i, k, v, rec[k])
+			}
+		}
+	}
+}
+
+func TestServeHTTPDeliverySummary(t *testing.T) {
+	captureLog(t)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", SummaryInterval: "1h"}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var summary *deliverySummary
+	for _, rec := range handler.(*notify).recorders {
+		if s, ok := rec.(*deliverySummary); ok {
+			summary = s
+		}
+	}
+	if summary == nil {
+		t.Fatal("expected the summary to record deliveries")
+	}
+	if sent := summary.sent.Load(); sent != 1 {
+		t.Errorf("expected 1 sent notification, got %d", sent)
+	}
+}
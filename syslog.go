@@ -0,0 +1,180 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+const (
+	syslogRFC5424 = "rfc5424"
+	syslogRaw     = "raw"
+
+	// syslogMaxAppName is the APP-NAME length limit of RFC 5424.
+	syslogMaxAppName = 48
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// syslogSink ships payloads to a syslog collector as RFC 5424 messages, or
+// as the bare payload in raw format, over udp, tcp or tls. Stream
+// transports frame RFC 5424 messages with octet counting (RFC 6587) and
+// raw payloads with a trailing newline.
+type syslogSink struct {
+	url      *url.URL
+	addr     string
+	raw      bool
+	priority int
+	hostname string
+	appName  string
+}
+
+func newSyslogSink(config *Config, name string) (*syslogSink, error) {
+	if config.SyslogUrl == "" {
+		return nil, fmt.Errorf("syslogurl cannot be empty")
+	}
+	u, err := url.Parse(config.SyslogUrl)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslogurl: %q", config.SyslogUrl)
+	}
+	var port string
+	switch u.Scheme {
+	case "udp":
+		port = "514"
+	case "tcp":
+		port = "601"
+	case "tls":
+		port = "6514"
+	default:
+		return nil, fmt.Errorf("invalid syslogurl: %q", config.SyslogUrl)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), port)
+	}
+
+	s := &syslogSink{url: u, addr: addr}
+	switch config.SyslogFormat {
+	case "", syslogRFC5424:
+	case syslogRaw:
+		s.raw = true
+	default:
+		return nil, fmt.Errorf("invalid syslogformat: %q", config.SyslogFormat)
+	}
+	facilityName, severityName := config.SyslogFacility, config.SyslogSeverity
+	if facilityName == "" {
+		facilityName = "user"
+	}
+	if severityName == "" {
+		severityName = "notice"
+	}
+	facility, ok := syslogFacilities[facilityName]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslogfacility: %q", config.SyslogFacility)
+	}
+	severity, ok := syslogSeverities[severityName]
+	if !ok {
+		return nil, fmt.Errorf("invalid syslogseverity: %q", config.SyslogSeverity)
+	}
+	s.priority = facility*8 + severity
+	if config.SyslogAppName != "" {
+		if syslogHeaderField(config.SyslogAppName, syslogMaxAppName) != config.SyslogAppName {
+			return nil, fmt.Errorf("invalid syslogappname: %q", config.SyslogAppName)
+		}
+		name = config.SyslogAppName
+	}
+	s.appName = syslogHeaderField(name, syslogMaxAppName)
+	hostname, _ := os.Hostname()
+	s.hostname = syslogHeaderField(hostname, 255)
+	return s, nil
+}
+
+func (s *syslogSink) Target() string {
+	return s.url.Scheme + "://" + s.addr
+}
+
+func (s *syslogSink) Send(ctx context.Context, n Notification) error {
+	msg, err := s.message(n.Body)
+	if err != nil {
+		return err
+	}
+	network := "udp"
+	if s.url.Scheme != "udp" {
+		network = "tcp"
+		if s.raw {
+			msg = append(msg, '\n')
+		} else {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, s.addr)
+	if err != nil {
+		return err
+	}
+	if s.url.Scheme == "tls" {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: s.url.Hostname()})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	_, err = conn.Write(msg)
+	return err
+}
+
+// message renders body, compacted when it is JSON so it stays on one
+// line, in the configured format.
+func (s *syslogSink) message(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if !s.raw {
+		// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA
+		fmt.Fprintf(&buf, "<%d>1 %s %s %s - - - ", s.priority,
+			timeNow().UTC().Format("2006-01-02T15:04:05.000000Z07:00"), s.hostname, s.appName)
+	}
+	if json.Valid(body) {
+		if err := json.Compact(&buf, body); err != nil {
+			return nil, err
+		}
+	} else {
+		buf.Write(body)
+	}
+	return buf.Bytes(), nil
+}
+
+// syslogHeaderField makes v a valid RFC 5424 header field: printable
+// US-ASCII without spaces, at most max bytes, "-" when empty.
+func syslogHeaderField(v string, max int) string {
+	b := make([]byte, 0, len(v))
+	for i := 0; i < len(v) && len(b) < max; i++ {
+		if v[i] >= 33 && v[i] <= 126 {
+			b = append(b, v[i])
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
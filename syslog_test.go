@@ -0,0 +1,146 @@
+package header2post
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNewSyslogSinkErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "empty url", expectErr: "syslogurl cannot be empty"},
+		{name: "scheme", config: Config{SyslogUrl: "http://collector"}, expectErr: `invalid syslogurl: "http://collector"`},
+		{name: "format", config: Config{SyslogUrl: "udp://collector", SyslogFormat: "json"}, expectErr: `invalid syslogformat: "json"`},
+		{name: "facility", config: Config{SyslogUrl: "udp://collector", SyslogFacility: "local9"}, expectErr: `invalid syslogfacility: "local9"`},
+		{name: "severity", config: Config{SyslogUrl: "udp://collector", SyslogSeverity: "error"}, expectErr: `invalid syslogseverity: "error"`},
+		{name: "app name", config: Config{SyslogUrl: "udp://collector", SyslogAppName: "my app"}, expectErr: `invalid syslogappname: "my app"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSyslogSink(&tt.config, "notify")
+			if err == nil || err.Error() != tt.expectErr {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestSyslogSink(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 250000000, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+	hostname, _ := os.Hostname()
+	hostname = syslogHeaderField(hostname, 255)
+	header := "<133>1 2024-05-01T12:30:00.250000Z " + hostname + " orders - - - "
+
+	tests := []struct {
+		name    string
+		network string
+		config  Config
+		body    string
+		// framed expects octet counting in front of expect
+		framed bool
+		expect string
+	}{
+		{
+			name:    "udp rfc5424",
+			network: "udp",
+			config:  Config{SyslogFacility: "local0"},
+			body:    "{\n  \"id\": 7\n}",
+			expect:  header + `{"id":7}`,
+		},
+		{
+			name:    "udp raw",
+			network: "udp",
+			config:  Config{SyslogFormat: "raw"},
+			body:    "order 7 failed",
+			expect:  "order 7 failed",
+		},
+		{
+			name:    "tcp octet counting",
+			network: "tcp",
+			config:  Config{SyslogFacility: "local0"},
+			body:    `{"id":7}`,
+			framed:  true,
+			expect:  header + `{"id":7}`,
+		},
+		{
+			name:    "tcp raw",
+			network: "tcp",
+			config:  Config{SyslogFormat: "raw"},
+			body:    `{"id":7}`,
+			expect:  "{\"id\":7}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			received := make(chan string, 1)
+			var addr string
+			if tt.network == "udp" {
+				pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer pc.Close()
+				addr = pc.LocalAddr().String()
+				go func() {
+					buf := make([]byte, 2048)
+					n, _, err := pc.ReadFrom(buf)
+					if err == nil {
+						received <- string(buf[:n])
+					}
+				}()
+			} else {
+				ln, err := net.Listen("tcp", "127.0.0.1:0")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer ln.Close()
+				addr = ln.Addr().String()
+				go func() {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					defer conn.Close()
+					b, _ := io.ReadAll(bufio.NewReader(conn))
+					received <- string(b)
+				}()
+			}
+
+			tt.config.SyslogUrl = tt.network + "://" + addr
+			s, err := newSyslogSink(&tt.config, "orders")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Target() != tt.config.SyslogUrl {
+				t.Errorf("expected target %q, got %q", tt.config.SyslogUrl, s.Target())
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := s.Send(ctx, Notification{Body: []byte(tt.body)}); err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case got := <-received:
+				if tt.framed {
+					tt.expect = strconv.Itoa(len(tt.expect)) + " " + tt.expect
+				}
+				if got != tt.expect {
+					t.Errorf("expected %q, got %q", tt.expect, got)
+				}
+			case <-ctx.Done():
+				t.Fatal("message not received")
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package header2post
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/template"
+)
+
+// keyTemplate renders short strings (message keys, topics, subjects) from
+// the decoded payload. The payload is available as .Payload.
+type keyTemplate struct {
+	tmpl *template.Template
+}
+
+func newKeyTemplate(name, text string) (*keyTemplate, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &keyTemplate{tmpl: tmpl}, nil
+}
+
+// render executes the template against data. A nil template renders the
+// empty string.
+func (k *keyTemplate) render(data []byte) (string, error) {
+	if k == nil {
+		return "", nil
+	}
+	var payload any
+	if err := json.Unmarshal(data, &payload); err != nil {
+		payload = string(data)
+	}
+	buf := getBuffer()
+	defer putBuffer(buf)
+	if err := k.tmpl.Execute(buf, map[string]any{"Payload": payload}); err != nil {
+		return "", err
+	}
+	if buf.String() == "<no value>" {
+		return "", nil
+	}
+	return buf.String(), nil
+}
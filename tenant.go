@@ -0,0 +1,41 @@
+package header2post
+
+import (
+	"context"
+	"fmt"
+)
+
+type tenantKey struct{}
+
+// withTenant returns ctx carrying the tenant of a notify request, read by
+// the request hooks selecting per tenant credentials.
+func withTenant(ctx context.Context, tenant string) context.Context {
+	if tenant == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantOf returns the tenant carried by ctx, or "".
+func tenantOf(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// validateTenants checks that per tenant credentials come with the header
+// selecting them.
+func validateTenants(config *Config) error {
+	if config.TenantHeader != "" {
+		return nil
+	}
+	for option, m := range map[string]map[string]string{
+		"tenantsigningkeys":    config.TenantSigningKeys,
+		"tenantwebhooksecrets": config.TenantWebhookSecrets,
+		"tenantapikeys":        config.TenantApiKeys,
+	} {
+		if len(m) > 0 {
+			return fmt.Errorf("%s requires tenantheader", option)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package header2post
+
+import (
+	"context"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestTenantKeys(t *testing.T) {
+	captureLog(t)
+	acmePublic, acmeKey, _ := ed25519.GenerateKey(cryptorand.Reader)
+	defaultPublic, defaultKey, _ := ed25519.GenerateKey(cryptorand.Reader)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:      "X-Notify",
+		NotifyUrl:         "https://example.com/notification",
+		SigningKey:        testPEM(t, defaultKey),
+		SigningKeyId:      "default",
+		TenantHeader:      "x-tenant",
+		TenantSigningKeys: map[string]string{"acme": testPEM(t, acmeKey)},
+		TenantApiKeys:     map[string]string{"acme": "acme-key"},
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got http.Header
+	var body []byte
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		got = req.Header
+		body, _ = io.ReadAll(req.Body)
+		return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+	})
+
+	tests := []struct {
+		name         string
+		tenant       string
+		expectPublic ed25519.PublicKey
+		expectKeyId  string
+		expectApiKey string
+	}{
+		{name: "tenant", tenant: "acme", expectPublic: acmePublic, expectApiKey: "acme-key"},
+		{name: "other tenant", tenant: "globex", expectPublic: defaultPublic, expectKeyId: "default"},
+		{name: "no tenant", expectPublic: defaultPublic, expectKeyId: "default"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.tenant != "" {
+				req.Header.Set("X-Tenant", tt.tenant)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+			if err := VerifySignature(got, body, tt.expectPublic, 0); err != nil {
+				t.Errorf("signature: %v", err)
+			}
+			if got.Get("X-Notify-Key-Id") != tt.expectKeyId {
+				t.Errorf("expected key id %q, got %q", tt.expectKeyId, got.Get("X-Notify-Key-Id"))
+			}
+			if got.Get("X-Api-Key") != tt.expectApiKey {
+				t.Errorf("expected api key %q, got %q", tt.expectApiKey, got.Get("X-Api-Key"))
+			}
+		})
+	}
+}
+
+func TestTenantWebhookSecrets(t *testing.T) {
+	secret := []byte("tenant-secret")
+	s, err := newWebhookSigner(&Config{
+		Format:               formatStandardWebhooks,
+		TenantWebhookSecrets: map[string]string{"acme": webhookSecretPrefix + base64.StdEncoding.EncodeToString(secret)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tenant := range []string{"acme", "globex"} {
+		req, _ := http.NewRequestWithContext(withTenant(context.Background(), tenant), http.MethodPost, "https://example.com", nil)
+		req.Header.Set(webhookIdHeader, "msg_1")
+		if err := s.sign(req.Context(), req); err != nil {
+			t.Fatal(err)
+		}
+		signature := req.Header.Get(webhookSignatureHeader)
+		if tenant == "acme" && signature != "v1,"+webhookSignature(secret, "msg_1", req.Header.Get(webhookTimestampHeader), nil) {
+			t.Errorf("unexpected signature %q", signature)
+		}
+		if tenant == "globex" && signature != "" {
+			t.Errorf("expected no signature without a secret, got %q", signature)
+		}
+	}
+}
+
+func TestTenantErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "header", config: Config{TenantApiKeys: map[string]string{"acme": "k"}}, expectErr: "tenantapikeys requires tenantheader"},
+		{name: "signing key", config: Config{TenantHeader: "X-Tenant", TenantSigningKeys: map[string]string{"acme": "nope"}}, expectErr: `invalid tenantsigningkeys "acme": `},
+		{name: "webhook format", config: Config{TenantHeader: "X-Tenant", TenantWebhookSecrets: map[string]string{"acme": "c2VjcmV0"}}, expectErr: `tenantwebhooksecrets requires format "standard-webhooks"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.NotifyHeader, tt.config.NotifyUrl = "X-Notify", "https://example.com/notification"
+			_, err := New(context.Background(), http.NotFoundHandler(), &tt.config, "header2post")
+			if err == nil || !strings.HasPrefix(err.Error(), tt.expectErr) {
+				t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package header2post
+
+import (
+	"context"
+	"time"
+)
+
+// sendWithin runs send detached from the client request and waits for it
+// until deadline. It reports whether send finished in time; otherwise the
+// delivery carries on in the background, drained on shutdown.
+func (a *notify) sendWithin(deadline time.Time, send func(context.Context)) bool {
+	done := make(chan struct{})
+	a.background.Add(1)
+	go func() {
+		defer a.background.Done()
+		defer close(done)
+		send(a.detached)
+	}()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,62 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeHTTPRequestTimeout(t *testing.T) {
+	captureLog(t)
+	var upstreamDeadline bool
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, upstreamDeadline = r.Context().Deadline()
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+		w.Write([]byte("ok"))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:   "X-Notify",
+		NotifyUrl:      "https://example.com/notification",
+		RequestTimeout: "50ms",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	delivered := make(chan struct{})
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		<-release
+		close(delivered)
+		return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected the response within the request timeout, took %s", elapsed)
+	}
+	if !upstreamDeadline {
+		t.Error("expected the upstream request to carry the deadline")
+	}
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+	}
+
+	// the delivery carries on in the background
+	close(release)
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not delivered in the background")
+	}
+	handler.(*notify).background.Wait()
+
+	_, err = New(context.Background(), next, &Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com/notification", RequestTimeout: "0s"}, "header2post")
+	if err == nil || err.Error() != `invalid requesttimeout: "0s"` {
+		t.Errorf("expected invalid requesttimeout error, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimingField(t *testing.T) {
+	captureLog(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	tests := []struct {
+		name          string
+		triggerSource string
+		expect        map[string]any
+		expectHeader  string
+	}{
+		{name: "response", expect: map[string]any{"upstream_ms": float64(50), "middleware_ms": float64(50)}, expectHeader: "50ms"},
+		{name: "request", triggerSource: triggerRequest, expect: map[string]any{"middleware_ms": float64(0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := base64.StdEncoding.EncodeToString([]byte(`{"a":1}`))
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				now = now.Add(50 * time.Millisecond)
+				w.Header().Set("X-Notify", payload)
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader:       "X-Notify",
+				NotifyUrl:          "https://example.com/notification",
+				TriggerSource:      tt.triggerSource,
+				TimingField:        "timing",
+				ExposeStatusHeader: true,
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]map[string]any
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				json.Unmarshal(b, &got)
+				return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+			})
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Notify", payload)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			timing := got["timing"]
+			if len(timing) != len(tt.expect) {
+				t.Fatalf("expected timing %v, got %v", tt.expect, timing)
+			}
+			for k, v := range tt.expect {
+				if timing[k] != v {
+					t.Errorf("expected %s %v, got %v", k, v, timing[k])
+				}
+			}
+			if h := rec.Header().Get("X-Notify-Upstream-Latency"); h != tt.expectHeader {
+				t.Errorf("expected upstream latency header %q, got %q", tt.expectHeader, h)
+			}
+		})
+	}
+}
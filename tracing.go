@@ -0,0 +1,265 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultOtlpServiceName = "header2post"
+	otlpFlushInterval      = 5 * time.Second
+	otlpMaxBatch           = 256
+
+	spanKindClient  = 3
+	spanStatusError = 2
+)
+
+// spanContext identifies a span in a W3C trace.
+type spanContext struct {
+	traceID string
+	spanID  string
+	sampled bool
+}
+
+// parseTraceparent reads a W3C traceparent header value.
+func parseTraceparent(value string) (spanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return spanContext{}, false
+	}
+	if !isHex(parts[1]) || !isHex(parts[2]) || parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return spanContext{}, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return spanContext{}, false
+	}
+	return spanContext{traceID: parts[1], spanID: parts[2], sampled: flags&1 == 1}, true
+}
+
+func (c spanContext) traceparent() string {
+	flags := "00"
+	if c.sampled {
+		flags = "01"
+	}
+	return "00-" + c.traceID + "-" + c.spanID + "-" + flags
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// tracer records a client span around every delivery and exports them as
+// OTLP/HTTP JSON.
+type tracer struct {
+	endpoint string
+	service  string
+	client   *http.Client
+	log      *slog.Logger
+
+	mu      sync.Mutex
+	pending []*span
+	timer   *time.Timer
+}
+
+func newTracer(config *Config, logger *slog.Logger) (*tracer, error) {
+	if config.OtlpEndpoint == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(config.OtlpEndpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid otlpendpoint: %q", config.OtlpEndpoint)
+	}
+	t := &tracer{
+		endpoint: config.OtlpEndpoint,
+		service:  config.OtlpServiceName,
+		client:   &http.Client{Timeout: defaultSendTimeout},
+		log:      logger,
+	}
+	if t.service == "" {
+		t.service = defaultOtlpServiceName
+	}
+	return t, nil
+}
+
+// span is one delivery attempt to one target.
+type span struct {
+	ctx    spanContext
+	parent string
+	name   string
+	start  time.Time
+	end    time.Time
+	attrs  map[string]any
+	err    string
+}
+
+// start opens a span for a delivery to target, as a child of parent when
+// it is set. It returns nil when t is nil or parent is not sampled.
+func (t *tracer) start(parent *spanContext, target string, size int) *span {
+	if t == nil {
+		return nil
+	}
+	s := &span{
+		ctx:   spanContext{traceID: randomHex(16), spanID: randomHex(8), sampled: true},
+		name:  "notify " + target,
+		start: timeNow(),
+		attrs: map[string]any{"url.full": target, "header2post.payload.size": size},
+	}
+	if parent != nil {
+		if !parent.sampled {
+			return nil
+		}
+		s.ctx.traceID = parent.traceID
+		s.parent = parent.spanID
+	}
+	return s
+}
+
+// inject returns a copy of h carrying the span's traceparent.
+func (s *span) inject(h http.Header) http.Header {
+	out := h.Clone()
+	if out == nil {
+		out = http.Header{}
+	}
+	out.Set("Traceparent", s.ctx.traceparent())
+	return out
+}
+
+// finish closes s with the delivery outcome and queues it for export.
+func (t *tracer) finish(s *span, result deliveryResult) {
+	if s == nil {
+		return
+	}
+	s.end = timeNow()
+	if result.Status != 0 {
+		s.attrs["http.response.status_code"] = result.Status
+	}
+	s.attrs["header2post.retry.count"] = result.Retries
+	if !result.Success {
+		s.err = result.Error
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending = append(t.pending, s)
+	if len(t.pending) == 1 {
+		t.timer = time.AfterFunc(otlpFlushInterval, func() { t.flush() })
+	}
+	if len(t.pending) >= otlpMaxBatch {
+		t.timer.Stop()
+		go t.flush()
+	}
+}
+
+// flush exports the pending spans.
+func (t *tracer) flush() {
+	t.mu.Lock()
+	spans := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(spans) == 0 {
+		return
+	}
+	body, err := json.Marshal(t.export(spans))
+	if err != nil {
+		t.log.Error("marshal spans error", "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultSendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		t.log.Error("export spans error", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent())
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.log.Error("export spans error", "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		t.log.Error("export spans error", "status", resp.StatusCode)
+	}
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+func otlpAttributes(attrs map[string]any) []otlpAttribute {
+	out := make([]otlpAttribute, 0, len(attrs))
+	for k, v := range attrs {
+		var value otlpValue
+		switch v := v.(type) {
+		case int:
+			s := strconv.Itoa(v)
+			value.IntValue = &s
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		out = append(out, otlpAttribute{Key: k, Value: value})
+	}
+	return out
+}
+
+// export builds an OTLP ExportTraceServiceRequest in its JSON encoding.
+func (t *tracer) export(spans []*span) map[string]any {
+	out := make([]map[string]any, 0, len(spans))
+	for _, s := range spans {
+		o := map[string]any{
+			"traceId":           s.ctx.traceID,
+			"spanId":            s.ctx.spanID,
+			"name":              s.name,
+			"kind":              spanKindClient,
+			"startTimeUnixNano": strconv.FormatInt(s.start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(s.end.UnixNano(), 10),
+			"attributes":        otlpAttributes(s.attrs),
+		}
+		if s.parent != "" {
+			o["parentSpanId"] = s.parent
+		}
+		if s.err != "" {
+			o["status"] = map[string]any{"code": spanStatusError, "message": s.err}
+		}
+		out = append(out, o)
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": otlpAttributes(map[string]any{"service.name": t.service}),
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "header2post", "version": Version},
+				"spans": out,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,128 @@
+package header2post
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  string
+		expect spanContext
+		ok     bool
+	}{
+		{
+			name:   "sampled",
+			value:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			expect: spanContext{traceID: "4bf92f3577b34da6a3ce929d0e0e4736", spanID: "00f067aa0ba902b7", sampled: true},
+			ok:     true,
+		},
+		{
+			name:   "not sampled",
+			value:  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00",
+			expect: spanContext{traceID: "4bf92f3577b34da6a3ce929d0e0e4736", spanID: "00f067aa0ba902b7"},
+			ok:     true,
+		},
+		{name: "empty"},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "not hex", value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+		{name: "short", value: "00-4bf92f3577b34da6-00f067aa0ba902b7-01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseTraceparent(tt.value)
+			if ok != tt.ok || got != tt.expect {
+				t.Errorf("expected %+v %v, got %+v %v", tt.expect, tt.ok, got, ok)
+			}
+			if ok && got.traceparent() != tt.value {
+				t.Errorf("expected traceparent %q, got %q", tt.value, got.traceparent())
+			}
+		})
+	}
+}
+
+func TestNewTracer(t *testing.T) {
+	if tr, err := newTracer(&Config{}, nil); tr != nil || err != nil {
+		t.Errorf("expected no tracer, got %v %v", tr, err)
+	}
+	if _, err := newTracer(&Config{OtlpEndpoint: "collector:4318"}, nil); err == nil || err.Error() != `invalid otlpendpoint: "collector:4318"` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestServeHTTPDeliverySpan(t *testing.T) {
+	captureLog(t)
+	exported := make(chan map[string]any, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		exported <- body
+	}))
+	defer collector.Close()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:    "X-Notify",
+		NotifyUrl:       "https://example.com/notification",
+		OtlpEndpoint:    collector.URL + "/v1/traces",
+		OtlpServiceName: "gateway",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var traceparent string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		traceparent = req.Header.Get("Traceparent")
+		return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(bytes.NewBufferString("down"))}, nil
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.(*notify).tracer.flush()
+
+	sent, ok := parseTraceparent(traceparent)
+	if !ok || sent.traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || sent.spanID == "00f067aa0ba902b7" {
+		t.Fatalf("unexpected notify traceparent %q", traceparent)
+	}
+	body := <-exported
+	rs := body["resourceSpans"].([]any)[0].(map[string]any)
+	if service := rs["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)["value"].(map[string]any)["stringValue"]; service != "gateway" {
+		t.Errorf("unexpected service name %v", service)
+	}
+	s := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)[0].(map[string]any)
+	if s["traceId"] != sent.traceID || s["spanId"] != sent.spanID || s["parentSpanId"] != "00f067aa0ba902b7" || s["kind"] != float64(spanKindClient) {
+		t.Errorf("unexpected span %v", s)
+	}
+	attrs := map[string]any{}
+	for _, a := range s["attributes"].([]any) {
+		a := a.(map[string]any)
+		v := a["value"].(map[string]any)
+		if iv, ok := v["intValue"]; ok {
+			attrs[a["key"].(string)] = iv
+		} else {
+			attrs[a["key"].(string)] = v["stringValue"]
+		}
+	}
+	for k, v := range map[string]any{
+		"url.full":                  "https://example.com/notification",
+		"header2post.payload.size":  "8",
+		"http.response.status_code": "502",
+		"header2post.retry.count":   "0",
+	} {
+		if attrs[k] != v {
+			t.Errorf("expected attribute %s %v, got %v", k, v, attrs[k])
+		}
+	}
+	if status := s["status"].(map[string]any); status["code"] != float64(spanStatusError) || status["message"] != "notify failed: down" {
+		t.Errorf("unexpected status %v", status)
+	}
+}
@@ -0,0 +1,25 @@
+package header2post
+
+import "net/http"
+
+// trailerValue returns the payload the upstream sent in the NotifyTrailer
+// trailer of h, either declared in the Trailer header or set with the
+// http.TrailerPrefix once the body was written, and removes it from h
+// unless KeepNotifyHeader is set.
+func (a *notify) trailerValue(h http.Header) (notifyValue, bool) {
+	if a.notifyTrailer == "" {
+		return notifyValue{}, false
+	}
+	value := h.Get(a.notifyTrailer)
+	if value == "" {
+		value = h.Get(http.TrailerPrefix + a.notifyTrailer)
+	}
+	if !a.keepNotifyHeader {
+		h.Del(a.notifyTrailer)
+		h.Del(http.TrailerPrefix + a.notifyTrailer)
+	}
+	if value == "" {
+		return notifyValue{}, false
+	}
+	return notifyValue{value: value}, true
+}
@@ -0,0 +1,73 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHTTPNotifyTrailer(t *testing.T) {
+	captureLog(t)
+	value := base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`))
+	tests := []struct {
+		name   string
+		config Config
+		next   http.HandlerFunc
+	}{
+		{name: "declared", next: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "X-Notify-Trailer")
+			w.Write([]byte("body"))
+			w.Header().Set("X-Notify-Trailer", value)
+		}},
+		{name: "trailer prefix", next: func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("body"))
+			w.Header().Set(http.TrailerPrefix+"X-Notify-Trailer", value)
+		}},
+		{name: "buffered", config: Config{ExposeStatusHeader: true}, next: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "X-Notify-Trailer")
+			w.Write([]byte("body"))
+			w.Header().Set("X-Notify-Trailer", value)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			config.NotifyTrailer = "x-notify-trailer"
+			config.NotifyUrl = "https://example.com/notification"
+			handler, err := New(context.Background(), tt.next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var delivered []string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				body, _ := io.ReadAll(req.Body)
+				delivered = append(delivered, string(body))
+				return &http.Response{StatusCode: http.StatusAccepted, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if len(delivered) != 1 || delivered[0] != `{"id":"e1"}` {
+				t.Errorf("unexpected deliveries %q", delivered)
+			}
+			res := rec.Result()
+			if res.Trailer.Get("X-Notify-Trailer") != "" || rec.Body.String() != "body" {
+				t.Errorf("unexpected response %v %q", res.Trailer, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestNotifyTrailerRequiresResponse(t *testing.T) {
+	_, err := New(context.Background(), http.NotFoundHandler(), &Config{
+		NotifyTrailer: "X-Notify",
+		NotifyUrl:     "https://example.com/notification",
+		TriggerSource: triggerRequest,
+	}, "header2post")
+	if err == nil || err.Error() != "notifytrailer requires triggersource response" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package header2post
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// transform is a compiled Transform expression reshaping the payload.
+type transform struct {
+	eval evalFunc
+}
+
+func newTransform(expr string) (*transform, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	eval, err := compileExpr(expr, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid transform: %w", err)
+	}
+	return &transform{eval: eval}, nil
+}
+
+// apply evaluates the expression against a JSON payload.
+func (t *transform) apply(data []byte) ([]byte, error) {
+	doc, err := decodeExprValue(data)
+	if err != nil {
+		return nil, errors.New("transform requires a json payload")
+	}
+	out, err := t.eval(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(out)
+}
@@ -0,0 +1,119 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	payload := `{"order":{"id":"o1","lines":[{"sku":"a"},{"sku":"b"}]},"price":2.5,"qty":4,"first":"Ada","last":"Lovelace","meta":{"x":1}}`
+	tests := []struct {
+		expr      string
+		expect    string
+		expectErr string
+	}{
+		{expr: ".", expect: `{"first":"Ada","last":"Lovelace","meta":{"x":1},"order":{"id":"o1","lines":[{"sku":"a"},{"sku":"b"}]},"price":2.5,"qty":4}`},
+		{expr: ".order.id", expect: `"o1"`},
+		{expr: `.order.lines[1].sku`, expect: `"b"`},
+		{expr: `.order.lines[-1]`, expect: `{"sku":"b"}`},
+		{expr: `.order | .id`, expect: `"o1"`},
+		{expr: `."price"`, expect: `2.5`},
+		{expr: `.missing.deep`, expect: `null`},
+		{expr: `{id: .order.id, total: .price * .qty, name: .first + " " + .last, qty}`, expect: `{"id":"o1","name":"Ada Lovelace","qty":4,"total":10}`},
+		{expr: `{"order id": .order.id, nested: {sku: .order.lines[0].sku}}`, expect: `{"nested":{"sku":"a"},"order id":"o1"}`},
+		{expr: `[.qty, -.qty, (.qty - 1) / 2, true, null]`, expect: `[4,-4,1.5,true,null]`},
+		{expr: `.meta + {y: 2}`, expect: `{"x":1,"y":2}`},
+		{expr: `.order.lines[0] + .price`, expectErr: "cannot apply + to object and number"},
+		{expr: `.first[0]`, expectErr: "cannot index string with number"},
+		{expr: `.qty / 0`, expectErr: "division by zero"},
+		{expr: `.qty > 3 && .first == "Ada"`, expect: `true`},
+		{expr: `[.price < .qty, .meta == {x: 1}, !.missing]`, expect: `[true,true,true]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			tr, err := newTransform(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := tr.apply([]byte(payload))
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Errorf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.expect {
+				t.Errorf("expected %s, got %s", tt.expect, got)
+			}
+		})
+	}
+}
+
+func TestNewTransformErrors(t *testing.T) {
+	if tr, err := newTransform(" "); tr != nil || err != nil {
+		t.Errorf("expected no transform, got %v %v", tr, err)
+	}
+	for expr, expect := range map[string]string{
+		`{id: .id`:     `invalid transform: expected "}" at end`,
+		`.a.`:          `invalid transform: expected a field name after "."`,
+		`.a ]`:         `invalid transform: unexpected "]"`,
+		`now`:          `invalid transform: unknown identifier "now"`,
+		`"open`:        `invalid transform: unterminated string`,
+		`.a ; .b`:      `invalid transform: unexpected character ';'`,
+		`{: .a}`:       `invalid transform: expected an object key`,
+		`1.2.3`:        `invalid transform: invalid number "1.2.3"`,
+		`(.a | .b`:     `invalid transform: expected ")" at end`,
+		`[.a, .b`:      `invalid transform: expected "]" at end`,
+		`.items[.i`:    `invalid transform: expected "]" at end`,
+		`.price * `:    `invalid transform: unexpected end of expression`,
+		`{id: .id,, }`: `invalid transform: expected an object key`,
+	} {
+		if _, err := newTransform(expr); err == nil || err.Error() != expect {
+			t.Errorf("%s: expected error %q, got %v", expr, expect, err)
+		}
+	}
+}
+
+func TestServeHTTPTransform(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    string
+		expectBody string
+	}{
+		{name: "json", payload: `{"user":{"id":7},"action":"login"}`, expectBody: `{"event":"login","user_id":7}`},
+		{name: "not json", payload: `plain`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureLog(t)
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(tt.payload)))
+			})
+			handler, err := New(context.Background(), next, &Config{
+				NotifyHeader: "X-Notify",
+				NotifyUrl:    "https://example.com/notification",
+				Transform:    "{user_id: .user.id, event: .action}",
+			}, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body string
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				b, _ := io.ReadAll(req.Body)
+				body = string(b)
+				return &http.Response{StatusCode: http.StatusAccepted}, nil
+			})
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+			if body != tt.expectBody {
+				t.Errorf("expected body %q, got %q", tt.expectBody, body)
+			}
+		})
+	}
+}
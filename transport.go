@@ -0,0 +1,210 @@
+package header2post
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultMaxIdleConnsPerHost = 16
+	defaultIdleConnTimeout     = 90 * time.Second
+	defaultDialTimeout         = 5 * time.Second
+	defaultTLSHandshakeTimeout = 5 * time.Second
+)
+
+// newHTTPClient builds the client used for the notify url. Unlike
+// http.DefaultClient it keeps enough idle connections per host for
+// notifications to reuse them under load. Requests go through ProxyUrl
+// when set and otherwise honor HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func newHTTPClient(config *Config) (*http.Client, error) {
+	idleConnTimeout, err := parseDuration("idleconntimeout", config.IdleConnTimeout, defaultIdleConnTimeout)
+	if err != nil {
+		return nil, err
+	}
+	dialTimeout, err := parseDuration("dialtimeout", config.DialTimeout, defaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	tlsHandshakeTimeout, err := parseDuration("tlshandshaketimeout", config.TLSHandshakeTimeout, defaultTLSHandshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if config.MaxIdleConnsPerHost < 0 {
+		return nil, fmt.Errorf("maxidleconnsperhost cannot be negative")
+	}
+	maxIdle := config.MaxIdleConnsPerHost
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdleConnsPerHost
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	dns, err := newDNSCache(config, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if dns != nil {
+		transport.DialContext = dns.dialContext(transport.DialContext)
+	}
+	transport.TLSHandshakeTimeout = tlsHandshakeTimeout
+	transport.MaxIdleConnsPerHost = maxIdle
+	if transport.MaxIdleConns < maxIdle {
+		transport.MaxIdleConns = maxIdle
+	}
+	transport.IdleConnTimeout = idleConnTimeout
+	if config.MaxConnsPerReceiver < 0 {
+		return nil, fmt.Errorf("maxconnsperreceiver cannot be negative")
+	}
+	transport.MaxConnsPerHost = config.MaxConnsPerReceiver
+	transport.DisableKeepAlives = config.DisableKeepAlives
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+	if config.ProxyUrl != "" {
+		proxy, err := url.Parse(config.ProxyUrl)
+		if err != nil || proxy.Host == "" || !validProxyScheme(proxy.Scheme) {
+			return nil, fmt.Errorf("invalid proxyurl: %q", config.ProxyUrl)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	switch config.HttpVersion {
+	case "":
+	case "1.1":
+		// a non-nil TLSNextProto keeps HTTP/2 from being negotiated
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	case "2":
+		if !h2cSupported && strings.HasPrefix(strings.ToLower(config.NotifyUrl), "http:") {
+			return nil, fmt.Errorf("httpversion 2 with a plaintext notifyurl requires go1.24 or later")
+		}
+		forceHTTP2(transport)
+	default:
+		return nil, fmt.Errorf("invalid httpversion: %q", config.HttpVersion)
+	}
+	if socket, _, ok := splitUnixURL(config.NotifyUrl); ok {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		transport.Proxy = nil
+	}
+	if config.ConnWaitTimeout != "" {
+		if config.MaxConnsPerReceiver == 0 {
+			return nil, fmt.Errorf("connwaittimeout requires maxconnsperreceiver")
+		}
+		wait, err := parseDuration("connwaittimeout", config.ConnWaitTimeout, 0)
+		if err != nil {
+			return nil, err
+		}
+		return &http.Client{Transport: &connWaitTransport{base: transport, wait: wait}}, nil
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// splitUnixURL splits a unix socket notify url of the form
+// unix:///var/run/notify.sock:/path into the socket path and the http url
+// requested over it. The http path defaults to "/".
+func splitUnixURL(raw string) (socket, requestURL string, ok bool) {
+	rest, found := strings.CutPrefix(raw, "unix://")
+	if !found {
+		return "", "", false
+	}
+	socket, path, _ := strings.Cut(rest, ":")
+	if path == "" {
+		path = "/"
+	}
+	return socket, "http://unix" + path, true
+}
+
+func validProxyScheme(scheme string) bool {
+	switch scheme {
+	case "http", "https", "socks5", "socks5h":
+		return true
+	}
+	return false
+}
+
+// parseDuration parses an optional positive duration option, returning def
+// when value is empty.
+func parseDuration(name, value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s: %q", name, value)
+	}
+	return d, nil
+}
+
+// newTLSConfig returns the TLS settings for the notify url: an optional
+// client certificate, extra trusted roots and verification. Certificate
+// files take precedence over inline PEM.
+func newTLSConfig(config *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify, //nolint:gosec // opt-in for test environments
+	}
+	cert, err := clientCertificate(config)
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*cert}
+	}
+	if config.RootCAFile != "" || config.RootCAPEM != "" {
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if config.RootCAFile != "" {
+			bundle, err := os.ReadFile(config.RootCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read rootcafile: %w", err)
+			}
+			if !roots.AppendCertsFromPEM(bundle) {
+				return nil, fmt.Errorf("rootcafile contains no certificates")
+			}
+		}
+		if config.RootCAPEM != "" && !roots.AppendCertsFromPEM([]byte(config.RootCAPEM)) {
+			return nil, fmt.Errorf("rootcapem contains no certificates")
+		}
+		tlsConfig.RootCAs = roots
+	}
+	return tlsConfig, nil
+}
+
+// clientCertificate loads the configured client certificate, if any.
+func clientCertificate(config *Config) (*tls.Certificate, error) {
+	if config.ClientCertFile != "" || config.ClientKeyFile != "" {
+		if config.ClientCertFile == "" || config.ClientKeyFile == "" {
+			return nil, fmt.Errorf("clientcertfile and clientkeyfile must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		return &cert, nil
+	}
+	if config.ClientCertPEM == "" && config.ClientKeyPEM == "" {
+		return nil, nil
+	}
+	if config.ClientCertPEM == "" || config.ClientKeyPEM == "" {
+		return nil, fmt.Errorf("clientcertpem and clientkeypem must be set together")
+	}
+	cert, err := tls.X509KeyPair([]byte(config.ClientCertPEM), []byte(config.ClientKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("load client certificate: %w", err)
+	}
+	return &cert, nil
+}
@@ -0,0 +1,373 @@
+package header2post
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            Config
+		expectMaxIdle     int
+		expectIdleTimeout time.Duration
+		expectKeepAlives  bool
+		expectErr         error
+	}{
+		{
+			name:              "defaults",
+			expectMaxIdle:     defaultMaxIdleConnsPerHost,
+			expectIdleTimeout: defaultIdleConnTimeout,
+			expectKeepAlives:  true,
+		},
+		{
+			name:              "tuned",
+			config:            Config{MaxIdleConnsPerHost: 200, IdleConnTimeout: "5m", DisableKeepAlives: true, DialTimeout: "1s"},
+			expectMaxIdle:     200,
+			expectIdleTimeout: 5 * time.Minute,
+		},
+		{
+			name:      "negative idle conns",
+			config:    Config{MaxIdleConnsPerHost: -1},
+			expectErr: errors.New("maxidleconnsperhost cannot be negative"),
+		},
+		{
+			name:      "invalid dial timeout",
+			config:    Config{DialTimeout: "fast"},
+			expectErr: errors.New(`invalid dialtimeout: "fast"`),
+		},
+		{
+			name:      "invalid idle timeout",
+			config:    Config{IdleConnTimeout: "0s"},
+			expectErr: errors.New(`invalid idleconntimeout: "0s"`),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newHTTPClient(&tt.config)
+			if tt.expectErr != nil {
+				if err == nil || err.Error() != tt.expectErr.Error() {
+					t.Fatalf("expected error %v, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			transport := client.Transport.(*http.Transport)
+			if transport.MaxIdleConnsPerHost != tt.expectMaxIdle || transport.MaxIdleConns < tt.expectMaxIdle {
+				t.Errorf("expected %d idle conns per host, got %d (total %d)", tt.expectMaxIdle, transport.MaxIdleConnsPerHost, transport.MaxIdleConns)
+			}
+			if transport.IdleConnTimeout != tt.expectIdleTimeout {
+				t.Errorf("expected idle timeout %v, got %v", tt.expectIdleTimeout, transport.IdleConnTimeout)
+			}
+			if transport.DisableKeepAlives == tt.expectKeepAlives {
+				t.Errorf("expected keep-alives %v", tt.expectKeepAlives)
+			}
+		})
+	}
+}
+
+func TestHTTPSenderReusesConnections(t *testing.T) {
+	var conns int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("queued"))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	client, err := newHTTPClient(&Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &HTTPSender{URL: srv.URL, Client: client}
+	for i := 0; i < 5; i++ {
+		if result := deliverTo(s, Notification{Body: []byte("{}")}); !result.Success {
+			t.Fatalf("delivery failed: %+v", result)
+		}
+	}
+	if n := atomic.LoadInt32(&conns); n != 1 {
+		t.Errorf("expected one connection, got %d", n)
+	}
+}
+
+func TestNewHTTPClientMutualTLS(t *testing.T) {
+	certPEM, keyPEM := testCertificate(t, "notify-client")
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	os.WriteFile(certFile, certPEM, 0o600)
+	os.WriteFile(keyFile, keyPEM, 0o600)
+
+	var peer string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) > 0 {
+			peer = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name       string
+		config     Config
+		expectErr  string
+		expectPeer string
+	}{
+		{name: "files", config: Config{ClientCertFile: certFile, ClientKeyFile: keyFile}, expectPeer: "notify-client"},
+		{name: "inline pem", config: Config{ClientCertPEM: string(certPEM), ClientKeyPEM: string(keyPEM)}, expectPeer: "notify-client"},
+		{name: "no certificate", expectPeer: ""},
+		{name: "key file missing", config: Config{ClientCertFile: certFile}, expectErr: "clientcertfile and clientkeyfile must be set together"},
+		{name: "key pem missing", config: Config{ClientCertPEM: string(certPEM)}, expectErr: "clientcertpem and clientkeypem must be set together"},
+		{name: "mismatched pem", config: Config{ClientCertPEM: string(keyPEM), ClientKeyPEM: string(keyPEM)}, expectErr: "load client certificate: "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer = ""
+			client, err := newHTTPClient(&tt.config)
+			if tt.expectErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.expectErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			roots := x509.NewCertPool()
+			roots.AddCert(srv.Certificate())
+			client.Transport.(*http.Transport).TLSClientConfig.RootCAs = roots
+			result := deliverTo(&HTTPSender{URL: srv.URL, Client: client}, Notification{Body: []byte("{}")})
+			if tt.expectPeer != "" && !result.Success {
+				t.Fatalf("delivery failed: %+v", result)
+			}
+			if peer != tt.expectPeer {
+				t.Errorf("expected client certificate %q, got %q", tt.expectPeer, peer)
+			}
+		})
+	}
+}
+
+// testCertificate returns a self-signed certificate and key in PEM form.
+func testCertificate(t *testing.T, commonName string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{commonName},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestNewHTTPClientRootCAs(t *testing.T) {
+	certPEM, keyPEM := testCertificate(t, "notify.internal")
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, certPEM, 0o600)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		name          string
+		config        Config
+		expectErr     string
+		expectSuccess bool
+	}{
+		{name: "untrusted", expectSuccess: false},
+		{name: "ca file", config: Config{RootCAFile: caFile}, expectSuccess: true},
+		{name: "ca pem", config: Config{RootCAPEM: string(certPEM)}, expectSuccess: true},
+		{name: "insecure skip verify", config: Config{InsecureSkipVerify: true}, expectSuccess: true},
+		{name: "missing ca file", config: Config{RootCAFile: filepath.Join(dir, "missing.pem")}, expectErr: "read rootcafile: "},
+		{name: "ca file without certificates", config: Config{RootCAFile: caFile, RootCAPEM: "junk"}, expectErr: "rootcapem contains no certificates"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := newHTTPClient(&tt.config)
+			if tt.expectErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.expectErr) {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := deliverTo(&HTTPSender{URL: srv.URL, Client: client}, Notification{Body: []byte("{}")})
+			if result.Success != tt.expectSuccess {
+				t.Errorf("expected success %v, got %+v", tt.expectSuccess, result)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer proxy.Close()
+
+	for _, bad := range []string{"ftp://proxy:21", "proxy:3128", "://"} {
+		if _, err := newHTTPClient(&Config{ProxyUrl: bad}); err == nil || err.Error() != `invalid proxyurl: "`+bad+`"` {
+			t.Errorf("%s: expected invalid proxyurl, got %v", bad, err)
+		}
+	}
+
+	client, err := newHTTPClient(&Config{ProxyUrl: proxy.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := deliverTo(&HTTPSender{URL: "http://notify.internal/hook", Client: client}, Notification{Body: []byte("{}")})
+	if !result.Success {
+		t.Fatalf("delivery failed: %+v", result)
+	}
+	if proxied != "http://notify.internal/hook" {
+		t.Errorf("expected request for http://notify.internal/hook through the proxy, got %q", proxied)
+	}
+}
+
+func TestUnixSocketNotifyUrl(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	var path string
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusAccepted)
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	tests := []struct {
+		notifyUrl  string
+		expectPath string
+		expectErr  string
+	}{
+		{notifyUrl: "unix://" + socket + ":/hooks/notify", expectPath: "/hooks/notify"},
+		{notifyUrl: "unix://" + socket, expectPath: "/"},
+		{notifyUrl: "unix://:/hook", expectErr: `invalid notifyurl: "unix://:/hook"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.notifyUrl, func(t *testing.T) {
+			path = ""
+			senders, err := newSenders(&Config{NotifyUrl: tt.notifyUrl}, "header2post")
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := deliverTo(senders[0], Notification{Body: []byte("{}")})
+			if !result.Success || result.Target != tt.notifyUrl {
+				t.Fatalf("unexpected result %+v", result)
+			}
+			if path != tt.expectPath {
+				t.Errorf("expected path %q, got %q", tt.expectPath, path)
+			}
+		})
+	}
+}
+
+func TestNewHTTPClientHTTPVersion(t *testing.T) {
+	var proto string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proto = r.Proto
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	tests := []struct {
+		version     string
+		expectProto string
+		expectErr   string
+	}{
+		{version: "", expectProto: "HTTP/2.0"},
+		{version: "1.1", expectProto: "HTTP/1.1"},
+		{version: "2", expectProto: "HTTP/2.0"},
+		{version: "3", expectErr: `invalid httpversion: "3"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			client, err := newHTTPClient(&Config{HttpVersion: tt.version, InsecureSkipVerify: true})
+			if tt.expectErr != "" {
+				if err == nil || err.Error() != tt.expectErr {
+					t.Fatalf("expected error %q, got %v", tt.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result := deliverTo(&HTTPSender{URL: srv.URL, Client: client}, Notification{Body: []byte("{}")}); !result.Success {
+				t.Fatalf("delivery failed: %+v", result)
+			}
+			if proto != tt.expectProto {
+				t.Errorf("expected %s, got %s", tt.expectProto, proto)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package header2post
+
+import (
+	"errors"
+	"time"
+)
+
+var errNotificationExpired = errors.New("notification expired")
+
+// expiry returns when a notification triggered at created expires, or the
+// zero time without NotificationTTL.
+func (a *notify) expiry(created time.Time) time.Time {
+	if a.notificationTTL == 0 {
+		return time.Time{}
+	}
+	return created.Add(a.notificationTTL)
+}
+
+// expired reports whether n outlived NotificationTTL.
+func (n Notification) expired() bool {
+	return !n.expires.IsZero() && !timeNow().Before(n.expires)
+}
+
+// expire fails the delivery of the expired msg to target.
+func (a *notify) expire(target string, msg Notification) deliveryResult {
+	a.log.Warn("notification expired", "target", target, "expired_at", msg.expires, "event_ids", msg.EventIDs)
+	result := deliveryResult{Target: target, Error: errNotificationExpired.Error()}
+	a.delivered(result)
+	return result
+}
@@ -0,0 +1,95 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotificationTTLRetries(t *testing.T) {
+	log := captureLog(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+	sleep = func(ctx context.Context, d time.Duration) error {
+		now = now.Add(d)
+		return ctx.Err()
+	}
+	defer func() { sleep = sleepContext }()
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+	})
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:    "X-Notify",
+		NotifyUrl:       "https://example.com/notification",
+		MaxRetries:      5,
+		RetryBackoff:    "1s",
+		NotificationTTL: "2500ms",
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := 0
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		attempts++
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// attempts at 0s and 1s, the retry due at 3s is past the ttl
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+	if !strings.Contains(log.String(), `"error":"notification expired"`) {
+		t.Errorf("missing expiry in delivery report: %s", log)
+	}
+}
+
+func TestDeliverExpired(t *testing.T) {
+	captureLog(t)
+	sent := false
+	a := &notify{log: discardLogger()}
+	s := SenderFunc(func(ctx context.Context, n Notification) error {
+		sent = true
+		return nil
+	})
+	result := a.deliver(context.Background(), s, Notification{expires: time.Now().Add(-time.Second)})
+	if sent || result.Success || result.Error != errNotificationExpired.Error() {
+		t.Errorf("expected an expired delivery, got %+v, sent %v", result, sent)
+	}
+}
+
+func TestSpoolReplayExpired(t *testing.T) {
+	captureLog(t)
+	sleep = func(ctx context.Context, _ time.Duration) error { return ctx.Err() }
+	defer func() { sleep = sleepContext }()
+
+	var received []string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		received = append(received, string(b))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	s, err := newSpool(&Config{SpoolFile: filepath.Join(t.TempDir(), "spool.jsonl")}, &HTTPSender{URL: receiver.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.log, s.timeout = discardLogger(), time.Second
+	s.add(Notification{Body: []byte("a"), expires: time.Now().Add(-time.Second)})
+	s.add(Notification{Body: []byte("b"), expires: time.Now().Add(time.Hour)})
+	s.replay(context.Background())
+	waitReplay(t, s)
+
+	if strings.Join(received, " ") != "b" || s.depth() != 0 {
+		t.Errorf("unexpected replay %v, %d pending", received, s.depth())
+	}
+}
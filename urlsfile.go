@@ -0,0 +1,166 @@
+package header2post
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultNotifyUrlsReloadInterval = 10 * time.Second
+
+// urlsFile delivers to the receiver urls listed in NotifyUrlsFile. The
+// file is read again when its modification time changes, checked at most
+// every interval; a file that cannot be read or holds an invalid url
+// leaves the previous list in place.
+type urlsFile struct {
+	mu       sync.Mutex
+	path     string
+	interval time.Duration
+	config   *Config
+	client   HTTPDoer
+	hooks    []requestHook
+	// skip holds the urls already delivered to as NotifyUrl or FanoutUrls.
+	skip    map[string]bool
+	senders []*HTTPSender
+	modTime time.Time
+	checked time.Time
+	log     *slog.Logger
+}
+
+func newUrlsFile(config *Config, client HTTPDoer, hooks []requestHook, skip map[string]bool) (*urlsFile, error) {
+	interval, err := parseDuration("notifyurlsreloadinterval", config.NotifyUrlsReloadInterval, defaultNotifyUrlsReloadInterval)
+	if err != nil {
+		return nil, err
+	}
+	f := &urlsFile{
+		path:     config.NotifyUrlsFile,
+		interval: interval,
+		config:   config,
+		client:   client,
+		hooks:    hooks,
+		skip:     skip,
+		log:      discardLogger(),
+	}
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return nil, fmt.Errorf("read notifyurlsfile: %w", err)
+	}
+	if err := f.load(info.ModTime()); err != nil {
+		return nil, err
+	}
+	f.checked = timeNow()
+	return f, nil
+}
+
+// Target names the file in delivery reports of Send.
+func (f *urlsFile) Target() string {
+	return f.path
+}
+
+// Send delivers n to every listed url in turn. The middleware instead
+// delivers to each url as a separate target, see current.
+func (f *urlsFile) Send(ctx context.Context, n Notification) error {
+	var errs []error
+	for _, s := range f.current() {
+		if err := s.Send(ctx, n); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Target(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// current returns the senders of the listed urls, reading the file again
+// if it changed.
+func (f *urlsFile) current() []*HTTPSender {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := timeNow()
+	if now.Sub(f.checked) < f.interval {
+		return f.senders
+	}
+	f.checked = now
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.log.Error("notifyurlsfile reload error", "error", err)
+		return f.senders
+	}
+	if info.ModTime().Equal(f.modTime) {
+		return f.senders
+	}
+	if err := f.load(info.ModTime()); err != nil {
+		f.log.Error("notifyurlsfile reload error", "error", err)
+		return f.senders
+	}
+	f.log.Info("notifyurlsfile reloaded", "urls", len(f.senders))
+	return f.senders
+}
+
+// load reads the urls modified at modTime: one per line, skipping blank
+// lines and # comments. Senders of urls listed before are kept.
+func (f *urlsFile) load(modTime time.Time) error {
+	b, err := os.ReadFile(f.path)
+	if err != nil {
+		return fmt.Errorf("read notifyurlsfile: %w", err)
+	}
+	previous := make(map[string]*HTTPSender, len(f.senders))
+	for _, s := range f.senders {
+		previous[s.URL] = s
+	}
+	var senders []*HTTPSender
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(b), "\n") {
+		raw := strings.TrimSpace(line)
+		if raw == "" || strings.HasPrefix(raw, "#") || f.skip[raw] || seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid notifyurlsfile url: %q", raw)
+		}
+		s := previous[raw]
+		if s == nil {
+			if s, err = newHTTPSender(f.config, raw, f.client, f.hooks); err != nil {
+				return err
+			}
+		}
+		senders = append(senders, s)
+	}
+	f.senders, f.modTime = senders, modTime
+	return nil
+}
+
+// setClient makes the listed urls, current and future, use d.
+func (f *urlsFile) setClient(d HTTPDoer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.client = d
+	for _, s := range f.senders {
+		s.Client = d
+	}
+}
+
+// expandUrlsFiles replaces the NotifyUrlsFile entry of senders with the
+// urls it currently lists.
+func expandUrlsFiles(senders []Sender) []Sender {
+	for i, s := range senders {
+		f, ok := s.(*urlsFile)
+		if !ok {
+			continue
+		}
+		listed := f.current()
+		out := make([]Sender, 0, len(senders)-1+len(listed))
+		out = append(out, senders[:i]...)
+		for _, s := range listed {
+			out = append(out, s)
+		}
+		return append(out, senders[i+1:]...)
+	}
+	return senders
+}
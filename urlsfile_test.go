@@ -0,0 +1,91 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotifyUrlsFileReload(t *testing.T) {
+	captureLog(t)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	path := filepath.Join(t.TempDir(), "urls")
+	write := func(content string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(path, mod, mod)
+	}
+	write("# receivers\nhttps://example.com/a\n\nhttps://example.com/notification\n", now)
+
+	var mu sync.Mutex
+	var got []string
+	doer := doerFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, req.URL.String())
+		return &http.Response{StatusCode: http.StatusAccepted, Body: http.NoBody}, nil
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"a":1}`)))
+	})
+	handler, err := NewWithOptions(context.Background(), next, &Config{
+		NotifyHeader:   "X-Notify",
+		NotifyUrl:      "https://example.com/notification",
+		NotifyUrlsFile: path,
+	}, "header2post", WithHTTPDoer(doer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	check := func(expect ...string) {
+		t.Helper()
+		got = nil
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		slices.Sort(got)
+		if strings.Join(got, " ") != strings.Join(expect, " ") {
+			t.Errorf("expected deliveries to %q, got %q", expect, got)
+		}
+	}
+	check("https://example.com/a", "https://example.com/notification")
+
+	write("https://example.com/b\n", now.Add(time.Second))
+	check("https://example.com/a", "https://example.com/notification")
+	now = now.Add(defaultNotifyUrlsReloadInterval)
+	check("https://example.com/b", "https://example.com/notification")
+
+	// an invalid list keeps the previous one
+	write("ftp://example.com/c\n", now.Add(2*time.Second))
+	now = now.Add(defaultNotifyUrlsReloadInterval)
+	check("https://example.com/b", "https://example.com/notification")
+}
+
+func TestNewUrlsFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "invalid")
+	os.WriteFile(invalid, []byte("example.com\n"), 0o600)
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{NotifyUrlsFile: filepath.Join(dir, "missing")}, expectErr: "read notifyurlsfile: stat " + filepath.Join(dir, "missing") + ": no such file or directory"},
+		{config: Config{NotifyUrlsFile: invalid}, expectErr: `invalid notifyurlsfile url: "example.com"`},
+		{config: Config{NotifyUrlsFile: invalid, NotifyUrlsReloadInterval: "soon"}, expectErr: `invalid notifyurlsreloadinterval: "soon"`},
+	}
+	for _, tt := range tests {
+		_, err := newUrlsFile(&tt.config, nil, nil, nil)
+		if err == nil || err.Error() != tt.expectErr {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
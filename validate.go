@@ -0,0 +1,118 @@
+package header2post
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// validateConfig checks the options New cannot build anything from
+// without failing later, one request at a time. Every problem found is
+// reported, joined into one error.
+func validateConfig(config *Config) error {
+	var errs []error
+	if len(config.NotifyHeader) == 0 && len(config.AggregateHeaders) == 0 && len(config.NotifyTrailer) == 0 && len(config.ErrorStatusCodes) == 0 &&
+		config.BodyPattern == "" && config.BodyPointer == "" && !config.PanicNotify {
+		errs = append(errs, errors.New("notifyheader cannot be empty"))
+	}
+	if len(config.NotifyUrl) == 0 && len(config.FanoutUrls) == 0 && config.NotifyUrlsFile == "" && (config.Sink == "" || config.Sink == sinkHTTP) {
+		errs = append(errs, errors.New("notifyurl cannot be empty"))
+	}
+	if _, _, srv := splitSRVURL(config.NotifyUrl); config.NotifyUrl != "" && !srv && !strings.HasPrefix(config.NotifyUrl, "unix://") {
+		u, err := url.Parse(config.NotifyUrl)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			errs = append(errs, fmt.Errorf("invalid notifyurl: %q", config.NotifyUrl))
+		}
+	}
+	if config.MaxErrorBodyBytes < 0 {
+		errs = append(errs, errors.New("maxerrorbodybytes cannot be negative"))
+	}
+	if config.SampleRate != nil && (*config.SampleRate < 0 || *config.SampleRate > 1) {
+		errs = append(errs, errors.New("samplerate must be between 0 and 1"))
+	}
+	if config.LowPrioritySampleRate < 0 || config.LowPrioritySampleRate > 1 {
+		errs = append(errs, errors.New("lowprioritysamplerate must be between 0 and 1"))
+	}
+
+	names := func(option string, values ...string) {
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" && !validHeaderName(v) {
+				errs = append(errs, fmt.Errorf("invalid %s header name: %q", option, v))
+			}
+		}
+	}
+	names("notifyheader", config.NotifyHeader)
+	names("notifyheaderaliases", config.NotifyHeaderAliases...)
+	names("notifytrailer", config.NotifyTrailer)
+	names("skipheader", config.SkipHeader)
+	names("priorityheader", config.PriorityHeader)
+	names("correlationidheader", config.CorrelationIdHeader)
+	names("enrichheader", config.EnrichHeader)
+	names("apikeyheader", config.ApiKeyHeader)
+	names("idempotencykeyheader", config.IdempotencyKeyHeader)
+	names("clientipheader", config.ClientIpHeader)
+	names("aggregateheaders", sortedValues(config.AggregateHeaders)...)
+	names("staticnotifyheaders", sortedKeys(config.StaticNotifyHeaders)...)
+	names("forwardheadermap", sortedKeys(config.ForwardHeaderMap)...)
+	names("forwardheadermap", sortedValues(config.ForwardHeaderMap)...)
+	names("requestidheaders", config.RequestIdHeaders...)
+	for _, list := range []struct {
+		option  string
+		entries []string
+	}{
+		{"forwardheaders", config.ForwardHeaders},
+		{"denyforwardheaders", config.DenyForwardHeaders},
+		{"forwardresponseheaders", config.ForwardResponseHeaders},
+		{"stripresponseheaders", config.StripResponseHeaders},
+	} {
+		// patterns are compiled, and checked, by newHeaderSelector
+		for _, e := range list.entries {
+			if !strings.ContainsAny(e, "*?[") {
+				names(list.option, e)
+			}
+		}
+	}
+	seen := map[string]bool{}
+	for _, e := range config.ForwardHeaders {
+		key := strings.ToLower(strings.TrimSpace(e))
+		if key != "" && seen[key] {
+			errs = append(errs, fmt.Errorf("duplicate forwardheaders: %q", e))
+		}
+		seen[key] = true
+	}
+	return errors.Join(errs...)
+}
+
+// validHeaderName reports whether name is an RFC 9110 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sortedValues(m map[string]string) []string {
+	out := make([]string, 0, len(m))
+	for _, v := range m {
+		out = append(out, v)
+	}
+	sort.Strings(out)
+	return out
+}
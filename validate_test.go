@@ -0,0 +1,56 @@
+package header2post
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		expectErr string
+	}{
+		{name: "valid", config: Config{
+			NotifyHeader:   "X-Notify-*",
+			NotifyUrl:      "https://example.com/{event}",
+			ForwardHeaders: []string{"X-Tenant", "X-Trace-*"},
+		}},
+		{name: "unix socket", config: Config{NotifyHeader: "X-Notify", NotifyUrl: "unix:///run/notify.sock:/events"}},
+		{name: "empty", expectErr: "notifyheader cannot be empty\nnotifyurl cannot be empty"},
+		{name: "typo in url", config: Config{NotifyHeader: "X-Notify", NotifyUrl: "htps://example.com"}, expectErr: `invalid notifyurl: "htps://example.com"`},
+		{name: "missing host", config: Config{NotifyHeader: "X-Notify", NotifyUrl: "https:///notify"}, expectErr: `invalid notifyurl: "https:///notify"`},
+		{name: "negative error body", config: Config{NotifyHeader: "X-Notify", NotifyUrl: "https://example.com", MaxErrorBodyBytes: -1}, expectErr: "maxerrorbodybytes cannot be negative"},
+		{name: "header names", config: Config{
+			NotifyHeader:        "X Notify",
+			NotifyUrl:           "https://example.com",
+			SkipHeader:          "X-Skip:",
+			StaticNotifyHeaders: map[string]string{"X-Env": "prod", "X(Env)": "prod"},
+		}, expectErr: "invalid notifyheader header name: \"X Notify\"\n" +
+			"invalid skipheader header name: \"X-Skip:\"\n" +
+			"invalid staticnotifyheaders header name: \"X(Env)\""},
+		{name: "forward headers", config: Config{
+			NotifyHeader:   "X-Notify",
+			NotifyUrl:      "https://example.com",
+			ForwardHeaders: []string{"X-Tenant", "x-tenant", "X Tenant", "X-Trace-*", "x-trace-*"},
+		}, expectErr: "invalid forwardheaders header name: \"X Tenant\"\n" +
+			"duplicate forwardheaders: \"x-tenant\"\n" +
+			"duplicate forwardheaders: \"x-trace-*\""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConfig(&tt.config)
+			if (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+				t.Errorf("expected error %q, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestNewValidatesConfig(t *testing.T) {
+	_, err := New(context.Background(), http.NotFoundHandler(), &Config{NotifyHeader: "X-Notify", NotifyUrl: "://bad", SampleRate: ptr(2.0)}, "header2post")
+	if err == nil || err.Error() != "invalid notifyurl: \"://bad\"\nsamplerate must be between 0 and 1" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package header2post
+
+// Version and Commit identify the plugin build. They can be overridden at
+// build time, e.g.:
+//
+//	go build -ldflags "-X github.com/arwoosa/header2post.Version=v1.2.0 -X github.com/arwoosa/header2post.Commit=abc123"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// BuildInfo describes the running plugin build.
+type BuildInfo struct {
+	Version string `json:"version"`
+	Commit  string `json:"commit,omitempty"`
+}
+
+// GetBuildInfo returns the version and commit of the running plugin.
+func GetBuildInfo() BuildInfo {
+	return BuildInfo{Version: Version, Commit: Commit}
+}
+
+// String formats the build info as "version (commit)".
+func (b BuildInfo) String() string {
+	if b.Commit == "" {
+		return b.Version
+	}
+	return b.Version + " (" + b.Commit + ")"
+}
+
+// userAgent is the default User-Agent sent with every notification.
+func userAgent() string {
+	return "header2post/" + Version
+}
+
+// configUserAgent returns the configured UserAgent, or the default.
+func configUserAgent(config *Config) string {
+	if config.UserAgent != "" {
+		return config.UserAgent
+	}
+	return userAgent()
+}
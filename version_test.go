@@ -0,0 +1,28 @@
+package header2post
+
+import "testing"
+
+func TestBuildInfo(t *testing.T) {
+	defer func(v, c string) { Version, Commit = v, c }(Version, Commit)
+
+	Version, Commit = "v1.0.0", ""
+	if got := GetBuildInfo().String(); got != "v1.0.0" {
+		t.Errorf("expected %q, got %q", "v1.0.0", got)
+	}
+	Commit = "abc123"
+	if got := GetBuildInfo().String(); got != "v1.0.0 (abc123)" {
+		t.Errorf("expected %q, got %q", "v1.0.0 (abc123)", got)
+	}
+	if got := userAgent(); got != "header2post/v1.0.0" {
+		t.Errorf("expected %q, got %q", "header2post/v1.0.0", got)
+	}
+}
+
+func TestConfigUserAgent(t *testing.T) {
+	if got := configUserAgent(&Config{}); got != userAgent() {
+		t.Errorf("expected %q, got %q", userAgent(), got)
+	}
+	if got := configUserAgent(&Config{UserAgent: "gateway-eu/1.2"}); got != "gateway-eu/1.2" {
+		t.Errorf("expected %q, got %q", "gateway-eu/1.2", got)
+	}
+}
@@ -0,0 +1,113 @@
+package header2post
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// formatStandardWebhooks posts the payload as-is with the headers of the
+// Standard Webhooks specification (https://www.standardwebhooks.com).
+const formatStandardWebhooks = "standard-webhooks"
+
+const (
+	webhookIdHeader        = "webhook-id"
+	webhookTimestampHeader = "webhook-timestamp"
+	webhookSignatureHeader = "webhook-signature"
+
+	webhookSecretPrefix = "whsec_"
+)
+
+// webhookHeader gives a message its webhook-id, kept across retries so
+// receivers can deduplicate.
+func webhookHeader() http.Header {
+	h := make(http.Header)
+	h.Set(webhookIdHeader, "msg_"+generateID())
+	return h
+}
+
+// webhookSigner signs every notify request as the Standard Webhooks
+// specification describes, with an HMAC-SHA256 shared secret.
+type webhookSigner struct {
+	secret []byte
+	// tenants holds the secrets of TenantWebhookSecrets.
+	tenants map[string][]byte
+}
+
+// newWebhookSigner returns nil unless the standard-webhooks format is
+// selected. WebhookSecret and the TenantWebhookSecrets are base64, with or
+// without the whsec_ prefix.
+func newWebhookSigner(config *Config) (*webhookSigner, error) {
+	if config.Format != formatStandardWebhooks {
+		if config.WebhookSecret != "" {
+			return nil, fmt.Errorf("webhooksecret requires format %q", formatStandardWebhooks)
+		}
+		if len(config.TenantWebhookSecrets) > 0 {
+			return nil, fmt.Errorf("tenantwebhooksecrets requires format %q", formatStandardWebhooks)
+		}
+		return nil, nil
+	}
+	if config.WebhookSecret == "" && len(config.TenantWebhookSecrets) == 0 {
+		return nil, fmt.Errorf("format %q requires webhooksecret", formatStandardWebhooks)
+	}
+	s := &webhookSigner{}
+	if config.WebhookSecret != "" {
+		secret, ok := decodeWebhookSecret(config.WebhookSecret)
+		if !ok {
+			return nil, fmt.Errorf("invalid webhooksecret: must be base64")
+		}
+		s.secret = secret
+	}
+	for tenant, raw := range config.TenantWebhookSecrets {
+		secret, ok := decodeWebhookSecret(raw)
+		if !ok {
+			return nil, fmt.Errorf("invalid tenantwebhooksecrets %q: must be base64", tenant)
+		}
+		if s.tenants == nil {
+			s.tenants = make(map[string][]byte, len(config.TenantWebhookSecrets))
+		}
+		s.tenants[tenant] = secret
+	}
+	return s, nil
+}
+
+func decodeWebhookSecret(raw string) ([]byte, bool) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(raw, webhookSecretPrefix))
+	return secret, err == nil && len(secret) > 0
+}
+
+// sign sets the timestamp and the v1 signature of the webhook id, the
+// timestamp and the body of req, with the secret of its tenant if it has
+// one. Every attempt is signed afresh.
+func (s *webhookSigner) sign(ctx context.Context, req *http.Request) error {
+	secret := s.secret
+	if tenantSecret, ok := s.tenants[tenantOf(ctx)]; ok {
+		secret = tenantSecret
+	}
+	if secret == nil {
+		return nil
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return fmt.Errorf("sign payload: %w", err)
+	}
+	id := req.Header.Get(webhookIdHeader)
+	timestamp := strconv.FormatInt(timeNow().Unix(), 10)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, "v1,"+webhookSignature(secret, id, timestamp, body))
+	return nil
+}
+
+// webhookSignature is the base64 HMAC-SHA256 of id, timestamp and body
+// joined by dots.
+func webhookSignature(secret []byte, id, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(id + "." + timestamp + "."))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
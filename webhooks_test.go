@@ -0,0 +1,88 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	// the example of the Standard Webhooks reference libraries
+	signer, err := newWebhookSigner(&Config{Format: formatStandardWebhooks, WebhookSecret: "whsec_MfKQ9r8GKYqrTwjUPD8ILPZIo2LaLaSw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := webhookSignature(signer.secret, "msg_p5jXN8AQM9LWM0D4loKWxJek", "1614265330", []byte(`{"test": 2432232314}`))
+	if expect := "g0hM9SsE+OTPJTGt/tmIKtSyZlE3uFJELVlNIOLJ1OE="; got != expect {
+		t.Errorf("expected %s, got %s", expect, got)
+	}
+}
+
+func TestNewWebhookSignerErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		err    string
+	}{
+		{name: "no secret", config: Config{Format: formatStandardWebhooks}, err: `format "standard-webhooks" requires webhooksecret`},
+		{name: "bad secret", config: Config{Format: formatStandardWebhooks, WebhookSecret: "whsec_???"}, err: "invalid webhooksecret: must be base64"},
+		{name: "other format", config: Config{WebhookSecret: "whsec_c2VjcmV0"}, err: `webhooksecret requires format "standard-webhooks"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newWebhookSigner(&tt.config)
+			if err == nil || err.Error() != tt.err {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestServeHTTPStandardWebhooks(t *testing.T) {
+	captureLog(t)
+	timeNow = func() time.Time { return time.Unix(1700000000, 0) }
+	generateID = func() string { return "2b1f0c5e" }
+	t.Cleanup(func() {
+		timeNow = time.Now
+		generateID = newUUID
+	})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Notify", base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`)))
+	})
+	secret := base64.StdEncoding.EncodeToString([]byte("webhook secret"))
+	handler, err := New(context.Background(), next, &Config{
+		NotifyHeader:  "X-Notify",
+		NotifyUrl:     "https://example.com/notification",
+		Format:        formatStandardWebhooks,
+		WebhookSecret: "whsec_" + secret,
+	}, "header2post")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got *http.Request
+	var body string
+	useTransport(handler, func(req *http.Request) (*http.Response, error) {
+		b, _ := io.ReadAll(req.Body)
+		got, body = req, string(b)
+		return &http.Response{StatusCode: http.StatusAccepted, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if body != `{"id":"e1"}` || got.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected the payload as-is, got %s %q", body, got.Header.Get("Content-Type"))
+	}
+	for k, v := range map[string]string{
+		"webhook-id":        "msg_2b1f0c5e",
+		"webhook-timestamp": "1700000000",
+		"webhook-signature": "v1," + webhookSignature([]byte("webhook secret"), "msg_2b1f0c5e", "1700000000", []byte(body)),
+	} {
+		if got.Header.Get(k) != v {
+			t.Errorf("expected header %s %q, got %q", k, v, got.Header.Get(k))
+		}
+	}
+}
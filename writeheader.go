@@ -0,0 +1,68 @@
+package header2post
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// serveOnWriteHeader serves a response trigger with TriggerOnWriteHeader:
+// the notify header is read when the upstream writes the response header
+// and the body always streams. A notification whose outcome changes the
+// response runs before the header is sent; the others run alongside the
+// body and are waited for before returning.
+func (a *notify) serveOnWriteHeader(next http.Handler, rw http.ResponseWriter, req *http.Request, body *capturedBody, phaseID string, received time.Time) {
+	var wg sync.WaitGroup
+	start := timeNow()
+	var respWriter *wrappedResponseWriter
+	respWriter = newResponseWriter(rw, false, 0, func(h http.Header) {
+		values := a.flowValues(respWriter.header)
+		skip := a.skip(respWriter.header)
+		priority := a.priority(respWriter.header)
+		if !a.keepNotifyHeader {
+			a.removeFlowHeaders(h)
+		}
+		a.stripResponse.strip(h)
+		if len(values) == 0 {
+			return
+		}
+		if skip {
+			a.skipFlows(values, &exchange{req: req, clientHeader: h})
+			return
+		}
+		upstream := timeNow().Sub(start)
+		exchanges := make([]*exchange, len(values))
+		for i, v := range values {
+			exchanges[i] = &exchange{req: req, respHeader: respWriter.header, status: respWriter.code, clientHeader: h, body: body, event: v.value.event, phaseID: phaseID, received: received, upstream: upstream, priority: priority}
+		}
+		if !a.bufferResponse {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i, v := range values {
+					v.flow.trigger(v.value, exchanges[i])
+				}
+			}()
+			return
+		}
+		// only the first notification to fail replaces the response
+		for i, v := range values {
+			v.flow.trigger(v.value, exchanges[i])
+			if !respWriter.discard && v.flow.replaceResponse(rw, exchanges[i]) {
+				respWriter.discard = true
+			}
+		}
+	})
+	defer wg.Wait()
+
+	a.serveNext(next, respWriter, req)
+	respWriter.finish()
+	upstream := timeNow().Sub(start)
+
+	// trailers are only known once the body is written
+	if v, ok := a.trailerValue(respWriter.Header()); ok && !a.skip(respWriter.header) {
+		for _, f := range a.flows() {
+			f.trigger(v, &exchange{req: req, respHeader: respWriter.header, status: respWriter.code, clientHeader: respWriter.Header(), body: body, phaseID: phaseID, received: received, upstream: upstream, priority: a.priority(respWriter.header)})
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package header2post
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTriggerOnWriteHeaderConfig(t *testing.T) {
+	tests := []struct {
+		config    Config
+		expectErr string
+	}{
+		{config: Config{TriggerSource: triggerRequest}, expectErr: "triggeronwriteheader requires triggersource response"},
+		{config: Config{EnrichMode: enrichMerge}, expectErr: `enrichmode "merge" cannot be combined with triggeronwriteheader`},
+		{config: Config{EnrichMode: enrichHeader}},
+	}
+	for _, tt := range tests {
+		config := tt.config
+		config.NotifyHeader = "X-Notify"
+		config.NotifyUrl = "https://example.com/notification"
+		config.TriggerOnWriteHeader = true
+		_, err := New(context.Background(), http.NotFoundHandler(), &config, "header2post")
+		if (err == nil && tt.expectErr != "") || (err != nil && err.Error() != tt.expectErr) {
+			t.Errorf("expected error %q, got %v", tt.expectErr, err)
+		}
+	}
+}
+
+func TestServeHTTPTriggerOnWriteHeader(t *testing.T) {
+	captureLog(t)
+	value := base64.StdEncoding.EncodeToString([]byte(`{"id":"e1"}`))
+	tests := []struct {
+		name         string
+		config       Config
+		notifyStatus int
+		expectStatus int
+		expectBody   string
+		expectHeader string
+	}{
+		{name: "streams alongside", notifyStatus: http.StatusAccepted, expectStatus: http.StatusCreated, expectBody: "body"},
+		{name: "expose status", config: Config{ExposeStatusHeader: true}, notifyStatus: http.StatusAccepted, expectStatus: http.StatusCreated, expectBody: "body", expectHeader: resultDelivered},
+		{name: "failclosed", config: Config{FailureMode: failClosed, ExposeStatusHeader: true}, notifyStatus: http.StatusInternalServerError, expectStatus: http.StatusBadGateway, expectBody: defaultFailureBody, expectHeader: resultFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			bodyWritten := make(chan struct{})
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Notify", value)
+				w.Header().Set("Content-Type", "text/plain")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("body"))
+				close(bodyWritten)
+			})
+			config := tt.config
+			config.NotifyHeader = "X-Notify"
+			config.NotifyUrl = "https://example.com/notification"
+			config.TriggerOnWriteHeader = true
+			handler, err := New(context.Background(), next, &config, "header2post")
+			if err != nil {
+				t.Fatal(err)
+			}
+			async := !handler.(*notify).bufferResponse
+			useTransport(handler, func(req *http.Request) (*http.Response, error) {
+				if async {
+					// the body reaches the client while the notification is in flight
+					select {
+					case <-bodyWritten:
+					case <-time.After(5 * time.Second):
+						t.Error("expected the body to be written during delivery")
+					}
+				}
+				return &http.Response{StatusCode: tt.notifyStatus, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("down"))}, nil
+			})
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != tt.expectStatus || rec.Body.String() != tt.expectBody {
+				t.Errorf("unexpected response %d %q", rec.Code, rec.Body.String())
+			}
+			if rec.Header().Get("X-Notify") != "" || rec.Header().Get(notifyResultHeader) != tt.expectHeader {
+				t.Errorf("unexpected headers %v", rec.Header())
+			}
+		})
+	}
+}
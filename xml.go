@@ -0,0 +1,108 @@
+package header2post
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	formatXML = "xml"
+
+	defaultXmlRootElement = "notification"
+	xmlArrayItem          = "item"
+)
+
+// encodeXML converts a JSON payload to an XML document under the root
+// element: object keys become child elements, array elements repeat the
+// element of their key and scalars become text. Payloads that are not
+// JSON are wrapped in a CDATA section.
+func (f *payloadFormat) encodeXML(data []byte) *encodedPayload {
+	buf := &bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	var doc any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		fmt.Fprintf(buf, "<%s><![CDATA[%s]]></%s>", f.xmlRoot, strings.ReplaceAll(string(data), "]]>", "]]]]><![CDATA[>"), f.xmlRoot)
+	} else {
+		writeXMLElement(buf, f.xmlRoot, doc)
+	}
+	return &encodedPayload{body: buf.Bytes(), contentType: "application/xml"}
+}
+
+func writeXMLElement(buf *bytes.Buffer, name string, v any) {
+	if arr, ok := v.([]any); ok {
+		// an array without a key to repeat wraps its elements in items
+		fmt.Fprintf(buf, "<%s>", name)
+		for _, e := range arr {
+			writeXMLValue(buf, xmlArrayItem, e)
+		}
+		fmt.Fprintf(buf, "</%s>", name)
+		return
+	}
+	writeXMLValue(buf, name, v)
+}
+
+func writeXMLValue(buf *bytes.Buffer, name string, v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintf(buf, "<%s>", name)
+		for _, k := range keys {
+			child := xmlName(k)
+			if arr, ok := v[k].([]any); ok {
+				for _, e := range arr {
+					writeXMLElement(buf, child, e)
+				}
+				continue
+			}
+			writeXMLValue(buf, child, v[k])
+		}
+		fmt.Fprintf(buf, "</%s>", name)
+	case []any:
+		writeXMLElement(buf, name, v)
+	case nil:
+		fmt.Fprintf(buf, "<%s/>", name)
+	default:
+		var text string
+		switch v := v.(type) {
+		case string:
+			text = v
+		case bool:
+			text = strconv.FormatBool(v)
+		default:
+			text = fmt.Sprint(v)
+		}
+		fmt.Fprintf(buf, "<%s>", name)
+		xml.EscapeText(buf, []byte(text))
+		fmt.Fprintf(buf, "</%s>", name)
+	}
+}
+
+// xmlName turns a JSON key into a valid XML element name.
+func xmlName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		valid := r == '_' || r == '-' || r == '.' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') || r > 0x7f
+		if i == 0 && (r == '-' || r == '.' || ('0' <= r && r <= '9')) {
+			b.WriteByte('_')
+		}
+		if !valid {
+			r = '_'
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}